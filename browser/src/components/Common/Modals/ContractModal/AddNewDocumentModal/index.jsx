@@ -23,6 +23,11 @@ import type { StageModalPropsType } from '../types'
 
 const path = require('path')
 
+type Props = StageModalPropsType & {
+  // index of the rejected stage doc replaced by the new version
+  supersedes?: number
+}
+
 type State = {
   error: boolean,
   selectedFileName: string,
@@ -36,10 +41,10 @@ type State = {
   withApproval: boolean
 }
 
-class AddNewDocumentModal extends React.Component<StageModalPropsType, State> {
+class AddNewDocumentModal extends React.Component<Props, State> {
   documentDescription: string
   child: Object
-  constructor (props: StageModalPropsType) {
+  constructor (props: Props) {
     super(props)
 
     this.documentDescription = ''
@@ -122,7 +127,7 @@ class AddNewDocumentModal extends React.Component<StageModalPropsType, State> {
   }
 
   createNewDocInputParams = async (fullOfficialDocName: string, expiresAt: Date) => {
-    const { stageIdx } = this.props
+    const { stageIdx, supersedes } = this.props
     const { withApproval, hash } = this.state
     let inputValue = {
       tid: tradeViewStore.id,
@@ -144,7 +149,8 @@ class AddNewDocumentModal extends React.Component<StageModalPropsType, State> {
       hash: hash,
       signedTx: signedTx,
       docID: '',
-      withApproval: withApproval
+      withApproval: withApproval,
+      supersedes: supersedes
     }
   }
 
@@ -202,7 +208,9 @@ class AddNewDocumentModal extends React.Component<StageModalPropsType, State> {
         onCloseModal={this.props.onCloseModal} status={'new_document'}
         spinnerStore={spinnerStore}>
         <div className={'add-new-document'}>
-          <p className={'modal-title'}>Submit New Document</p>
+          <p className={'modal-title'}>
+            {this.props.supersedes != null ? 'Upload New Version' : 'Submit New Document'}
+          </p>
           {RenderDropZone(this.onDropHandler, this.state.selectedFileName)}
           <input type={'text'} placeholder={'Rename proper document name...'}
             size='large' value={this.state.fileName} onChange={this.onFilenameChange} />
@@ -0,0 +1,98 @@
+// @flow
+
+import { Table } from 'antd'
+import React from 'react'
+import { addNotificationHelper, toLocalTime } from '../../../../../lib/helper'
+import type { TradeStageDocType, TradeStageDocVersionType } from '../../../../../model/flowType'
+import tradeViewStore from '../../../../../stores/tradeViewStore'
+import Button from '../../../Button/Button'
+import BaseModal from '../BaseModal'
+import type { StageModalPropsType } from '../types'
+
+type Props = StageModalPropsType & {
+  stageDoc: TradeStageDocType
+}
+
+type State = {
+  versions: Array<TradeStageDocVersionType>
+}
+
+class DocHistoryModal extends React.Component<Props, State> {
+  state = {
+    versions: []
+  }
+
+  async componentDidMount () {
+    const { stageIdx, stageDoc } = this.props
+    try {
+      const versions = await tradeViewStore.fetchStageDocHistory(stageIdx, stageDoc.index)
+      this.setState({ versions })
+    } catch (err) {
+      addNotificationHelper(err, 'error')
+    }
+  }
+
+  renderDocHistory = () => {
+    const columns = [{
+      title: 'Version',
+      dataIndex: 'version',
+      key: 'version'
+    }, {
+      title: 'Title',
+      dataIndex: 'name',
+      key: 'name'
+    }, {
+      title: 'Submitted By',
+      dataIndex: 'submittedBy',
+      key: 'submittedBy'
+    }, {
+      title: 'Status',
+      dataIndex: 'status',
+      key: 'status'
+    }, {
+      title: 'Changes',
+      dataIndex: 'changes',
+      key: 'changes',
+      width: 232
+    }]
+    const items = this.state.versions.map((v) => {
+      const { doc } = v.stageDoc
+      return {
+        key: v.stageDocIdx,
+        version: v.version,
+        name: doc ? doc.name : '',
+        submittedBy: doc
+          ? `${doc.createdBy.firstName} ${doc.createdBy.lastName} ${toLocalTime(doc.createdAt)}`
+          : '',
+        status: v.stageDoc.status,
+        changes: v.changes.map((c) => <p key={c.field}>{`${c.field}: ${c.old} → ${c.new}`}</p>)
+      }
+    })
+    return (
+      <div>
+        <p>Versions of the document "<span className={'highlight-text'}>
+          {this.props.stageDoc.doc.name}</span>"</p>
+        <Table columns={columns} dataSource={items} />
+      </div>
+    )
+  }
+
+  render () {
+    const { onCloseModal, visible } = this.props
+    return (
+      <BaseModal visible={visible} onCloseModal={onCloseModal} status={'history'}>
+        <div className={'confirm-closing-stage'}>
+          <p className={'modal-title'}>Document Version History</p>
+          {
+            this.renderDocHistory()
+          }
+          <div className={'bottom-right-button'}>
+            <Button type={'primary'} text={'Close'} onClick={onCloseModal} />
+          </div>
+        </div>
+      </BaseModal>
+    )
+  }
+}
+
+export default DocHistoryModal
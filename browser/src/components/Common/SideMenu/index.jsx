@@ -16,7 +16,8 @@ const appEntries = [
     ['Profile edit', '/settings/profile'],
     ['Password change', '/settings/password'], // TODO - link update
     ['Email change', '/settings/email'],
-    ['Preferences', '/settings/preferences']
+    ['Preferences', '/settings/preferences'],
+    ['Invitations', '/settings/invitations']
   ]]
 ]
 
@@ -3,14 +3,17 @@
 import React, { useState } from 'react'
 import { observer } from 'mobx-react-lite'
 import type { TradeStageDocType } from '../../../model/flowType'
-import { approveStatus, warningExpireTime, locationMap } from '../../../constants/tradeConst'
+import { approveStatus, warningExpireTime, locationMap, tradeActorMap } from '../../../constants/tradeConst'
 import { Tooltip } from 'antd'
 import { toLocalTime } from '../../../lib/helper'
 import RejectedDocumentModal from '../../Common/Modals/ContractModal/RejectedDocumentModal'
 import ApproveTradeStageDocModal from '../../Common/Modals/ContractModal/ApproveTradeStageDocModal'
 import RejectTradeStageDocModal from '../../Common/Modals/ContractModal/RejectTradeStageDocModal'
+import AddNewDocumentModal from '../../Common/Modals/ContractModal/AddNewDocumentModal'
+import DocHistoryModal from '../../Common/Modals/ContractModal/DocHistoryModal'
 import currentUser from '../../../stores/current-user'
 import appStore from '../../../stores/app-store'
+import tradeViewStore from '../../../stores/tradeViewStore'
 import * as moment from 'moment-timezone'
 import { openDocFile } from '../../../lib/downloader'
 
@@ -23,6 +26,8 @@ export default observer((props: Props) => {
   const [displayApproveStageDocModal, setDisplayApproveStageDocModal] = useState(false)
   const [displayRejectStageDocModal, setDisplayRejectStageDocModal] = useState(false)
   const [displayRejectedDocumentModal, setDisplayRejectedDocumentModal] = useState(false)
+  const [displayNewVersionModal, setDisplayNewVersionModal] = useState(false)
+  const [displayDocHistoryModal, setDisplayDocHistoryModal] = useState(false)
   const { stageDoc, stageIdx } = props
 
   function renderReason () {
@@ -45,6 +50,15 @@ export default observer((props: Props) => {
   }
 
   function renderAction () {
+    if (canUploadNewVersion(stageDoc, stageIdx)) {
+      return (
+        <p className={'approve-action hoverable'}
+          onClick={() => setDisplayNewVersionModal(true)}>
+          <i className='fas fa-upload' />
+          new version
+        </p>
+      )
+    }
     if (stageDoc.status !== approveStatus.pending ||
       currentUser.user.id === stageDoc.doc.createdBy.id ||
       appStore.adminMode) {
@@ -84,6 +98,15 @@ export default observer((props: Props) => {
               Download
           </a>
         </span>
+        {
+          hasVersions(stageDoc) &&
+          <span className={'doc-download'}>
+            <i className='fas fa-history' />
+            <a onClick={() => setDisplayDocHistoryModal(true)}>
+              {`Version ${stageDoc.version || 1} history`}
+            </a>
+          </span>
+        }
       </div>
       <div className={'doc-submitted'}>
         <p>{`${stageDoc.doc.createdBy.firstName} ${stageDoc.doc.createdBy.lastName}`}</p>
@@ -120,10 +143,41 @@ export default observer((props: Props) => {
           stageIdx={stageIdx}
         />
       }
+      {
+        displayNewVersionModal &&
+        <AddNewDocumentModal
+          visible={displayNewVersionModal}
+          onCloseModal={() => setDisplayNewVersionModal(false)}
+          stageIdx={stageIdx}
+          supersedes={stageDoc.index}
+        />
+      }
+      {
+        displayDocHistoryModal &&
+        <DocHistoryModal
+          visible={displayDocHistoryModal}
+          onCloseModal={() => setDisplayDocHistoryModal(false)}
+          stageDoc={stageDoc}
+          stageIdx={stageIdx}
+        />
+      }
     </div>
   )
 })
 
+// the latest version of a rejected doc can be replaced by the party who can submit stage docs
+function canUploadNewVersion (stageDoc: TradeStageDocType, stageIdx: number): boolean {
+  const stage = tradeViewStore.stages[stageIdx]
+  return stageDoc.status === approveStatus.rejected &&
+    stageDoc.supersededBy == null &&
+    !appStore.adminMode &&
+    (tradeViewStore.currentRole === tradeActorMap[stage.owner] || stage.owner === 'n')
+}
+
+function hasVersions (stageDoc: TradeStageDocType): boolean {
+  return stageDoc.supersedes != null || stageDoc.supersededBy != null
+}
+
 function renderDocStatus (stageDoc: TradeStageDocType) {
   switch (stageDoc.status) {
     case approveStatus.pending:
@@ -1,6 +1,6 @@
 // @flow
 
-import React from 'react'
+import React, { useEffect } from 'react'
 import { Spin } from 'antd'
 import _ from '../../polyfills/underscore.js'
 import { observer } from 'mobx-react-lite'
@@ -9,6 +9,7 @@ import EmptyTrades from '../Trade/EmptyTrade'
 import TradeCard from './TradeCard'
 import tradesStore from '../../stores/trade-store'
 import modTradesStore from '../../stores/moderatorStore/modTrades'
+import { queryParam, tradeIDFromEntity } from '../../lib/helper'
 
 type Props = {
   location: Object
@@ -21,6 +22,15 @@ export default observer((props: Props) => {
   const auth = props.location.auth === 'admin'
   const selectTrade = auth ? modTradesStore.setSelectedTab : tradesStore.setSelectedTab
   const detailPath = auth ? '/admin/home' : '/home'
+  // notification links point to the list with the notification entity
+  const entityTradeID = tradeIDFromEntity(queryParam(props.location.search, 'entity'))
+  const entityTradeIdx = trades.findIndex(t => t.id === entityTradeID)
+  useEffect(() => {
+    if (entityTradeIdx >= 0) {
+      selectTrade(entityTradeIdx)
+      history.replace(detailPath)
+    }
+  }, [entityTradeIdx, selectTrade, history, detailPath])
 
   return (
     <div className={'trades-list'}>
@@ -73,7 +73,7 @@ class PasswordLoginForm extends React.Component<Props, State> {
           )}
         </FormItem>
         <div className={'forgot-password'}>
-          <Link to={'/forgot-password'}>Forgot password?</Link>
+          <Link to={'/reset-password'}>Forgot password?</Link>
         </div>
         <div className={'btn-group'}>
           <Button type={'primary'} text={'Login'} onClick={this.onLogin} />
@@ -9,12 +9,13 @@ export default observer(() => {
   const { history } = useReactRouter()
   const isAuth = currentUser.isAuthenticated
   useEffect(() => {
-    localStorage.setItem('auth_token', '')
-    if (isAuth) {
-      window.location.reload()
-    } else {
-      history.push('/login')
-    }
+    currentUser.logout().then(() => {
+      if (isAuth) {
+        window.location.reload()
+      } else {
+        history.push('/login')
+      }
+    })
   }, [isAuth, history])
   return null
 })
@@ -0,0 +1,50 @@
+// @flow
+
+import React, { useEffect, useState } from 'react'
+import { observer } from 'mobx-react-lite'
+import { Spin } from 'antd'
+import Button from '../../Common/Button/Button'
+import { addNotificationHelper } from '../../../lib/helper'
+import usersStore from '../../../stores/user-store'
+import type { OrgInvitationType } from '../../../model/flowType'
+
+// OrgInvitations lists the pending invitations of the user to organizations
+export default observer(() => {
+  const [loading, setLoading] = useState(true)
+  useEffect(() => {
+    usersStore.fetchOrgInvitations()
+      .catch(err => addNotificationHelper(err, 'error'))
+      .then(() => setLoading(false))
+  }, [])
+
+  async function respond (id: string, accept: boolean) {
+    setLoading(true)
+    try {
+      await usersStore.respondOrgInvitation(id, accept)
+    } catch (err) {
+      addNotificationHelper(err, 'error')
+    }
+    setLoading(false)
+  }
+
+  function renderInvitation (inv: OrgInvitationType) {
+    return (
+      <div key={inv.id}>
+        <p className={'profile-label'}>{inv.org ? inv.org.name : inv.id}</p>
+        <p>Role: {inv.role}</p>
+        <Button type={'primary'} text={'Accept'} onClick={() => respond(inv.id, true)} />
+        <Button text={'Reject'} onClick={() => respond(inv.id, false)} />
+      </div>
+    )
+  }
+
+  return (
+    <div className={'profile-page'}>
+      <Spin spinning={loading} size='large' tip={'please wait...'}>
+        {usersStore.orgInvitations.length === 0
+          ? <p>You have no pending invitations.</p>
+          : usersStore.orgInvitations.map(renderInvitation)}
+      </Spin>
+    </div>
+  )
+})
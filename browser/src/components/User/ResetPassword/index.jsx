@@ -0,0 +1,70 @@
+// @flow
+
+import React, { useState } from 'react'
+import { Link } from 'react-router-dom'
+import { Input, Spin } from 'antd'
+import useReactRouter from 'use-react-router'
+import Button from '../../Common/Button/Button'
+import { addNotificationHelper, queryParam } from '../../../lib/helper'
+import usersStore from '../../../stores/user-store'
+
+// ResetPassword requests the password reset email or, with the token from the email,
+// sets a new password
+export default () => {
+  const { location, history } = useReactRouter()
+  const token = queryParam(location.search, 'token')
+  const [value, setValue] = useState('')
+  const [confirm, setConfirm] = useState('')
+  const [loading, setLoading] = useState(false)
+  const [sent, setSent] = useState(false)
+
+  async function onSubmit () {
+    if (token && value !== confirm) {
+      addNotificationHelper('Both new passwords should match!', 'error')
+      return
+    }
+    setLoading(true)
+    try {
+      if (token) {
+        await usersStore.resetPassword(token, value)
+        addNotificationHelper('Password is changed successfully!', 'success')
+        history.push('/login')
+        return
+      }
+      await usersStore.requestPasswordReset(value)
+      setSent(true)
+    } catch (err) {
+      addNotificationHelper(err, 'error')
+    }
+    setLoading(false)
+  }
+
+  return (
+    <div className={'plain-container'}>
+      <div className={'userForm-content'}>
+        <Spin spinning={loading} size='large' tip={'please wait...'}>
+          {sent
+            ? <p>If the email belongs to an account, we have sent you a link to reset the password.</p>
+            : token
+              ? <div>
+                <p className={'profile-label'}>New Password</p>
+                <Input type='password' placeholder={'New Password'} value={value}
+                  onChange={e => setValue(e.target.value)} />
+                <p className={'profile-label'}>Confirm New Password</p>
+                <Input type='password' placeholder={'Confirm Password'} value={confirm}
+                  onChange={e => setConfirm(e.target.value)} />
+                <Button type={'primary'} text={'Reset Password'} onClick={onSubmit} />
+              </div>
+              : <div>
+                <p className={'profile-label'}>Email</p>
+                <Input type='email' placeholder={'Email'} value={value}
+                  onChange={e => setValue(e.target.value)} />
+                <Button type={'primary'} text={'Send Reset Link'} onClick={onSubmit} />
+              </div>
+          }
+          <Link to={'/login'}>Back to login</Link>
+        </Spin>
+      </div>
+    </div>
+  )
+}
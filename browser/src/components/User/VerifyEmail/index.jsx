@@ -0,0 +1,36 @@
+// @flow
+
+import React, { useEffect, useState } from 'react'
+import { Link } from 'react-router-dom'
+import { Spin } from 'antd'
+import useReactRouter from 'use-react-router'
+import { queryParam } from '../../../lib/helper'
+import usersStore from '../../../stores/user-store'
+
+// VerifyEmail confirms the email address with the token from the verification email
+export default () => {
+  const { location } = useReactRouter()
+  const [status, setStatus] = useState('pending')
+  const token = queryParam(location.search, 'token')
+  useEffect(() => {
+    if (!token) {
+      setStatus('error')
+      return
+    }
+    usersStore.verifyEmail(token)
+      .then(() => setStatus('success'))
+      .catch(() => setStatus('error'))
+  }, [token])
+
+  return (
+    <div className={'plain-container'}>
+      <div className={'userForm-content'}>
+        <Spin spinning={status === 'pending'} size='large' tip={'please wait...'}>
+          {status === 'success' && <p>Your email has been verified.</p>}
+          {status === 'error' && <p>The verification link is invalid or expired.</p>}
+          <Link to={'/login'}>Go to login</Link>
+        </Spin>
+      </div>
+    </div>
+  )
+}
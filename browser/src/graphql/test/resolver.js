@@ -96,7 +96,8 @@ export const resolvers = {
       reqTx: '',
       approvedTx: signedTx,
       approvedBy: mkEmptyUser(),
-      rejectReason: ''
+      rejectReason: '',
+      version: 1
     }),
     tradeStageDocReject: (parent: any, { id, signedTx, reason }: TradeStageDocReject) => 0,
     tradeStageCloseReq: (parent: any, { id, signedTx, reason }: TradeStageWithReasonType) => ({
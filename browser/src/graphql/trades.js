@@ -36,6 +36,7 @@ const userFragment = gql`
 const authUserFragment = gql`
   fragment authUser on AuthUser {
     token
+    refreshToken
   }
 `
 
@@ -67,6 +68,9 @@ const stageDocFragment = gql`
     approvedAt
     rejectReason
     expiresAt
+    version
+    supersedes
+    supersededBy
   }
   ${userFragment}
   ${docFragment}
@@ -261,6 +265,24 @@ export const getAdminTradeData = gql`
   ${tradeFragment}
 `
 
+export const getTradeStageDocHistory = gql`
+  query tradeStageDocHistory($tid: ID!, $stageIdx: Uint!, $stageDocIdx: Uint!) {
+    tradeStageDocHistory(tid: $tid, stageIdx: $stageIdx, stageDocIdx: $stageDocIdx) {
+      stageDocIdx
+      version
+      stageDoc {
+        ...tradeStageDoc
+      }
+      changes {
+        field
+        old
+        new
+      }
+    }
+  }
+  ${stageDocFragment}
+`
+
 export const getTemplates = gql`
   query getTemplates {
     tradeTemplates {
@@ -465,6 +487,24 @@ export const userSignup = gql`
   }
 `
 
+export const userEmailVerify = gql`
+  mutation userEmailVerify($token: String!) {
+    userEmailVerify(token: $token)
+  }
+`
+
+export const userPasswordResetRequest = gql`
+  mutation userPasswordResetRequest($email: Email!) {
+    userPasswordResetRequest(email: $email)
+  }
+`
+
+export const userPasswordResetConfirm = gql`
+  mutation userPasswordResetConfirm($token: String!, $newPassword: String!) {
+    userPasswordResetConfirm(token: $token, newPassword: $newPassword)
+  }
+`
+
 export const userLogin = gql`
   mutation userLogin($input: UserLoginInput!){
     userLogin(input: $input){
@@ -474,6 +514,21 @@ export const userLogin = gql`
   ${authUserFragment}
 `
 
+export const userTokenRefresh = gql`
+  mutation userTokenRefresh($refreshToken: String!){
+    userTokenRefresh(refreshToken: $refreshToken){
+      ...authUser
+    }
+  }
+  ${authUserFragment}
+`
+
+export const userLogout = gql`
+  mutation userLogout{
+    userLogout
+  }
+`
+
 export const getStellarInfo = gql`
   query stellarNet{
     stellarNet{
@@ -502,6 +557,32 @@ export const getAllOrganizations = gql`
   ${orgFragment}
 `
 
+export const getOrgInvitations = gql`
+  query orgInvitations{
+    orgInvitations{
+      id
+      org{
+        ...organization
+      }
+      role
+      createdAt
+    }
+  }
+  ${orgFragment}
+`
+
+export const orgInvitationAccept = gql`
+  mutation orgInvitationAccept($id: ID!) {
+    orgInvitationAccept(id: $id)
+  }
+`
+
+export const orgInvitationReject = gql`
+  mutation orgInvitationReject($id: ID!) {
+    orgInvitationReject(id: $id)
+  }
+`
+
 export const changePassword = gql`
   mutation userPasswordChange($input: ChangePasswordInput!){
     userPasswordChange(input: $input)
@@ -19,7 +19,10 @@ import Profile from '../components/User/Profile'
 import PasswordPage from '../components/User/ChangePassword'
 import EmailPage from '../components/User/ChangeEmail'
 import Preferences from '../components/Common/Preferences/index'
+import OrgInvitations from '../components/User/OrgInvitations'
 import Logout from '../components/User/Logout'
+import VerifyEmail from '../components/User/VerifyEmail'
+import ResetPassword from '../components/User/ResetPassword'
 import Landing from '../components/Landing/Landing'
 import MainApp from './MainApp'
 import AdminApp from './AdminApp'
@@ -43,6 +46,8 @@ export default function RouteComponent () {
             <Route path={'/login'} component={Login} />
             <Route path={'/signup'} component={SignUp} />
             <Route path={'/logout'} component={Logout} />
+            <Route path={'/verify-email'} component={VerifyEmail} />
+            <Route path={'/reset-password'} component={ResetPassword} />
             <MainApp path={'/home'} component={Home} />
             <MainApp path={'/trades'} component={TradeList} />
             <MainApp path={'/trade-offer/new'} component={BidOfferAddNew} />
@@ -52,6 +57,7 @@ export default function RouteComponent () {
             <MainApp path={'/settings/password'} component={PasswordPage} />
             <MainApp path={'/settings/email'} component={EmailPage} />
             <MainApp path={'/settings/preferences'} component={Preferences} />
+            <MainApp path={'/settings/invitations'} component={OrgInvitations} />
             <AdminApp path={'/admin/trades'} component={TradeList} />
             <AdminApp path={'/admin/home'} component={Home} />
             <Route path={'*'} render={() => (<div className={'empty-field'}>Page Not Found</div>)} />
@@ -28,6 +28,22 @@ export const mkJWTHeader = (): Object => {
   return header
 }
 
+// storeAuthTokens saves the tokens of the AuthUser response; null clears them
+export const storeAuthTokens = (authUser: ?Object) => {
+  localStorage.setItem('auth_token', (authUser && authUser.token) || '')
+  localStorage.setItem('refresh_token', (authUser && authUser.refreshToken) || '')
+}
+
+// jwtExpiresAt returns the expiry time of the JWT in milliseconds, 0 when it can't be read
+export const jwtExpiresAt = (token: ?string): number => {
+  try {
+    const payload = (token || '').split('.')[1].replace(/-/g, '+').replace(/_/g, '/')
+    return JSON.parse(atob(payload)).exp * 1000
+  } catch (e) {
+    return 0
+  }
+}
+
 export const mkFormDataHeaders = () => {
   let header = {
     Accept: 'multipart/form-data',
@@ -164,3 +180,16 @@ export const findInPairs = (data: Array<any>, key: string) => {
   console.warn(`can't find`, key, `in`, data)
   return ''
 }
+
+// tradeIDFromEntity returns the trade ID of a notification entity,
+// eg: 'trades:123/stages:0/docs:1' -> '123'
+export const tradeIDFromEntity = (entityID: string): ?string => {
+  const m = /^trades:([^/]+)/.exec(entityID || '')
+  return m ? m[1] : null
+}
+
+// queryParam returns the decoded URL query parameter or null
+export const queryParam = (search: string, name: string): ?string => {
+  const m = new RegExp('[?&]' + name + '=([^&]*)').exec(search || '')
+  return m ? decodeURIComponent(m[1].replace(/\+/g, ' ')) : null
+}
@@ -20,6 +20,13 @@ export type OrganizationType = {
   address: string,
 }
 
+export type OrgInvitationType = {
+  id: string,
+  org: ?OrganizationType,
+  role: string,
+  createdAt: string,
+}
+
 export type OrgMapType = {
   org: OrganizationType,
   role: string
@@ -62,7 +69,23 @@ export type TradeStageDocType = {
   rejectReason: string,
   expiresAt: Date,
   reqTx: string,
-  index: number
+  index: number,
+  version: number,
+  supersedes: ?number,
+  supersededBy: ?number
+}
+
+export type DocMetaChangeType = {
+  field: string,
+  old: string,
+  new: string
+}
+
+export type TradeStageDocVersionType = {
+  stageDocIdx: number,
+  version: number,
+  stageDoc: TradeStageDocType,
+  changes: Array<DocMetaChangeType>
 }
 
 export type ApproveReqType = {
@@ -141,7 +164,7 @@ export type TradeOfferType = {
   isSell: Boolean,
   priceType: OfferPriceType,
   currency: Currency,
-  createdBy: UserType,
+  createdBy: ?UserType,
   createdAt: Date,
   expiresAt: Date,
   closedAt: Date,
@@ -218,7 +241,8 @@ export type CreateNewDocType = {
   expiresAt: Date,
   hash: string,
   signedTx: string,
-  withApproval: boolean
+  withApproval: boolean,
+  supersedes?: number
 }
 
 export type CreateTradeStageInputType = {
@@ -1,7 +1,9 @@
 // @flow
 
-import { ApolloClient, ApolloLink, HttpLink, InMemoryCache } from 'apollo-boost'
-import { mkJWTHeader } from '../lib/helper'
+import { ApolloClient, ApolloLink, HttpLink, InMemoryCache, Observable } from 'apollo-boost'
+import { print } from 'graphql'
+import { mkJWTHeader, storeAuthTokens, jwtExpiresAt } from '../lib/helper'
+import { userTokenRefresh } from '../graphql/trades'
 
 var host = process.env.REACT_APP_API_HOST || ''
 if (host === '') {
@@ -35,12 +37,46 @@ export function mkPublicLink (path: string) {
 const httpLink = new HttpLink({
   uri: mkLink('/query') })
 
-const authLink = new ApolloLink((operation, forward) => {
-  operation.setContext({
-    headers: mkJWTHeader()
+// refresh the access token this long before it expires
+const refreshLead = 60 * 1000
+let refreshing: ?Promise<void> = null
+
+/* refreshAuthToken gets a new access token with the refresh token when the current one
+   expires soon. The refresh token is single use, so concurrent requests wait for the
+   same refresh. The tokens are cleared when the session can't be refreshed.
+ */
+function refreshAuthToken (): Promise<void> {
+  const refreshToken = localStorage.getItem('refresh_token')
+  const expiresAt = jwtExpiresAt(localStorage.getItem('auth_token'))
+  if (!refreshToken || expiresAt - refreshLead > Date.now()) {
+    return Promise.resolve()
+  }
+  if (!refreshing) {
+    refreshing = fetch(mkLink('/query'), {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ query: print(userTokenRefresh), variables: { refreshToken } })
+    })
+      .then(resp => resp.json())
+      .then(({ data }) => storeAuthTokens(data && data.userTokenRefresh))
+      .catch(e => console.warn('Can not refresh the session', e))
+      .then(() => { refreshing = null })
+  }
+  return refreshing || Promise.resolve()
+}
+
+const authLink = new ApolloLink((operation, forward) =>
+  new Observable(observer => {
+    let sub = null
+    refreshAuthToken().then(() => {
+      operation.setContext({
+        headers: mkJWTHeader()
+      })
+      sub = forward(operation).subscribe(observer)
+    })
+    return () => sub && sub.unsubscribe()
   })
-  return forward(operation)
-})
+)
 
 export const GqlClient = new ApolloClient({
   link: authLink.concat(httpLink),
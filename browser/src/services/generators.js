@@ -44,7 +44,10 @@ export const mkEmptyDoc = () => {
     closeReqs: [],
     status: 'pending',
     reqTx: '',
-    index: 0
+    index: 0,
+    version: 1,
+    supersedes: null,
+    supersededBy: null
   }
 }
 
@@ -3,6 +3,7 @@
 import { action, observable, runInAction, computed } from 'mobx'
 import {
   userLogin,
+  userLogout,
   getCurrentUser,
   changePassword,
   changeEmail,
@@ -17,6 +18,7 @@ import type {
 import { userRoleMap } from '../constants/tradeConst'
 import { GqlClient } from '../services/cerealia'
 import { mkEmptyUser } from '../services/generators'
+import { storeAuthTokens } from '../lib/helper'
 
 class CurrentUser {
   @observable user: UserType = mkEmptyUser()
@@ -51,12 +53,24 @@ class CurrentUser {
       variables: { 'input': input }
     })
     await runInAction('fetchSuccess', async () => {
-      localStorage.setItem('auth_token', response.data.userLogin.token)
+      storeAuthTokens(response.data.userLogin)
     })
     // Login request doesn't have the session loaded so it can't fetch any non-public data
     await this.authenticate()
   }
 
+  // logout revokes the session on the server and clears the local tokens
+  @action async logout () {
+    if (localStorage.getItem('auth_token')) {
+      try {
+        await this.gqlClient.mutate({ mutation: userLogout })
+      } catch (e) {
+        console.warn('Can not revoke the session', e)
+      }
+    }
+    storeAuthTokens(null)
+  }
+
   async changePassword (input: ChangePasswordType) {
     await this.gqlClient.mutate({
       mutation: changePassword,
@@ -19,7 +19,8 @@ import {
   tradeStageDelReqReject,
   tradeStageDocApprove,
   tradeStageDocReject,
-  tradeStageSetExpireTime
+  tradeStageSetExpireTime,
+  getTradeStageDocHistory
 } from '../graphql/trades'
 import type {
   ApproveReqType,
@@ -28,6 +29,7 @@ import type {
   TradeStageAddReqType,
   TradeStageDocPathType,
   TradeStageDocType,
+  TradeStageDocVersionType,
   TradeStagePathType,
   TradeStageType,
   TradeType,
@@ -95,8 +97,17 @@ class TradeViewStore {
     stageDoc.status = params.withApproval ? 'pending' : 'submitted'
     stageDoc.expiresAt = params.expiresAt
     if (this.stages[params.stageIdx].docs) {
-      stageDoc.index = this.stages[params.stageIdx].docs.length
-      this.stages[params.stageIdx].docs.push(stageDoc)
+      const docs = this.stages[params.stageIdx].docs
+      stageDoc.index = docs.length
+      if (params.supersedes != null) {
+        const prev = docs.find(d => d.index === params.supersedes)
+        if (prev) {
+          prev.supersededBy = stageDoc.index
+          stageDoc.version = (prev.version || 1) + 1
+        }
+        stageDoc.supersedes = params.supersedes
+      }
+      docs.push(stageDoc)
     } else {
       stageDoc.index = 0
       this.stages[params.stageIdx].docs = [stageDoc]
@@ -264,6 +275,15 @@ class TradeViewStore {
     })
   }
 
+  async fetchStageDocHistory (stageIdx: number, stageDocIdx: number): Promise<Array<TradeStageDocVersionType>> {
+    let response = await this.gqlClient.query({
+      query: getTradeStageDocHistory,
+      variables: { tid: this.id, stageIdx, stageDocIdx },
+      fetchPolicy: 'network-only'
+    })
+    return response.data.tradeStageDocHistory
+  }
+
   @action async createStage (input: CreateTradeStageInputType,
     signedTx: string, withApproval: boolean) {
     let response = await this.gqlClient.mutate({
@@ -5,21 +5,29 @@ import {
   getAllUsers,
   userSignup,
   changePassword,
+  userEmailVerify,
+  userPasswordResetRequest,
+  userPasswordResetConfirm,
   createOrganization,
-  getAllOrganizations
+  getAllOrganizations,
+  getOrgInvitations,
+  orgInvitationAccept,
+  orgInvitationReject
 } from '../graphql/trades'
 import type {
   UserType,
   OrgInputType,
   NewUserInputType,
   ChangePasswordType,
-  OrganizationType
+  OrganizationType,
+  OrgInvitationType
 } from '../model/flowType'
 import { GqlClient } from '../services/cerealia'
 
 class UsersStore {
   @observable.ref users: Array<UserType> = []
   @observable organizations: Array<OrganizationType> = []
+  @observable.ref orgInvitations: Array<OrgInvitationType> = []
   gqlClient: Object
   constructor () {
     this.gqlClient = GqlClient
@@ -39,6 +47,21 @@ class UsersStore {
     })
   }
 
+  @action async fetchOrgInvitations () {
+    let response = await this.gqlClient.query({ query: getOrgInvitations, fetchPolicy: 'network-only' })
+    runInAction('fetchSuccess', () => {
+      this.orgInvitations = response.data.orgInvitations
+    })
+  }
+
+  async respondOrgInvitation (id: string, accept: boolean) {
+    await this.gqlClient.mutate({
+      mutation: accept ? orgInvitationAccept : orgInvitationReject,
+      variables: { id }
+    })
+    await this.fetchOrgInvitations()
+  }
+
   async signup (input: NewUserInputType) {
     await this.gqlClient.mutate({
       mutation: userSignup,
@@ -53,6 +76,27 @@ class UsersStore {
     })
   }
 
+  async verifyEmail (token: string) {
+    await this.gqlClient.mutate({
+      mutation: userEmailVerify,
+      variables: { token }
+    })
+  }
+
+  async requestPasswordReset (email: string) {
+    await this.gqlClient.mutate({
+      mutation: userPasswordResetRequest,
+      variables: { email }
+    })
+  }
+
+  async resetPassword (token: string, newPassword: string) {
+    await this.gqlClient.mutate({
+      mutation: userPasswordResetConfirm,
+      variables: { token, newPassword }
+    })
+  }
+
   async createOrganization (input: OrgInputType) {
     let response = await this.gqlClient.mutate({
       mutation: createOrganization,
@@ -3,9 +3,12 @@ package main
 import (
 	"context"
 
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
 	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
 	"bitbucket.org/cerealia/apps/go-lib/setup"
 	"bitbucket.org/cerealia/apps/go-lib/setup/arangodb"
+	"bitbucket.org/cerealia/apps/go-lib/stellar/hdkey"
 	driver "github.com/arangodb/go-driver"
 	"github.com/robert-zaremba/flag"
 	"github.com/robert-zaremba/log15"
@@ -37,6 +40,8 @@ func main() {
 	createCollections(ctx)
 	createIndexes(ctx)
 	createGraphs(ctx)
+	migrateHDWallets(ctx)
+	migrateOrgAdmins(ctx)
 	logger.Info("Database migrated successfully")
 }
 
@@ -64,6 +69,16 @@ var graphs = []GraphDef{
 		To:       dbconst.ColTradeOffers,
 		Title:    dbconst.GraphDocTradeOffer,
 		EdgeColl: dbconst.ColDocTradeOfferEdges,
+	}, {
+		From:     dbconst.ColTradeOfferBids,
+		To:       dbconst.ColTradeOffers,
+		Title:    dbconst.GraphTradeOfferBid,
+		EdgeColl: dbconst.ColTradeOfferBidEdges,
+	}, {
+		From:     dbconst.ColDocs,
+		To:       dbconst.ColDocs,
+		Title:    dbconst.GraphDocSupersedes,
+		EdgeColl: dbconst.ColDocSupersedesEdges,
 	},
 }
 
@@ -111,12 +126,21 @@ func createCollections(ctx context.Context) {
 	collections := []collection{
 		// Edge collections are omitted, they are created in createUsingGraphDef
 		{dbconst.ColUsers, &driver.CreateCollectionOptions{}},
+		{dbconst.ColUserSessions, &defaultOpts},
+		{dbconst.ColUserTokens, &defaultOpts},
+		{dbconst.ColAPIKeys, &defaultOpts},
 		{dbconst.ColOrganizations, &defaultOpts},
+		{dbconst.ColOrgMemberReqs, &defaultOpts},
+		{dbconst.ColOrgAuditLog, &defaultOpts},
+		{dbconst.ColSanctionEntries, &defaultOpts},
+		{dbconst.ColScreeningHits, &defaultOpts},
+		{dbconst.ColLoginAttempts, &defaultOpts},
 		{dbconst.ColTrades, &defaultOpts},
 		{dbconst.ColTradeTemplates, &defaultOpts},
 		{dbconst.ColDocs, &defaultOpts},
 		{dbconst.ColTxEntryLog, &defaultOpts},
 		{dbconst.ColTradeOffers, &defaultOpts},
+		{dbconst.ColTradeOfferBids, &defaultOpts},
 		{dbconst.ColNotifications, &defaultOpts},
 		{dbconst.ColTxSourceAccs, &driver.CreateCollectionOptions{
 			WaitForSync: true,
@@ -148,7 +172,7 @@ func createIndexes(ctx context.Context) {
 		options    *driver.EnsureHashIndexOptions
 	}
 	indexes := []index{
-		index{dbconst.ColTradeTemplates, []string{"name"}, &defaultOptions},
+		index{dbconst.ColTradeTemplates, []string{"familyID", "version"}, &defaultOptions},
 		// we can't use this index, bug in arangodb: https://github.com/arangodb/arangodb/issues/8359
 		// index{dbconst.ColUsers, []string{"emails[*]"}, &defaultOptions},
 		index{dbconst.ColOrganizations, []string{"name"}, &defaultOptions},
@@ -164,4 +188,114 @@ func createIndexes(ctx context.Context) {
 			logger.Fatal("Can't create hash index", err)
 		}
 	}
+	// template versions share the name, the name uniqueness is checked only among the
+	// active templates
+	obsolete := []index{
+		index{dbconst.ColTradeTemplates, []string{"name"}, &defaultOptions},
+	}
+	for _, idx := range obsolete {
+		col, err := db.Collection(ctx, string(idx.collection))
+		if err != nil {
+			logger.Fatal("Can't connect to collection", "name", idx.collection, err)
+		}
+		existing, err := col.Indexes(ctx)
+		if err != nil {
+			logger.Fatal("Can't list indexes", "name", idx.collection, err)
+		}
+		for _, i := range existing {
+			if !sameFields(i.Fields(), idx.fields) {
+				continue
+			}
+			if err = i.Remove(ctx); err != nil {
+				logger.Fatal("Can't remove hash index", err)
+			}
+			logger.Info("Obsolete index removed", "collection", idx.collection, "fields", idx.fields)
+		}
+	}
+}
+
+func sameFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hdWalletMigrationKeys is the number of unused keys derived for a migrated HD wallet
+const hdWalletMigrationKeys = 20
+
+// migrateHDWallets replaces the private extended keys of HD wallets with the public keys
+// derived from them. The server doesn't keep the private keys anymore.
+func migrateHDWallets(ctx context.Context) {
+	q := `FOR u IN users FILTER u.hdCerealiaWallets != null
+	FOR id IN ATTRIBUTES(u.hdCerealiaWallets) LET w = u.hdCerealiaWallets[id]
+	FILTER w.extendedKey != null
+	RETURN {userID: u._key, walletID: id, extendedKey: w.extendedKey,
+		pubKeys: w.pubKeys || [], derivationIndex: w.derivationIndex}`
+	var wallets []struct {
+		UserID          string   `json:"userID"`
+		WalletID        string   `json:"walletID"`
+		ExtendedKey     string   `json:"extendedKey"`
+		PubKeys         []string `json:"pubKeys"`
+		DerivationIndex uint32   `json:"derivationIndex"`
+	}
+	if err := dal.DBQueryMany(ctx, &wallets, q, nil, db); err != nil {
+		logger.Fatal("Can't read HD wallets", err)
+	}
+	for _, w := range wallets {
+		k, err := hdkey.Parse(w.ExtendedKey)
+		if err != nil {
+			logger.Fatal("Malformed HD wallet extended key", "user", w.UserID, "wallet", w.WalletID, err)
+		}
+		pubKeys := w.PubKeys
+		for i := uint32(len(pubKeys)); i < w.DerivationIndex+hdWalletMigrationKeys; i++ {
+			child, err := k.Derive(i)
+			if err != nil {
+				logger.Fatal("Can't derive HD wallet key", "user", w.UserID, "wallet", w.WalletID, err)
+			}
+			kp, err := child.Keypair()
+			if err != nil {
+				logger.Fatal("Can't derive HD wallet key", "user", w.UserID, "wallet", w.WalletID, err)
+			}
+			pubKeys = append(pubKeys, kp.Address())
+		}
+		uq := `UPDATE @key WITH {hdCerealiaWallets: {[@wallet]: {pubKeys: @pubKeys, extendedKey: null}}}
+		IN users OPTIONS {keepNull: false}`
+		vars := map[string]interface{}{"key": w.UserID, "wallet": w.WalletID, "pubKeys": pubKeys}
+		if err := dal.DBQueryMany(ctx, &[]interface{}{}, uq, vars, db); err != nil {
+			logger.Fatal("Can't migrate HD wallet", "user", w.UserID, "wallet", w.WalletID, err)
+		}
+		logger.Info("HD wallet migrated to public keys", "user", w.UserID, "wallet", w.WalletID)
+	}
+}
+
+// migrateOrgAdmins makes the longest registered member the admin of organizations
+// created before the organization roles, which have no admin
+func migrateOrgAdmins(ctx context.Context) {
+	q := `FOR o IN organizations
+	LET members = (FOR u IN users FILTER HAS(u.organizations || {}, o._key) SORT u.createdAt RETURN u)
+	FILTER LENGTH(FOR u IN members FILTER u.organizations[o._key] == "admin" RETURN 1) == 0
+	RETURN {orgID: o._key, userID: FIRST(members)._key}`
+	var orgs []struct {
+		OrgID  string `json:"orgID"`
+		UserID string `json:"userID"`
+	}
+	if err := dal.DBQueryMany(ctx, &orgs, q, nil, db); err != nil {
+		logger.Fatal("Can't read organizations without an admin", err)
+	}
+	for _, o := range orgs {
+		if o.UserID == "" {
+			logger.Warn("Organization has no members to become the admin", "org", o.OrgID)
+			continue
+		}
+		if err := dal.SetOrgMember(ctx, db, o.UserID, o.OrgID, model.OrgRoleAdmin); err != nil {
+			logger.Fatal("Can't set the organization admin", "org", o.OrgID, "user", o.UserID, err)
+		}
+		logger.Info("Organization admin set", "org", o.OrgID, "user", o.UserID)
+	}
 }
@@ -0,0 +1,51 @@
+// Imports a sanctions list and screens all users and organizations against the
+// imported lists. Entries of the list imported before are replaced. The CSV file
+// columns are: entry reference, name and optional aliases separated by `;`.
+// The first line is a header.
+//
+// Usage:
+//
+// ./bin/screening <list name> <csv file>
+package main
+
+import (
+	"context"
+
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/screening"
+	"bitbucket.org/cerealia/apps/go-lib/setup"
+	dbs "bitbucket.org/cerealia/apps/go-lib/setup/arangodb"
+	"github.com/robert-zaremba/flag"
+	"github.com/robert-zaremba/log15"
+)
+
+var logger = log15.Root()
+
+func init() {
+	flag.Parse()
+}
+
+func main() {
+	setup.FlagSimpleInit("screening", "list-name csv-file")
+	list, fname := flag.Arg(0), flag.Arg(1)
+
+	entries, errs := screening.ReadListFile(fname, list)
+	if errs != nil {
+		logger.Fatal("Can't read the sanctions list", "file", fname, errs)
+	}
+	ctx := context.Background()
+	db, errs := dbs.GetDb(ctx)
+	if errs != nil {
+		logger.Fatal("Can't connect to the database", errs)
+	}
+	if errs = dal.ReplaceSanctionList(ctx, db, list, entries); errs != nil {
+		logger.Fatal("Can't import the sanctions list", "list", list, errs)
+	}
+	logger.Info("Sanctions list imported", "list", list, "entries", len(entries))
+
+	n, errs := screening.ScreenAll(ctx, db)
+	if errs != nil {
+		logger.Fatal("Can't screen users and organizations", errs)
+	}
+	logger.Info("Screening completed", "new_hits", n)
+}
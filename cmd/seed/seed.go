@@ -20,8 +20,6 @@ import (
 var fixturesPath setup.PathFlag
 var logger = log15.Root()
 
-const tradeContractName = "trade contract"
-
 var seedCollections = []dbconst.Col{
 	dbconst.ColUsers,
 	dbconst.ColOrganizations,
@@ -33,6 +31,8 @@ var seedCollections = []dbconst.Col{
 	dbconst.ColTxEntryLogEdges,
 	dbconst.ColDocTradeOfferEdges,
 	dbconst.ColTradeOffers,
+	dbconst.ColTradeOfferBids,
+	dbconst.ColTradeOfferBidEdges,
 	dbconst.ColNotifications,
 	dbconst.ColTxSourceAccs,
 }
@@ -101,7 +101,7 @@ func GetSeedData(colName dbconst.Col) (interface{}, errstack.E) {
 		if errs != nil {
 			return *items, errs
 		}
-		return *items, checkTradeContract(items)
+		return *items, checkTradeTemplates(items)
 	case dbconst.ColDocs:
 		var items = new([]model.Doc)
 		return *items, bat.DecodeJSONFile(seedFile, items, logger)
@@ -120,6 +120,12 @@ func GetSeedData(colName dbconst.Col) (interface{}, errstack.E) {
 	case dbconst.ColDocTradeOfferEdges:
 		var items = new([]model.TradeDocOfferEdgeDO)
 		return *items, bat.DecodeJSONFile(seedFile, items, logger)
+	case dbconst.ColTradeOfferBids:
+		var items = new([]model.TradeOfferBid)
+		return *items, bat.DecodeJSONFile(seedFile, items, logger)
+	case dbconst.ColTradeOfferBidEdges:
+		var items = new([]model.TradeOfferBidEdgeDO)
+		return *items, bat.DecodeJSONFile(seedFile, items, logger)
 	case dbconst.ColNotifications:
 		var items = new([]model.Notification)
 		return *items, bat.DecodeJSONFile(seedFile, items, logger)
@@ -131,18 +137,12 @@ func GetSeedData(colName dbconst.Col) (interface{}, errstack.E) {
 	}
 }
 
-func checkTradeContract(t *[]model.TradeTemplate) errstack.E {
-	res := []model.TradeTemplate{}
+func checkTradeTemplates(t *[]model.TradeTemplate) errstack.E {
 	for _, tt := range *t {
-		if len(tt.Stages) == 0 {
-			return errstack.NewDomain("the tradeTemplate " + tt.Name + " doesn't include any stages!")
-		} else if tt.Stages[0].Name != tradeContractName {
-			return errstack.NewDomain("the tradeTemplate " + tt.Name + " doesn't include trade contract as first stage!")
-		} else {
-			res = append(res, tt)
+		if errs := tt.Validate(); errs != nil {
+			return errstack.WrapAsDomainF(errs, "the tradeTemplate %s is invalid", tt.Name)
 		}
 	}
-	*t = res
 	return nil
 }
 
@@ -155,6 +155,11 @@ func checkUserPubKey(t *[]model.User) errstack.E {
 				return errstack.NewInfF("the user %s %s wallet %s has invalid public key: '%s'", user.FirstName, user.LastName, wallet, wallet.PubKey)
 			}
 		}
+		for id, wallet := range user.HDCerealiaWallets {
+			if err := wallet.Validate(); err != nil {
+				return errstack.WrapAsInfF(err, "the user %s %s HD wallet %s is invalid", user.FirstName, user.LastName, id)
+			}
+		}
 		res = append(res, user)
 	}
 	*t = res
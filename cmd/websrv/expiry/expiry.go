@@ -0,0 +1,118 @@
+// Package expiry contains a background worker which expires stage documents and stages
+// past their deadline and alerts the trade participants about the coming deadlines.
+package expiry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
+	"bitbucket.org/cerealia/apps/go-lib/pubsub"
+	"bitbucket.org/cerealia/apps/go-lib/utils"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+	bat "github.com/robert-zaremba/go-bat"
+	"github.com/robert-zaremba/log15"
+)
+
+var logger = log15.Root()
+
+// Run scans the trades every interval until the ctx is done. It is safe to run it on
+// every server instance: each expiry item is updated conditionally, so only one
+// instance applies it and sends its notification.
+func Run(ctx context.Context, db driver.Database, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if errs := Scan(ctx, db, time.Now().UTC()); errs != nil {
+			logger.Error("Trade expiry scan failed", errs)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan expires the trade items past their deadline and sends the expiry alerts
+func Scan(ctx context.Context, db driver.Database, now time.Time) errstack.E {
+	ts, errs := dal.GetTradesExpiringBefore(ctx, db, now.Add(model.ExpiryAlertLeads[0]))
+	if errs != nil {
+		return errs
+	}
+	for i := range ts {
+		t := &ts[i]
+		prev, errs := t.Clone()
+		if errs != nil {
+			return errs
+		}
+		updated := false
+		for _, e := range t.UpdateExpiry(now) {
+			// every server instance scans the trades, only the one which updates the item notifies
+			ok, errs := dal.UpdateTradeExpiry(ctx, db, prev, t, e)
+			if errs != nil {
+				logger.Error("Can't update expired trade", "trade", t.ID, errs)
+				continue
+			}
+			if !ok {
+				logger.Debug("Trade expiry item changed concurrently", "trade", t.ID, "stage", e.StageIdx)
+				continue
+			}
+			updated = true
+			if errs = notify.Deliver(ctx, db, mkExpiryNotif(t, e, now)); errs != nil {
+				logger.Error("Can't deliver expiry notification", "trade", t.ID, errs)
+			}
+		}
+		if updated {
+			publishTrade(ctx, db, t.ID)
+		}
+	}
+	return nil
+}
+
+// publishTrade publishes the stored trade, the scanned one may miss concurrent updates
+func publishTrade(ctx context.Context, db driver.Database, id string) {
+	t, errs := dal.GetTrade(ctx, db, id)
+	if errs != nil {
+		logger.Error("Can't read updated trade", "trade", id, errs)
+		return
+	}
+	pubsub.Default.PublishTrade(t)
+}
+
+func mkExpiryNotif(t *model.Trade, e model.ExpiryEvent, now time.Time) *model.Notification {
+	entityID := bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(e.StageIdx))
+	item := fmt.Sprintf("Stage '%s'", t.Stages[e.StageIdx].Name)
+	if e.DocIdx != nil {
+		entityID = bat.StrJoin("/", entityID, "docs:"+utils.UintToString(*e.DocIdx))
+		item = fmt.Sprintf("Document waiting for approval in stage '%s'", t.Stages[e.StageIdx].Name)
+	}
+	n := model.Notification{
+		CreatedAt: now,
+		Receiver:  t.PartyIDs(),
+		Type:      model.NotifTypeAlert,
+		Dismissed: []string{},
+		EntityID:  entityID,
+		Action:    model.ApprovalPending,
+	}
+	if e.Expired() {
+		n.Action = model.ApprovalExpired
+		n.Msg = fmt.Sprintf("%s of trade '%s' has expired", item, t.Name)
+	} else {
+		n.Msg = fmt.Sprintf("%s of trade '%s' expires in %s", item, t.Name, formatLead(e.Lead))
+	}
+	return &n
+}
+
+func formatLead(d time.Duration) string {
+	if h := int(d / time.Hour); h > 1 {
+		return fmt.Sprintf("%d hours", h)
+	} else if h == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d minutes", int(d/time.Minute))
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"bitbucket.org/cerealia/apps/cmd/websrv/config"
+	"bitbucket.org/cerealia/apps/go-lib/fstore"
+	routing "github.com/go-ozzo/ozzo-routing"
+	"github.com/robert-zaremba/errstack"
+)
+
+const filesPath = "/v1/files"
+
+func mkFileStore() fstore.Store {
+	if *config.F.Storage.Backend == config.StorageS3 {
+		s, errs := fstore.NewS3Store(config.F.Storage.S3.URL)
+		if errs != nil {
+			logger.Fatal("Can't create S3 file store", errs)
+		}
+		return s
+	}
+	return fstore.NewFSStore(config.F.FileStorageDir.String(), *config.F.AppURL+filesPath,
+		[]byte(*config.F.Storage.URLSecret))
+}
+
+// mkDocStore enables the trade document encryption when the master key is configured
+func mkDocStore(s fstore.Store) fstore.Store {
+	key := config.F.Storage.EncryptionKey()
+	if key == nil {
+		logger.Warn("Trade documents are stored without encryption, file-encryption-key is not set")
+		return s
+	}
+	es, errs := fstore.NewEncryptedStore(s, key)
+	if errs != nil {
+		logger.Fatal("Can't create encrypted file store", errs)
+	}
+	return es
+}
+
+// serveSignedFile serves files of the fs file store through the signed URLs
+func serveSignedFile(c *routing.Context) error {
+	s, ok := fstore.Default.(*fstore.FSStore)
+	if !ok {
+		return errstack.NewReq("Signed file URLs are served by the object storage")
+	}
+	key := strings.TrimPrefix(c.Request.URL.Path, filesPath+"/")
+	q := c.Request.URL.Query()
+	if errs := s.Verify(key, q.Get("expires"), q.Get("signature"), time.Now()); errs != nil {
+		return errs
+	}
+	c.Response.Header().Del("Content-Type")
+	return fstore.Serve(c.Request.Context(), c.Response, s, key)
+}
@@ -2,8 +2,9 @@ package main
 
 import (
 	"net/http"
+	"strings"
 
-	"bitbucket.org/cerealia/apps/cmd/websrv/config"
+	"bitbucket.org/cerealia/apps/go-lib/fstore"
 	routing "github.com/go-ozzo/ozzo-routing"
 	"github.com/go-ozzo/ozzo-routing/file"
 )
@@ -12,18 +13,21 @@ const frontendDir = "./browser/build/"
 
 // SetFrontendRoutes serves the app
 func SetFrontendRoutes(r *routing.Router) {
-	var fileStorage = config.F.FileStorageDir.String()
 	r.Get("/", redirectToHome)
 	r.Get("/view/*", file.Content(frontendDir+"index.html"))
-	r.Get("/assets/public/*", file.Server(
-		file.PathMap{"/assets": ""},
-		file.ServerOptions{RootPath: fileStorage}))
+	r.Get("/assets/public/*", servePublicFile)
 	// this must be the last handler
 	r.Get("/*", file.Server(
 		file.PathMap{"": ""},
 		file.ServerOptions{RootPath: frontendDir}))
 }
 
+// servePublicFile serves public files (eg avatars) from the file store
+func servePublicFile(c *routing.Context) error {
+	key := strings.TrimPrefix(c.Request.URL.Path, "/assets/")
+	return fstore.Serve(c.Request.Context(), c.Response, fstore.Default, key)
+}
+
 func redirectToHome(c *routing.Context) error {
 	http.Redirect(c.Response, c.Request, "/view/home", http.StatusMovedPermanently)
 	return nil
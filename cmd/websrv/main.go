@@ -4,22 +4,31 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"bitbucket.org/cerealia/apps/cmd/websrv/config"
+	"bitbucket.org/cerealia/apps/cmd/websrv/expiry"
 	"bitbucket.org/cerealia/apps/cmd/websrv/trades"
 	"bitbucket.org/cerealia/apps/cmd/websrv/users"
+	"bitbucket.org/cerealia/apps/go-lib/auth/throttle"
+	"bitbucket.org/cerealia/apps/go-lib/auth/throttle/throttleimpl"
+	"bitbucket.org/cerealia/apps/go-lib/fstore"
 	"bitbucket.org/cerealia/apps/go-lib/gql"
 	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
 	"bitbucket.org/cerealia/apps/go-lib/resolver"
 	"bitbucket.org/cerealia/apps/go-lib/setup"
 	dbs "bitbucket.org/cerealia/apps/go-lib/setup/arangodb"
 	"bitbucket.org/cerealia/apps/go-lib/stellar"
+	"bitbucket.org/cerealia/apps/go-lib/stellar/secretkey"
 	"bitbucket.org/cerealia/apps/go-lib/stellar/txsource"
 	"bitbucket.org/cerealia/apps/go-lib/stellar/txsource/txsourceimpl"
+	"bitbucket.org/cerealia/apps/go-lib/stellar/webauth"
 	"github.com/99designs/gqlgen/handler"
 	driver "github.com/arangodb/go-driver"
 	routing "github.com/go-ozzo/ozzo-routing"
+	"github.com/gorilla/websocket"
 	"github.com/robert-zaremba/errstack"
 	"github.com/robert-zaremba/log15"
 	"github.com/robert-zaremba/log15/rollbar"
@@ -41,6 +50,12 @@ func main() {
 	if err != nil {
 		logger.Fatal("Can't build stellar.Driver", err)
 	}
+	webauth.Default = mkWebAuth(stellarDriver.Network)
+	notify.Default = notify.NewDispatcher(mkEmailSender(), *config.F.AppURL)
+	fstore.Default = mkFileStore()
+	fstore.Docs = mkDocStore(fstore.Default)
+	throttle.Default = mkLoginThrottler()
+	go expiry.Run(ctx, db, time.Duration(*config.F.ExpiryScanInterval)*time.Second)
 	lockDriver := txsourceimpl.NewDriver(db, time.Duration(*config.F.SCAddrLockDuration)*time.Second)
 	router, err := buildRouter(stellarDriver, lockDriver)
 	if err != nil {
@@ -52,22 +67,69 @@ func main() {
 		http.ListenAndServe(":"+*config.F.Port, nil))
 }
 
+// mkWebAuth configures the key login. The challenges are signed with a random key when
+// web-auth-secret is not set, so they can't be verified by other server instances.
+func mkWebAuth(n stellar.Network) *webauth.Server {
+	u, err := url.Parse(*config.F.AppURL)
+	if err != nil {
+		logger.Fatal("Can't parse app-url", err)
+	}
+	if *config.F.WebAuthSecret == "" {
+		logger.Warn("Key login challenges are signed with a random key, web-auth-secret is not set")
+		return webauth.NewServer(n.Passphrase, webauth.Default.Key, u.Hostname())
+	}
+	key, errs := secretkey.Parse(*config.F.WebAuthSecret)
+	if errs != nil {
+		logger.Fatal("Can't parse web-auth-secret", errs)
+	}
+	return webauth.NewServer(n.Passphrase, key, u.Hostname())
+}
+
+// mkLoginThrottler configures the failed login counters. The memory counters are not
+// shared by the server instances.
+func mkLoginThrottler() *throttle.Throttler {
+	if *config.F.LoginLimiter == config.LimiterMemory {
+		return throttle.NewThrottler(throttle.NewMemLimiter(throttle.Window))
+	}
+	return throttle.NewThrottler(throttleimpl.NewLimiter(db, throttle.Window))
+}
+
+func mkEmailSender() notify.Sender {
+	switch *config.F.Email.Backend {
+	case config.EmailBackendSMTP:
+		return notify.NewSMTPSender(config.F.Email.SMTP.URL, *config.F.Email.From)
+	case config.EmailBackendFile:
+		return notify.NewFileSender(*config.F.Email.File)
+	}
+	return notify.LogSender{}
+}
+
 func buildRouter(stellarDriver *stellar.Driver, txSourceDriver txsource.Driver) (http.Handler, error) {
 	recovery := handler.RecoverFunc(func(ctx context.Context, err interface{}) error {
 		logger.Crit("Unhandled exception", err)
 		return errstack.NewInf("Internal server error")
 	})
 	graphQLLogging := handler.ErrorPresenter(middleware.GraphQLError)
+	wsUpgrader := websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+	if !*config.F.Production {
+		// same as CORS: allow subscriptions from all origins
+		wsUpgrader.CheckOrigin = func(r *http.Request) bool { return true }
+	}
 	gqlconfig := gql.Config{
-		Resolvers: resolver.NewResolver(db, stellarDriver, txSourceDriver),
+		Resolvers:  resolver.NewResolver(db, stellarDriver, txSourceDriver),
+		Directives: gql.DirectiveRoot{HasPermission: middleware.HasPermission},
 	}
 	router, rgroup := middleware.StdRouter(db, *config.F.Production)
 	trades.SetTradeRoutes(rgroup.Group("/v1/trades"), stellarDriver, txSourceDriver)
 	trades.SetTradeOfferRoutes(rgroup.Group("/v1/trade-offers"))
 	users.SetUserRoutes(rgroup.Group("/v1/users"))
+	users.SetOrgRoutes(rgroup.Group("/v1/orgs"))
+	rgroup.Get(filesPath+"/*", serveSignedFile)
 	const gqlEndpoint = "/query"
 	rgroup.Any(gqlEndpoint, routing.HTTPHandlerFunc(
-		handler.GraphQL(gql.NewExecutableSchema(gqlconfig), recovery, graphQLLogging)))
+		handler.GraphQL(gql.NewExecutableSchema(gqlconfig), recovery, graphQLLogging,
+			handler.ResolverMiddleware(middleware.APIKeyScopes),
+			handler.WebsocketUpgrader(wsUpgrader))))
 	rgroup.Get("/graphiql", routing.HTTPHandlerFunc(handler.Playground("GraphQL playground", gqlEndpoint)))
 	SetFrontendRoutes(router)
 	return router, nil
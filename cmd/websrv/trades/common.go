@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"path/filepath"
+	"path"
 	"time"
 
 	"bitbucket.org/cerealia/apps/cmd/websrv/config"
@@ -58,9 +58,7 @@ func storeDocFile(r *http.Request, idx int, destDir string) (model.FileInfo, err
 	}
 	defer errstack.CallAndLog(logger, file.Close)
 
-	// get the query data
-	dir := filepath.Join(config.F.FileStorageDir.String(), destDir)
-	storedName, hash, errs := fstore.SaveDoc(file, fileHeader.Filename, dir)
+	storedName, hash, errs := fstore.SaveDoc(r.Context(), fstore.Docs, file, fileHeader.Filename, destDir)
 	return model.FileInfo{
 		FileName: fileHeader.Filename,
 		Hash:     hash,
@@ -73,10 +71,16 @@ func validateStageOwnership(ctx context.Context, db driver.Database, u *model.Us
 	if err != nil {
 		return nil, nil, err
 	}
+	if err = middleware.AssertAPIKeyTrade(ctx, t); err != nil {
+		return nil, nil, err
+	}
 	if int(stageIdx) >= len(t.Stages) {
 		return nil, nil, errstack.NewReqF("Stage '%d' does not exist", stageIdx)
 	}
 	s := t.Stages[stageIdx]
+	if err = t.AssertCan(u, model.TradeActionDocAdd); err != nil {
+		return nil, nil, err
+	}
 	return t, &s, s.AssertOwnedBy(t, u.ID)
 }
 
@@ -86,9 +90,15 @@ func readAndValidate(ctx context.Context, c *routing.Context, db driver.Database
 		return input, nil, nil, errstack.WrapAsReqF(err, "Can't read document input")
 	}
 	t, s, err := validateStageOwnership(ctx, db, u, input.Tid, input.StageIdx)
+	if err != nil {
+		return input, t, s, err
+	}
 	if now.After(input.ExpiresAtTime) && input.WithApproval {
 		return input, t, s, errstack.NewReq("Approval expired")
 	}
+	if input.SupersedesIdx != nil {
+		err = s.CanBeSuperseded(*input.SupersedesIdx)
+	}
 	return input, t, s, err
 }
 
@@ -107,10 +117,20 @@ func serveDocFile(ctx context.Context, c *routing.Context, db driver.Database, d
 	if err != nil {
 		return err
 	}
+	key := path.Join(tradeDocDir, doc.URL)
+	if expiry := *config.F.Storage.URLExpiry; expiry > 0 {
+		u, errs := fstore.Docs.SignedURL(key, time.Duration(expiry)*time.Second)
+		if errs == nil {
+			http.Redirect(c.Response, c.Request, u, http.StatusFound)
+			return nil
+		}
+		if errs != fstore.ErrNoSignedURL {
+			return errs
+		}
+		// encrypted documents are decrypted by the web server
+	}
 	// Content-Type is set by header negotiation middleware (forced to application/json)
-	// For correct web browser preview http.ServeFile has to choose the header
+	// For correct web browser preview fstore.Serve has to choose the header
 	c.Response.Header().Del("Content-Type")
-	http.ServeFile(c.Response, c.Request,
-		filepath.Join(config.F.FileStorageDir.String(), tradeDocDir, doc.URL))
-	return nil
+	return fstore.Serve(ctx, c.Response, fstore.Docs, key)
 }
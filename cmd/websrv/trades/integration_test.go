@@ -12,6 +12,7 @@ import (
 	"bitbucket.org/cerealia/apps/go-lib/stellar/txvalidation"
 	routing "github.com/go-ozzo/ozzo-routing"
 	. "github.com/robert-zaremba/checkers"
+	"github.com/robert-zaremba/errstack"
 	bat "github.com/robert-zaremba/go-bat"
 	. "gopkg.in/check.v1"
 )
@@ -22,6 +23,11 @@ var sampleDesc = "123456789012345678901234567890"
 
 func (s *TradeIntegrationSuite) TestCloseStageReqApprove(c *C) {
 	mr := s.noopResolver.Mutation()
+	for _, u := range []*testutil.Credentials{s.buyer, s.seller} {
+		disableTOTP, err := testutil.EnableTOTP(s.noopResolver, u)
+		c.Assert(err, IsNil)
+		defer func() { c.Check(disableTOTP(), IsNil) }()
+	}
 	// New doc
 	docHash := "f308fc02ce9172ad02a7d75800ecfc027109bc67987ea32aba9b8dcc7b10150e"
 	docPath := model.TradeStageDocPath{
@@ -127,6 +133,53 @@ func (s *TradeIntegrationSuite) TestDownloadDocAuth(c *C) {
 	c.Assert(err, ErrorContains, "Authentication required")
 }
 
+func (s *TradeIntegrationSuite) TestDocsOtherOrgAPIKey(c *C) {
+	mr := s.noopResolver.Mutation()
+	created, err := mr.APIKeyCreate(s.buyer.Ctx, model.APIKeyInput{
+		OrgID:  "430644",
+		Name:   "ERP",
+		Scopes: []model.APIKeyScope{model.APIKeyScopeReadTrades, model.APIKeyScopeUploadDocs},
+	})
+	c.Assert(err, IsNil)
+	defer func() {
+		_, err := mr.APIKeyRevoke(s.buyer.Ctx, created.APIKey.ID)
+		c.Check(err, IsNil)
+	}()
+	key, errs := testutil.LoginWithAPIKey(s.noopResolver, created.Key)
+	c.Assert(errs, IsNil)
+	// the trade doesn't belong to the organization of the key
+	c.Assert(s.trade.OrgID, IsNil)
+
+	_, err = UploadDoc(key.Ctx, s.noopDocHandler, UploadDocInput{
+		StageIdx:  0,
+		Data:      "test",
+		TradeID:   s.trade.ID,
+		ExpiresAt: s.sampleExpireTimeStr,
+		SignedTX:  "signed-tx",
+		DocHash:   testDocHash,
+	})
+	c.Check(err, Equals, model.ErrUnauthorized)
+	c.Check(statusCode(err), Equals, http.StatusForbidden)
+
+	doc := model.Doc{Name: "other-org.pdf", CreatedBy: s.buyer.ID, CreatedAt: time.Now().UTC()}
+	meta, errs := dal.InsertTradeDoc(key.Ctx, s.db, &doc, model.TradeDocEdge{TradeID: s.trade.ID})
+	c.Assert(errs, IsNil)
+	res := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/trades/stage-docs/"+meta.Key, nil)
+	rctx := routing.NewContext(res, req.WithContext(key.Ctx), s.noopDocHandler.HandleGetDocByID)
+	rctx.SetParam("docID", meta.Key)
+	err = rctx.Next()
+	c.Check(err, Equals, model.ErrUnauthorized)
+	c.Check(statusCode(err), Equals, http.StatusForbidden)
+}
+
+func statusCode(err error) int {
+	if e, ok := err.(errstack.HasStatusCode); ok {
+		return e.StatusCode()
+	}
+	return 0
+}
+
 func (s *TradeIntegrationSuite) TestCloseStageReqReject(c *C) {
 	mr := s.noopResolver.Mutation()
 	docHash := "f308fc02ce9172ad02a7d75800ecfc027109bc67987ea32aba9b8dcc7b10150e"
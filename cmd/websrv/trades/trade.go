@@ -4,6 +4,7 @@ package trades
 import (
 	"time"
 
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
 	"bitbucket.org/cerealia/apps/go-lib/model/txlog"
@@ -28,7 +29,7 @@ func (h DocHandler) HandlePostTradeStageDoc(c *routing.Context) error {
 	now := time.Now()
 	input, t, stage, err := readAndValidate(ctx, c, db, u, now)
 	if err != nil {
-		return errstack.WrapAsReq(err, "Request is not valid")
+		return err
 	}
 	nextStageDocIdx := uint(len(stage.Docs))
 	eBuilder, _, err := validateDocAddTX(input, t, u, nextStageDocIdx, now)
@@ -76,7 +77,10 @@ func (h DocHandler) HandleGetDocByID(c *routing.Context) error {
 	if errs != nil {
 		return errs
 	}
-	if errs = t.CanBeModifiedBy(u); errs != nil {
+	if errs = middleware.AssertAPIKeyTrade(ctx, t); errs != nil {
+		return errs
+	}
+	if errs = t.CanBeReadBy(u); errs != nil {
 		return errs
 	}
 	return serveDocFile(ctx, c, db, docID)
@@ -85,6 +89,8 @@ func (h DocHandler) HandleGetDocByID(c *routing.Context) error {
 // SetTradeRoutes sets trade routes
 func SetTradeRoutes(routerG *routing.RouteGroup, sd *stellar.Driver, txsd txsource.Driver) {
 	h := DocHandler{sd, txsd}
-	routerG.Post("/stage-docs", h.HandlePostTradeStageDoc)
-	routerG.Get("/stage-docs/<docID>", h.HandleGetDocByID)
+	routerG.Post("/stage-docs", middleware.RequireScope(model.APIKeyScopeUploadDocs),
+		middleware.RequirePermission(model.PermissionTradeWrite), h.HandlePostTradeStageDoc)
+	routerG.Get("/stage-docs/<docID>", middleware.RequireScope(model.APIKeyScopeReadTrades),
+		middleware.RequirePermission(model.PermissionTradeRead), h.HandleGetDocByID)
 }
@@ -10,6 +10,8 @@ import (
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
 	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
+	"bitbucket.org/cerealia/apps/go-lib/pubsub"
 	"bitbucket.org/cerealia/apps/go-lib/utils"
 	driver "github.com/arangodb/go-driver"
 	"github.com/robert-zaremba/errstack"
@@ -18,10 +20,11 @@ import (
 
 // UploadModel field names. Have to be the same as in UploadModel
 const (
-	tidField       = "tid"
-	stageIdxField  = "stageIdx"
-	expiresAtField = "expiresAt"
-	signedTxField  = "signedTx"
+	tidField        = "tid"
+	stageIdxField   = "stageIdx"
+	expiresAtField  = "expiresAt"
+	signedTxField   = "signedTx"
+	supersedesField = "supersedes"
 )
 
 // TradeStageDocInput is for document upload data
@@ -37,21 +40,33 @@ type (
 		Hash         string          `form:"hash"`
 		SignedTx     string          `form:"signedTx"`
 		WithApproval bool            `form:"withApproval"`
+		Supersedes   string          `form:"supersedes"` // index of the replaced rejected doc
 	}
 	// TradeStageDocInputP contains parsed and validated fields
 	TradeStageDocInputP struct {
 		TradeStageDocInput
 		ExpiresAtTime time.Time
+		SupersedesIdx *uint
 	}
 )
 
 func (u TradeStageDocInput) parseFields() (*TradeStageDocInputP, errstack.Builder) {
 	errb := u.Validate()
 	t, _ := time.Parse(time.RFC3339, u.ExpiresAt)
-	return &TradeStageDocInputP{
+	p := &TradeStageDocInputP{
 		TradeStageDocInput: u,
 		ExpiresAtTime:      t,
-	}, errb
+	}
+	if u.Supersedes != "" {
+		idx, err := strconv.ParseUint(u.Supersedes, 10, 32)
+		if err != nil {
+			errb.Put(supersedesField, "must be an index of the replaced stage doc")
+		} else {
+			i := uint(idx)
+			p.SupersedesIdx = &i
+		}
+	}
+	return p, errb
 }
 
 // updateDB saves file information in DB
@@ -83,10 +98,41 @@ func (u TradeStageDocInputP) updateDB(ctx context.Context, db driver.Database, t
 		StageIdx:    u.StageIdx,
 		StageDocIdx: uint(len(stage.Docs)),
 	}
+	if u.SupersedesIdx != nil {
+		if errs := stage.CanBeSuperseded(*u.SupersedesIdx); errs != nil {
+			return nil, errs
+		}
+		// claim the replaced doc first, so concurrent uploads can't both supersede it
+		errs := dal.SupersedeTradeStageDoc(ctx, db, t.ID, u.StageIdx, *u.SupersedesIdx, de.StageDocIdx)
+		if errs != nil {
+			return nil, errs
+		}
+	}
+	sd, errs := u.addDoc(ctx, db, t, d, de, stellarTxHash)
+	if errs != nil {
+		if u.SupersedesIdx != nil {
+			errstack.Log(logger, dal.ResetTradeStageDocSupersede(ctx, db, t.ID, u.StageIdx, *u.SupersedesIdx, de.StageDocIdx))
+		}
+		return nil, errs
+	}
+	pubsub.Default.PublishTrade(t)
+	return sd, nil
+}
+
+// addDoc stores the doc and adds it to the trade stage
+func (u TradeStageDocInputP) addDoc(ctx context.Context, db driver.Database, t *model.Trade, d model.Doc,
+	de model.TradeDocEdge, stellarTxHash string) (*model.TradeStageDoc, errstack.E) {
+	stage := &t.Stages[u.StageIdx]
 	meta, errs := dal.InsertTradeDoc(ctx, db, &d, de)
 	if errs != nil {
 		return nil, errs
 	}
+	if u.SupersedesIdx != nil {
+		errs = dal.InsertDocSupersedesEdge(ctx, db, meta.Key, stage.Docs[*u.SupersedesIdx].DocID)
+		if errs != nil {
+			return nil, errs
+		}
+	}
 	docStatus := model.ApprovalSubmitted
 	if u.WithApproval {
 		docStatus = model.ApprovalPending
@@ -97,22 +143,30 @@ func (u TradeStageDocInputP) updateDB(ctx context.Context, db driver.Database, t
 		ExpiresAt: u.ExpiresAtTime,
 		ReqTx:     stellarTxHash,
 	}
-	stage.Docs = append(stage.Docs, sd)
-	_, errs = dal.UpdateTrade(ctx, db, t)
-	if errs != nil {
+	if errs = stage.AddDoc(sd, u.SupersedesIdx); errs != nil {
 		return nil, errs
 	}
-	return &sd, errs
+	sd = stage.Docs[len(stage.Docs)-1]
+	if _, errs = dal.UpdateTrade(ctx, db, t); errs != nil {
+		return nil, errs
+	}
+	return &sd, nil
 }
 
 func tradeStageDocAddNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
 	stageIdx uint, withApproval bool) (*model.Notification, errstack.E) {
-	receiver, _ := t.NotificationReceiver(u)
-	idx := strconv.Itoa(len(t.Stages[stageIdx].Docs) - 1)
+	receiver, _ := t.NotificationReceivers(u)
+	docs := t.Stages[stageIdx].Docs
+	idx := strconv.Itoa(len(docs) - 1)
 	action := model.ApprovalSubmitted
 	if withApproval {
 		action = model.ApprovalPending
 	}
+	msg := fmt.Sprintf("New stage doc has been created by %s %s", u.FirstName, u.LastName)
+	if sd := docs[len(docs)-1]; sd.Supersedes != nil {
+		msg = fmt.Sprintf("Version %d of the rejected stage doc has been uploaded by %s %s",
+			sd.VersionNum(), u.FirstName, u.LastName)
+	}
 	n := model.Notification{
 		CreatedAt:   time.Now().UTC(),
 		Receiver:    receiver,
@@ -120,8 +174,8 @@ func tradeStageDocAddNotif(ctx context.Context, db driver.Database, t *model.Tra
 		Type:        model.NotifTypeAction,
 		Dismissed:   []string{},
 		EntityID:    bat.StrJoin("/", string(dbconst.ColTrades)+":"+t.ID, "stages:"+utils.UintToString(stageIdx), "docs:"+idx),
-		Msg:         fmt.Sprintf("New stage doc has been created by %s %s", u.FirstName, u.LastName),
+		Msg:         msg,
 		Action:      action,
 	}
-	return &n, dal.InsertNotification(ctx, db, &n)
+	return &n, notify.Deliver(ctx, db, &n)
 }
@@ -23,3 +23,28 @@ func (s *TradeModelTest) TestParseUploadModel(c *C) {
 	_, errb := um.parseFields()
 	c.Check(errb.NotNil(), Equals, false)
 }
+
+func (s *TradeModelTest) TestParseSupersedes(c *C) {
+	um := TradeStageDocInput{
+		Tid:        "string",
+		ExpiresAt:  "2006-01-02T15:04:05+07:00",
+		SignedTx:   sampleB64Envelope,
+		Supersedes: "2",
+	}
+	p, errb := um.parseFields()
+	c.Assert(errb.NotNil(), Equals, false)
+	c.Assert(p.SupersedesIdx, NotNil)
+	c.Check(*p.SupersedesIdx, Equals, uint(2))
+
+	um.Supersedes = ""
+	p, errb = um.parseFields()
+	c.Check(errb.NotNil(), Equals, false)
+	c.Check(p.SupersedesIdx, IsNil)
+
+	for _, v := range []string{"-1", "abc"} {
+		um.Supersedes = v
+		p, errb = um.parseFields()
+		c.Check(errb.Get(supersedesField), NotNil, Commentf("supersedes: %s", v))
+		c.Check(p.SupersedesIdx, IsNil)
+	}
+}
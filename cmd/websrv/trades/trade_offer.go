@@ -1,6 +1,8 @@
 package trades
 
 import (
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
 	routing "github.com/go-ozzo/ozzo-routing"
 	"github.com/robert-zaremba/errstack"
@@ -52,6 +54,7 @@ func (o TradeOfferHandler) HandleGetOfferDocByID(c *routing.Context) error {
 // SetTradeOfferRoutes sets trade offer routes
 func SetTradeOfferRoutes(routerG *routing.RouteGroup) {
 	o := TradeOfferHandler{}
-	routerG.Post("", o.HandlePostTradeOffer)
-	routerG.Get("/docs/<docID>", o.HandleGetOfferDocByID)
+	routerG.Use(middleware.RequireScope(model.APIKeyScopeManageOffers))
+	routerG.Post("", middleware.RequirePermission(model.PermissionOfferWrite), o.HandlePostTradeOffer)
+	routerG.Get("/docs/<docID>", middleware.RequirePermission(model.PermissionOfferRead), o.HandleGetOfferDocByID)
 }
@@ -0,0 +1,123 @@
+package users
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"bitbucket.org/cerealia/apps/cmd/websrv/config"
+	"bitbucket.org/cerealia/apps/go-lib/fstore"
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	dbs "bitbucket.org/cerealia/apps/go-lib/setup/arangodb"
+	routing "github.com/go-ozzo/ozzo-routing"
+	"github.com/robert-zaremba/errstack"
+)
+
+const maxKYBDocSize int64 = 5000000 // 5 MB
+const kybDocDir = "kyb-docs"
+
+// OrgHandler is a route object for organizations
+type OrgHandler struct{}
+
+// HandlePostKYBDoc uploads an organization KYB document. The form contains the
+// document `kind` and the `formfile` file. Organization admins only.
+func (h OrgHandler) HandlePostKYBDoc(c *routing.Context) error {
+	ctx := c.Request.Context()
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return errs
+	}
+	orgID := c.Param("orgID")
+	if !u.CanManageOrg(orgID) {
+		return model.ErrUnauthorized
+	}
+	db, errs := dbs.GetDb(ctx)
+	if errs != nil {
+		return errs
+	}
+	if _, errs = dal.GetOrganization(ctx, db, orgID); errs != nil {
+		return errs
+	}
+	if err := c.Request.ParseMultipartForm(maxKYBDocSize); err != nil {
+		return errstack.WrapAsReq(err, "Can't Parse the form data")
+	}
+	kind := model.KYBDocKind(c.Request.FormValue("kind"))
+	if !kind.IsValid() {
+		return errstack.NewReqF("Invalid KYB document kind: %q", kind)
+	}
+	if len(c.Request.MultipartForm.File["formfile"]) != 1 {
+		return errstack.NewReq("Expecting exactly one file")
+	}
+	fileHeader := c.Request.MultipartForm.File["formfile"][0]
+	if fileHeader.Size > maxKYBDocSize {
+		return errstack.NewReq(fmt.Sprint(fileHeader.Filename, " file is too big. Max size: ", maxKYBDocSize/1000000))
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return errstack.WrapAsReq(err, "Can't get file data from request")
+	}
+	defer errstack.CallAndLog(logger, file.Close)
+	storedName, hash, errs := fstore.SaveDoc(ctx, fstore.Docs, file, fileHeader.Filename, kybDocDir)
+	if errs != nil {
+		return errs
+	}
+	fi := model.FileInfo{FileName: fileHeader.Filename, Hash: hash, URL: storedName}
+	d, errs := dal.InsertOrgKYBDoc(ctx, db, orgID, kind, fi, u.ID)
+	if errs != nil {
+		return errs
+	}
+	return c.Write(d.DocID)
+}
+
+// HandleGetKYBDoc serves an organization KYB document to the organization admins
+// and the reviewers
+func (h OrgHandler) HandleGetKYBDoc(c *routing.Context) error {
+	ctx := c.Request.Context()
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return errs
+	}
+	orgID, docID := c.Param("orgID"), c.Param("docID")
+	if !u.CanReadKYBDocs(orgID) {
+		return model.ErrUnauthorized
+	}
+	db, errs := dbs.GetDb(ctx)
+	if errs != nil {
+		return errs
+	}
+	o, errs := dal.GetOrganization(ctx, db, orgID)
+	if errs != nil {
+		return errs
+	}
+	if !o.HasKYBDoc(docID) {
+		return errstack.NewReqF("Organization KYB document [%s] doesn't exist", docID)
+	}
+	doc, errs := dal.GetDoc(ctx, db, docID)
+	if errs != nil {
+		return errs
+	}
+	key := path.Join(kybDocDir, doc.URL)
+	if expiry := *config.F.Storage.URLExpiry; expiry > 0 {
+		url, errs := fstore.Docs.SignedURL(key, time.Duration(expiry)*time.Second)
+		if errs == nil {
+			http.Redirect(c.Response, c.Request, url, http.StatusFound)
+			return nil
+		}
+		if errs != fstore.ErrNoSignedURL {
+			return errs
+		}
+	}
+	c.Response.Header().Del("Content-Type")
+	return fstore.Serve(ctx, c.Response, fstore.Docs, key)
+}
+
+// SetOrgRoutes sets organization routes
+func SetOrgRoutes(routerG *routing.RouteGroup) {
+	h := OrgHandler{}
+	routerG.Use(middleware.NoAPIKey)
+	routerG.Post("/<orgID>/kyb-docs", h.HandlePostKYBDoc)
+	routerG.Get("/<orgID>/kyb-docs/<docID>", h.HandleGetKYBDoc)
+}
@@ -1,14 +1,10 @@
-// Package users contains rest api services for users
+// Package users contains rest api services for users and organizations
 package users
 
 import (
-	"path/filepath"
-
-	"bitbucket.org/cerealia/apps/go-lib/model/dal"
-
-	"bitbucket.org/cerealia/apps/cmd/websrv/config"
 	"bitbucket.org/cerealia/apps/go-lib/fstore"
 	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
 	dbs "bitbucket.org/cerealia/apps/go-lib/setup/arangodb"
 	routing "github.com/go-ozzo/ozzo-routing"
 	"github.com/robert-zaremba/errstack"
@@ -44,8 +40,7 @@ func (h UserHandler) HandlePostUserAvatar(c *routing.Context) error {
 	if err != nil {
 		return errstack.WrapAsReq(err, "Can't get file data from request")
 	}
-	avatarStoreDir := filepath.Join(config.F.FileStorageDir.String(), avatarDir)
-	fileName, errs := fstore.SaveAvatar(file, fileHeader.Filename, avatarStoreDir)
+	fileName, errs := fstore.SaveAvatar(ctx, fstore.Default, file, fileHeader.Filename, avatarDir)
 	if errs != nil {
 		return errs
 	}
@@ -55,5 +50,6 @@ func (h UserHandler) HandlePostUserAvatar(c *routing.Context) error {
 // SetUserRoutes sets user routes
 func SetUserRoutes(routerG *routing.RouteGroup) {
 	u := UserHandler{}
+	routerG.Use(middleware.NoAPIKey)
 	routerG.Post("/avatar", u.HandlePostUserAvatar)
 }
@@ -13,15 +13,22 @@ import (
 	"github.com/robert-zaremba/errstack"
 )
 
-// ExpireTime means the duration of expires time, its unit is minute.
-// default expires time is 60min
-const ExpireTime = 200
+// ExpireTime means the duration of access token expires time, its unit is minute.
+// Access tokens are short lived, clients get a new one with the refresh token.
+const ExpireTime = 15
+
+// MFAExpireTime is the time in minutes to finish the login with a TOTP code
+const MFAExpireTime = 5
+
+// mfaAudience marks tokens which only allow to finish the two-factor login
+const mfaAudience = "mfa"
 
 var signKey *rsa.PrivateKey
 
 // AppClaims provides custom claim for JWT
 type AppClaims struct {
-	UserID string `json:"userid"`
+	UserID    string `json:"userid"`
+	SessionID string `json:"sid"`
 	jwt.StandardClaims
 }
 
@@ -35,8 +42,8 @@ func InitKeys() errstack.E {
 	return errstack.WrapAsInf(err, "Can not parse private key")
 }
 
-// CreateJWT generates a new JWT token
-func CreateJWT(userID string) (string, errstack.E) {
+// CreateJWT generates a new JWT token of the user session
+func CreateJWT(userID, sessionID string) (string, errstack.E) {
 	errs := InitKeys()
 	if errs != nil {
 		return "", errs
@@ -44,6 +51,7 @@ func CreateJWT(userID string) (string, errstack.E) {
 	// Create the Claims
 	claims := AppClaims{
 		userID,
+		sessionID,
 		jwt.StandardClaims{
 			ExpiresAt: time.Now().Add(time.Minute * ExpireTime).Unix(),
 			Issuer:    "admin",
@@ -54,13 +62,60 @@ func CreateJWT(userID string) (string, errstack.E) {
 	return ss, errstack.WrapAsDomain(err, "Can not generate JWT token")
 }
 
-// Authorize Middleware for validating JWT tokens
-func Authorize(tokenStr string) (string, errstack.E) {
-	// init config
+// CreateMFAToken generates a token for the second login step of a user with two-factor
+// authentication. It can't be used as an access token. tokenID binds the JWT to a stored
+// token which counts the attempts.
+func CreateMFAToken(userID, tokenID string) (string, errstack.E) {
 	errs := InitKeys()
 	if errs != nil {
 		return "", errs
 	}
+	claims := AppClaims{
+		UserID: userID,
+		StandardClaims: jwt.StandardClaims{
+			Audience:  mfaAudience,
+			Id:        tokenID,
+			ExpiresAt: time.Now().Add(time.Minute * MFAExpireTime).Unix(),
+			Issuer:    "admin",
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	ss, err := token.SignedString(signKey)
+	return ss, errstack.WrapAsDomain(err, "Can not generate JWT token")
+}
+
+// AuthorizeMFA validates the token created by CreateMFAToken and returns the user ID
+// and the token ID
+func AuthorizeMFA(tokenStr string) (string, string, errstack.E) {
+	claims, errs := parseJWT(tokenStr)
+	if errs != nil {
+		return "", "", errs
+	}
+	if claims.Audience != mfaAudience || claims.Id == "" {
+		return "", "", errstack.NewReq("Invalid JWT token!")
+	}
+	return claims.UserID, claims.Id, nil
+}
+
+// Authorize Middleware for validating JWT tokens. It doesn't check if the session
+// is revoked.
+func Authorize(tokenStr string) (*AppClaims, errstack.E) {
+	claims, errs := parseJWT(tokenStr)
+	if errs != nil {
+		return nil, errs
+	}
+	if claims.Audience != "" {
+		return nil, errstack.NewReq("Invalid JWT token!")
+	}
+	return claims, nil
+}
+
+func parseJWT(tokenStr string) (*AppClaims, errstack.E) {
+	// init config
+	errs := InitKeys()
+	if errs != nil {
+		return nil, errs
+	}
 	token, err := jwt.ParseWithClaims(tokenStr,
 		&AppClaims{},
 		func(token *jwt.Token) (interface{}, error) {
@@ -73,33 +128,34 @@ func Authorize(tokenStr string) (string, errstack.E) {
 		case *jwt.ValidationError:
 			switch vErr.Errors {
 			case jwt.ValidationErrorExpired:
-				return "", errstack.WrapAsReq(err, "JWT Access Token is expired, get a new Token")
+				return nil, errstack.WrapAsReq(err, "JWT Access Token is expired, get a new Token")
 			default:
-				return "", errstack.WrapAsReq(err, "JWT Validation Error")
+				return nil, errstack.WrapAsReq(err, "JWT Validation Error")
 			}
 
 		default:
-			return "", errstack.WrapAsReq(err, "Error while parsing the JWT Access Token!")
+			return nil, errstack.WrapAsReq(err, "Error while parsing the JWT Access Token!")
 		}
 
 	}
 	if !token.Valid {
-		return "", errstack.NewReq("Invalid JWT token!")
+		return nil, errstack.NewReq("Invalid JWT token!")
 	}
-	// Set user name to HTTP context
-	// context.Set(c.Request, "user", token.Claims.(*AppClaims).UserID)
-	return token.Claims.(*AppClaims).UserID, nil
+	return token.Claims.(*AppClaims), nil
 }
 
 // TokenFromAuthHeader is a "TokenExtractor" that takes a given request and extracts
 // the JWT token from the Authorization header.
 func TokenFromAuthHeader(r *http.Request) (string, errstack.E) {
 	// Look for an Authorization header
-	if ah := r.Header.Get("Authorization"); ah != "" {
-		// Should be a bearer token
-		if len(ah) > 6 && strings.ToUpper(ah[:6]) == "BEARER" {
-			return ah[7:], nil
-		}
+	return TokenFromBearer(r.Header.Get("Authorization"))
+}
+
+// TokenFromBearer extracts the JWT token from the "Bearer <token>" authorization value.
+func TokenFromBearer(ah string) (string, errstack.E) {
+	// Should be a bearer token
+	if len(ah) > 6 && strings.ToUpper(ah[:6]) == "BEARER" {
+		return ah[7:], nil
 	}
 	return "", errstack.NewReq("No token in the HTTP request")
 }
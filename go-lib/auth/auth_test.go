@@ -14,22 +14,85 @@ type S struct{}
 var _ = Suite(&S{})
 
 func (s *S) TestCreateJWT(c *C) {
-	samToken, err := CreateJWT("1")
+	samToken, err := CreateJWT("1", "s1")
 	c.Check(err, IsNil, Comment("Failed to generate Sam's JWT token, please ensure the correct info"))
-	userID, errs := Authorize(samToken)
-	c.Check(userID, Equals, "1", Comment("Parsed userID is wrong"))
-	c.Check(errs, IsNil, Comment("Failed to parse the user token"))
+	claims, errs := Authorize(samToken)
+	c.Assert(errs, IsNil, Comment("Failed to parse the user token"))
+	c.Check(claims.UserID, Equals, "1", Comment("Parsed userID is wrong"))
+	c.Check(claims.SessionID, Equals, "s1", Comment("Parsed sessionID is wrong"))
 
-	benToken, err := CreateJWT("2")
+	benToken, err := CreateJWT("2", "s2")
 	c.Check(err, IsNil, Comment("Failed to generate Ben's JWT token, please ensure the correct info"))
-	userID, errs = Authorize(benToken)
-	c.Check(userID, Equals, "2", Comment("Parsed userID is wrong"))
-	c.Check(errs, IsNil, Comment("Failed to parse the user token"))
+	claims, errs = Authorize(benToken)
+	c.Assert(errs, IsNil, Comment("Failed to parse the user token"))
+	c.Check(claims.UserID, Equals, "2", Comment("Parsed userID is wrong"))
 
 	// negative test
-	antonToken, err := CreateJWT("10")
+	antonToken, err := CreateJWT("10", "s10")
 	c.Check(err, IsNil, Comment("Failed to generate Ben's JWT token, please ensure the correct info"))
-	userID, errs = Authorize(antonToken)
-	c.Check(userID, Not(Equals), "100", Comment("Parsed userID should be different with expected value but now same"))
-	c.Check(errs, IsNil, Comment("Failed to parse the user token"))
+	claims, errs = Authorize(antonToken)
+	c.Assert(errs, IsNil, Comment("Failed to parse the user token"))
+	c.Check(claims.UserID, Not(Equals), "100", Comment("Parsed userID should be different with expected value but now same"))
+}
+
+func (s *S) TestOpaqueToken(c *C) {
+	token, hash, errs := NewOpaqueToken("t1")
+	c.Assert(errs, IsNil)
+	id, hash2, errs := ParseOpaqueToken(token)
+	c.Assert(errs, IsNil)
+	c.Check(id, Equals, "t1")
+	c.Check(hash2, Equals, hash)
+
+	other, otherHash, errs := NewOpaqueToken("t1")
+	c.Assert(errs, IsNil)
+	c.Check(other, Not(Equals), token)
+	c.Check(otherHash, Not(Equals), hash)
+
+	for _, t := range []string{"", "t1", ".abc", "t1."} {
+		_, _, errs = ParseOpaqueToken(t)
+		c.Check(errs, ErrorContains, "Malformed", Comment(t))
+	}
+}
+
+func (s *S) TestRefreshToken(c *C) {
+	token, hash, errs := NewRefreshToken("s1")
+	c.Assert(errs, IsNil)
+	sid, hash2, errs := ParseRefreshToken(token)
+	c.Assert(errs, IsNil)
+	c.Check(sid, Equals, "s1")
+	c.Check(hash2, Equals, hash)
+}
+
+func (s *S) TestAPIKey(c *C) {
+	key, hash, errs := NewAPIKey("k1")
+	c.Assert(errs, IsNil)
+	c.Check(IsAPIKey(key), IsTrue)
+	id, hash2, errs := ParseAPIKey(key)
+	c.Assert(errs, IsNil)
+	c.Check(id, Equals, "k1")
+	c.Check(hash2, Equals, hash)
+
+	token, _, errs := NewOpaqueToken("k1")
+	c.Assert(errs, IsNil)
+	c.Check(IsAPIKey(token), IsFalse)
+	_, _, errs = ParseAPIKey(token)
+	c.Check(errs, ErrorContains, "Malformed")
+	_, _, errs = ParseAPIKey(APIKeyPrefix + "k1")
+	c.Check(errs, ErrorContains, "Malformed")
+}
+
+func (s *S) TestMFAToken(c *C) {
+	token, errs := CreateMFAToken("1", "t1")
+	c.Assert(errs, IsNil)
+	userID, tokenID, errs := AuthorizeMFA(token)
+	c.Assert(errs, IsNil)
+	c.Check(userID, Equals, "1")
+	c.Check(tokenID, Equals, "t1")
+	_, errs = Authorize(token)
+	c.Check(errs, ErrorContains, "Invalid JWT", Comment("MFA token can't be used as an access token"))
+
+	access, errs := CreateJWT("1", "s1")
+	c.Assert(errs, IsNil)
+	_, _, errs = AuthorizeMFA(access)
+	c.Check(errs, ErrorContains, "Invalid JWT")
 }
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// NewOpaqueToken creates a new random token prefixed with the id, so the token can be
+// found without storing it. It returns the token, which is given to the client, and
+// the token hash to store in the DB. Refresh tokens, API keys and the emailed user
+// tokens use this format.
+func NewOpaqueToken(id string) (string, string, errstack.E) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", errstack.WrapAsInf(err, "Can't generate token")
+	}
+	token := id + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return token, hashToken(token), nil
+}
+
+// ParseOpaqueToken returns the id and the hash of the token created by NewOpaqueToken
+func ParseOpaqueToken(token string) (string, string, errstack.E) {
+	idx := strings.LastIndexByte(token, '.')
+	if idx <= 0 || idx == len(token)-1 {
+		return "", "", errstack.NewReq("Malformed token")
+	}
+	return token[:idx], hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
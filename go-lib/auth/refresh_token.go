@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// RefreshExpireTime is the validity of a refresh token. Every token refresh extends
+// the session by this time.
+const RefreshExpireTime = 30 * 24 * time.Hour
+
+// NewRefreshToken creates a new random refresh token of the session.
+// It returns the token, which is sent to the client, and the token hash to store
+// in the session.
+func NewRefreshToken(sessionID string) (string, string, errstack.E) {
+	return NewOpaqueToken(sessionID)
+}
+
+// ParseRefreshToken returns the session ID and the hash of the refresh token
+func ParseRefreshToken(token string) (string, string, errstack.E) {
+	return ParseOpaqueToken(token)
+}
+
+// APIKeyPrefix distinguishes API keys from JWT access tokens
+const APIKeyPrefix = "crl_"
+
+// NewAPIKey creates a new secret API key with the given id. It returns the key and
+// the key hash to store in the DB.
+func NewAPIKey(id string) (string, string, errstack.E) {
+	token, _, errs := NewOpaqueToken(id)
+	if errs != nil {
+		return "", "", errs
+	}
+	key := APIKeyPrefix + token
+	return key, hashToken(key), nil
+}
+
+// IsAPIKey checks if the bearer token is an API key
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// ParseAPIKey returns the API key ID and the key hash
+func ParseAPIKey(key string) (string, string, errstack.E) {
+	if !IsAPIKey(key) {
+		return "", "", errstack.NewReq("Malformed API key")
+	}
+	id, _, errs := ParseOpaqueToken(strings.TrimPrefix(key, APIKeyPrefix))
+	if errs != nil {
+		return "", "", errstack.NewReq("Malformed API key")
+	}
+	return id, hashToken(key), nil
+}
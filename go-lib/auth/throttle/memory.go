@@ -0,0 +1,87 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// minPruneSize is the number of counters when the memory limiter starts to remove
+// the expired ones
+const minPruneSize = 1024
+
+// MemLimiter keeps the counters in memory. The counters are not shared by the
+// server instances.
+type MemLimiter struct {
+	mu       sync.Mutex
+	window   time.Duration
+	attempts map[string]Attempts
+	pruneAt  int
+}
+
+// NewMemLimiter creates a memory limiter
+func NewMemLimiter(window time.Duration) *MemLimiter {
+	return &MemLimiter{window: window, attempts: map[string]Attempts{}, pruneAt: minPruneSize}
+}
+
+func (l *MemLimiter) get(key string, now time.Time) Attempts {
+	a := l.attempts[key]
+	if a.LastFailure.Add(l.window).Before(now) {
+		return Attempts{}
+	}
+	return a
+}
+
+// Reserve implements Limiter interface
+func (l *MemLimiter) Reserve(ctx context.Context, key string, now time.Time) (prev, next Attempts, errs errstack.E) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	prev = l.get(key, now)
+	next = Attempts{prev.Failures + 1, now}
+	l.attempts[key] = next
+	if len(l.attempts) >= l.pruneAt {
+		l.prune(now)
+	}
+	return prev, next, nil
+}
+
+// Release implements Limiter interface
+func (l *MemLimiter) Release(ctx context.Context, key string, now time.Time, prev Attempts) errstack.E {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	a, ok := l.attempts[key]
+	if !ok {
+		return nil
+	}
+	if a.Failures > 0 {
+		a.Failures--
+	}
+	if a.LastFailure.Equal(now) {
+		a.LastFailure = prev.LastFailure
+	}
+	l.attempts[key] = a
+	return nil
+}
+
+// prune removes the expired counters
+func (l *MemLimiter) prune(now time.Time) {
+	for k, a := range l.attempts {
+		if a.LastFailure.Add(l.window).Before(now) {
+			delete(l.attempts, k)
+		}
+	}
+	l.pruneAt = 2 * len(l.attempts)
+	if l.pruneAt < minPruneSize {
+		l.pruneAt = minPruneSize
+	}
+}
+
+// Reset implements Limiter interface
+func (l *MemLimiter) Reset(ctx context.Context, key string) errstack.E {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, key)
+	return nil
+}
@@ -0,0 +1,234 @@
+// Package throttle limits failed login attempts. Failures are counted per account and
+// per client IP. After a few free attempts every next attempt is delayed exponentially
+// and too many failures lock the account temporarily.
+package throttle
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"github.com/robert-zaremba/errstack"
+	"github.com/robert-zaremba/log15"
+)
+
+var logger = log15.Root()
+
+// Window is the time after the last failure when a counter starts again
+const Window = 24 * time.Hour
+
+// Attempts are the failed attempts of a key
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Limiter stores the failed attempt counters. Counters without a failure in the
+// limiter window start again.
+type Limiter interface {
+	// Reserve atomically records an attempt of the key and returns the attempts
+	// before and after it
+	Reserve(ctx context.Context, key string, now time.Time) (prev, next Attempts, errs errstack.E)
+	// Release removes the attempt of the key reserved at now. prev are the attempts
+	// returned by Reserve.
+	Release(ctx context.Context, key string, now time.Time, prev Attempts) errstack.E
+	// Reset removes the counter of the key
+	Reset(ctx context.Context, key string) errstack.E
+}
+
+// Policy defines the backoff and the lockout of a counter
+type Policy struct {
+	// FreeAttempts is the number of failures which are not delayed
+	FreeAttempts int
+	// BaseDelay is the delay after the first failure above FreeAttempts. It doubles
+	// with every next failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockoutAfter failures block the key for LockoutDuration. 0 disables the lockout.
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+// IsLocked checks if the failures reached the lockout
+func (p Policy) IsLocked(a Attempts) bool {
+	return p.LockoutAfter > 0 && a.Failures >= p.LockoutAfter
+}
+
+// BlockedUntil returns the time until the next attempt is rejected
+func (p Policy) BlockedUntil(a Attempts) time.Time {
+	if p.IsLocked(a) {
+		return a.LastFailure.Add(p.LockoutDuration)
+	}
+	n := a.Failures - p.FreeAttempts
+	if n <= 0 {
+		return time.Time{}
+	}
+	d := p.MaxDelay
+	if n < 32 && p.BaseDelay<<uint(n-1) < d {
+		d = p.BaseDelay << uint(n-1)
+	}
+	return a.LastFailure.Add(d)
+}
+
+// Throttler checks login attempts of the accounts and the client IPs
+type Throttler struct {
+	Limiter Limiter
+	Account Policy
+	IP      Policy
+}
+
+// DefaultAccountPolicy delays the login after 3 failures and locks the account after 10
+var DefaultAccountPolicy = Policy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        5 * time.Minute,
+	LockoutAfter:    10,
+	LockoutDuration: 30 * time.Minute,
+}
+
+// DefaultIPPolicy delays logins from the IP after 20 failures. Clients behind a NAT
+// share the IP, so it's never locked.
+var DefaultIPPolicy = Policy{
+	FreeAttempts: 20,
+	BaseDelay:    time.Second,
+	MaxDelay:     15 * time.Minute,
+}
+
+// Default is the throttler used by the resolvers. It keeps the counters in memory.
+var Default = NewThrottler(NewMemLimiter(Window))
+
+// NewThrottler creates a throttler with the default policies
+func NewThrottler(l Limiter) *Throttler {
+	return &Throttler{l, DefaultAccountPolicy, DefaultIPPolicy}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Attempt is a login attempt counted before the credentials are verified
+type Attempt struct {
+	t        *Throttler
+	now      time.Time
+	keys     []string
+	prevs    []Attempts
+	account  string
+	accountA Attempts
+}
+
+// Reserve counts the login attempt to the account from the IP before the credentials
+// are verified, so concurrent attempts can't exceed the limits. When the login is
+// blocked, the attempt is released and an error is returned. The account or the IP
+// is not counted when it's empty.
+func (t *Throttler) Reserve(ctx context.Context, account, ip string, now time.Time) (*Attempt, errstack.E) {
+	a := &Attempt{t: t, now: now}
+	if account != "" {
+		a.account = accountKey(account)
+		prev, next, errs := a.reserve(ctx, a.account)
+		if errs != nil {
+			return nil, errs
+		}
+		a.accountA = next
+		if now.Before(t.Account.BlockedUntil(prev)) {
+			a.releaseAndLog(ctx)
+			if t.Account.IsLocked(prev) {
+				return nil, model.ErrAccountLocked
+			}
+			return nil, model.ErrTooManyLoginAttempts
+		}
+	}
+	if ip != "" {
+		prev, _, errs := a.reserve(ctx, ipKey(ip))
+		if errs != nil {
+			a.releaseAndLog(ctx)
+			return nil, errs
+		}
+		if now.Before(t.IP.BlockedUntil(prev)) {
+			a.releaseAndLog(ctx)
+			return nil, model.ErrTooManyLoginAttempts
+		}
+	}
+	return a, nil
+}
+
+func (a *Attempt) reserve(ctx context.Context, key string) (prev, next Attempts, errs errstack.E) {
+	prev, next, errs = a.t.Limiter.Reserve(ctx, key, a.now)
+	if errs == nil {
+		a.keys = append(a.keys, key)
+		a.prevs = append(a.prevs, prev)
+	}
+	return prev, next, errs
+}
+
+// Release removes the attempt from the counters. It's used when the attempt failed
+// for other reasons than wrong credentials.
+func (a *Attempt) Release(ctx context.Context) errstack.E {
+	for i, k := range a.keys {
+		if errs := a.t.Limiter.Release(ctx, k, a.now, a.prevs[i]); errs != nil {
+			return errs
+		}
+	}
+	a.keys, a.prevs = nil, nil
+	return nil
+}
+
+// ReleaseAccount removes the attempt from the account counter only. It's used when the
+// account is unknown or not accepted: the IP still counts the attempt, so guessing
+// the emails is throttled, but the account of a pending user isn't locked.
+func (a *Attempt) ReleaseAccount(ctx context.Context) errstack.E {
+	for i, k := range a.keys {
+		if k != a.account {
+			continue
+		}
+		if errs := a.t.Limiter.Release(ctx, k, a.now, a.prevs[i]); errs != nil {
+			return errs
+		}
+		a.keys = append(a.keys[:i:i], a.keys[i+1:]...)
+		a.prevs = append(a.prevs[:i:i], a.prevs[i+1:]...)
+		break
+	}
+	return nil
+}
+
+func (a *Attempt) releaseAndLog(ctx context.Context) {
+	if errs := a.Release(ctx); errs != nil {
+		logger.Error("Can't release the login attempt", errs)
+	}
+}
+
+// Succeeded removes the attempt from the counters and resets the failed login counter
+// of the account
+func (a *Attempt) Succeeded(ctx context.Context) errstack.E {
+	if errs := a.Release(ctx); errs != nil {
+		return errs
+	}
+	if a.account == "" {
+		return nil
+	}
+	return a.t.Limiter.Reset(ctx, a.account)
+}
+
+// LockedUntil returns the time when the account lockout ends if the attempt locked
+// the account, otherwise nil
+func (a *Attempt) LockedUntil() *time.Time {
+	if a.account == "" || !a.t.Account.IsLocked(a.accountA) {
+		return nil
+	}
+	until := a.t.Account.BlockedUntil(a.accountA)
+	return &until
+}
+
+// Unlock resets the failed login counter of the accounts
+func (t *Throttler) Unlock(ctx context.Context, accounts ...string) errstack.E {
+	for _, e := range accounts {
+		if errs := t.Limiter.Reset(ctx, accountKey(e)); errs != nil {
+			return errs
+		}
+	}
+	return nil
+}
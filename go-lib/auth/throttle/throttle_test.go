@@ -0,0 +1,191 @@
+package throttle
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ThrottleSuite struct{}
+
+var _ = Suite(&ThrottleSuite{})
+
+var t0 = time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+
+func (s *ThrottleSuite) TestBlockedUntil(c *C) {
+	p := Policy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: 10 * time.Second,
+		LockoutAfter: 8, LockoutDuration: time.Hour}
+	var tcs = []struct {
+		failures int
+		delay    time.Duration
+	}{
+		{0, 0},
+		{2, 0},
+		{3, time.Second},
+		{4, 2 * time.Second},
+		{5, 4 * time.Second},
+		{6, 8 * time.Second},
+		{7, 10 * time.Second},
+		{8, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tc := range tcs {
+		a := Attempts{tc.failures, t0}
+		expected := time.Time{}
+		if tc.delay > 0 {
+			expected = t0.Add(tc.delay)
+		}
+		c.Check(p.BlockedUntil(a), Equals, expected, Comment("failures: ", tc.failures))
+		c.Check(p.IsLocked(a), Equals, tc.failures >= 8, Comment("failures: ", tc.failures))
+	}
+
+	p.LockoutAfter = 0
+	c.Check(p.IsLocked(Attempts{100, t0}), IsFalse)
+	c.Check(p.BlockedUntil(Attempts{100, t0}), Equals, t0.Add(10*time.Second))
+}
+
+func (s *ThrottleSuite) TestMemLimiter(c *C) {
+	ctx := context.Background()
+	l := NewMemLimiter(time.Hour)
+	prev, next, errs := l.Reserve(ctx, "k", t0)
+	c.Assert(errs, IsNil)
+	c.Check(prev, Equals, Attempts{})
+	c.Check(next, Equals, Attempts{1, t0})
+
+	prev, next, _ = l.Reserve(ctx, "k", t0.Add(time.Minute))
+	c.Check(prev, Equals, Attempts{1, t0})
+	c.Check(next, Equals, Attempts{2, t0.Add(time.Minute)})
+
+	// the released attempt restores the previous attempts
+	c.Assert(l.Release(ctx, "k", t0.Add(time.Minute), prev), IsNil)
+	prev, _, _ = l.Reserve(ctx, "k", t0.Add(time.Hour))
+	c.Check(prev, Equals, Attempts{1, t0})
+
+	// the counter starts again after the window
+	prev, next, _ = l.Reserve(ctx, "k", t0.Add(3*time.Hour))
+	c.Check(prev, Equals, Attempts{})
+	c.Check(next.Failures, Equals, 1)
+
+	c.Assert(l.Reset(ctx, "k"), IsNil)
+	prev, _, _ = l.Reserve(ctx, "k", t0.Add(3*time.Hour))
+	c.Check(prev, Equals, Attempts{})
+	c.Assert(l.Release(ctx, "unknown", t0, Attempts{}), IsNil)
+}
+
+func (s *ThrottleSuite) TestMemLimiterPrune(c *C) {
+	ctx := context.Background()
+	l := NewMemLimiter(time.Hour)
+	for i := 0; i < minPruneSize-1; i++ {
+		l.Reserve(ctx, string(rune('a'+i%26))+string(rune(i)), t0)
+	}
+	l.Reserve(ctx, "new", t0.Add(2*time.Hour))
+	c.Check(l.attempts, HasLen, 1)
+	c.Check(l.pruneAt, Equals, minPruneSize)
+}
+
+func (s *ThrottleSuite) TestThrottler(c *C) {
+	ctx := context.Background()
+	t := NewThrottler(NewMemLimiter(Window))
+	t.Account = Policy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute,
+		LockoutAfter: 3, LockoutDuration: time.Hour}
+	t.IP = Policy{FreeAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+
+	a, errs := t.Reserve(ctx, "ann@example.com", "1.2.3.4", t0)
+	c.Assert(errs, IsNil)
+	c.Check(a.LockedUntil(), IsNil)
+	_, errs = t.Reserve(ctx, "Ann@Example.com ", "1.2.3.4", t0)
+	c.Assert(errs, IsNil)
+	// the reserved attempts are counted before they fail
+	_, errs = t.Reserve(ctx, "ann@example.com", "1.2.3.4", t0)
+	c.Check(errs, Equals, model.ErrTooManyLoginAttempts)
+
+	a, errs = t.Reserve(ctx, "ann@example.com", "1.2.3.4", t0.Add(time.Second))
+	c.Assert(errs, IsNil)
+	until := a.LockedUntil()
+	c.Assert(until, NotNil)
+	c.Check(*until, Equals, t0.Add(time.Second+time.Hour))
+	_, errs = t.Reserve(ctx, "ann@example.com", "5.6.7.8", t0.Add(time.Minute))
+	c.Check(errs, Equals, model.ErrAccountLocked)
+	_, errs = t.Reserve(ctx, "ann@example.com", "5.6.7.8", t0.Add(2*time.Hour))
+	c.Check(errs, IsNil)
+
+	// other accounts are blocked by the IP counter only
+	_, errs = t.Reserve(ctx, "bob@example.com", "1.2.3.4", t0.Add(time.Second))
+	c.Check(errs, IsNil)
+	_, errs = t.Reserve(ctx, "bob@example.com", "1.2.3.4", t0.Add(2*time.Second))
+	c.Check(errs, Equals, model.ErrTooManyLoginAttempts)
+	a, errs = t.Reserve(ctx, "bob@example.com", "", t0.Add(2*time.Second))
+	c.Assert(errs, IsNil)
+
+	// succeeded attempts are released and reset the account counter
+	c.Assert(a.Succeeded(ctx), IsNil)
+	c.Assert(t.Unlock(ctx, "ann@example.com"), IsNil)
+	for i := 0; i < 3; i++ {
+		a, errs = t.Reserve(ctx, "ann@example.com", "", t0.Add(time.Minute))
+		c.Assert(errs, IsNil)
+		c.Assert(a.Succeeded(ctx), IsNil)
+	}
+	a, errs = t.Reserve(ctx, "", "9.9.9.9", t0)
+	c.Assert(errs, IsNil)
+	c.Assert(a.Release(ctx), IsNil)
+	c.Check(a.LockedUntil(), IsNil)
+}
+
+func (s *ThrottleSuite) TestThrottlerUnknownEmails(c *C) {
+	ctx := context.Background()
+	t := NewThrottler(NewMemLimiter(Window))
+	t.Account = Policy{FreeAttempts: 1, BaseDelay: time.Hour, MaxDelay: time.Hour,
+		LockoutAfter: 2, LockoutDuration: time.Hour}
+	t.IP = Policy{FreeAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+
+	// the unknown accounts are released, but the IP keeps counting the attempts
+	for i := 0; i < 4; i++ {
+		a, errs := t.Reserve(ctx, "ann@example.com", "1.2.3.4", t0)
+		c.Assert(errs, IsNil, Comment("attempt: ", i))
+		c.Assert(a.ReleaseAccount(ctx), IsNil)
+		c.Check(a.LockedUntil(), IsNil)
+	}
+	_, errs := t.Reserve(ctx, "bob@example.com", "1.2.3.4", t0)
+	c.Check(errs, Equals, model.ErrTooManyLoginAttempts)
+
+	// other IPs are not blocked and the account counter is empty
+	a, errs := t.Reserve(ctx, "ann@example.com", "5.6.7.8", t0)
+	c.Assert(errs, IsNil)
+	c.Assert(a.ReleaseAccount(ctx), IsNil)
+	// the full release after ReleaseAccount removes the IP attempt only
+	c.Assert(a.Release(ctx), IsNil)
+	prev, _, _ := t.Limiter.Reserve(ctx, ipKey("5.6.7.8"), t0)
+	c.Check(prev.Failures, Equals, 0)
+	prev, _, _ = t.Limiter.Reserve(ctx, accountKey("ann@example.com"), t0)
+	c.Check(prev.Failures, Equals, 0)
+}
+
+func (s *ThrottleSuite) TestThrottlerConcurrent(c *C) {
+	ctx := context.Background()
+	t := NewThrottler(NewMemLimiter(Window))
+	t.Account = Policy{FreeAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	passed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, errs := t.Reserve(ctx, "ann@example.com", "", t0); errs == nil {
+				mu.Lock()
+				passed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	// only the free attempts and the first delayed one pass
+	c.Check(passed <= 4, IsTrue, Commentf("passed: %d", passed))
+}
@@ -0,0 +1,95 @@
+// Package throttleimpl implements the login attempts limiter backed by ArangoDB, so
+// the counters are shared by all server instances.
+package throttleimpl
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/auth/throttle"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// attemptsDO is the login_attempts document. LastFailure is in unix milliseconds.
+type attemptsDO struct {
+	Failures    int   `json:"failures"`
+	LastFailure int64 `json:"lastFailure"`
+}
+
+func (a attemptsDO) toAttempts() throttle.Attempts {
+	return throttle.Attempts{
+		Failures:    a.Failures,
+		LastFailure: time.Unix(0, a.LastFailure*int64(time.Millisecond)).UTC()}
+}
+
+type limiter struct {
+	db     driver.Database
+	window time.Duration
+}
+
+// NewLimiter creates a new instance of the ArangoDB limiter
+func NewLimiter(db driver.Database, window time.Duration) throttle.Limiter {
+	return limiter{db, window}
+}
+
+// docKey hashes the key, because emails can contain characters not allowed in _key
+func docKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// Reserve implements throttle.Limiter interface. The counter is updated atomically, so
+// concurrent attempts on many instances are all counted.
+func (l limiter) Reserve(ctx context.Context, key string, now time.Time) (prev, next throttle.Attempts, errs errstack.E) {
+	q := `UPSERT {_key: @key}
+	INSERT {_key: @key, failures: 1, lastFailure: @now}
+	UPDATE {failures: OLD.lastFailure < @windowStart ? 1 : OLD.failures + 1, lastFailure: @now}
+	IN login_attempts
+	RETURN {prev: OLD, next: NEW}`
+	windowStart := toMillis(now.Add(-l.window))
+	var res struct {
+		Prev *attemptsDO `json:"prev"`
+		Next attemptsDO  `json:"next"`
+	}
+	errs = dal.DBQueryOne(ctx, &res, q, map[string]interface{}{
+		"key":         docKey(key),
+		"now":         toMillis(now),
+		"windowStart": windowStart}, l.db)
+	if errs != nil {
+		return prev, next, errs
+	}
+	if res.Prev != nil && res.Prev.LastFailure >= windowStart {
+		prev = res.Prev.toAttempts()
+	}
+	return prev, res.Next.toAttempts(), nil
+}
+
+// Release implements throttle.Limiter interface. The last failure time is restored
+// only when no other attempt was reserved later.
+func (l limiter) Release(ctx context.Context, key string, now time.Time, prev throttle.Attempts) errstack.E {
+	q := `FOR d IN login_attempts FILTER d._key == @key
+	UPDATE d WITH {failures: MAX([d.failures - 1, 0]),
+		lastFailure: d.lastFailure == @now ? @prevLastFailure : d.lastFailure} IN login_attempts`
+	var prevLastFailure int64
+	if !prev.LastFailure.IsZero() {
+		prevLastFailure = toMillis(prev.LastFailure)
+	}
+	return dal.DBExec(ctx, q, map[string]interface{}{
+		"key":             docKey(key),
+		"now":             toMillis(now),
+		"prevLastFailure": prevLastFailure}, l.db)
+}
+
+// Reset implements throttle.Limiter interface
+func (l limiter) Reset(ctx context.Context, key string) errstack.E {
+	q := "REMOVE {_key: @key} IN login_attempts OPTIONS {ignoreErrors: true}"
+	return dal.DBExec(ctx, q, map[string]interface{}{"key": docKey(key)}, l.db)
+}
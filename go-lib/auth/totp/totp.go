@@ -0,0 +1,95 @@
+// Package totp implements RFC 6238 time-based one-time passwords (HMAC-SHA1, 6 digits,
+// 30 seconds period), compatible with the common authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+const (
+	// Period is the validity time of a code
+	Period = 30 * time.Second
+	// Digits is the length of the codes
+	Digits = 6
+	// skew is the number of periods accepted before and after the current one
+	skew = 1
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret creates a new random base32 encoded secret
+func NewSecret() (string, errstack.E) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", errstack.WrapAsInf(err, "Can't generate TOTP secret")
+	}
+	return b32.EncodeToString(secret), nil
+}
+
+// Counter returns the time step of t
+func Counter(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code computes the code of the counter
+func Code(secret string, counter int64) (string, errstack.E) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", errstack.WrapAsDomain(err, "Malformed TOTP secret")
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	_, _ = mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0xf
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000), nil
+}
+
+// Verify checks the code at the time now. Codes of the counters lower or equal
+// to lastCounter are rejected, so a code can't be used twice.
+// It returns the counter of the matching code.
+func Verify(secret, code string, now time.Time, lastCounter int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+	current := Counter(now)
+	for c := current - skew; c <= current+skew; c++ {
+		if c <= lastCounter {
+			continue
+		}
+		expected, errs := Code(secret, c)
+		if errs != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// ProvisioningURI returns the otpauth URI which is encoded in the enrollment QR code
+func ProvisioningURI(secret, issuer, account string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period/time.Second)))
+	label := url.PathEscape(issuer) + ":" + url.PathEscape(account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
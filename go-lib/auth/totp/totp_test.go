@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type TOTPSuite struct{}
+
+var _ = Suite(&TOTPSuite{})
+
+// base32 of the RFC 6238 SHA1 test secret "12345678901234567890"
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func (s *TOTPSuite) TestCode(c *C) {
+	// RFC 6238 appendix B vectors, truncated to 6 digits
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for ts, expected := range vectors {
+		code, errs := Code(rfcSecret, Counter(time.Unix(ts, 0)))
+		c.Assert(errs, IsNil)
+		c.Check(code, Equals, expected, Comment(ts))
+	}
+	_, errs := Code("not base32!", 1)
+	c.Check(errs, ErrorContains, "Malformed")
+}
+
+func (s *TOTPSuite) TestVerify(c *C) {
+	now := time.Unix(1111111111, 0)
+	counter := Counter(now)
+	code, errs := Code(rfcSecret, counter)
+	c.Assert(errs, IsNil)
+	matched, ok := Verify(rfcSecret, code, now, 0)
+	c.Check(ok, IsTrue)
+	c.Check(matched, Equals, counter)
+
+	_, ok = Verify(rfcSecret, code, now.Add(Period), 0)
+	c.Check(ok, IsTrue, Comment("previous period is accepted"))
+	_, ok = Verify(rfcSecret, code, now.Add(3*Period), 0)
+	c.Check(ok, IsFalse)
+	_, ok = Verify(rfcSecret, code, now, counter)
+	c.Check(ok, IsFalse, Comment("used code can't be replayed"))
+	_, ok = Verify(rfcSecret, "12345", now, 0)
+	c.Check(ok, IsFalse)
+}
+
+func (s *TOTPSuite) TestSecretAndURI(c *C) {
+	secret, errs := NewSecret()
+	c.Assert(errs, IsNil)
+	c.Check(secret, HasLen, 32)
+	now := time.Now()
+	code, errs := Code(secret, Counter(now))
+	c.Assert(errs, IsNil)
+	_, ok := Verify(secret, code, now, 0)
+	c.Check(ok, IsTrue)
+
+	uri := ProvisioningURI(secret, "Cerealia", "ann@example.com")
+	c.Check(strings.HasPrefix(uri, "otpauth://totp/Cerealia:ann@example.com?"), IsTrue, Comment(uri))
+	c.Check(uri, Contains, "secret="+secret)
+	c.Check(uri, Contains, "issuer=Cerealia")
+}
@@ -0,0 +1,234 @@
+package fstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// Encrypted object format:
+//
+//	magic | master key nonce | wrapped data key | chunk...
+//
+// Each chunk is encrypted with AES-256-GCM using the data key. The chunk nonce is the chunk
+// counter and the additional data marks the last chunk, so reordered or truncated objects
+// fail the decryption.
+const (
+	encMagic     = "CRLENC01"
+	encKeySize   = 32
+	encChunkSize = 64 * 1024
+)
+
+var (
+	adChunk     = []byte{0}
+	adLastChunk = []byte{1}
+)
+
+// EncryptedStore encrypts objects of the underlying store with envelope encryption:
+// every object is encrypted with a random data key, which is wrapped with the master key
+// and stored in the object header. Objects without the header are read as plaintext.
+type EncryptedStore struct {
+	Store
+	master cipher.AEAD
+}
+
+// NewEncryptedStore wraps the store with encryption. The master key must have 32 bytes.
+func NewEncryptedStore(s Store, masterKey []byte) (*EncryptedStore, errstack.E) {
+	master, errs := newAEAD(masterKey)
+	if errs != nil {
+		return nil, errstack.WrapAsReq(errs, "Invalid master key")
+	}
+	return &EncryptedStore{s, master}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, errstack.E) {
+	if len(key) != encKeySize {
+		return nil, errstack.NewReqF("Key must have %d bytes", encKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errstack.WrapAsInf(err, "Can't create cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errstack.WrapAsInf(err, "Can't create cipher")
+}
+
+// Put implements Store interface
+func (s *EncryptedStore) Put(ctx context.Context, key string, src io.Reader) errstack.E {
+	dataKey := make([]byte, encKeySize)
+	nonce := make([]byte, s.master.NonceSize())
+	if _, err := rand.Read(dataKey); err != nil {
+		return errstack.WrapAsInf(err, "Can't generate data key")
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return errstack.WrapAsInf(err, "Can't generate data key")
+	}
+	aead, errs := newAEAD(dataKey)
+	if errs != nil {
+		return errs
+	}
+	header := append([]byte(encMagic), nonce...)
+	header = s.master.Seal(header, nonce, dataKey, []byte(encMagic))
+	return s.Store.Put(ctx, key, &encReader{aead: aead, src: src, header: header})
+}
+
+// Get implements Store interface. The object is decrypted while it's read.
+func (s *EncryptedStore) Get(ctx context.Context, key string) (io.ReadCloser, errstack.E) {
+	rc, errs := s.Store.Get(ctx, key)
+	if errs != nil {
+		return nil, errs
+	}
+	src := bufio.NewReader(rc)
+	if magic, _ := src.Peek(len(encMagic)); string(magic) != encMagic {
+		// stored before the encryption was enabled
+		return readCloser{src, rc}, nil
+	}
+	header := make([]byte, len(encMagic)+s.master.NonceSize()+encKeySize+s.master.Overhead())
+	if _, err := io.ReadFull(src, header); err != nil {
+		errstack.CallAndLog(logger, rc.Close)
+		return nil, errstack.WrapAsInf(err, "Can't read encrypted file header")
+	}
+	nonce := header[len(encMagic) : len(encMagic)+s.master.NonceSize()]
+	dataKey, err := s.master.Open(nil, nonce, header[len(encMagic)+len(nonce):], []byte(encMagic))
+	if err != nil {
+		errstack.CallAndLog(logger, rc.Close)
+		return nil, errstack.WrapAsInf(err, "Can't decrypt the file key")
+	}
+	aead, errs := newAEAD(dataKey)
+	if errs != nil {
+		errstack.CallAndLog(logger, rc.Close)
+		return nil, errs
+	}
+	return readCloser{&decReader{aead: aead, src: src}, rc}, nil
+}
+
+// SignedURL implements Store interface. Encrypted objects must be served through Get.
+func (s *EncryptedStore) SignedURL(key string, ttl time.Duration) (string, errstack.E) {
+	return "", ErrNoSignedURL
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func chunkNonce(aead cipher.AEAD, counter uint64) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], counter)
+	return nonce
+}
+
+// encReader encrypts the src in chunks
+type encReader struct {
+	aead    cipher.AEAD
+	src     io.Reader
+	header  []byte
+	out     bytes.Buffer
+	pending []byte // next plaintext chunk
+	srcEOF  bool
+	started bool
+	done    bool
+	counter uint64
+}
+
+func (r *encReader) Read(p []byte) (int, error) {
+	for r.out.Len() == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	return r.out.Read(p)
+}
+
+// fill encrypts the next chunk. It reads one chunk ahead to mark the last one.
+func (r *encReader) fill() error {
+	if !r.started {
+		r.started = true
+		r.out.Write(r.header)
+		return r.readAhead()
+	}
+	chunk, last := r.pending, r.srcEOF
+	if !last {
+		if err := r.readAhead(); err != nil {
+			return err
+		}
+		last = r.srcEOF && len(r.pending) == 0
+	}
+	ad := adChunk
+	if last {
+		ad = adLastChunk
+	}
+	r.out.Write(r.aead.Seal(nil, chunkNonce(r.aead, r.counter), chunk, ad))
+	r.counter++
+	r.done = last
+	return nil
+}
+
+func (r *encReader) readAhead() error {
+	chunk := make([]byte, encChunkSize)
+	n, err := io.ReadFull(r.src, chunk)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		r.srcEOF, err = true, nil
+	}
+	r.pending = chunk[:n]
+	return err
+}
+
+// decReader decrypts chunks of the src
+type decReader struct {
+	aead    cipher.AEAD
+	src     *bufio.Reader
+	out     []byte
+	done    bool
+	counter uint64
+}
+
+func (r *decReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decReader) next() error {
+	chunk := make([]byte, encChunkSize+r.aead.Overhead())
+	n, err := io.ReadFull(r.src, chunk)
+	last := err == io.EOF || err == io.ErrUnexpectedEOF
+	if err != nil && !last {
+		return err
+	}
+	if !last {
+		_, err = r.src.Peek(1)
+		last = err == io.EOF
+	}
+	ad := adChunk
+	if last {
+		ad = adLastChunk
+	}
+	out, err := r.aead.Open(chunk[:0], chunkNonce(r.aead, r.counter), chunk[:n], ad)
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't decrypt the file; it's corrupted or truncated")
+	}
+	r.out = out
+	r.counter++
+	r.done = last
+	return nil
+}
@@ -0,0 +1,119 @@
+package fstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	"golang.org/x/crypto/blake2s"
+	. "gopkg.in/check.v1"
+)
+
+type EncryptedStoreSuite struct {
+	dir   string
+	plain *FSStore
+	s     *EncryptedStore
+}
+
+var _ = Suite(&EncryptedStoreSuite{})
+
+func (s *EncryptedStoreSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.plain = NewFSStore(s.dir, "http://localhost:8000/v1/files", []byte("secret"))
+	var errs error
+	s.s, errs = NewEncryptedStore(s.plain, bytes.Repeat([]byte{1}, 32))
+	c.Assert(errs, IsNil)
+}
+
+func (s *EncryptedStoreSuite) readAll(c *C, st Store, key string) ([]byte, error) {
+	r, errs := st.Get(context.Background(), key)
+	c.Assert(errs, IsNil)
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (s *EncryptedStoreSuite) TestRoundTrip(c *C) {
+	ctx := context.Background()
+	for _, size := range []int{0, 10, encChunkSize - 1, encChunkSize, encChunkSize + 5, 3 * encChunkSize} {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		c.Assert(err, IsNil)
+		c.Assert(s.s.Put(ctx, "a.pdf", bytes.NewReader(data)), IsNil)
+
+		raw, err := s.readAll(c, s.plain, "a.pdf")
+		c.Assert(err, IsNil)
+		c.Check(strings.HasPrefix(string(raw), encMagic), IsTrue)
+		if size > 0 {
+			c.Check(bytes.Contains(raw, data), IsFalse, Comment("size ", size))
+		}
+
+		out, err := s.readAll(c, s.s, "a.pdf")
+		c.Assert(err, IsNil, Comment("size ", size))
+		c.Check(bytes.Equal(out, data), IsTrue, Comment("size ", size))
+	}
+}
+
+func (s *EncryptedStoreSuite) TestTampering(c *C) {
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("contract"), encChunkSize/4)
+	c.Assert(s.s.Put(ctx, "a.pdf", bytes.NewReader(data)), IsNil)
+	fpath := filepath.Join(s.dir, "a.pdf")
+	raw, err := ioutil.ReadFile(fpath)
+	c.Assert(err, IsNil)
+
+	// truncated after the first chunk
+	headerLen := len(raw) - len(data) - 2*s.s.master.Overhead()
+	c.Assert(ioutil.WriteFile(fpath, raw[:headerLen+encChunkSize+s.s.master.Overhead()], 0600), IsNil)
+	_, err = s.readAll(c, s.s, "a.pdf")
+	c.Check(err, ErrorContains, "corrupted or truncated")
+
+	// modified content
+	modified := append([]byte{}, raw...)
+	modified[len(modified)-1] ^= 1
+	c.Assert(ioutil.WriteFile(fpath, modified, 0600), IsNil)
+	_, err = s.readAll(c, s.s, "a.pdf")
+	c.Check(err, ErrorContains, "corrupted or truncated")
+
+	// other master key
+	c.Assert(ioutil.WriteFile(fpath, raw, 0600), IsNil)
+	other, errs := NewEncryptedStore(s.plain, bytes.Repeat([]byte{2}, 32))
+	c.Assert(errs, IsNil)
+	_, errs = other.Get(ctx, "a.pdf")
+	c.Check(errs, ErrorContains, "Can't decrypt the file key")
+}
+
+func (s *EncryptedStoreSuite) TestPlaintextFallback(c *C) {
+	ctx := context.Background()
+	c.Assert(s.plain.Put(ctx, "old.pdf", strings.NewReader("old content")), IsNil)
+	out, err := s.readAll(c, s.s, "old.pdf")
+	c.Assert(err, IsNil)
+	c.Check(string(out), Equals, "old content")
+
+	_, errs := s.s.Get(ctx, "missing.pdf")
+	c.Check(errs, Equals, ErrNotFound)
+	_, errs = s.s.SignedURL("old.pdf", time.Minute)
+	c.Check(errs, Equals, ErrNoSignedURL)
+}
+
+func (s *EncryptedStoreSuite) TestSaveDocHashesPlaintext(c *C) {
+	data := []byte("bill of lading")
+	name, hash, errs := SaveDoc(context.Background(), s.s, bytes.NewReader(data), "bol.pdf", "trade-docs")
+	c.Assert(errs, IsNil)
+	expected := blake2s.Sum256(data)
+	c.Check(hash, Equals, hex.EncodeToString(expected[:]))
+
+	raw, err := ioutil.ReadFile(filepath.Join(s.dir, "trade-docs", name))
+	c.Assert(err, IsNil)
+	c.Check(bytes.Contains(raw, data), IsFalse)
+}
+
+func (s *EncryptedStoreSuite) TestInvalidMasterKey(c *C) {
+	_, errs := NewEncryptedStore(s.plain, []byte("short"))
+	c.Check(errs, ErrorContains, "32 bytes")
+}
@@ -0,0 +1,99 @@
+package fstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// FSStore stores files in a local directory.
+// Signed URLs point to `baseURL` and are verified with `Verify` using an HMAC secret.
+type FSStore struct {
+	dir     string
+	baseURL string
+	secret  []byte
+}
+
+// NewFSStore creates a filesystem store in the dir. Signed URLs are disabled when
+// the secret is empty.
+func NewFSStore(dir, baseURL string, secret []byte) *FSStore {
+	return &FSStore{dir, baseURL, secret}
+}
+
+// path maps the key to a file in the store directory; keys can't escape the directory
+func (s *FSStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+// Put implements Store interface
+func (s *FSStore) Put(ctx context.Context, key string, src io.Reader) errstack.E {
+	fpath := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(fpath), os.ModePerm); err != nil {
+		return errstack.WrapAsInf(err, "Can't create upload directory")
+	}
+	dest, err := os.Create(fpath)
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't create file")
+	}
+	defer errstack.CallAndLog(logger, dest.Close) // idempotent, okay to call twice
+	_, err = io.Copy(dest, src)
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't write to file")
+	}
+	return errstack.WrapAsInf(dest.Close(), "Can't write to file")
+}
+
+// Get implements Store interface
+func (s *FSStore) Get(ctx context.Context, key string) (io.ReadCloser, errstack.E) {
+	f, err := os.Open(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, errstack.WrapAsInf(err, "Can't open file")
+}
+
+// SignedURL implements Store interface
+func (s *FSStore) SignedURL(key string, ttl time.Duration) (string, errstack.E) {
+	if len(s.secret) == 0 {
+		return "", errstack.NewDomain("Signed URLs are not configured for the file store")
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	q := url.Values{}
+	q.Set("expires", expires)
+	q.Set("signature", s.sign(key, expires))
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+// Verify checks the signed URL parameters of the key
+func (s *FSStore) Verify(key, expires, signature string, now time.Time) errstack.E {
+	if len(s.secret) == 0 {
+		return errstack.NewDomain("Signed URLs are not configured for the file store")
+	}
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errstack.WrapAsReq(err, "Malformed URL expiry time")
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, expires))) {
+		return errstack.NewReq("Invalid URL signature")
+	}
+	if now.Unix() > exp {
+		return errstack.NewReq("URL has expired")
+	}
+	return nil
+}
+
+func (s *FSStore) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	_, _ = mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,64 @@
+package fstore
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type FSStoreSuite struct {
+	dir string
+	s   *FSStore
+}
+
+var _ = Suite(&FSStoreSuite{})
+
+func (s *FSStoreSuite) SetUpTest(c *C) {
+	s.dir = c.MkDir()
+	s.s = NewFSStore(s.dir, "http://localhost:8000/v1/files", []byte("secret"))
+}
+
+func (s *FSStoreSuite) TestPutGet(c *C) {
+	ctx := context.Background()
+	c.Assert(s.s.Put(ctx, "trade-docs/a.pdf", strings.NewReader("content")), IsNil)
+	_, err := os.Stat(filepath.Join(s.dir, "trade-docs", "a.pdf"))
+	c.Check(err, IsNil)
+
+	r, errs := s.s.Get(ctx, "trade-docs/a.pdf")
+	c.Assert(errs, IsNil)
+	data, err := ioutil.ReadAll(r)
+	c.Check(err, IsNil)
+	c.Check(string(data), Equals, "content")
+	c.Check(r.Close(), IsNil)
+
+	_, errs = s.s.Get(ctx, "trade-docs/missing.pdf")
+	c.Check(errs, Equals, ErrNotFound)
+
+	// keys can't escape the store directory
+	c.Check(s.s.path("../../etc/passwd"), Equals, filepath.Join(s.dir, "etc", "passwd"))
+}
+
+func (s *FSStoreSuite) TestSignedURL(c *C) {
+	u, errs := s.s.SignedURL("trade-docs/a b.pdf", time.Minute)
+	c.Assert(errs, IsNil)
+	c.Check(strings.HasPrefix(u, "http://localhost:8000/v1/files/trade-docs/a%20b.pdf?"), IsTrue, Comment(u))
+	parsed, err := url.Parse(u)
+	c.Assert(err, IsNil)
+	q := parsed.Query()
+	now := time.Now()
+	c.Check(s.s.Verify("trade-docs/a b.pdf", q.Get("expires"), q.Get("signature"), now), IsNil)
+	c.Check(s.s.Verify("trade-docs/other.pdf", q.Get("expires"), q.Get("signature"), now),
+		ErrorContains, "Invalid URL signature")
+	c.Check(s.s.Verify("trade-docs/a b.pdf", q.Get("expires"), q.Get("signature"), now.Add(2*time.Minute)),
+		ErrorContains, "expired")
+
+	_, errs = NewFSStore(s.dir, "", nil).SignedURL("a.pdf", time.Minute)
+	c.Check(errs, NotNil)
+}
@@ -0,0 +1,52 @@
+package fstore
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// ErrNotFound is returned when a stored object doesn't exist
+var ErrNotFound = errstack.NewReq("File not found")
+
+// ErrNoSignedURL is returned by stores which can't serve the objects directly
+var ErrNoSignedURL = errstack.NewDomain("The file store doesn't support signed URLs")
+
+// Store is a file storage backend. Objects are identified by slash separated keys.
+type Store interface {
+	// Put writes the object under the key
+	Put(ctx context.Context, key string, src io.Reader) errstack.E
+	// Get opens the object. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, errstack.E)
+	// SignedURL returns a URL which allows to download the object without
+	// further authorization until the ttl passes.
+	SignedURL(key string, ttl time.Duration) (string, errstack.E)
+}
+
+// Default is the store used by the web server handlers
+var Default Store = NewFSStore("/tmp/cerealia-files", "", nil)
+
+// Docs is the store of the trade documents. It's usually the Default store wrapped
+// in the EncryptedStore.
+var Docs = Default
+
+// Serve writes the object to the response. The Content-Type is detected from the key extension.
+func Serve(ctx context.Context, w http.ResponseWriter, s Store, key string) errstack.E {
+	r, errs := s.Get(ctx, key)
+	if errs != nil {
+		return errs
+	}
+	defer errstack.CallAndLog(logger, r.Close)
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		w.Header().Set("Content-Type", ct)
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	_, err := io.Copy(w, r)
+	return errstack.WrapAsInf(err, "Can't send the file")
+}
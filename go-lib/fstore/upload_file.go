@@ -2,10 +2,11 @@
 package fstore
 
 import (
+	"context"
 	"encoding/hex"
 	"hash"
 	"io"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
@@ -33,21 +34,21 @@ func getFileExtension(filename string, fileType []string) (string, error) {
 	return fx, nil
 }
 
-// SaveDoc saves document file to disk and calculates it's hash
-func SaveDoc(src io.Reader, filename string, storageDir string) (string, string, errstack.E) {
+// SaveDoc saves document file in the store dir and calculates it's hash
+func SaveDoc(ctx context.Context, s Store, src io.Reader, filename string, dir string) (string, string, errstack.E) {
 	copy, hasher := readerHasher(src)
-	nakedFilename, err := Save(copy, filename, storageDir, docTypes)
+	nakedFilename, err := Save(ctx, s, copy, filename, dir, docTypes)
 	return nakedFilename, hex.EncodeToString(hasher.Sum(nil)), err
 }
 
-// SaveAvatar saves avatar image file to disk
-func SaveAvatar(src io.Reader, filename string, storageDir string) (string, errstack.E) {
-	return Save(src, filename, storageDir, avatarTypes)
+// SaveAvatar saves avatar image file in the store dir
+func SaveAvatar(ctx context.Context, s Store, src io.Reader, filename string, dir string) (string, errstack.E) {
+	return Save(ctx, s, src, filename, dir, avatarTypes)
 }
 
-// Save saves file to the filesystem.
-// Returns saved filename without storage path and file hash
-func Save(src io.Reader, filename string, storageDir string, fileTypes []string) (string, errstack.E) {
+// Save saves file in the store under the dir with a new unique name.
+// Returns saved filename without the dir.
+func Save(ctx context.Context, s Store, src io.Reader, filename string, dir string, fileTypes []string) (string, errstack.E) {
 	if srcClose, ok := src.(io.ReadCloser); ok {
 		defer errstack.CallAndLog(logger, srcClose.Close)
 	}
@@ -56,17 +57,7 @@ func Save(src io.Reader, filename string, storageDir string, fileTypes []string)
 		return "", errstack.WrapAsInf(err, "Bad file extension")
 	}
 	nakedFilename := uuid.NewUUID().String() + fileExtension
-	absoluteFilename := filepath.Join(storageDir, nakedFilename)
-	if err := os.MkdirAll(storageDir, os.ModePerm); err != nil {
-		return "", errstack.WrapAsInf(err, "Can't create upload directory")
-	}
-	dest, err := os.Create(absoluteFilename)
-	if err != nil {
-		return "", errstack.WrapAsInf(err, "Can't create file")
-	}
-	defer errstack.CallAndLog(logger, dest.Close) // idempotent, okay to call twice
-	_, err = io.Copy(dest, src)
-	return nakedFilename, errstack.WrapAsInf(err, "Can't write to file")
+	return nakedFilename, s.Put(ctx, path.Join(dir, nakedFilename), src)
 }
 
 func readerHasher(input io.Reader) (io.Reader, hash.Hash) {
@@ -2,6 +2,7 @@ package fstore
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -89,17 +90,17 @@ func filetypecheck(filename, typ string, c *C, isPositive bool) {
 	// create new request context as for form file upload
 	req, err := newfileUploadRequest("", nil, filefieldname, filename)
 	c.Check(err, IsNil, Comment("The file info to upload is not correct. please make sure the correct file info"))
-	context := routing.NewContext(nil, req, nil)
+	rctx := routing.NewContext(nil, req, nil)
 
 	// validate the file size as given limit size
-	context.Request.Body = http.MaxBytesReader(context.Response, context.Request.Body, int64(maxfilesize))
-	err = context.Request.ParseMultipartForm(int64(maxfilesize))
+	rctx.Request.Body = http.MaxBytesReader(rctx.Response, rctx.Request.Body, int64(maxfilesize))
+	err = rctx.Request.ParseMultipartForm(int64(maxfilesize))
 	c.Check(err, IsNil, Comment("Filesize is too big.", err))
 
 	// validate the uploading of the files
-	file, handler, err := context.Request.FormFile(filefieldname)
+	file, handler, err := rctx.Request.FormFile(filefieldname)
 	c.Assert(err, IsNil)
-	_, _, errs := SaveDoc(file, handler.Filename, fileuploadpath)
+	_, _, errs := SaveDoc(context.Background(), NewFSStore(fileuploadpath, "", nil), file, handler.Filename, "")
 	if isPositive {
 		c.Check(errs, IsNil, Comment("Filetype ", typ, " must be supported.", errs))
 	} else {
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/auth"
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"github.com/99designs/gqlgen/graphql"
+	driver "github.com/arangodb/go-driver"
+	"github.com/go-ozzo/ozzo-routing"
+	"github.com/robert-zaremba/errstack"
+)
+
+// ctxAPIKey is a key used to store the API key in a context
+var ctxAPIKey = &contextKey{"api-key"}
+
+// apiKeyFieldScopes lists the GraphQL root fields allowed to API keys with the scope.
+// Other fields are denied to API keys.
+var apiKeyFieldScopes = map[string]model.APIKeyScope{
+	"Query.trade":                 model.APIKeyScopeReadTrades,
+	"Query.trades":                model.APIKeyScopeReadTrades,
+	"Query.tradesConnection":      model.APIKeyScopeReadTrades,
+	"Query.tradeStageDocHistory":  model.APIKeyScopeReadTrades,
+	"Query.tradeTemplates":        model.APIKeyScopeReadTrades,
+	"Query.tradeTemplateVersions": model.APIKeyScopeReadTrades,
+	"Query.stellarNet":            model.APIKeyScopeReadTrades,
+
+	"Mutation.mkTradeStageDocTx": model.APIKeyScopeUploadDocs,
+
+	"Query.tradeOffer":             model.APIKeyScopeManageOffers,
+	"Query.tradeOffers":            model.APIKeyScopeManageOffers,
+	"Query.tradeOffersConnection":  model.APIKeyScopeManageOffers,
+	"Query.tradeOfferMatches":      model.APIKeyScopeManageOffers,
+	"Query.tradeOfferBids":         model.APIKeyScopeManageOffers,
+	"Mutation.tradeOfferCreate":    model.APIKeyScopeManageOffers,
+	"Mutation.tradeOfferClose":     model.APIKeyScopeManageOffers,
+	"Mutation.tradeOfferAccept":    model.APIKeyScopeManageOffers,
+	"Mutation.tradeOfferBid":       model.APIKeyScopeManageOffers,
+	"Mutation.tradeOfferBidAccept": model.APIKeyScopeManageOffers,
+	"Mutation.tradeOfferBidReject": model.APIKeyScopeManageOffers,
+}
+
+// authorizeAPIKey validates the API key and returns it with the key creator, who is
+// the acting user. The creator must still be accepted and allowed to manage the keys of
+// the key organization.
+func authorizeAPIKey(ctx context.Context, db driver.Database, key string) (*model.APIKey, *model.User, errstack.E) {
+	id, hash, errs := auth.ParseAPIKey(key)
+	if errs != nil {
+		return nil, nil, model.ErrInvalidAPIKey
+	}
+	k, errs := dal.GetAPIKey(ctx, db, id)
+	if errs != nil {
+		return nil, nil, errs
+	}
+	now := time.Now()
+	if subtle.ConstantTimeCompare([]byte(k.KeyHash), []byte(hash)) != 1 || !k.IsActive(now) {
+		return nil, nil, model.ErrInvalidAPIKey
+	}
+	u, errs := dal.GetUser(ctx, db, k.CreatedBy)
+	if errs != nil {
+		return nil, nil, errs
+	}
+	if !u.IsAccepted() || !u.CanInOrg(k.OrgID, model.PermissionAPIKeyManage) {
+		return nil, nil, model.ErrInvalidAPIKey
+	}
+	if k.ShouldTouch(now) {
+		if errs = dal.TouchAPIKey(ctx, db, k.ID, now); errs != nil {
+			logger.Error("Can't update API key last use time", "key", k.ID, errs)
+		}
+	}
+	return k, u, nil
+}
+
+// GetAuthAPIKey returns the API key of the request or nil when the request is not
+// authenticated with an API key.
+func GetAuthAPIKey(ctx context.Context) *model.APIKey {
+	k, _ := ctx.Value(ctxAPIKey).(*model.APIKey)
+	return k
+}
+
+// APIKeyOrgID returns the organization of the request API key or an empty string when
+// the request is not authenticated with an API key.
+func APIKeyOrgID(ctx context.Context) string {
+	if k := GetAuthAPIKey(ctx); k != nil {
+		return k.OrgID
+	}
+	return ""
+}
+
+// AssertAPIKeyTrade denies access to trades of other organizations than the organization
+// of the request API key.
+func AssertAPIKeyTrade(ctx context.Context, t *model.Trade) errstack.E {
+	orgID := APIKeyOrgID(ctx)
+	if orgID != "" && (t.OrgID == nil || *t.OrgID != orgID) {
+		return model.ErrUnauthorized
+	}
+	return nil
+}
+
+// RequireScope is a REST handler which rejects API key requests without the scope
+func RequireScope(scope model.APIKeyScope) routing.Handler {
+	return func(c *routing.Context) error {
+		k := GetAuthAPIKey(c.Request.Context())
+		if k != nil && !k.HasScope(scope) {
+			return model.ErrUnauthorized
+		}
+		return nil
+	}
+}
+
+// NoAPIKey is a REST handler which rejects requests authenticated with an API key
+func NoAPIKey(c *routing.Context) error {
+	if GetAuthAPIKey(c.Request.Context()) != nil {
+		return model.ErrUnauthorized
+	}
+	return nil
+}
+
+// APIKeyScopes is a GraphQL resolver middleware. Requests authenticated with an API key
+// can only resolve the root fields allowed by the key scopes.
+func APIKeyScopes(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	k := GetAuthAPIKey(ctx)
+	if k == nil {
+		return next(ctx)
+	}
+	rctx := graphql.GetResolverContext(ctx)
+	switch rctx.Object {
+	case "Query", "Mutation", "Subscription":
+	default:
+		// nested fields are allowed when the root field is allowed
+		return next(ctx)
+	}
+	scope, ok := apiKeyFieldScopes[rctx.Object+"."+rctx.Field.Name]
+	if !ok || !k.HasScope(scope) {
+		return nil, model.ErrUnauthorized
+	}
+	return next(ctx)
+}
@@ -2,10 +2,12 @@ package middleware
 
 import (
 	"context"
+	"time"
 
 	"bitbucket.org/cerealia/apps/go-lib/auth"
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"github.com/99designs/gqlgen/handler"
 	driver "github.com/arangodb/go-driver"
 	"github.com/go-ozzo/ozzo-routing"
 	"github.com/robert-zaremba/errstack"
@@ -18,28 +20,59 @@ type contextKey struct {
 // ctxUserKey is a key used to store UserID in a context
 var ctxUserKey = &contextKey{"user-id"}
 
-// WithAuth attaches userID in the context
+// ctxSessionKey is a key used to store the user session ID in a context
+var ctxSessionKey = &contextKey{"session-id"}
+
+// WithAuth attaches userID in the context. Tokens of revoked or expired sessions
+// are ignored. API keys authenticate the key creator and are attached to the context.
 func WithAuth(db driver.Database) routing.Handler {
 	return func(c *routing.Context) error {
 		tokenStr, _ := auth.TokenFromAuthHeader(c.Request)
 		if tokenStr == "" {
 			return nil
 		}
-		userid, _ := auth.Authorize(tokenStr)
-		if userid == "" {
+		ctx := c.Request.Context()
+		if auth.IsAPIKey(tokenStr) {
+			k, u, errs := authorizeAPIKey(ctx, db, tokenStr)
+			if errs != nil {
+				return nil
+			}
+			ctx = context.WithValue(ctx, ctxUserKey, u)
+			ctx = context.WithValue(ctx, ctxAPIKey, k)
+			c.Request = c.Request.WithContext(ctx)
 			return nil
 		}
-		ctx := c.Request.Context()
-		u, errs := dal.GetUser(ctx, db, userid)
+		claims, errs := authorize(ctx, db, tokenStr)
+		if errs != nil {
+			return nil
+		}
+		u, errs := dal.GetUser(ctx, db, claims.UserID)
 		if errs != nil {
 			logger.Error("Get User Failed", errs)
 		}
 		ctx = context.WithValue(ctx, ctxUserKey, u)
+		ctx = context.WithValue(ctx, ctxSessionKey, claims.SessionID)
 		c.Request = c.Request.WithContext(ctx)
 		return nil
 	}
 }
 
+// authorize validates the token and checks if its session is still active
+func authorize(ctx context.Context, db driver.Database, tokenStr string) (*auth.AppClaims, errstack.E) {
+	claims, errs := auth.Authorize(tokenStr)
+	if errs != nil {
+		return nil, errs
+	}
+	s, errs := dal.GetUserSession(ctx, db, claims.SessionID)
+	if errs != nil {
+		return nil, errs
+	}
+	if s.UserID != claims.UserID || !s.IsActive(time.Now()) {
+		return nil, model.ErrInvalidSession
+	}
+	return claims, nil
+}
+
 // GetAuthUser returns User from the current request context.
 // If there is no authenticated user returns nil and unauthenticated error.
 func GetAuthUser(ctx context.Context) (*model.User, errstack.E) {
@@ -50,3 +83,28 @@ func GetAuthUser(ctx context.Context) (*model.User, errstack.E) {
 	u, _ := raw.(*model.User)
 	return u, nil
 }
+
+// GetAuthSessionID returns the session ID of the authenticated user or empty string.
+func GetAuthSessionID(ctx context.Context) string {
+	sid, _ := ctx.Value(ctxSessionKey).(string)
+	return sid
+}
+
+// GetWebsocketAuthUser returns User authenticated with the token sent in the websocket
+// `connection_init` payload. Browsers can't set HTTP headers for websocket connections,
+// so GraphQL subscription clients pass the "Authorization" value in the init payload.
+// If the request is already authenticated by WithAuth, the request user is returned.
+func GetWebsocketAuthUser(ctx context.Context, db driver.Database) (*model.User, errstack.E) {
+	if u, errs := GetAuthUser(ctx); errs == nil && u != nil {
+		return u, nil
+	}
+	tokenStr, _ := auth.TokenFromBearer(handler.GetInitPayload(ctx).Authorization())
+	if tokenStr == "" {
+		return nil, model.ErrUnauthenticated
+	}
+	claims, errs := authorize(ctx, db, tokenStr)
+	if errs != nil {
+		return nil, errs
+	}
+	return dal.GetUser(ctx, db, claims.UserID)
+}
@@ -0,0 +1,73 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-ozzo/ozzo-routing"
+)
+
+// ctxClientIPKey is a key used to store the client IP in a context
+var ctxClientIPKey = &contextKey{"client-ip"}
+
+// WithClientIP attaches the client IP in the context
+func WithClientIP(c *routing.Context) error {
+	ctx := context.WithValue(c.Request.Context(), ctxClientIPKey, clientIP(c.Request))
+	c.Request = c.Request.WithContext(ctx)
+	return nil
+}
+
+// clientIP returns the IP of the request client. Proxy headers are trusted only when
+// the request comes from a local or a private network proxy, otherwise clients could
+// fake their IP.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !(ip.IsLoopback() || isPrivateIP(ip)) {
+		return host
+	}
+	if h := strings.TrimSpace(r.Header.Get("X-Real-IP")); h != "" {
+		return h
+	}
+	// the last address is added by our proxy
+	if h := r.Header.Get("X-Forwarded-For"); h != "" {
+		ips := strings.Split(h, ",")
+		return strings.TrimSpace(ips[len(ips)-1])
+	}
+	return host
+}
+
+var privateNets = []*net.IPNet{
+	mustParseCIDR("10.0.0.0/8"),
+	mustParseCIDR("172.16.0.0/12"),
+	mustParseCIDR("192.168.0.0/16"),
+	mustParseCIDR("fc00::/7"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+func isPrivateIP(ip net.IP) bool {
+	for _, n := range privateNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetClientIP returns the client IP attached by WithClientIP
+func GetClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(ctxClientIPKey).(string)
+	return ip
+}
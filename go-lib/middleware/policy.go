@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/go-ozzo/ozzo-routing"
+)
+
+// HasPermission implements the @hasPermission GraphQL directive. It requires the
+// authenticated user to have a role granting the permission.
+func HasPermission(ctx context.Context, obj interface{}, next graphql.Resolver, permission model.Permission) (interface{}, error) {
+	u, errs := GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.Can(permission) {
+		return nil, model.ErrUnauthorized
+	}
+	return next(ctx)
+}
+
+// RequirePermission is a REST handler which rejects users without the permission
+func RequirePermission(permission model.Permission) routing.Handler {
+	return func(c *routing.Context) error {
+		u, errs := GetAuthUser(c.Request.Context())
+		if errs != nil {
+			return errs
+		}
+		if !u.Can(permission) {
+			return model.ErrUnauthorized
+		}
+		return nil
+	}
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"context"
+	"io/ioutil"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"github.com/vektah/gqlparser"
+	"github.com/vektah/gqlparser/ast"
+	. "gopkg.in/check.v1"
+)
+
+type PolicySuite struct{}
+
+var _ = Suite(&PolicySuite{})
+
+var allRoles = []model.UserRole{model.UserRoleTrader, model.UserRoleModerator, model.UserRoleOrgAdmin,
+	model.UserRoleViewer, model.UserRoleAuditor, model.UserRoleComplianceOfficer}
+
+var (
+	tradeWriters  = []model.UserRole{model.UserRoleTrader, model.UserRoleModerator}
+	orgManagers   = []model.UserRole{model.UserRoleModerator, model.UserRoleOrgAdmin}
+	keyManagers   = []model.UserRole{model.UserRoleTrader, model.UserRoleModerator, model.UserRoleOrgAdmin}
+	userApprovers = []model.UserRole{model.UserRoleModerator, model.UserRoleComplianceOfficer}
+	moderators    = []model.UserRole{model.UserRoleModerator}
+)
+
+// mutationPolicy lists the permission and the roles allowed for every mutation.
+// Mutations without a permission are public, only require authentication or check
+// the user role in the organization.
+var mutationPolicy = []struct {
+	name  string
+	perm  model.Permission
+	roles []model.UserRole
+}{
+	{"userSignup", "", allRoles},
+	{"userLogin", "", allRoles},
+	{"userTOTPLogin", "", allRoles},
+	{"userKeyLoginChallenge", "", allRoles},
+	{"userLoginWithKey", "", allRoles},
+	{"userTokenRefresh", "", allRoles},
+	{"userLogout", "", allRoles},
+	{"userLogoutAll", "", allRoles},
+	{"userPasswordChange", "", allRoles},
+	{"userEmailVerify", "", allRoles},
+	{"userEmailVerificationResend", "", allRoles},
+	{"userPasswordResetRequest", "", allRoles},
+	{"userPasswordResetConfirm", "", allRoles},
+	{"userEmailChange", "", allRoles},
+	{"userTOTPEnroll", "", allRoles},
+	{"userTOTPEnable", "", allRoles},
+	{"userTOTPDisable", "", allRoles},
+	{"userTOTPVerify", "", allRoles},
+	{"userProfileUpdate", "", allRoles},
+	{"userHDWalletRegister", "", allRoles},
+	{"userHDWalletKeysAdd", "", allRoles},
+	{"userDefaultWalletSet", "", allRoles},
+	{"userNotificationPrefs", "", allRoles},
+	{"organizationCreate", model.PermissionOrgCreate, keyManagers},
+	{"orgInvite", "", allRoles},
+	{"orgInvitationAccept", "", allRoles},
+	{"orgInvitationReject", "", allRoles},
+	{"orgJoinRequest", "", allRoles},
+	{"orgJoinRequestApprove", "", allRoles},
+	{"orgJoinRequestReject", "", allRoles},
+	{"orgMemberRoleSet", "", allRoles},
+	{"orgMemberRemove", "", allRoles},
+	{"apiKeyCreate", model.PermissionAPIKeyManage, keyManagers},
+	{"apiKeyRevoke", model.PermissionAPIKeyManage, keyManagers},
+
+	{"tradeCreate", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageAddReq", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageAddReqApprove", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageAddReqReject", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageDelReq", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageDelReqApprove", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageDelReqReject", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageCloseReq", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageCloseReqApprove", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageCloseReqReject", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageSetExpireTime", model.PermissionTradeWrite, tradeWriters},
+	{"tradeCloseReq", model.PermissionTradeWrite, tradeWriters},
+	{"tradeCloseReqApprove", model.PermissionTradeWrite, tradeWriters},
+	{"tradeCloseReqReject", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageDocApprove", model.PermissionTradeWrite, tradeWriters},
+	{"tradeStageDocReject", model.PermissionTradeWrite, tradeWriters},
+	{"tradeModerationClaim", model.PermissionTradeModerate, moderators},
+	{"tradeModerationRelease", model.PermissionTradeModerate, moderators},
+	{"tradeModerationDone", model.PermissionTradeModerate, moderators},
+	{"tradeStageModerationClaim", model.PermissionTradeModerate, moderators},
+	{"tradeStageModerationRelease", model.PermissionTradeModerate, moderators},
+
+	{"tradeTemplateCreate", model.PermissionTradeModerate, moderators},
+	{"tradeTemplateUpdate", model.PermissionTradeModerate, moderators},
+	{"tradeTemplateArchive", model.PermissionTradeModerate, moderators},
+
+	{"tradeOfferCreate", model.PermissionOfferWrite, tradeWriters},
+	{"tradeOfferClose", model.PermissionOfferWrite, tradeWriters},
+	{"tradeOfferAccept", model.PermissionOfferWrite, tradeWriters},
+	{"tradeOfferBid", model.PermissionOfferWrite, tradeWriters},
+	{"tradeOfferBidAccept", model.PermissionOfferWrite, tradeWriters},
+	{"tradeOfferBidReject", model.PermissionOfferWrite, tradeWriters},
+
+	{"notificationDismiss", "", allRoles},
+
+	{"mkTradeStageDocTx", model.PermissionTradeWrite, tradeWriters},
+	{"mkTradeStageCloseTx", model.PermissionTradeWrite, tradeWriters},
+	{"mkTradeStageAddTx", model.PermissionTradeWrite, tradeWriters},
+	{"mkTradeCloseTx", model.PermissionTradeWrite, tradeWriters},
+
+	{"adminApproveUser", model.PermissionUserApprove, userApprovers},
+	{"adminUserUnlock", model.PermissionUserApprove, userApprovers},
+	{"adminApproveOrg", model.PermissionUserApprove, userApprovers},
+	{"adminScreeningHitReview", model.PermissionUserApprove, userApprovers},
+	{"adminOrgTOTPRequire", model.PermissionOrgManage, orgManagers},
+}
+
+func hasRole(roles []model.UserRole, role model.UserRole) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func loadMutations(c *C) ast.FieldList {
+	input, err := ioutil.ReadFile("../../api/websrv_schema.graphql")
+	c.Assert(err, IsNil)
+	schema, gerr := gqlparser.LoadSchema(&ast.Source{Name: "websrv_schema.graphql", Input: string(input)})
+	c.Assert(gerr, IsNil)
+	return schema.Mutation.Fields
+}
+
+func fieldPermission(f *ast.FieldDefinition) model.Permission {
+	d := f.Directives.ForName("hasPermission")
+	if d == nil {
+		return ""
+	}
+	return model.Permission(d.Arguments.ForName("permission").Value.Raw)
+}
+
+// resolveField runs the field resolver behind the @hasPermission directive of the field,
+// the same way the generated executor does, and reports whether the resolver was called.
+func resolveField(ctx context.Context, f *ast.FieldDefinition) (bool, error) {
+	called := false
+	next := func(ctx context.Context) (interface{}, error) {
+		called = true
+		return nil, nil
+	}
+	perm := fieldPermission(f)
+	if perm == "" {
+		_, err := next(ctx)
+		return called, err
+	}
+	_, err := HasPermission(ctx, nil, next, perm)
+	return called, err
+}
+
+func (s *PolicySuite) TestMutationPermissions(c *C) {
+	mutations := loadMutations(c)
+	c.Assert(mutations, HasLen, len(mutationPolicy), Commentf("every mutation must be listed in mutationPolicy"))
+	for _, tc := range mutationPolicy {
+		f := mutations.ForName(tc.name)
+		c.Assert(f, NotNil, Commentf(tc.name))
+		c.Check(fieldPermission(f), Equals, tc.perm, Commentf(tc.name))
+		for _, role := range allRoles {
+			u := &model.User{Roles: []model.UserRole{role}}
+			ctx := context.WithValue(context.Background(), ctxUserKey, u)
+			called, err := resolveField(ctx, f)
+			allowed := hasRole(tc.roles, role)
+			c.Check(called, Equals, allowed, Commentf("%s %s", tc.name, role))
+			if !allowed {
+				c.Check(err, Equals, model.ErrUnauthorized, Commentf("%s %s", tc.name, role))
+			}
+		}
+		if tc.perm != "" {
+			called, err := resolveField(context.Background(), f)
+			c.Check(called, Equals, false, Commentf(tc.name))
+			c.Check(err, Equals, model.ErrUnauthenticated, Commentf(tc.name))
+		}
+	}
+}
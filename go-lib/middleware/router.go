@@ -33,6 +33,7 @@ func StdRouter(db driver.Database, isProduction bool) (*routing.Router, *routing
 	}
 	r := router.Group("")
 	r.Use(
+		WithClientIP,
 		WithAuth(db),
 		content.TypeNegotiator(content.HTML, content.JSON),
 		slash.Remover(http.StatusMovedPermanently))
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/validation"
+	"github.com/robert-zaremba/errstack"
+)
+
+// APIKeyTouchInterval limits how often the API key last use time is updated
+const APIKeyTouchInterval = time.Minute
+
+// SetID implements dal.HasID interface
+func (k *APIKey) SetID(id string) {
+	k.ID = id
+}
+
+// IsActive checks if the key can be used to authenticate requests
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// HasScope checks if the key grants the scope
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// ShouldTouch checks if the last use time is outdated
+func (k *APIKey) ShouldTouch(now time.Time) bool {
+	return k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= APIKeyTouchInterval
+}
+
+// Validate checks the API key input
+func (in *APIKeyInput) Validate(now time.Time) errstack.E {
+	errb := errstack.NewBuilder()
+	validation.NotEmpty(in.OrgID, errb.Putter("orgID"))
+	validation.NotEmpty(in.Name, errb.Putter("name"))
+	if len(in.Scopes) == 0 {
+		errb.Put("scopes", "at least one scope is required")
+	}
+	if in.ExpiresAt != nil && !in.ExpiresAt.After(now) {
+		errb.Put("expiresAt", "must be in the future")
+	}
+	return errb.ToReqErr()
+}
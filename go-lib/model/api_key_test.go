@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestAPIKey(c *C) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	k := APIKey{Scopes: []APIKeyScope{APIKeyScopeReadTrades}}
+	c.Check(k.IsActive(now), IsTrue)
+	c.Check(k.HasScope(APIKeyScopeReadTrades), IsTrue)
+	c.Check(k.HasScope(APIKeyScopeUploadDocs), IsFalse)
+
+	k.ExpiresAt = &later
+	c.Check(k.IsActive(now), IsTrue)
+	c.Check(k.IsActive(later), IsFalse)
+	k.RevokedAt = &now
+	c.Check(k.IsActive(now), IsFalse)
+
+	c.Check(k.ShouldTouch(now), IsTrue)
+	k.LastUsedAt = &now
+	c.Check(k.ShouldTouch(now.Add(time.Second)), IsFalse)
+	c.Check(k.ShouldTouch(now.Add(APIKeyTouchInterval)), IsTrue)
+}
+
+func (s *S) TestAPIKeyInputValidate(c *C) {
+	now := time.Now()
+	in := APIKeyInput{OrgID: "1", Name: "ERP", Scopes: []APIKeyScope{APIKeyScopeUploadDocs}}
+	c.Check(in.Validate(now), IsNil)
+	in.ExpiresAt = &now
+	c.Check(in.Validate(now), ErrorContains, "expiresAt")
+	c.Check((&APIKeyInput{}).Validate(now), ErrorContains, "scopes")
+}
@@ -0,0 +1,60 @@
+package dal
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// InsertAPIKey inserts a new API key
+func InsertAPIKey(ctx context.Context, db driver.Database, k *model.APIKey) errstack.E {
+	_, errs := insertHasID(ctx, dbconst.ColAPIKeys, k, db)
+	return errs
+}
+
+// GetAPIKey gets an API key by its id. Returns model.ErrInvalidAPIKey when the key
+// doesn't exist.
+func GetAPIKey(ctx context.Context, db driver.Database, id string) (*model.APIKey, errstack.E) {
+	var k model.APIKey
+	errs := DBGetOneFromColl(ctx, &k, id, dbconst.ColAPIKeys, db)
+	if IsNotFound(errs) || errs == model.ErrNoID {
+		return nil, model.ErrInvalidAPIKey
+	}
+	return &k, errs
+}
+
+// GetOrgAPIKeys returns all API keys of the organization, newest first
+func GetOrgAPIKeys(ctx context.Context, db driver.Database, orgID string) ([]model.APIKey, errstack.E) {
+	q := "FOR d IN api_keys FILTER d.orgID == @orgID SORT d.createdAt DESC RETURN d"
+	ks := []model.APIKey{}
+	return ks, DBQueryMany(ctx, &ks, q, map[string]interface{}{"orgID": orgID}, db)
+}
+
+// RevokeAPIKey revokes the API key. Returns model.ErrInvalidAPIKey when the key is
+// already revoked.
+func RevokeAPIKey(ctx context.Context, db driver.Database, id string) errstack.E {
+	q := `FOR d IN api_keys FILTER d._key == @key && d.revokedAt == null
+	UPDATE d WITH {revokedAt: @now} IN api_keys
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key": id,
+		"now": time.Now().UTC()}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to revoke API key")
+	}
+	if len(keys) == 0 {
+		return model.ErrInvalidAPIKey
+	}
+	return nil
+}
+
+// TouchAPIKey sets the last use time of the API key
+func TouchAPIKey(ctx context.Context, db driver.Database, id string, now time.Time) errstack.E {
+	_, errs := UpdateDoc(ctx, db, dbconst.ColAPIKeys, id, map[string]interface{}{"lastUsedAt": now.UTC()})
+	return errs
+}
@@ -17,6 +17,16 @@ func InsertTradeDoc(ctx context.Context, db driver.Database, entity *model.Doc,
 	return InsertIntoGraph(ctx, db, dbconst.ColDocs, dbconst.ColDocEdges, entity, edge)
 }
 
+// InsertDocSupersedesEdge links the new version of the doc to the previous version
+func InsertDocSupersedesEdge(ctx context.Context, db driver.Database, docID, supersededDocID string) errstack.E {
+	edge := model.DocSupersedesEdgeDO{
+		FullDocID:        dbconst.ColDocs.FullID(docID),
+		FullSupersededID: dbconst.ColDocs.FullID(supersededDocID),
+	}
+	_, errs := InsertAny(ctx, dbconst.ColDocSupersedesEdges, &edge, db)
+	return errs
+}
+
 // GetDoc fetches doc from DB
 func GetDoc(ctx context.Context, db driver.Database, id string) (*model.Doc, errstack.E) {
 	var d model.Doc
@@ -25,6 +35,10 @@ func GetDoc(ctx context.Context, db driver.Database, id string) (*model.Doc, err
 
 // InsertOfferDoc inserts new trade Offer document
 func InsertOfferDoc(ctx context.Context, db driver.Database, fi model.FileInfo, uid string) (*model.Doc, errstack.E) {
+	return insertFileDoc(ctx, db, fi, uid)
+}
+
+func insertFileDoc(ctx context.Context, db driver.Database, fi model.FileInfo, uid string) (*model.Doc, errstack.E) {
 	d := model.Doc{
 		Name:      fi.FileName,
 		Type:      filepath.Ext(fi.FileName)[1:],
@@ -0,0 +1,180 @@
+package dal
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// InsertOrgMemberReq inserts a new invitation or join request
+func InsertOrgMemberReq(ctx context.Context, db driver.Database, r *model.OrgMemberReq) errstack.E {
+	_, errs := insertHasID(ctx, dbconst.ColOrgMemberReqs, r, db)
+	return errs
+}
+
+// GetOrgMemberReq gets an invitation or a join request by its id
+func GetOrgMemberReq(ctx context.Context, db driver.Database, id string) (*model.OrgMemberReq, errstack.E) {
+	var r model.OrgMemberReq
+	return &r, DBGetOneFromColl(ctx, &r, id, dbconst.ColOrgMemberReqs, db)
+}
+
+// GetOrgPendingMemberReqs returns pending invitations and join requests of the organization
+func GetOrgPendingMemberReqs(ctx context.Context, db driver.Database, orgID string) ([]model.OrgMemberReq, errstack.E) {
+	q := `FOR d IN org_member_reqs FILTER d.orgID == @orgID && d.status == "pending"
+	SORT d.createdAt DESC RETURN d`
+	rs := []model.OrgMemberReq{}
+	return rs, DBQueryMany(ctx, &rs, q, map[string]interface{}{"orgID": orgID}, db)
+}
+
+// GetPendingOrgInvitations returns pending invitations sent to the emails
+func GetPendingOrgInvitations(ctx context.Context, db driver.Database, emails []string) ([]model.OrgMemberReq, errstack.E) {
+	q := `FOR d IN org_member_reqs FILTER d.kind == "invitation" && d.status == "pending" && d.email IN @emails
+	SORT d.createdAt DESC RETURN d`
+	rs := []model.OrgMemberReq{}
+	return rs, DBQueryMany(ctx, &rs, q, map[string]interface{}{"emails": emails}, db)
+}
+
+// HasPendingOrgMemberReq checks if there is a pending invitation of the email or
+// a pending join request of the user to the organization
+func HasPendingOrgMemberReq(ctx context.Context, db driver.Database, orgID string, email, userID *string) (bool, errstack.E) {
+	q := `FOR d IN org_member_reqs FILTER d.orgID == @orgID && d.status == "pending" &&
+		((d.kind == "invitation" && d.email == @email) || (d.kind == "joinRequest" && d.userID == @userID))
+	LIMIT 1 RETURN d._key`
+	vars := map[string]interface{}{
+		"orgID":  orgID,
+		"email":  email,
+		"userID": userID}
+	var keys []string
+	errs := DBQueryMany(ctx, &keys, q, vars, db)
+	return len(keys) > 0, errs
+}
+
+// RespondOrgMemberReq resolves the pending invitation or join request. userID is the user
+// accepting the invitation. Returns model.ErrOrgMemberReqResolved when the request
+// isn't pending.
+func RespondOrgMemberReq(ctx context.Context, db driver.Database, r *model.OrgMemberReq,
+	status model.Approval, respondedBy string, userID *string) errstack.E {
+	now := time.Now().UTC()
+	q := `FOR d IN org_member_reqs FILTER d._key == @key && d.status == "pending"
+	UPDATE d WITH {status: @status, role: @role, userID: @userID, respondedBy: @by, respondedAt: @now} IN org_member_reqs
+	RETURN NEW._key`
+	if userID == nil {
+		userID = r.UserID
+	}
+	vars := map[string]interface{}{
+		"key":    r.ID,
+		"status": status,
+		"role":   r.Role,
+		"userID": userID,
+		"by":     respondedBy,
+		"now":    now}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to update the organization request")
+	}
+	if len(keys) == 0 {
+		return model.ErrOrgMemberReqResolved
+	}
+	r.Status, r.UserID, r.RespondedBy, r.RespondedAt = status, userID, &respondedBy, &now
+	return nil
+}
+
+// SetOrgMember adds the user to the organization or changes the user role in it.
+// It returns model.ErrOrgLastAdmin when the organization would lose its last admin.
+func SetOrgMember(ctx context.Context, db driver.Database, userID, orgID string, role model.OrgRole) errstack.E {
+	return updateOrgMember(ctx, db, userID, orgID,
+		"UPDATE u WITH {organizations: {[@orgID]: @role}} IN users", map[string]interface{}{"role": role},
+		role != model.OrgRoleAdmin)
+}
+
+// RemoveOrgMember removes the user from the organization.
+// It returns model.ErrOrgLastAdmin when the user is the last organization admin.
+func RemoveOrgMember(ctx context.Context, db driver.Database, userID, orgID string) errstack.E {
+	return updateOrgMember(ctx, db, userID, orgID,
+		"UPDATE u WITH {organizations: UNSET(u.organizations, @orgID)} IN users OPTIONS {mergeObjects: false}",
+		map[string]interface{}{}, true)
+}
+
+// updateOrgMember applies the update to the user. When the user loses the admin role,
+// the update is applied only if the organization has another admin.
+// The organization document is updated in the same query, so concurrent member changes
+// of the organization conflict instead of removing all the admins.
+func updateOrgMember(ctx context.Context, db driver.Database, userID, orgID, update string,
+	vars map[string]interface{}, demotes bool) errstack.E {
+	q := `LET admins = LENGTH(FOR m IN users FILTER m.organizations[@orgID] == "admin" RETURN 1)
+	FOR u IN users FILTER u._key == @uid && (!@demotes || u.organizations[@orgID] != "admin" || admins > 1)
+	` + update + `
+	UPDATE @orgID WITH {membersUpdatedAt: DATE_ISO8601(DATE_NOW())} IN organizations
+	RETURN u._key`
+	vars["uid"] = userID
+	vars["orgID"] = orgID
+	vars["demotes"] = demotes
+	var keys []string
+	errs := DBQueryMany(ctx, &keys, q, vars, db)
+	if errs != nil {
+		if driver.IsConflict(errstack.Cause(errs)) {
+			return model.ErrOrgMembersChanged
+		}
+		return errs
+	}
+	if len(keys) == 0 {
+		return model.ErrOrgLastAdmin
+	}
+	return nil
+}
+
+// GetOrgMembers returns users who belong to the organization
+func GetOrgMembers(ctx context.Context, db driver.Database, orgID string) ([]model.User, errstack.E) {
+	q := "FOR u IN users FILTER HAS(u.organizations, @orgID) SORT u.lastname, u.firstname RETURN u"
+	us := []model.User{}
+	return us, DBQueryMany(ctx, &us, q, map[string]interface{}{"orgID": orgID}, db)
+}
+
+// InsertOrgAuditEntry records the organization membership change
+func InsertOrgAuditEntry(ctx context.Context, db driver.Database, e *model.OrgAuditEntry) errstack.E {
+	_, errs := insertHasID(ctx, dbconst.ColOrgAuditLog, e, db)
+	return errs
+}
+
+// GetOrgAuditLog returns membership changes of the organization, newest first
+func GetOrgAuditLog(ctx context.Context, db driver.Database, orgID string) ([]model.OrgAuditEntry, errstack.E) {
+	q := "FOR d IN org_audit_log FILTER d.orgID == @orgID SORT d.createdAt DESC RETURN d"
+	es := []model.OrgAuditEntry{}
+	return es, DBQueryMany(ctx, &es, q, map[string]interface{}{"orgID": orgID}, db)
+}
+
+// InsertOrgKYBDoc inserts the uploaded file and adds it to the organization KYB documents
+func InsertOrgKYBDoc(ctx context.Context, db driver.Database, orgID string, kind model.KYBDocKind,
+	fi model.FileInfo, uid string) (*model.KYBDoc, errstack.E) {
+	d, errs := insertFileDoc(ctx, db, fi, uid)
+	if errs != nil {
+		return nil, errs
+	}
+	kd := model.KYBDoc{
+		DocID:     d.ID,
+		Kind:      kind,
+		Name:      d.Name,
+		CreatedBy: uid,
+		CreatedAt: d.CreatedAt,
+	}
+	q := `FOR o IN organizations FILTER o._key == @orgID
+	UPDATE o WITH {kybDocs: PUSH(o.kybDocs || [], @doc)} IN organizations`
+	vars := map[string]interface{}{
+		"orgID": orgID,
+		"doc":   kd}
+	return &kd, DBExec(ctx, q, vars, db)
+}
+
+// AddOrgApproval appends the KYB review to the organization approvals
+func AddOrgApproval(ctx context.Context, db driver.Database, orgID string, a model.AccessApproval) errstack.E {
+	q := `FOR o IN organizations FILTER o._key == @orgID
+	UPDATE o WITH {approvals: PUSH(o.approvals || [], @approval)} IN organizations`
+	vars := map[string]interface{}{
+		"orgID":    orgID,
+		"approval": a}
+	return DBExec(ctx, q, vars, db)
+}
@@ -0,0 +1,161 @@
+package dal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// Page size limits
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// cursor points to a document in a sorted list. The list is sorted by a numeric
+// sort value and the document key.
+type cursor struct {
+	Sort float64 `json:"s"`
+	Key  string  `json:"k"`
+}
+
+func (c cursor) String() string {
+	b, _ := json.Marshal(c) // never fails for this struct
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseCursor(s string) (cursor, errstack.E) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, errstack.WrapAsReq(err, "Malformed pagination cursor")
+	}
+	err = json.Unmarshal(b, &c)
+	return c, errstack.WrapAsReq(err, "Malformed pagination cursor")
+}
+
+// pageItem is a query result item. It contains the document and its cursor sort value.
+type pageItem struct {
+	Doc  json.RawMessage `json:"doc"`
+	Key  string          `json:"key"`
+	Sort float64         `json:"sort"`
+}
+
+// pageQuery builds AQL queries for Relay style pagination of a collection
+type pageQuery struct {
+	col     dbconst.Col
+	filters []string
+	vars    map[string]interface{}
+	// sortBy is a numeric AQL expression of the document `d`
+	sortBy string
+	desc   bool
+}
+
+func newPageQuery(col dbconst.Col, sortBy string, desc bool) *pageQuery {
+	return &pageQuery{col: col, sortBy: sortBy, desc: desc, vars: map[string]interface{}{}}
+}
+
+// filter adds an AQL filter expression. `vars` are bind variable name, value pairs.
+func (q *pageQuery) filter(expr string, vars ...interface{}) {
+	q.filters = append(q.filters, expr)
+	for i := 0; i+1 < len(vars); i += 2 {
+		q.vars[vars[i].(string)] = vars[i+1]
+	}
+}
+
+func (q *pageQuery) filterQuery() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "FOR d IN %s", q.col)
+	for _, f := range q.filters {
+		b.WriteString("\n\tFILTER " + f)
+	}
+	return b.String()
+}
+
+// count returns the number of all documents matching the filters
+func (q *pageQuery) count(ctx context.Context, db driver.Database) (int, errstack.E) {
+	var n int
+	query := fmt.Sprintf("RETURN COUNT(%s\n\tRETURN 1)", q.filterQuery())
+	return n, DBQueryOne(ctx, &n, query, q.vars, db)
+}
+
+// query returns the AQL query and bind variables to fetch `first` documents after the cursor
+func (q *pageQuery) query(first int, after *cursor) (string, map[string]interface{}) {
+	vars := make(map[string]interface{}, len(q.vars)+3)
+	for k, v := range q.vars {
+		vars[k] = v
+	}
+	dir, cmp := "ASC", ">"
+	if q.desc {
+		dir, cmp = "DESC", "<"
+	}
+	var b strings.Builder
+	b.WriteString(q.filterQuery())
+	fmt.Fprintf(&b, "\n\tLET sortVal = %s", q.sortBy)
+	if after != nil {
+		fmt.Fprintf(&b, "\n\tFILTER sortVal %s @afterSort || (sortVal == @afterSort && d._key %s @afterKey)", cmp, cmp)
+		vars["afterSort"] = after.Sort
+		vars["afterKey"] = after.Key
+	}
+	fmt.Fprintf(&b, "\n\tSORT sortVal %s, d._key %s\n\tLIMIT @limit", dir, dir)
+	b.WriteString("\n\tRETURN {doc: d, key: d._key, sort: sortVal}")
+	vars["limit"] = first + 1
+	return b.String(), vars
+}
+
+// page fetches documents after the cursor and returns the items and the page info
+func (q *pageQuery) page(ctx context.Context, db driver.Database, first *int, after *string) ([]pageItem, model.PageInfo, errstack.E) {
+	var pi model.PageInfo
+	n, errs := pageSize(first)
+	if errs != nil {
+		return nil, pi, errs
+	}
+	var c *cursor
+	if after != nil {
+		ac, errs := parseCursor(*after)
+		if errs != nil {
+			return nil, pi, errs
+		}
+		c = &ac
+	}
+	query, vars := q.query(n, c)
+	var items []pageItem
+	if errs = DBQueryMany(ctx, &items, query, vars, db); errs != nil {
+		return nil, pi, errs
+	}
+	if len(items) > n {
+		items = items[:n]
+		pi.HasNextPage = true
+	}
+	if len(items) > 0 {
+		last := items[len(items)-1].cursor().String()
+		pi.EndCursor = &last
+	}
+	return items, pi, nil
+}
+
+func (it pageItem) cursor() cursor {
+	return cursor{it.Sort, it.Key}
+}
+
+func pageSize(first *int) (int, errstack.E) {
+	if first == nil {
+		return DefaultPageSize, nil
+	}
+	if *first < 0 || *first > MaxPageSize {
+		return 0, errstack.NewReqF("`first` must be between 0 and %d", MaxPageSize)
+	}
+	return *first, nil
+}
+
+func timestamp(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
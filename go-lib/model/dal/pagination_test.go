@@ -0,0 +1,107 @@
+package dal
+
+import (
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type PageSuite struct{}
+
+var _ = Suite(&PageSuite{})
+
+func (s *PageSuite) TestCursor(c *C) {
+	cur := cursor{1537878205833, "1993134"}
+	parsed, errs := parseCursor(cur.String())
+	c.Assert(errs, IsNil)
+	c.Check(parsed, Equals, cur)
+
+	_, errs = parseCursor("not a cursor!")
+	c.Check(errs, ErrorContains, "Malformed pagination cursor")
+}
+
+func (s *PageSuite) TestPageSize(c *C) {
+	n, errs := pageSize(nil)
+	c.Check(errs, IsNil)
+	c.Check(n, Equals, DefaultPageSize)
+	big, negative := MaxPageSize+1, -1
+	_, errs = pageSize(&big)
+	c.Check(errs, ErrorContains, "`first` must be between")
+	_, errs = pageSize(&negative)
+	c.Check(errs, NotNil)
+}
+
+func (s *PageSuite) TestQuery(c *C) {
+	q := newPageQuery(dbconst.ColTrades, "DATE_TIMESTAMP(d.createdAt)", true)
+	q.filter("d.templateID == @templateID", "templateID", "t1")
+	query, vars := q.query(10, &cursor{10, "k"})
+	c.Check(query, Contains, "FOR d IN trades\n\tFILTER d.templateID == @templateID")
+	c.Check(query, Contains, "FILTER sortVal < @afterSort || (sortVal == @afterSort && d._key < @afterKey)")
+	c.Check(query, Contains, "SORT sortVal DESC, d._key DESC")
+	c.Check(vars, DeepEquals, map[string]interface{}{
+		"templateID": "t1", "afterSort": 10.0, "afterKey": "k", "limit": 11})
+	// the query vars are not modified
+	c.Check(q.vars, HasLen, 1)
+
+	q.desc = false
+	query, vars = q.query(5, nil)
+	c.Check(query, Not(Contains), "@afterSort")
+	c.Check(query, Contains, "SORT sortVal ASC, d._key ASC")
+	c.Check(vars["limit"], Equals, 6)
+}
+
+func tradeIDs(conn *model.TradeConnection) []string {
+	ids := []string{}
+	for _, e := range conn.Edges {
+		ids = append(ids, e.Node.ID)
+	}
+	return ids
+}
+
+func (s *DalSuite) TestGetTradesPage(c *C) {
+	first := 2
+	conn, errs := GetTradesPage(testctx, s.db, "", "", nil, nil, &first, nil)
+	c.Assert(errs, IsNil)
+	c.Check(conn.TotalCount, Equals, 3)
+	c.Check(tradeIDs(conn), DeepEquals, []string{"3155708", "1993135"})
+	c.Check(conn.PageInfo.HasNextPage, IsTrue)
+
+	conn, errs = GetTradesPage(testctx, s.db, "", "", nil, nil, &first, conn.PageInfo.EndCursor)
+	c.Assert(errs, IsNil)
+	c.Check(tradeIDs(conn), DeepEquals, []string{"1993134"})
+	c.Check(conn.PageInfo.HasNextPage, IsFalse)
+
+	open := model.TradeStatusOpen
+	asc := model.TradeOrderCreatedAsc
+	conn, errs = GetTradesPage(testctx, s.db, "2", "", &model.TradeFilter{Status: &open}, &asc, nil, nil)
+	c.Assert(errs, IsNil)
+	c.Check(conn.TotalCount, Equals, 2)
+	c.Check(tradeIDs(conn), DeepEquals, []string{"1993134", "1993135"})
+
+	conn, errs = GetTradesPage(testctx, s.db, "100", "", nil, nil, nil, nil)
+	c.Assert(errs, IsNil)
+	c.Check(conn.Edges, HasLen, 0)
+	c.Check(conn.PageInfo.EndCursor, IsNil)
+
+	// fixture trades don't belong to any organization
+	conn, errs = GetTradesPage(testctx, s.db, "", "org1", nil, nil, nil, nil)
+	c.Assert(errs, IsNil)
+	c.Check(conn.TotalCount, Equals, 0)
+}
+
+func (s *DalSuite) TestGetTradeOffersPage(c *C) {
+	isSell := true
+	order := model.TradeOfferOrderPriceDesc
+	conn, errs := GetTradeOffersPage(testctx, s.db, &model.TradeOfferFilter{IsSell: &isSell}, &order, nil, nil)
+	c.Assert(errs, IsNil)
+	c.Check(conn.TotalCount, Equals, 2)
+	c.Assert(conn.Edges, HasLen, 2)
+	c.Check(conn.Edges[0].Node.ID, Equals, "2")
+	c.Check(conn.Edges[1].Node.ID, Equals, "3")
+
+	maxPrice := 1250.0
+	conn, errs = GetTradeOffersPage(testctx, s.db, &model.TradeOfferFilter{PriceMax: &maxPrice}, nil, nil, nil)
+	c.Assert(errs, IsNil)
+	c.Check(conn.TotalCount, Equals, 1)
+}
@@ -0,0 +1,92 @@
+package dal
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// ReplaceSanctionList removes the entries of the list and inserts the new ones
+func ReplaceSanctionList(ctx context.Context, db driver.Database, list string, es []model.SanctionEntry) errstack.E {
+	q := "FOR e IN sanction_entries FILTER e.list == @list REMOVE e IN sanction_entries"
+	if errs := DBExec(ctx, q, map[string]interface{}{"list": list}, db); errs != nil {
+		return errs
+	}
+	if len(es) == 0 {
+		return nil
+	}
+	col, err := GetCollByName(ctx, db, dbconst.ColSanctionEntries)
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't open the sanction entries collection")
+	}
+	_, errSlice, err := col.CreateDocuments(ctx, es)
+	if err == nil {
+		err = errSlice.FirstNonNil()
+	}
+	return errstack.WrapAsInf(err, "Can't insert the sanction entries")
+}
+
+// GetSanctionEntries returns entries of all imported sanctions lists
+func GetSanctionEntries(ctx context.Context, db driver.Database) ([]model.SanctionEntry, errstack.E) {
+	es := []model.SanctionEntry{}
+	return es, DBQueryMany(ctx, &es, "FOR e IN sanction_entries RETURN e", nil, db)
+}
+
+// InsertScreeningHit inserts the hit unless the subject was already matched with
+// the list entry. Returns true when the hit was inserted.
+func InsertScreeningHit(ctx context.Context, db driver.Database, h *model.ScreeningHit) (bool, errstack.E) {
+	q := `UPSERT {subjectID: @hit.subjectID, list: @hit.list, entryRef: @hit.entryRef}
+	INSERT @hit UPDATE {} IN screening_hits
+	RETURN OLD ? null : NEW._key`
+	var key *string
+	if errs := DBQueryOne(ctx, &key, q, map[string]interface{}{"hit": h}, db); errs != nil {
+		return false, errs
+	}
+	if key == nil {
+		return false, nil
+	}
+	h.ID = *key
+	return true, nil
+}
+
+// GetScreeningHit gets a screening hit by its id
+func GetScreeningHit(ctx context.Context, db driver.Database, id string) (*model.ScreeningHit, errstack.E) {
+	var h model.ScreeningHit
+	return &h, DBGetOneFromColl(ctx, &h, id, dbconst.ColScreeningHits, db)
+}
+
+// GetScreeningHits returns screening hits with the status, newest first.
+// All hits are returned when the status is nil.
+func GetScreeningHits(ctx context.Context, db driver.Database, status *model.ScreeningHitStatus) ([]model.ScreeningHit, errstack.E) {
+	q := `FOR h IN screening_hits FILTER @status == null || h.status == @status
+	SORT h.createdAt DESC RETURN h`
+	hs := []model.ScreeningHit{}
+	return hs, DBQueryMany(ctx, &hs, q, map[string]interface{}{"status": status}, db)
+}
+
+// ReviewScreeningHit sets the moderator decision on the hit
+func ReviewScreeningHit(ctx context.Context, db driver.Database, h *model.ScreeningHit,
+	status model.ScreeningHitStatus, reviewer, reason string) errstack.E {
+	now := time.Now().UTC()
+	diff := map[string]interface{}{
+		"status":     status,
+		"reviewedBy": reviewer,
+		"reviewedAt": now,
+		"reason":     reason}
+	if _, errs := UpdateDoc(ctx, db, dbconst.ColScreeningHits, h.ID, diff); errs != nil {
+		return errs
+	}
+	h.Status, h.ReviewedBy, h.ReviewedAt, h.Reason = status, &reviewer, &now, &reason
+	return nil
+}
+
+// HasBlockingScreeningHit checks if any of the subjects has a pending or confirmed hit
+func HasBlockingScreeningHit(ctx context.Context, db driver.Database, subjectIDs []string) (bool, errstack.E) {
+	var exists bool
+	q := existsQuery(`FOR h IN screening_hits FILTER h.subjectID IN @ids && h.status != "cleared"`)
+	return exists, DBQueryOne(ctx, &exists, q, map[string]interface{}{"ids": subjectIDs}, db)
+}
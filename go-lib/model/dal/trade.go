@@ -3,6 +3,7 @@ package dal
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -43,16 +44,91 @@ func GetTradeOfDocument(ctx context.Context, db driver.Database, docID string) (
 	return &tradeDest, err
 }
 
-// GetTrades gets all trade by user id
-func GetTrades(ctx context.Context, db driver.Database, uid string) ([]model.Trade, errstack.E) {
-	q := "for d in trades filter d.buyer.userID == @uid || d.seller.userID == @uid sort d.createdAt return d"
+// GetTrades gets all trade by user id.
+// When orgID is not empty, only the trades of the organization are returned.
+func GetTrades(ctx context.Context, db driver.Database, uid, orgID string) ([]model.Trade, errstack.E) {
+	q := "for d in trades filter " + userTradesFilter
 	vars := map[string]interface{}{
 		"uid": uid}
+	if orgID != "" {
+		q += " filter d.orgID == @orgID"
+		vars["orgID"] = orgID
+	}
+	q += " sort d.createdAt return d"
 	var ts []model.Trade
 	err := DBQueryMany(ctx, &ts, q, vars, db)
 	return ts, err
 }
 
+// userTradesFilter matches trades of the user, including trades where the user is an
+// additional participant, and of the user organizations.
+// Viewers of an organization can read its trades too.
+const userTradesFilter = `(@uid IN [d.buyer.userID, d.seller.userID] ||
+	@uid IN (d.participants || [])[*].userID ||
+	(d.orgID != null && HAS(DOCUMENT("users", @uid).organizations || {}, d.orgID)))`
+
+// GetTradesPage returns a page of trades matching the filter.
+// When uid is not empty, only the trades of the user and the user organizations are returned.
+// When orgID is not empty, only the trades of the organization are returned.
+func GetTradesPage(ctx context.Context, db driver.Database, uid, orgID string, f *model.TradeFilter,
+	order *model.TradeOrder, first *int, after *string) (*model.TradeConnection, errstack.E) {
+	desc := order == nil || *order == model.TradeOrderCreatedDesc
+	q := newPageQuery(dbconst.ColTrades, "DATE_TIMESTAMP(d.createdAt)", desc)
+	if uid != "" {
+		q.filter(userTradesFilter, "uid", uid)
+	}
+	if orgID != "" {
+		q.filter("d.orgID == @orgID", "orgID", orgID)
+	}
+	if f != nil {
+		filterTrades(q, f)
+	}
+	items, pi, errs := q.page(ctx, db, first, after)
+	if errs != nil {
+		return nil, errs
+	}
+	total, errs := q.count(ctx, db)
+	if errs != nil {
+		return nil, errs
+	}
+	conn := model.TradeConnection{
+		Edges:      make([]model.TradeEdge, len(items)),
+		PageInfo:   pi,
+		TotalCount: total,
+	}
+	for i, it := range items {
+		conn.Edges[i].Cursor = it.cursor().String()
+		if err := json.Unmarshal(it.Doc, &conn.Edges[i].Node); err != nil {
+			return nil, errstack.WrapAsDomain(err, "Can't read trade")
+		}
+	}
+	return &conn, nil
+}
+
+func filterTrades(q *pageQuery, f *model.TradeFilter) {
+	const closed = `LENGTH(d.closeReqs) > 0 && LAST(d.closeReqs).status == "approved"`
+	if f.Status != nil {
+		if *f.Status == model.TradeStatusClosed {
+			q.filter(closed)
+		} else {
+			q.filter("!(" + closed + ")")
+		}
+	}
+	if f.Counterparty != nil {
+		q.filter("@counterparty IN APPEND([d.buyer.userID, d.seller.userID], (d.participants || [])[*].userID)",
+			"counterparty", *f.Counterparty)
+	}
+	if f.TemplateID != nil {
+		q.filter("d.templateID == @templateID", "templateID", *f.TemplateID)
+	}
+	if f.CreatedFrom != nil {
+		q.filter("DATE_TIMESTAMP(d.createdAt) >= @createdFrom", "createdFrom", timestamp(*f.CreatedFrom))
+	}
+	if f.CreatedTo != nil {
+		q.filter("DATE_TIMESTAMP(d.createdAt) <= @createdTo", "createdTo", timestamp(*f.CreatedTo))
+	}
+}
+
 // GetAllTrades gets all trade data
 func GetAllTrades(ctx context.Context, db driver.Database) ([]model.Trade, errstack.E) {
 	q := "for d in trades sort d.createdAt return d"
@@ -60,14 +136,238 @@ func GetAllTrades(ctx context.Context, db driver.Database) ([]model.Trade, errst
 	return ts, DBQueryMany(ctx, &ts, q, nil, db)
 }
 
+// GetTradesExpiringBefore gets open trades with a pending stage document
+// or an open stage which expires before the given time. As in Trade.UpdateExpiry,
+// closed and deleted stages and docs without the expire time are skipped.
+func GetTradesExpiringBefore(ctx context.Context, db driver.Database, until time.Time) ([]model.Trade, errstack.E) {
+	q := `FOR t IN trades
+	FILTER LENGTH(t.closeReqs) == 0 || LAST(t.closeReqs).status != "approved"
+	LET open = (FOR s IN t.stages
+		FILTER LENGTH(s.closeReqs) == 0 || LAST(s.closeReqs).status != "approved"
+		FILTER LENGTH(s.delReqs) == 0 || LAST(s.delReqs).status != "approved"
+		RETURN s)
+	LET docs = (FOR s IN open FOR d IN s.docs
+		FILTER d.status == "pending" && DATE_YEAR(d.expiresAt) > 1 && DATE_TIMESTAMP(d.expiresAt) < @until
+		RETURN 1)
+	LET stages = (FOR s IN open
+		FILTER s.expiresAt != null && DATE_TIMESTAMP(s.expiresAt) < @until
+		FILTER LENGTH(s.closeReqs) == 0 || LAST(s.closeReqs).status != "expired"
+		RETURN 1)
+	FILTER LENGTH(docs) > 0 || LENGTH(stages) > 0
+	RETURN t`
+	vars := map[string]interface{}{
+		"until": until.UnixNano() / int64(time.Millisecond)}
+	var ts []model.Trade
+	return ts, DBQueryMany(ctx, &ts, q, vars, db)
+}
+
+// UpdateTradeExpiry saves the expiry event of the stage or the stage doc. prev is the
+// trade before the event. Only the expiry fields of the item are updated and only if the
+// item didn't change since it was read, so concurrent approvals aren't reverted and only
+// one server instance applies the event. Returns false when the item has changed.
+func UpdateTradeExpiry(ctx context.Context, db driver.Database, prev, t *model.Trade, e model.ExpiryEvent) (bool, errstack.E) {
+	ps, s := prev.Stages[e.StageIdx], t.Stages[e.StageIdx]
+	vars := map[string]interface{}{
+		"key": t.ID,
+		"s":   e.StageIdx}
+	var q string
+	if e.DocIdx != nil {
+		q = `FOR t IN trades FILTER t._key == @key
+		LET d = t.stages[@s].docs[@d]
+		FILTER d.status == "pending" && (d.alertedBefore || 0) == @prevAlerted
+		UPDATE t WITH {stages: (FOR i IN 0..LENGTH(t.stages)-1 RETURN i != @s ? t.stages[i] :
+			MERGE(t.stages[i], {docs: (FOR j IN 0..LENGTH(t.stages[i].docs)-1 RETURN j != @d ? t.stages[i].docs[j] :
+				MERGE(t.stages[i].docs[j], {status: @status, alertedBefore: @alerted}))}))} IN trades
+		RETURN NEW._key`
+		pd, d := ps.Docs[*e.DocIdx], s.Docs[*e.DocIdx]
+		vars["d"] = *e.DocIdx
+		vars["prevAlerted"] = pd.AlertedBefore
+		vars["status"] = d.Status
+		vars["alerted"] = d.AlertedBefore
+	} else {
+		q = `FOR t IN trades FILTER t._key == @key
+		LET s = t.stages[@s]
+		FILTER (s.alertedBefore || 0) == @prevAlerted
+		FILTER LENGTH(s.closeReqs || []) == @prevCloseReqs && (LAST(s.closeReqs).status || "") == @prevCloseStatus
+		FILTER LENGTH(s.delReqs || []) == @prevDelReqs
+		UPDATE t WITH {stages: (FOR i IN 0..LENGTH(t.stages)-1 RETURN i != @s ? t.stages[i] :
+			MERGE(t.stages[i], {closeReqs: @closeReqs, alertedBefore: @alerted}))} IN trades
+		RETURN NEW._key`
+		vars["prevAlerted"] = ps.AlertedBefore
+		vars["prevCloseReqs"] = len(ps.CloseReqs)
+		vars["prevCloseStatus"] = ""
+		if l := len(ps.CloseReqs); l > 0 {
+			vars["prevCloseStatus"] = ps.CloseReqs[l-1].Status
+		}
+		vars["prevDelReqs"] = len(ps.DelReqs)
+		vars["closeReqs"] = s.CloseReqs
+		vars["alerted"] = s.AlertedBefore
+	}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return false, errstack.WrapAsInf(errs, "Failed to update the trade expiry")
+	}
+	return len(keys) > 0, nil
+}
+
+// GetModerationQueue gets open trades with pending approvals, ordered by the oldest
+// pending approval
+func GetModerationQueue(ctx context.Context, db driver.Database) ([]model.ModerationQueueItem, errstack.E) {
+	q := `FOR t IN trades
+	FILTER LENGTH(t.closeReqs) == 0 || LAST(t.closeReqs).status != "approved"
+	LET docs = (FOR s IN t.stages FOR d IN s.docs FILTER d.status == "pending"
+		RETURN {id: d.docID, createdAt: DOCUMENT("docs", d.docID).createdAt})
+	LET reqs = UNION(t.stageAddReqs || [], t.closeReqs || [],
+		FLATTEN(t.stages[*].delReqs), FLATTEN(t.stages[*].closeReqs))
+	FILTER LENGTH(docs) > 0 || "pending" IN reqs[*].status
+	RETURN {trade: t, docs}`
+	var rows []struct {
+		Trade model.Trade `json:"trade"`
+		Docs  []struct {
+			ID        string    `json:"id"`
+			CreatedAt time.Time `json:"createdAt"`
+		} `json:"docs"`
+	}
+	if errs := DBQueryMany(ctx, &rows, q, nil, db); errs != nil {
+		return nil, errs
+	}
+	queue := []model.ModerationQueueItem{}
+	for _, r := range rows {
+		docCreatedAt := make(map[string]time.Time, len(r.Docs))
+		for _, d := range r.Docs {
+			docCreatedAt[d.ID] = d.CreatedAt
+		}
+		n, oldest := r.Trade.PendingApprovals(docCreatedAt)
+		if n > 0 {
+			queue = append(queue, model.ModerationQueueItem{
+				Trade: r.Trade, PendingCount: n, OldestPendingAt: oldest})
+		}
+	}
+	model.SortModerationQueue(queue)
+	return queue, nil
+}
+
+// UpdateTradeModerator sets the trade moderator and the moderation status. It fails
+// with ErrModerationClaimed when the trade isn't claimed by prevUID anymore.
+func UpdateTradeModerator(ctx context.Context, db driver.Database, t *model.Trade, prevUID string) errstack.E {
+	q := `FOR t IN trades FILTER t._key == @key && (t.moderator.userID || "") == @prev
+	UPDATE t WITH {moderator: @moderator, moderating: @moderating} IN trades
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key":        t.ID,
+		"prev":       prevUID,
+		"moderator":  t.Moderator,
+		"moderating": t.Moderating}
+	return updateModerator(ctx, db, q, vars)
+}
+
+// UpdateStageModerator sets the moderator of the trade stage. It fails with
+// ErrModerationClaimed when the stage isn't claimed by prevUID anymore.
+func UpdateStageModerator(ctx context.Context, db driver.Database, t *model.Trade, idx uint, prevUID string) errstack.E {
+	q := `FOR t IN trades FILTER t._key == @key && (t.stages[@idx].moderator.userID || "") == @prev
+	UPDATE t WITH {stages: (FOR i IN 0..LENGTH(t.stages)-1
+		RETURN i == @idx ? MERGE(t.stages[i], {moderator: @moderator}) : t.stages[i])} IN trades
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key":       t.ID,
+		"idx":       idx,
+		"prev":      prevUID,
+		"moderator": t.Stages[idx].Moderator}
+	return updateModerator(ctx, db, q, vars)
+}
+
+func updateModerator(ctx context.Context, db driver.Database, q string, vars map[string]interface{}) errstack.E {
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to update the trade moderator")
+	}
+	if len(keys) == 0 {
+		return model.ErrModerationClaimed
+	}
+	return nil
+}
+
+// SupersedeTradeStageDoc links the stage doc to its new version with the by index. It
+// fails with ErrStageDocSuperseded when the doc already has a newer version, so
+// concurrent uploads can't replace the same doc.
+func SupersedeTradeStageDoc(ctx context.Context, db driver.Database, tid string, stageIdx, docIdx, by uint) errstack.E {
+	return setStageDocSupersededBy(ctx, db, tid, stageIdx, docIdx, nil, &by)
+}
+
+// ResetTradeStageDocSupersede removes the link set by SupersedeTradeStageDoc when the
+// new version wasn't stored
+func ResetTradeStageDocSupersede(ctx context.Context, db driver.Database, tid string, stageIdx, docIdx, by uint) errstack.E {
+	return setStageDocSupersededBy(ctx, db, tid, stageIdx, docIdx, &by, nil)
+}
+
+func setStageDocSupersededBy(ctx context.Context, db driver.Database, tid string, stageIdx, docIdx uint, prev, next *uint) errstack.E {
+	q := `FOR t IN trades FILTER t._key == @key
+	LET d = t.stages[@s].docs[@d]
+	FILTER d != null && d.supersededBy == @prev
+	UPDATE t WITH {stages: (FOR i IN 0..LENGTH(t.stages)-1 RETURN i != @s ? t.stages[i] :
+		MERGE(t.stages[i], {docs: (FOR j IN 0..LENGTH(t.stages[i].docs)-1 RETURN j != @d ? t.stages[i].docs[j] :
+			MERGE(t.stages[i].docs[j], {supersededBy: @next}))}))} IN trades
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key":  tid,
+		"s":    stageIdx,
+		"d":    docIdx,
+		"prev": prev,
+		"next": next}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Can't update the stage doc version")
+	}
+	if len(keys) == 0 {
+		return model.ErrStageDocSuperseded
+	}
+	return nil
+}
+
 // DeleteTradeData delete the selected trade data
 func DeleteTradeData(ctx context.Context, db driver.Database, tradeID string) errstack.E {
 	return deleteDoc(ctx, db, dbconst.ColTrades, tradeID)
 }
 
-// UpdateTrade updates trade
+// UpdateTrade updates trade. The moderator claims are only changed by
+// UpdateTradeModerator and UpdateStageModerator, so the stored claims are kept and t
+// is updated with them. Only the new stages take the moderator from t.
 func UpdateTrade(ctx context.Context, db driver.Database, t *model.Trade) (driver.DocumentMeta, errstack.E) {
-	return UpdateDoc(ctx, db, dbconst.ColTrades, t.ID, t)
+	if t == nil {
+		return driver.DocumentMeta{}, errstack.NewDomain("Can't update nil object")
+	}
+	q := `FOR d IN trades FILTER d._key == @key
+	UPDATE d WITH MERGE(@trade, {moderator: d.moderator, moderating: d.moderating,
+		stages: LENGTH(@trade.stages) == 0 ? @trade.stages : (FOR i IN 0..LENGTH(@trade.stages)-1
+			RETURN i < LENGTH(d.stages) ? MERGE(@trade.stages[i], {moderator: d.stages[i].moderator}) : @trade.stages[i])})
+	IN trades
+	RETURN {_key: NEW._key, _id: NEW._id, _rev: NEW._rev, moderator: NEW.moderator,
+		moderating: NEW.moderating, stageModerators: NEW.stages[*].moderator}`
+	vars := map[string]interface{}{
+		"key":   t.ID,
+		"trade": t}
+	var rows []struct {
+		driver.DocumentMeta
+		Moderator       *model.StageModerator   `json:"moderator"`
+		Moderating      model.DoneStatus        `json:"moderating"`
+		StageModerators []*model.StageModerator `json:"stageModerators"`
+	}
+	if errs := DBQueryMany(ctx, &rows, q, vars, db); errs != nil {
+		return driver.DocumentMeta{}, errstack.WrapAsInf(errs, "Can't update trades object")
+	}
+	if len(rows) == 0 {
+		return driver.DocumentMeta{}, NewNotFound("Trade not found")
+	}
+	r := rows[0]
+	t.Moderator, t.Moderating = r.Moderator, r.Moderating
+	for i, m := range r.StageModerators {
+		if m == nil {
+			t.Stages[i].Moderator = model.StageModerator{}
+		} else {
+			t.Stages[i].Moderator = *m
+		}
+	}
+	return r.DocumentMeta, nil
 }
 
 // GetTradeAndStage retrieves Trade And Stage from DB
@@ -2,6 +2,7 @@ package dal
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
@@ -24,6 +25,101 @@ func GetTradeOffers(ctx context.Context, db driver.Database) ([]model.TradeOffer
 	return tofs, DBQueryMany(ctx, &tofs, q, nil, db)
 }
 
+// GetTradeOfferMatchCandidates fetches active opposite side offers of other users with the same
+// commodity, incoterm and currency and an overlapping shipment window.
+// Use `model.RankTradeOfferMatches` to check the price and rank the candidates.
+func GetTradeOfferMatchCandidates(ctx context.Context, db driver.Database, tof *model.TradeOffer) ([]model.TradeOffer, errstack.E) {
+	if len(tof.Shipment) != 2 {
+		return nil, errstack.NewDomain("Trade offer shipment must be a [from, to] window")
+	}
+	q := `FOR d IN trade_offers
+	FILTER d.closedAt == null && (d.expiresAt == null || DATE_TIMESTAMP(d.expiresAt) > DATE_NOW())
+	FILTER d.isSell != @isSell && d.createdBy != @createdBy
+	FILTER d.commodity == @commodity && d.incoterm == @incoterm && d.currency == @currency
+	FILTER DATE_TIMESTAMP(FIRST(d.shipment)) <= @shipmentTo && DATE_TIMESTAMP(LAST(d.shipment)) >= @shipmentFrom
+	RETURN d`
+	vars := map[string]interface{}{
+		"isSell":       tof.IsSell,
+		"createdBy":    tof.CreatedBy,
+		"commodity":    tof.Commodity,
+		"incoterm":     tof.Incoterm,
+		"currency":     tof.Currency,
+		"shipmentFrom": timestamp(tof.Shipment[0]),
+		"shipmentTo":   timestamp(tof.Shipment[1]),
+	}
+	var tofs []model.TradeOffer
+	return tofs, DBQueryMany(ctx, &tofs, q, vars, db)
+}
+
+// GetTradeOffersPage returns a page of active offers matching the filter.
+func GetTradeOffersPage(ctx context.Context, db driver.Database, f *model.TradeOfferFilter,
+	order *model.TradeOfferOrder, first *int, after *string) (*model.TradeOfferConnection, errstack.E) {
+	sortBy, desc := "DATE_TIMESTAMP(d.createdAt)", true
+	if order != nil {
+		switch *order {
+		case model.TradeOfferOrderCreatedAsc:
+			desc = false
+		case model.TradeOfferOrderPriceAsc:
+			sortBy, desc = "d.price", false
+		case model.TradeOfferOrderPriceDesc:
+			sortBy = "d.price"
+		}
+	}
+	q := newPageQuery(dbconst.ColTradeOffers, sortBy, desc)
+	q.filter("d.closedAt == null")
+	if f != nil {
+		filterTradeOffers(q, f)
+	}
+	items, pi, errs := q.page(ctx, db, first, after)
+	if errs != nil {
+		return nil, errs
+	}
+	total, errs := q.count(ctx, db)
+	if errs != nil {
+		return nil, errs
+	}
+	conn := model.TradeOfferConnection{
+		Edges:      make([]model.TradeOfferEdge, len(items)),
+		PageInfo:   pi,
+		TotalCount: total,
+	}
+	for i, it := range items {
+		conn.Edges[i].Cursor = it.cursor().String()
+		if err := json.Unmarshal(it.Doc, &conn.Edges[i].Node); err != nil {
+			return nil, errstack.WrapAsDomain(err, "Can't read trade offer")
+		}
+	}
+	return &conn, nil
+}
+
+func filterTradeOffers(q *pageQuery, f *model.TradeOfferFilter) {
+	if f.Commodity != nil {
+		q.filter("d.commodity == @commodity", "commodity", *f.Commodity)
+	}
+	if f.Currency != nil {
+		q.filter("d.currency == @currency", "currency", *f.Currency)
+	}
+	if f.Incoterm != nil {
+		q.filter("d.incoterm == @incoterm", "incoterm", *f.Incoterm)
+	}
+	if f.IsSell != nil {
+		q.filter("d.isSell == @isSell", "isSell", *f.IsSell)
+	}
+	if f.PriceMin != nil {
+		q.filter("d.price >= @priceMin", "priceMin", *f.PriceMin)
+	}
+	if f.PriceMax != nil {
+		q.filter("d.price <= @priceMax", "priceMax", *f.PriceMax)
+	}
+	// shipment is a [from, to] window
+	if f.ShipmentFrom != nil {
+		q.filter("DATE_TIMESTAMP(LAST(d.shipment)) >= @shipmentFrom", "shipmentFrom", timestamp(*f.ShipmentFrom))
+	}
+	if f.ShipmentTo != nil {
+		q.filter("DATE_TIMESTAMP(FIRST(d.shipment)) <= @shipmentTo", "shipmentTo", timestamp(*f.ShipmentTo))
+	}
+}
+
 // InsertTradeOffer creates new tradeOffer
 func InsertTradeOffer(ctx context.Context, db driver.Database, tof *model.TradeOffer) (*model.TradeOffer, errstack.E) {
 	dm, errs := insertHasID(ctx, dbconst.ColTradeOffers, tof, db)
@@ -40,10 +136,29 @@ func InsertTradeOffer(ctx context.Context, db driver.Database, tof *model.TradeO
 	return tof, errs
 }
 
-// CloseTradeOffer update the tradeOffer by setting closeAt with current time
+// CloseTradeOffer closes the tradeOffer by setting closedAt with current time.
+// The check and update are done in a single query, so only one caller can close an
+// offer. It returns model.ErrTradeOfferClosed if the offer is already closed.
 func CloseTradeOffer(ctx context.Context, db driver.Database, offerID string) errstack.E {
-	now := time.Now().UTC()
-	diff := map[string]*time.Time{"closedAt": &now}
+	q := `FOR d IN trade_offers FILTER d._key == @key && d.closedAt == null
+	UPDATE d WITH {closedAt: @now} IN trade_offers
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key": offerID,
+		"now": time.Now().UTC()}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to update tradeOffer")
+	}
+	if len(keys) == 0 {
+		return model.ErrTradeOfferClosed
+	}
+	return nil
+}
+
+// ReopenTradeOffer clears the offer closedAt time
+func ReopenTradeOffer(ctx context.Context, db driver.Database, offerID string) errstack.E {
+	diff := map[string]*time.Time{"closedAt": nil}
 	_, err := UpdateDoc(ctx, db, dbconst.ColTradeOffers, offerID, diff)
 	return errstack.WrapAsInf(err, "Failed to update tradeOffer")
 }
@@ -0,0 +1,88 @@
+package dal
+
+import (
+	"context"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// InsertTradeOfferBid creates a new bid linked to its offer
+func InsertTradeOfferBid(ctx context.Context, db driver.Database, b *model.TradeOfferBid) errstack.E {
+	_, errs := InsertIntoGraph(ctx, db, dbconst.ColTradeOfferBids, dbconst.ColTradeOfferBidEdges,
+		b, model.TradeOfferBidEdge{OfferID: b.OfferID})
+	return errs
+}
+
+// GetTradeOfferBid fetches a bid by ID
+func GetTradeOfferBid(ctx context.Context, db driver.Database, id string) (*model.TradeOfferBid, errstack.E) {
+	var b model.TradeOfferBid
+	return &b, DBGetOneFromColl(ctx, &b, id, dbconst.ColTradeOfferBids, db)
+}
+
+// GetTradeOfferBids fetches bids of the offer, oldest first.
+// If bidder is not empty then only bids of the bidder's thread are returned.
+func GetTradeOfferBids(ctx context.Context, db driver.Database, offerID, bidder string) ([]model.TradeOfferBid, errstack.E) {
+	q := `FOR b IN 1..1 INBOUND @offer tradeoffer_bid_edges
+	FILTER @bidder == "" || b.bidder == @bidder
+	SORT b.createdAt, b._key
+	RETURN b`
+	vars := map[string]interface{}{
+		"offer":  dbconst.ColTradeOffers.FullID(offerID),
+		"bidder": bidder,
+	}
+	bids := []model.TradeOfferBid{}
+	return bids, DBQueryMany(ctx, &bids, q, vars, db)
+}
+
+// RespondTradeOfferBid saves the response to the pending bid. It returns
+// model.ErrBidNotPending if the bid was responded or countered in the meantime.
+func RespondTradeOfferBid(ctx context.Context, db driver.Database, b *model.TradeOfferBid) errstack.E {
+	q := `FOR d IN trade_offer_bids FILTER d._key == @key && d.status == "pending"
+	UPDATE d WITH {status: @status, respondedAt: @respondedAt, rejectReason: @reason} IN trade_offer_bids
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key":         b.ID,
+		"status":      b.Status,
+		"respondedAt": b.RespondedAt,
+		"reason":      b.RejectReason}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to update the bid")
+	}
+	if len(keys) == 0 {
+		return model.ErrBidNotPending
+	}
+	return nil
+}
+
+// SetTradeOfferAcceptedBid freezes the offer terms by setting the accepted bid.
+// It returns model.ErrTradeOfferClosed if the offer is closed or another bid was accepted.
+func SetTradeOfferAcceptedBid(ctx context.Context, db driver.Database, offerID, bidID string) errstack.E {
+	q := `FOR d IN trade_offers FILTER d._key == @key && d.closedAt == null && d.acceptedBid == null
+	UPDATE d WITH {acceptedBid: @bid} IN trade_offers
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key": offerID,
+		"bid": bidID}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to update tradeOffer")
+	}
+	if len(keys) == 0 {
+		return model.ErrTradeOfferClosed
+	}
+	return nil
+}
+
+// ResetTradeOfferAcceptedBid clears the accepted bid of the offer if it is still bidID
+func ResetTradeOfferAcceptedBid(ctx context.Context, db driver.Database, offerID, bidID string) errstack.E {
+	q := `FOR d IN trade_offers FILTER d._key == @key && d.acceptedBid == @bid
+	UPDATE d WITH {acceptedBid: null} IN trade_offers`
+	vars := map[string]interface{}{
+		"key": offerID,
+		"bid": bidID}
+	return DBExec(ctx, q, vars, db)
+}
@@ -2,6 +2,7 @@ package dal
 
 import (
 	"context"
+	"time"
 
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
@@ -9,15 +10,88 @@ import (
 	"github.com/robert-zaremba/errstack"
 )
 
-// GetTradeTemplates return all trade templates
+// GetTradeTemplates return the latest versions of the active trade templates
 func GetTradeTemplates(ctx context.Context, db driver.Database) ([]model.TradeTemplate, errstack.E) {
-	q := "for d in trade_templates sort d._key return d"
+	q := `for d in trade_templates filter d.supersededBy == null && d.archivedAt == null
+	sort d._key return d`
 	var tts []model.TradeTemplate
 	return tts, DBQueryMany(ctx, &tts, q, nil, db)
 }
 
+// GetTradeTemplateVersions returns all versions of the template family, newest first
+func GetTradeTemplateVersions(ctx context.Context, db driver.Database, familyID string) ([]model.TradeTemplate, errstack.E) {
+	q := `for d in trade_templates filter (d.familyID || d._key) == @family
+	sort d.version desc return d`
+	tts := []model.TradeTemplate{}
+	return tts, DBQueryMany(ctx, &tts, q, map[string]interface{}{"family": familyID}, db)
+}
+
 // GetTradeTempate get a trade template by ID
 func GetTradeTempate(ctx context.Context, db driver.Database, ttID string) (*model.TradeTemplate, errstack.E) {
 	var tt model.TradeTemplate
 	return &tt, DBGetOneFromColl(ctx, &tt, ttID, dbconst.ColTradeTemplates, db)
 }
+
+// IsTradeTemplateNameTaken checks if an active template outside of the family has the name
+func IsTradeTemplateNameTaken(ctx context.Context, db driver.Database, name, familyID string) (bool, errstack.E) {
+	var exists bool
+	q := existsQuery(`FOR d IN trade_templates FILTER LOWER(d.name) == LOWER(@name)
+	&& d.supersededBy == null && d.archivedAt == null && (d.familyID || d._key) != @family`)
+	vars := map[string]interface{}{
+		"name":   name,
+		"family": familyID}
+	return exists, DBQueryOne(ctx, &exists, q, vars, db)
+}
+
+// InsertTradeTemplateVersion inserts the template. When prev is not nil, the template
+// is its next version and prev is marked as superseded. It returns
+// model.ErrTemplateNotActive if prev was already superseded or archived.
+func InsertTradeTemplateVersion(ctx context.Context, db driver.Database, prev, tt *model.TradeTemplate) errstack.E {
+	_, errs := insertHasID(ctx, dbconst.ColTradeTemplates, tt, db)
+	if errs != nil {
+		// the unique family version index rejects concurrent updates
+		if driver.IsConflict(errstack.Cause(errs)) {
+			return model.ErrTemplateNotActive
+		}
+		return errs
+	}
+	if prev == nil {
+		return nil
+	}
+	q := `FOR d IN trade_templates FILTER d._key == @key && d.supersededBy == null && d.archivedAt == null
+	UPDATE d WITH {supersededBy: @next} IN trade_templates
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key":  prev.ID,
+		"next": tt.ID}
+	var keys []string
+	if errs = DBQueryMany(ctx, &keys, q, vars, db); errs == nil && len(keys) == 0 {
+		errs = model.ErrTemplateNotActive
+	}
+	if errs != nil {
+		errstack.Log(logger, deleteDoc(ctx, db, dbconst.ColTradeTemplates, tt.ID))
+		return errs
+	}
+	prev.SupersededBy = &tt.ID
+	return nil
+}
+
+// ArchiveTradeTemplate archives the latest version of the template family.
+// It returns model.ErrTemplateNotActive if the family is already archived.
+func ArchiveTradeTemplate(ctx context.Context, db driver.Database, familyID string) errstack.E {
+	q := `FOR d IN trade_templates FILTER (d.familyID || d._key) == @family
+	&& d.supersededBy == null && d.archivedAt == null
+	UPDATE d WITH {archivedAt: @now} IN trade_templates
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"family": familyID,
+		"now":    time.Now().UTC()}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to archive the trade template")
+	}
+	if len(keys) == 0 {
+		return model.ErrTemplateNotActive
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package dal
+
+import (
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	. "gopkg.in/check.v1"
+)
+
+func (s *DalSuite) TestGetTradesExpiringBefore(c *C) {
+	now := time.Now().UTC()
+	past := now.Add(-time.Hour)
+	pendingDoc := model.TradeStageDoc{DocID: "1", Status: model.ApprovalPending, ExpiresAt: past}
+	closed := []model.ApproveReq{{Status: model.ApprovalApproved}}
+	trades := map[string]*model.Trade{
+		"open stage": {Stages: []model.TradeStage{{Docs: []model.TradeStageDoc{pendingDoc}}}},
+		"closed stage": {Stages: []model.TradeStage{
+			{Docs: []model.TradeStageDoc{pendingDoc}, CloseReqs: closed}}},
+		"deleted stage": {Stages: []model.TradeStage{
+			{Docs: []model.TradeStageDoc{pendingDoc}, DelReqs: closed}}},
+		"no expire time": {Stages: []model.TradeStage{{Docs: []model.TradeStageDoc{
+			{DocID: "1", Status: model.ApprovalPending}}}}},
+	}
+	for name, t := range trades {
+		t.Name = name
+		_, errs := InsertTrade(testctx, s.db, t)
+		c.Assert(errs, IsNil)
+		defer func(id string) { c.Check(DeleteTradeData(testctx, s.db, id), IsNil) }(t.ID)
+	}
+
+	ts, errs := GetTradesExpiringBefore(testctx, s.db, now)
+	c.Assert(errs, IsNil)
+	found := map[string]bool{}
+	for _, t := range ts {
+		found[t.ID] = true
+	}
+	c.Check(found[trades["open stage"].ID], Equals, true)
+	for _, name := range []string{"closed stage", "deleted stage", "no expire time"} {
+		c.Check(found[trades[name].ID], Equals, false, Commentf(name))
+	}
+}
+
+func (s *DalSuite) TestSupersedeTradeStageDoc(c *C) {
+	rejected := model.TradeStageDoc{DocID: "1", Status: model.ApprovalRejected}
+	t := &model.Trade{Name: "supersede", Stages: []model.TradeStage{{Docs: []model.TradeStageDoc{rejected}}}}
+	_, errs := InsertTrade(testctx, s.db, t)
+	c.Assert(errs, IsNil)
+	defer func() { c.Check(DeleteTradeData(testctx, s.db, t.ID), IsNil) }()
+
+	c.Assert(SupersedeTradeStageDoc(testctx, s.db, t.ID, 0, 0, 1), IsNil)
+	// the concurrent upload of another version fails
+	c.Check(SupersedeTradeStageDoc(testctx, s.db, t.ID, 0, 0, 2), Equals, model.ErrStageDocSuperseded)
+	c.Check(SupersedeTradeStageDoc(testctx, s.db, t.ID, 0, 1, 2), Equals, model.ErrStageDocSuperseded)
+
+	c.Check(ResetTradeStageDocSupersede(testctx, s.db, t.ID, 0, 0, 2), Equals, model.ErrStageDocSuperseded)
+	c.Assert(ResetTradeStageDocSupersede(testctx, s.db, t.ID, 0, 0, 1), IsNil)
+	got, errs := GetTrade(testctx, s.db, t.ID)
+	c.Assert(errs, IsNil)
+	c.Check(got.Stages[0].Docs[0].SupersededBy, IsNil)
+	c.Check(SupersedeTradeStageDoc(testctx, s.db, t.ID, 0, 0, 2), IsNil)
+}
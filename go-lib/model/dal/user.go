@@ -51,13 +51,15 @@ func InsertUser(ctx context.Context, db driver.Database, nu *model.NewUserInput)
 		return nil, errs
 	}
 	u := model.User{
-		CreatedAt:     time.Now().UTC(),
-		Emails:        []string{nu.Email},
-		FirstName:     nu.FirstName,
-		LastName:      nu.LastName,
-		Biography:     *nu.Biography,
-		Avatar:        *nu.Avatar,
-		Organizations: map[string]string{nu.OrgID: nu.OrgRole},
+		CreatedAt:      time.Now().UTC(),
+		Emails:         []string{nu.Email},
+		VerifiedEmails: []string{},
+		FirstName:      nu.FirstName,
+		LastName:       nu.LastName,
+		Biography:      *nu.Biography,
+		Avatar:         *nu.Avatar,
+		// the user joins the organization when the join request is approved
+		Organizations: map[string]string{},
 		// currently we set trader role for all new users
 		Roles:     []model.UserRole{model.UserRoleTrader},
 		Approvals: []model.AccessApproval{},
@@ -91,11 +93,11 @@ func UserLogin(ctx context.Context, db driver.Database, ul model.UserLoginInput)
 	if errs != nil && !notFound {
 		return u, errs
 	}
-	if len(u.Approvals) == 0 || u.Approvals[len(u.Approvals)-1].Status == model.SimpleApprovalRejected {
-		return nil, errstack.NewDomain("Failed to login, Your account should be accepted by Cerealia team")
+	if !u.IsAccepted() {
+		return nil, model.ErrUserNotAccepted
 	}
 	if notFound || !bytes.Equal(u.Password, createPwdHash(ul.Password, u.Salt)) {
-		return u, errstack.NewReq("Invalid credentials!")
+		return u, model.ErrInvalidCredentials
 	}
 	return u, nil
 }
@@ -132,6 +134,19 @@ func GetApprovedUsers(ctx context.Context, db driver.Database) ([]model.User, er
 	return us, DBQueryMany(ctx, &us, q, nil, db)
 }
 
+// GetAllUsers fetches all users from db.
+func GetAllUsers(ctx context.Context, db driver.Database) ([]model.User, errstack.E) {
+	var us []model.User
+	return us, DBQueryMany(ctx, &us, "FOR d IN users RETURN d", nil, db)
+}
+
+// GetUserIDsWithRole fetches IDs of all users with the role
+func GetUserIDsWithRole(ctx context.Context, db driver.Database, role model.UserRole) ([]string, errstack.E) {
+	q := "FOR d IN users FILTER @role IN (d.roles || []) RETURN d._key"
+	var ids []string
+	return ids, DBQueryMany(ctx, &ids, q, map[string]interface{}{"role": role}, db)
+}
+
 // GetAdminUsers fetches all users from DB.
 func GetAdminUsers(ctx context.Context, db driver.Database) ([]model.AdminUser, errstack.E) {
 	q := "FOR d IN users RETURN d"
@@ -160,6 +175,16 @@ func GetUserByEmail(ctx context.Context, db driver.Database, email string) (*mod
 	return u, DBQueryOne(ctx, u, query, bindVars, db)
 }
 
+// GetUserByPubKey fetches the user owning a static wallet with the public key
+func GetUserByPubKey(ctx context.Context, db driver.Database, pubKey string) (*model.User, errstack.E) {
+	var u = new(model.User)
+	query := "FOR d IN users FILTER @pubKey IN VALUES(d.staticWallets || {})[*].pubKey RETURN d"
+	bindVars := map[string]interface{}{
+		"pubKey": pubKey,
+	}
+	return u, DBQueryOne(ctx, u, query, bindVars, db)
+}
+
 // GetOrganization fetches Organization from DB by its ID
 func GetOrganization(ctx context.Context, db driver.Database, id string) (*model.Organization, errstack.E) {
 	var o model.Organization
@@ -220,9 +245,83 @@ func ChangePassword(ctx context.Context, db driver.Database, u *model.User,
 	if !bytes.Equal(u.Password, createPwdHash(input.OldPassword, u.Salt)) {
 		return errstack.NewReq("Your old password is invalid")
 	}
-	u.Password = createPwdHash(input.NewPassword, u.Salt)
-	_, err := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, u)
-	return errstack.WrapAsInf(err, "Failed to update password")
+	return SetPassword(ctx, db, u, input.NewPassword)
+}
+
+// SetPassword updates the user password and revokes all user sessions
+func SetPassword(ctx context.Context, db driver.Database, u *model.User, password string) errstack.E {
+	u.Password = createPwdHash(password, u.Salt)
+	diff := map[string][]byte{"password": u.Password}
+	if _, err := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, diff); err != nil {
+		return errstack.WrapAsInf(err, "Failed to update password")
+	}
+	return RevokeUserSessions(ctx, db, u.ID)
+}
+
+// UpdateUserTOTP stores the user two-factor authentication setup
+func UpdateUserTOTP(ctx context.Context, db driver.Database, u *model.User) errstack.E {
+	diff := map[string]*model.UserTOTP{"totp": u.TOTP}
+	_, err := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, diff)
+	return errstack.WrapAsInf(err, "Failed to update user two-factor authentication")
+}
+
+// UseUserTOTPCode saves the code accepted by User.VerifySecondFactor. The update is
+// conditional, so a code accepted by concurrent requests can be used only once. Returns
+// model.ErrInvalidTOTP when the code was already used.
+func UseUserTOTPCode(ctx context.Context, db driver.Database, uid string, use model.SecondFactorUse) errstack.E {
+	q := `FOR u IN users FILTER u._key == @key && u.totp.enabled == true && u.totp.lastCounter < @counter
+	UPDATE u WITH {totp: {lastCounter: @counter}} IN users
+	RETURN NEW._key`
+	vars := map[string]interface{}{"key": uid}
+	if use.RecoveryCode != "" {
+		q = `FOR u IN users FILTER u._key == @key && u.totp.enabled == true && POSITION(u.totp.recoveryCodes, @code)
+		UPDATE u WITH {totp: {recoveryCodes: REMOVE_VALUE(u.totp.recoveryCodes, @code, 1)}} IN users
+		RETURN NEW._key`
+		vars["code"] = use.RecoveryCode
+	} else {
+		vars["counter"] = use.Counter
+	}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to update user two-factor authentication")
+	}
+	if len(keys) == 0 {
+		return model.ErrInvalidTOTP
+	}
+	return nil
+}
+
+// IsTOTPRequired checks if any organization of the user requires two-factor authentication
+func IsTOTPRequired(ctx context.Context, db driver.Database, u *model.User) (bool, errstack.E) {
+	orgIDs := make([]string, 0, len(u.Organizations))
+	for id := range u.Organizations {
+		orgIDs = append(orgIDs, id)
+	}
+	q := `FOR o IN organizations FILTER o._key IN @ids && o.requireTOTP == true LIMIT 1 RETURN o._key`
+	var keys []string
+	errs := DBQueryMany(ctx, &keys, q, map[string]interface{}{"ids": orgIDs}, db)
+	return len(keys) > 0, errs
+}
+
+// SetOrgRequireTOTP sets if the organization members must use two-factor authentication
+func SetOrgRequireTOTP(ctx context.Context, db driver.Database, orgID string, required bool) errstack.E {
+	diff := map[string]bool{"requireTOTP": required}
+	_, err := UpdateDoc(ctx, db, dbconst.ColOrganizations, orgID, diff)
+	return errstack.WrapAsInf(err, "Failed to update organization")
+}
+
+// VerifyUserEmail marks the user email as verified
+func VerifyUserEmail(ctx context.Context, db driver.Database, u *model.User, email string) errstack.E {
+	if !u.HasEmail(email) {
+		return model.ErrInvalidToken
+	}
+	if u.IsEmailVerified(email) {
+		return nil
+	}
+	u.VerifiedEmails = append(u.VerifiedEmails, email)
+	diff := map[string][]string{"verifiedEmails": u.VerifiedEmails}
+	_, err := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, diff)
+	return errstack.WrapAsInf(err, "Failed to update user emails")
 }
 
 // ChangeEmail updates the user emails
@@ -239,29 +338,116 @@ func ChangeEmail(ctx context.Context, db driver.Database, u *model.User, emails
 	if len(dupEmails) > 0 {
 		return errstack.NewReqF("%v  emails are already used", dupEmails)
 	}
-	diff := map[string][]string{"emails": emails}
+	// removed emails have to be verified again when they are added back
+	verified := []string{}
+	for _, e := range emails {
+		if u.IsEmailVerified(e) {
+			verified = append(verified, e)
+		}
+	}
+	diff := map[string][]string{"emails": emails, "verifiedEmails": verified}
 	_, err := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, diff)
 	if err == nil {
 		u.Emails = emails
+		u.VerifiedEmails = verified
+	}
+	return err
+}
+
+// UpdateNotifPrefs sets and stores the user notification preferences
+func UpdateNotifPrefs(ctx context.Context, db driver.Database, u *model.User, prefs []model.NotifPref) errstack.E {
+	if errs := u.SetNotifPrefs(prefs); errs != nil {
+		return errs
 	}
+	diff := map[string][]model.NotifPref{"notifPrefs": u.NotifPrefs}
+	_, err := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, diff)
 	return err
 }
 
-// UpdateUserProfile updates user profile
+// UpdateUserProfile updates user profile. Organization memberships are not changed.
 func UpdateUserProfile(ctx context.Context, db driver.Database, u *model.User, input model.UserProfileInput) (*model.User, errstack.E) {
-	var errs errstack.E
 	u.FirstName = input.FirstName
 	u.LastName = input.LastName
-	m := make(map[string]string)
-	for _, v := range input.OrgMap {
-		if errs = assertOrgExists(ctx, db, v.ID); errs != nil {
-			return u, errs
-		}
-		m[v.ID] = v.Role
-	}
-	u.Organizations = m
 	u.Biography = input.Biography
-	return u, ReplaceUser(ctx, db, u)
+	diff := map[string]string{
+		"firstname": u.FirstName,
+		"lastname":  u.LastName,
+		"biography": u.Biography}
+	_, errs := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, diff)
+	return u, errs
+}
+
+// AddHDWallet stores a new HD wallet of the user and returns the wallet ID.
+// If setDefault is true, the wallet becomes the user's default wallet.
+func AddHDWallet(ctx context.Context, db driver.Database, u *model.User, w model.HDCerealiaWallet, setDefault bool) (string, errstack.E) {
+	walletID, err := uuid.NewRandom()
+	if err != nil {
+		return "", errstack.WrapAsInf(err, "Can't generate wallet ID")
+	}
+	walletIDStr := walletID.String()
+	if errs := u.AddHDWallet(walletIDStr, w); errs != nil {
+		return "", errs
+	}
+	// only the new wallet is merged, other wallets may be used concurrently
+	diff := map[string]interface{}{
+		"hdCerealiaWallets": map[string]model.HDCerealiaWallet{walletIDStr: w}}
+	if setDefault {
+		u.DefaultWalletID = walletIDStr
+		diff["defaultwalletID"] = walletIDStr
+	}
+	_, errs := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, diff)
+	return walletIDStr, errs
+}
+
+// AddHDWalletKeys registers more public keys of the user HD wallet
+func AddHDWalletKeys(ctx context.Context, db driver.Database, u *model.User, walletID string, pubKeys []string) errstack.E {
+	n := len(u.HDCerealiaWallets[walletID].PubKeys)
+	if errs := u.AddHDWalletKeys(walletID, pubKeys); errs != nil {
+		return errs
+	}
+	q := `FOR u IN users FILTER u._key == @key && LENGTH(u.hdCerealiaWallets[@wallet].pubKeys) == @n
+	UPDATE u WITH {hdCerealiaWallets: {[@wallet]: {pubKeys: @pubKeys}}} IN users
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key":     u.ID,
+		"wallet":  walletID,
+		"n":       n,
+		"pubKeys": u.HDCerealiaWallets[walletID].PubKeys}
+	return updateHDWallet(ctx, db, q, vars)
+}
+
+// UseHDWalletKey moves the derivation index of the HD wallet past the key with
+// the index. It fails when the key was used concurrently.
+func UseHDWalletKey(ctx context.Context, db driver.Database, uid, walletID string, idx int) errstack.E {
+	q := `FOR u IN users FILTER u._key == @key && u.hdCerealiaWallets[@wallet].derivationIndex == @idx
+	UPDATE u WITH {hdCerealiaWallets: {[@wallet]: {derivationIndex: @idx + 1}}} IN users
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key":    uid,
+		"wallet": walletID,
+		"idx":    idx}
+	return updateHDWallet(ctx, db, q, vars)
+}
+
+func updateHDWallet(ctx context.Context, db driver.Database, q string, vars map[string]interface{}) errstack.E {
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Can't update the HD wallet")
+	}
+	if len(keys) == 0 {
+		return errstack.NewReq("The HD wallet has been changed in the meantime, please try again")
+	}
+	return nil
+}
+
+// SetDefaultWallet sets the user's default wallet
+func SetDefaultWallet(ctx context.Context, db driver.Database, u *model.User, walletID string) errstack.E {
+	if errs := u.SetDefaultWallet(walletID); errs != nil {
+		return errs
+	}
+	diff := map[string]string{"defaultwalletID": walletID}
+	_, errs := UpdateDoc(ctx, db, dbconst.ColUsers, u.ID, diff)
+	return errs
 }
 
 // ReplaceUser replaces user object in DB
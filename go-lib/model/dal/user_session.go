@@ -0,0 +1,80 @@
+package dal
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// InsertUserSession inserts a new user session
+func InsertUserSession(ctx context.Context, db driver.Database, s *model.UserSession) errstack.E {
+	_, errs := insertHasID(ctx, dbconst.ColUserSessions, s, db)
+	return errs
+}
+
+// GetUserSession gets a user session by its id. Returns model.ErrInvalidSession when
+// the session doesn't exist.
+func GetUserSession(ctx context.Context, db driver.Database, id string) (*model.UserSession, errstack.E) {
+	var s model.UserSession
+	errs := DBGetOneFromColl(ctx, &s, id, dbconst.ColUserSessions, db)
+	if IsNotFound(errs) || errs == model.ErrNoID {
+		return nil, model.ErrInvalidSession
+	}
+	return &s, errs
+}
+
+// RotateUserSession replaces the session refresh token hash and extends the session.
+// The update is atomic: it fails with model.ErrInvalidSession when the token was already
+// rotated or the session was revoked.
+func RotateUserSession(ctx context.Context, db driver.Database, id, oldHash, newHash string, expiresAt time.Time) errstack.E {
+	q := `FOR d IN user_sessions FILTER d._key == @key && d.tokenHash == @old && d.revokedAt == null
+	UPDATE d WITH {tokenHash: @new, refreshedAt: @now, expiresAt: @expiresAt} IN user_sessions
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key":       id,
+		"old":       oldHash,
+		"new":       newHash,
+		"now":       time.Now().UTC(),
+		"expiresAt": expiresAt.UTC()}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to update user session")
+	}
+	if len(keys) == 0 {
+		return model.ErrInvalidSession
+	}
+	return nil
+}
+
+// RevokeUserSession revokes the session
+func RevokeUserSession(ctx context.Context, db driver.Database, id string) errstack.E {
+	q := `FOR d IN user_sessions FILTER d._key == @key && d.revokedAt == null
+	UPDATE d WITH {revokedAt: @now} IN user_sessions`
+	vars := map[string]interface{}{
+		"key": id,
+		"now": time.Now().UTC()}
+	_, err := db.Query(ctx, q, vars)
+	return errstack.WrapAsInf(err, "Failed to revoke user session")
+}
+
+// RevokeUserSessions revokes all active sessions of the user
+func RevokeUserSessions(ctx context.Context, db driver.Database, userID string) errstack.E {
+	q := `FOR d IN user_sessions FILTER d.userID == @uid && d.revokedAt == null
+	UPDATE d WITH {revokedAt: @now} IN user_sessions`
+	vars := map[string]interface{}{
+		"uid": userID,
+		"now": time.Now().UTC()}
+	_, err := db.Query(ctx, q, vars)
+	return errstack.WrapAsInf(err, "Failed to revoke user sessions")
+}
+
+// SetUserSessionMFAVerified records the TOTP verification in the session
+func SetUserSessionMFAVerified(ctx context.Context, db driver.Database, id string, t time.Time) errstack.E {
+	diff := map[string]time.Time{"mfaVerifiedAt": t.UTC()}
+	_, err := UpdateDoc(ctx, db, dbconst.ColUserSessions, id, diff)
+	return errstack.WrapAsInf(err, "Failed to update user session")
+}
@@ -0,0 +1,98 @@
+package dal
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// InsertUserToken inserts a new user token
+func InsertUserToken(ctx context.Context, db driver.Database, t *model.UserToken) errstack.E {
+	_, errs := insertHasID(ctx, dbconst.ColUserTokens, t, db)
+	return errs
+}
+
+// UseUserToken marks the token as used and returns it. The update is atomic, so a token
+// can be used only once. Returns model.ErrInvalidToken when the token doesn't exist,
+// is expired or was already used.
+func UseUserToken(ctx context.Context, db driver.Database, id, hash string, purpose model.UserTokenPurpose) (*model.UserToken, errstack.E) {
+	q := `FOR d IN user_tokens
+	FILTER d._key == @key && d.tokenHash == @hash && d.purpose == @purpose && d.usedAt == null
+		&& DATE_TIMESTAMP(d.expiresAt) > DATE_TIMESTAMP(@now)
+	UPDATE d WITH {usedAt: @now} IN user_tokens
+	RETURN NEW`
+	vars := map[string]interface{}{
+		"key":     id,
+		"hash":    hash,
+		"purpose": purpose,
+		"now":     time.Now().UTC()}
+	var ts []model.UserToken
+	if errs := DBQueryMany(ctx, &ts, q, vars, db); errs != nil {
+		return nil, errstack.WrapAsInf(errs, "Failed to update user token")
+	}
+	if len(ts) == 0 {
+		return nil, model.ErrInvalidToken
+	}
+	return &ts[0], nil
+}
+
+// ReserveUserTokenAttempt counts an attempt to use the token before it's verified, so
+// concurrent attempts are counted too. Returns model.ErrInvalidToken when the token
+// doesn't exist, is expired, used or had maxAttempts already.
+func ReserveUserTokenAttempt(ctx context.Context, db driver.Database, id string, purpose model.UserTokenPurpose, maxAttempts int) (*model.UserToken, errstack.E) {
+	q := `FOR d IN user_tokens
+	FILTER d._key == @key && d.purpose == @purpose && d.usedAt == null && (d.attempts || 0) < @max
+		&& DATE_TIMESTAMP(d.expiresAt) > DATE_TIMESTAMP(@now)
+	UPDATE d WITH {attempts: (d.attempts || 0) + 1} IN user_tokens
+	RETURN NEW`
+	vars := map[string]interface{}{
+		"key":     id,
+		"purpose": purpose,
+		"max":     maxAttempts,
+		"now":     time.Now().UTC()}
+	var ts []model.UserToken
+	if errs := DBQueryMany(ctx, &ts, q, vars, db); errs != nil {
+		return nil, errstack.WrapAsInf(errs, "Failed to update user token")
+	}
+	if len(ts) == 0 {
+		return nil, model.ErrInvalidToken
+	}
+	return &ts[0], nil
+}
+
+// MarkUserTokenUsed marks the token reserved by ReserveUserTokenAttempt as used.
+// Returns model.ErrInvalidToken when it was already used.
+func MarkUserTokenUsed(ctx context.Context, db driver.Database, id string) errstack.E {
+	q := `FOR d IN user_tokens FILTER d._key == @key && d.usedAt == null
+	UPDATE d WITH {usedAt: @now} IN user_tokens
+	RETURN NEW._key`
+	vars := map[string]interface{}{
+		"key": id,
+		"now": time.Now().UTC()}
+	var keys []string
+	if errs := DBQueryMany(ctx, &keys, q, vars, db); errs != nil {
+		return errstack.WrapAsInf(errs, "Failed to update user token")
+	}
+	if len(keys) == 0 {
+		return model.ErrInvalidToken
+	}
+	return nil
+}
+
+// InvalidateUserTokens marks the unused user tokens with the purpose, sent to the email,
+// as used. Tokens sent to the other emails of the user stay valid.
+func InvalidateUserTokens(ctx context.Context, db driver.Database, userID string, purpose model.UserTokenPurpose, email string) errstack.E {
+	q := `FOR d IN user_tokens
+	FILTER d.userID == @uid && d.purpose == @purpose && d.email == @email && d.usedAt == null
+	UPDATE d WITH {usedAt: @now} IN user_tokens`
+	vars := map[string]interface{}{
+		"uid":     userID,
+		"purpose": purpose,
+		"email":   email,
+		"now":     time.Now().UTC()}
+	return errstack.WrapAsInf(DBExec(ctx, q, vars, db), "Failed to invalidate user tokens")
+}
@@ -14,16 +14,29 @@ const (
 	GraphDoc              Col = "graph_doc_to_trade"
 	GraphTxLog            Col = "graph_txlog_to_trade"
 	GraphDocTradeOffer    Col = "graph_doc_to_tradeoffer"
+	GraphTradeOfferBid    Col = "graph_bid_to_tradeoffer"
+	GraphDocSupersedes    Col = "graph_doc_supersedes"
 	ColTrades             Col = "trades"
 	ColUsers              Col = "users"
+	ColUserSessions       Col = "user_sessions"
+	ColUserTokens         Col = "user_tokens"
+	ColAPIKeys            Col = "api_keys"
 	ColDocs               Col = "docs"
 	ColDocEdges           Col = "doc_edges"
+	ColDocSupersedesEdges Col = "doc_supersedes_edges"
 	ColTradeTemplates     Col = "trade_templates"
 	ColOrganizations      Col = "organizations"
+	ColOrgMemberReqs      Col = "org_member_reqs"
+	ColOrgAuditLog        Col = "org_audit_log"
+	ColSanctionEntries    Col = "sanction_entries"
+	ColScreeningHits      Col = "screening_hits"
+	ColLoginAttempts      Col = "login_attempts"
 	ColTxEntryLog         Col = "tx_entry_log"
 	ColTxEntryLogEdges    Col = "tx_entry_log_edges"
 	ColTradeOffers        Col = "trade_offers"
 	ColDocTradeOfferEdges Col = "doc_tradeoffer_edges"
+	ColTradeOfferBids     Col = "trade_offer_bids"
+	ColTradeOfferBidEdges Col = "tradeoffer_bid_edges"
 	ColNotifications      Col = "notifications"
 	ColTxSourceAccs       Col = "tx_source_accounts"
 )
@@ -17,3 +17,21 @@ func (de TradeDocEdge) ToEdgeDO(absoluteDocID string) interface{} {
 		StageDocIdx: de.StageDocIdx,
 	}
 }
+
+// DocMetaChanges lists the metadata fields which differ in the next version of the doc
+func DocMetaChanges(prev, next *Doc) []DocMetaChange {
+	fields := []struct{ name, old, new string }{
+		{"name", prev.Name, next.Name},
+		{"type", prev.Type, next.Type},
+		{"note", prev.Note, next.Note},
+		{"hash", prev.Hash, next.Hash},
+		{"createdBy", prev.CreatedBy, next.CreatedBy},
+	}
+	changes := []DocMetaChange{}
+	for _, f := range fields {
+		if f.old != f.new {
+			changes = append(changes, DocMetaChange{Field: f.name, Old: f.old, New: f.new})
+		}
+	}
+	return changes
+}
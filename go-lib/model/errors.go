@@ -1,6 +1,8 @@
 package model
 
 import (
+	"net/http"
+
 	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
 	"github.com/robert-zaremba/errstack"
 )
@@ -9,11 +11,72 @@ var (
 	// ErrUnauthenticated is thrown when user login is required get the resources
 	ErrUnauthenticated = errstack.NewReq("Authentication required")
 	// ErrUnauthorized is thrown when user doesn't have permission to get resources
-	ErrUnauthorized = errstack.NewReq("Access denied")
+	ErrUnauthorized = forbidden{errstack.NewReq("Access denied")}
 	// ErrNoID is the NoneID error
 	ErrNoID = errstack.NewDomain("The provided ID is empty")
+	// ErrTradeOfferClosed is thrown when closing or accepting a trade offer which is already closed
+	ErrTradeOfferClosed = errstack.NewReq("Trade offer is already closed")
+	// ErrBidNotPending is thrown when responding to or countering a bid which was already
+	// responded or countered
+	ErrBidNotPending = errstack.NewReq("Only a pending bid can be accepted or rejected")
+	// ErrInvalidSession is thrown when the user session is expired or revoked
+	ErrInvalidSession = errstack.NewReq("Session is expired or revoked, please login again")
+	// ErrUserNotAccepted is thrown when a user, who is not accepted by the Cerealia team, logs in
+	ErrUserNotAccepted = errstack.NewDomain("Failed to login, Your account should be accepted by Cerealia team")
+	// ErrOrgMemberReqResolved is thrown when responding to an invitation or a join request which isn't pending
+	ErrOrgMemberReqResolved = errstack.NewReq("The invitation or the join request is already resolved")
+	// ErrOrgLastAdmin is thrown when removing or demoting the last organization admin
+	ErrOrgLastAdmin = errstack.NewReq("The organization must have an admin")
+	// ErrOrgMembersChanged is thrown when the organization members are changed concurrently
+	ErrOrgMembersChanged = errstack.NewReq("The organization members changed at the same time, please try again")
+	// ErrOrgNotVerified is thrown when an organization without the KYB verification
+	// posts a firm trade offer or becomes a trade party
+	ErrOrgNotVerified = errstack.NewReq("The organization is not verified")
+	// ErrScreeningFlagged is thrown when a trade party has a pending or confirmed
+	// sanctions screening hit
+	ErrScreeningFlagged = errstack.NewReq("A trade party is flagged by the sanctions screening and waits for the compliance review")
+	// ErrInvalidAPIKey is thrown when the API key is unknown, expired or revoked
+	ErrInvalidAPIKey = errstack.NewReq("API key is invalid, expired or revoked")
+	// ErrInvalidToken is thrown when an emailed token is unknown, expired or already used
+	ErrInvalidToken = errstack.NewReq("The link is invalid or expired")
+	// ErrInvalidTOTP is thrown when the two-factor authentication code is wrong or reused
+	ErrInvalidTOTP = errstack.NewReq("Invalid two-factor authentication code")
+	// ErrInvalidCredentials is thrown when the login email or password is wrong
+	ErrInvalidCredentials = errstack.NewReq("Invalid credentials!")
+	// ErrTooManyLoginAttempts is thrown when the login is delayed after failed attempts
+	ErrTooManyLoginAttempts = errstack.NewReq("Too many failed login attempts, please try again later")
+	// ErrAccountLocked is thrown when the account is temporarily locked after failed logins
+	ErrAccountLocked = errstack.NewReq("The account is temporarily locked after too many failed login attempts")
+	// ErrTOTPNotEnabled is thrown when an operation requires two-factor authentication
+	ErrTOTPNotEnabled = errstack.NewReq("Please enable two-factor authentication first")
+	// ErrTOTPRequired is thrown when an operation requires a recently verified TOTP code
+	ErrTOTPRequired = errstack.NewReq("Please confirm the operation with a two-factor authentication code")
+	// ErrTemplateNotActive is thrown when a superseded or archived trade template is
+	// used for a new trade or updated
+	ErrTemplateNotActive = errstack.NewReq("The trade template is archived or replaced by a newer version")
+	// ErrTemplateNameTaken is thrown when another active trade template has the same name
+	ErrTemplateNameTaken = errstack.NewReq("A trade template with this name already exists")
+	// ErrModerationClaimed is thrown when claiming a trade or a stage which is claimed
+	// by another moderator
+	ErrModerationClaimed = errstack.NewReq("The moderation is claimed by another moderator")
+	// ErrModerationNotClaimed is thrown when a moderator releases or finishes
+	// a moderation claimed by someone else
+	ErrModerationNotClaimed = errstack.NewReq("You haven't claimed the moderation")
+	// ErrStageDocSuperseded is thrown when a new version of a stage doc is uploaded
+	// after another one has replaced it
+	ErrStageDocSuperseded = errstack.NewReq("The stage doc already has a newer version")
 )
 
+// forbidden wraps errstack.E into an error with the HTTP 403 status
+type forbidden struct {
+	errstack.E
+}
+
+// StatusCode implements errstack.HasStatusCode
+func (forbidden) StatusCode() int {
+	return http.StatusForbidden
+}
+
 // ErrDbCollection returns fromated error message during connection of db collections
 func ErrDbCollection(err error, col dbconst.Col) errstack.E {
 	return errstack.WrapAsInf(err, "DB: Can't connect the collection "+string(col))
@@ -1,7 +0,0 @@
-package model
-
-// TradeParticipants is a buyer and seller data of a trade
-type TradeParticipants struct {
-	Buyer  TradeParticipant
-	Seller TradeParticipant
-}
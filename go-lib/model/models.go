@@ -44,16 +44,19 @@ type StaticWallet struct {
 	PubKey string `json:"pubKey"`
 }
 
-// HDCerealiaWallet wallet stores metadata for HD public key derivation wallet
-// https://github.com/bitcoin/bips/blob/master/bip-0032.mediawiki#public-parent-key--public-child-key
-// TODO: Currently it's dead code
+// HDCerealiaWallet wallet stores public keys of a SLIP-0010 ed25519 wallet (SEP-0005).
+// ed25519 supports only hardened derivation, which requires the private key, so the
+// client derives the keys and registers only the public ones: PubKeys[i] is the key of
+// the `m/44'/148'/<i>'` path. Each trade gets the next unused key.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0005.md
 type HDCerealiaWallet struct {
 	Wallet
-	MasterPubKey    string `json:"masterPubKey"`
-	DerivationIndex int    `json:"derivationIndex"` // Next index for derivation
+	PubKeys         []string `json:"pubKeys"`
+	DerivationIndex int      `json:"derivationIndex"` // Next index for derivation
 }
 
-// StageModerator is a type for moderating user info
+// StageModerator is a type for moderating user info.
+// CreatedAt is the time when the moderator claimed the trade or the stage.
 type StageModerator struct {
 	UserID    string     `json:"userID"`
 	CreatedAt *time.Time `json:"createdAt"`
@@ -76,6 +79,7 @@ type User struct {
 	FirstName         string                      `json:"firstname"`
 	LastName          string                      `json:"lastname"`
 	Emails            []string                    `json:"emails"`
+	VerifiedEmails    []string                    `json:"verifiedEmails"`
 	Roles             []UserRole                  `json:"roles"`
 	Avatar            string                      `json:"avatar"`
 	Password          []byte                      `json:"password"`
@@ -85,16 +89,82 @@ type User struct {
 	Biography         string                      `json:"biography"`
 	DefaultWalletID   string                      `json:"defaultwalletID"`
 	StaticWallets     map[string]StaticWallet     `json:"staticWallets"`
-	HDCerealiaWallets map[string]HDCerealiaWallet `json:"hdCerealiaWallets"` // currently it's dead code
+	HDCerealiaWallets map[string]HDCerealiaWallet `json:"hdCerealiaWallets"`
 	Approvals         []AccessApproval            `json:"approvals"`
+	NotifPrefs        []NotifPref                 `json:"notifPrefs"`
+	TOTP              *UserTOTP                   `json:"totp"`
 }
 
-// TradeParticipant shows the user and his key in his wallet
+// UserTOTP is the user two-factor authentication setup. The secret is active when
+// Enabled is set; before it's a pending enrollment.
+type UserTOTP struct {
+	Secret    string     `json:"secret"`
+	Enabled   bool       `json:"enabled"`
+	EnabledAt *time.Time `json:"enabledAt"`
+	// LastCounter is the time step of the last accepted code; codes can't be reused
+	LastCounter int64 `json:"lastCounter"`
+	// RecoveryCodes are hashes of the unused recovery codes
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// UserSession is a login session of the user. Access tokens are issued for the session
+// until it expires or is revoked. The refresh token is rotated on every use and only
+// its hash is stored.
+type UserSession struct {
+	ID          string     `json:"_key,omitempty"`
+	UserID      string     `json:"userID"`
+	TokenHash   string     `json:"tokenHash"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	RefreshedAt time.Time  `json:"refreshedAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+	// MFAVerifiedAt is the time of the last TOTP verification in the session
+	MFAVerifiedAt *time.Time `json:"mfaVerifiedAt"`
+}
+
+// UserTokenPurpose defines what a UserToken can be used for
+type UserTokenPurpose string
+
+// UserToken purposes
+const (
+	UserTokenEmailVerification UserTokenPurpose = "emailVerification"
+	UserTokenPasswordReset     UserTokenPurpose = "passwordReset"
+	UserTokenKeyLogin          UserTokenPurpose = "keyLogin"
+	UserTokenMFALogin          UserTokenPurpose = "mfaLogin"
+)
+
+// UserToken is a single use, expiring token emailed to the user to verify the email
+// address or to reset the password. It's also the nonce of the Stellar key login
+// challenge. Only the token hash is stored.
+type UserToken struct {
+	ID        string           `json:"_key,omitempty"`
+	UserID    string           `json:"userID"`
+	Purpose   UserTokenPurpose `json:"purpose"`
+	Email     string           `json:"email"`
+	TokenHash string           `json:"tokenHash"`
+	CreatedAt time.Time        `json:"createdAt"`
+	ExpiresAt time.Time        `json:"expiresAt"`
+	UsedAt    *time.Time       `json:"usedAt"`
+	// Attempts counts the verifications of the token, see MaxMFAAttempts
+	Attempts int `json:"attempts,omitempty"`
+}
+
+// NotifPref is a user opt in / opt out of notification emails.
+// Action is nil when the preference applies to all actions of the notification type.
+type NotifPref struct {
+	Type   NotifType `json:"type"`
+	Action *Approval `json:"action"`
+	Email  bool      `json:"email"`
+}
+
+// TradeParticipant shows the user, his role and his key in his wallet.
+// Role is empty in the buyer and seller of older trades.
 type TradeParticipant struct {
-	UserID            string `json:"userID"`
-	KeyDerivationPath string `json:"keyPath,omitempty"` // should be used in HD wallets later, currently it's dead code
-	WalletID          string `json:"walletID"`
-	PubKey            string `json:"pubKey"`
+	UserID            string     `json:"userID"`
+	Role              TradeActor `json:"role,omitempty"`
+	KeyDerivationPath string     `json:"keyPath,omitempty"` // empty for static wallets
+	WalletID          string     `json:"walletID"`
+	PubKey            string     `json:"pubKey"`
 }
 
 // Trade type for trade info
@@ -105,6 +175,7 @@ type Trade struct {
 	TemplateID   string             `json:"templateID"`
 	Buyer        TradeParticipant   `json:"buyer"`
 	Seller       TradeParticipant   `json:"seller"`
+	Participants []TradeParticipant `json:"participants"`
 	SCAddr       SCAddr             `json:"scAddr"`
 	SCVersion    uint               `json:"scVersion"`
 	Stages       []TradeStage       `json:"stages"`
@@ -113,7 +184,9 @@ type Trade struct {
 	CreatedBy    string             `json:"createdBy"`
 	CreatedAt    time.Time          `json:"createdAt"`
 	TradeOfferID *string            `json:"tradeOffer,omitempty"`
+	OrgID        *string            `json:"orgID,omitempty"`
 	Moderating   DoneStatus         `json:"moderating"`
+	Moderator    *StageModerator    `json:"moderator,omitempty"`
 }
 
 // TradeStageAddReq type for tradeStageAddReq info
@@ -121,24 +194,33 @@ type TradeStageAddReq struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	Owner       TradeActor `json:"owner"`
+	DependsOn   []uint     `json:"dependsOn,omitempty"`
 
 	ApproveReq
 }
 
-// TradeStage type for stage info
+// TradeStage type for stage info.
+// DependsOn lists indexes of the prerequisite stages, which must be closed or deleted
+// before the stage can be closed.
 type TradeStage struct {
 	Name        string          `json:"name"`
 	Description string          `json:"description"`
 	AddReqIdx   int             `json:"addReqIdx"`
 	Owner       TradeActor      `json:"owner"`
+	DependsOn   []uint          `json:"dependsOn,omitempty"`
 	ExpiresAt   *time.Time      `json:"expiresAt"`
 	Docs        []TradeStageDoc `json:"docs"`
 	DelReqs     []ApproveReq    `json:"delReqs"`
 	CloseReqs   []ApproveReq    `json:"closeReqs"`
 	Moderator   StageModerator  `json:"moderator"`
+	// AlertedBefore is the smallest lead of the sent expiry alerts
+	AlertedBefore time.Duration `json:"alertedBefore"`
 }
 
-// TradeStageDoc type for TradeStageDoc info
+// TradeStageDoc type for TradeStageDoc info.
+// A document uploaded again after a rejection is a new version of the rejected one:
+// Supersedes and SupersededBy link the versions by their stage doc index. Every
+// version has its own file and Stellar transactions.
 type TradeStageDoc struct {
 	DocID        string     `json:"docID"`
 	Status       Approval   `json:"status"`
@@ -148,6 +230,11 @@ type TradeStageDoc struct {
 	ApprovedAt   *time.Time `json:"approvedAt,omitempty"`
 	ExpiresAt    time.Time  `json:"expiresAt"`
 	RejectReason string     `json:"rejectReason,omitempty"`
+	Version      int        `json:"version,omitempty"`
+	Supersedes   *uint      `json:"supersedes,omitempty"`
+	SupersededBy *uint      `json:"supersededBy,omitempty"`
+	// AlertedBefore is the smallest lead of the sent expiry alerts
+	AlertedBefore time.Duration `json:"alertedBefore"`
 }
 
 // TradeDocEdge represents graph edge between Doc and Trade
@@ -165,18 +252,35 @@ type TradeDocEdgeDO struct {
 	StageDocIdx uint   `json:"stageDocIdx"`
 }
 
+// DocSupersedesEdgeDO is a database object for the relation between a new version of
+// a document and the previous version it supersedes
+type DocSupersedesEdgeDO struct {
+	FullDocID        string `json:"_from"`
+	FullSupersededID string `json:"_to"`
+}
+
 // TradeDocOfferEdgeDO is a database object for document-tradeOffer edge relation
 type TradeDocOfferEdgeDO struct {
 	FullDocID        string `json:"_from"`
 	FullTradeOfferID string `json:"_to"`
 }
 
-// TradeTemplate type for collection model of stages for  one trade
+// TradeTemplate type for collection model of stages for  one trade.
+// Templates are immutable: an update creates a new version of the template family
+// and marks the previous version as superseded, so trades keep the version they
+// were built from.
 type TradeTemplate struct {
 	ID          string               `json:"_key,omitempty"`
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
 	Stages      []TradeStageTemplate `json:"stages"`
+	// FamilyID is the ID of the first version; it's empty in the first version
+	FamilyID     string     `json:"familyID,omitempty"`
+	Version      int        `json:"version"`
+	CreatedBy    string     `json:"createdBy,omitempty"`
+	CreatedAt    *time.Time `json:"createdAt"`
+	SupersededBy *string    `json:"supersededBy"`
+	ArchivedAt   *time.Time `json:"archivedAt"`
 }
 
 // TradeStageTemplate type is for template stage model in trade template.
@@ -184,6 +288,8 @@ type TradeStageTemplate struct {
 	Name        string     `json:"name"`
 	Description string     `json:"description"`
 	Owner       TradeActor `json:"owner"`
+	// DependsOn lists indexes of the preceding template stages
+	DependsOn []uint `json:"dependsOn,omitempty"`
 }
 
 // TradeOffer object
@@ -209,6 +315,33 @@ type TradeOffer struct {
 	Shipment    []time.Time    `json:"shipment"`
 	Note        string         `json:"note"`
 	DocID       *string        `json:"docID"`
+	// AcceptedBid is the ID of the accepted bid; its terms override the offer terms
+	AcceptedBid *string `json:"acceptedBid"`
+}
+
+// TradeOfferBid is a counter-offer in a negotiation thread between the offer creator
+// and a bidder. Each bid holds the complete proposed terms.
+type TradeOfferBid struct {
+	ID      string `json:"_key,omitempty"`
+	OfferID string `json:"offerID"`
+	// Bidder identifies the negotiation thread
+	Bidder       string      `json:"bidder"`
+	CreatedBy    string      `json:"createdBy"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	Price        float64     `json:"price"`
+	Vol          int         `json:"vol"`
+	Shipment     []time.Time `json:"shipment"`
+	Incoterm     Incoterm    `json:"incoterm"`
+	Message      string      `json:"message"`
+	Status       Approval    `json:"status"`
+	RespondedAt  *time.Time  `json:"respondedAt"`
+	RejectReason string      `json:"rejectReason,omitempty"`
+}
+
+// TradeOfferBidEdgeDO is a database object for bid-tradeOffer edge relation
+type TradeOfferBidEdgeDO struct {
+	FullBidID        string `json:"_from"`
+	FullTradeOfferID string `json:"_to"`
 }
 
 // Doc type for trade document
@@ -230,6 +363,97 @@ type Organization struct {
 	Address   string `json:"address"`
 	Telephone string `json:"telephone"`
 	Email     string `json:"email"`
+	// RequireTOTP forces the members to use two-factor authentication
+	RequireTOTP bool `json:"requireTOTP"`
+	// KYBDocs are the know-your-business documents uploaded by the organization admins
+	KYBDocs []KYBDoc `json:"kybDocs"`
+	// Approvals are the moderator reviews of the KYB documents
+	Approvals []AccessApproval `json:"approvals"`
+}
+
+// KYBDoc is a know-your-business document of an organization stored in the docs collection
+type KYBDoc struct {
+	DocID     string     `json:"docID"`
+	Kind      KYBDocKind `json:"kind"`
+	Name      string     `json:"name"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// OrgMemberReq is an invitation to an organization or a user request to join it
+type OrgMemberReq struct {
+	ID          string           `json:"_key,omitempty"`
+	OrgID       string           `json:"orgID"`
+	Kind        OrgMemberReqKind `json:"kind"`
+	Email       *string          `json:"email"`
+	UserID      *string          `json:"userID"`
+	Role        OrgRole          `json:"role"`
+	Position    string           `json:"position,omitempty"`
+	Message     string           `json:"message"`
+	Status      Approval         `json:"status"`
+	CreatedBy   string           `json:"createdBy"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	RespondedBy *string          `json:"respondedBy"`
+	RespondedAt *time.Time       `json:"respondedAt"`
+}
+
+// SanctionEntry is a person or an entity from an imported sanctions list.
+// Names are stored normalized for the matching.
+type SanctionEntry struct {
+	ID string `json:"_key,omitempty"`
+	// List is the name of the imported sanctions list
+	List string `json:"list"`
+	// Ref is the entry identifier in the list
+	Ref       string   `json:"ref"`
+	Name      string   `json:"name"`
+	Aliases   []string `json:"aliases"`
+	NormNames []string `json:"normNames"`
+}
+
+// ScreeningHit is a user or an organization name matching a sanctions list entry.
+// Pending and confirmed hits block trades of the subject.
+type ScreeningHit struct {
+	ID          string             `json:"_key,omitempty"`
+	SubjectKind ScreeningSubject   `json:"subjectKind"`
+	SubjectID   string             `json:"subjectID"`
+	SubjectName string             `json:"subjectName"`
+	List        string             `json:"list"`
+	EntryRef    string             `json:"entryRef"`
+	EntryName   string             `json:"entryName"`
+	Score       float64            `json:"score"`
+	Status      ScreeningHitStatus `json:"status"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	ReviewedBy  *string            `json:"reviewedBy"`
+	ReviewedAt  *time.Time         `json:"reviewedAt"`
+	Reason      *string            `json:"reason"`
+}
+
+// OrgAuditEntry records an organization membership change
+type OrgAuditEntry struct {
+	ID        string         `json:"_key,omitempty"`
+	OrgID     string         `json:"orgID"`
+	Action    OrgAuditAction `json:"action"`
+	ActorID   string         `json:"actorID"`
+	UserID    *string        `json:"userID"`
+	Email     *string        `json:"email"`
+	Role      *OrgRole       `json:"role"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// APIKey is an organization key for machine-to-machine integrations. Requests
+// authenticated with the key act as the key creator, limited to the key scopes.
+// Only the key hash is stored.
+type APIKey struct {
+	ID         string        `json:"_key,omitempty"`
+	OrgID      string        `json:"orgID"`
+	Name       string        `json:"name"`
+	Scopes     []APIKeyScope `json:"scopes"`
+	KeyHash    string        `json:"keyHash"`
+	CreatedBy  string        `json:"createdBy"`
+	CreatedAt  time.Time     `json:"createdAt"`
+	ExpiresAt  *time.Time    `json:"expiresAt"`
+	LastUsedAt *time.Time    `json:"lastUsedAt"`
+	RevokedAt  *time.Time    `json:"revokedAt"`
 }
 
 // TxLog type for transaction logging into DB
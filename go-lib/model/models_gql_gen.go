@@ -9,6 +9,19 @@ import (
 	"time"
 )
 
+type APIKeyCreated struct {
+	// the secret key; only its hash is stored
+	Key    string `json:"key"`
+	APIKey APIKey `json:"apiKey"`
+}
+
+type APIKeyInput struct {
+	OrgID     string        `json:"orgID"`
+	Name      string        `json:"name"`
+	Scopes    []APIKeyScope `json:"scopes"`
+	ExpiresAt *time.Time    `json:"expiresAt"`
+}
+
 // AdminUser; User with special Admin information
 type AdminUser struct {
 	User      *User            `json:"user"`
@@ -17,8 +30,13 @@ type AdminUser struct {
 
 // AuthUser; after user login, backend sends user token
 type AuthUser struct {
-	ID    string `json:"id"`
-	Token string `json:"token"`
+	ID string `json:"id"`
+	// short lived access token
+	Token *string `json:"token"`
+	// single use token to get a new access token, see userTokenRefresh
+	RefreshToken *string `json:"refreshToken"`
+	// set instead of the tokens when the login requires a TOTP code, see userTOTPLogin
+	MfaToken *string `json:"mfaToken"`
 }
 
 // Password change data
@@ -27,6 +45,32 @@ type ChangePasswordInput struct {
 	NewPassword string `json:"newPassword"`
 }
 
+// Changed document metadata field
+type DocMetaChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// HD wallet registration data.
+// pubKeys are the Stellar public keys of the m/44'/148'/<index>' paths, starting with
+// index 0. The client derives them, the private keys never leave the client. Each new
+// trade uses the next unused key.
+type HDWalletInput struct {
+	Name       string   `json:"name"`
+	Note       string   `json:"note"`
+	PubKeys    []string `json:"pubKeys"`
+	SetDefault bool     `json:"setDefault"`
+}
+
+// Trade waiting for the moderator attention
+type ModerationQueueItem struct {
+	Trade Trade `json:"trade"`
+	// number of requests and stage documents waiting for an approval
+	PendingCount    int        `json:"pendingCount"`
+	OldestPendingAt *time.Time `json:"oldestPendingAt"`
+}
+
 // New trade fields
 type NewStageInput struct {
 	Tid         string `json:"tid"`
@@ -34,6 +78,8 @@ type NewStageInput struct {
 	Name        string `json:"name"`
 	Description string `json:"description"`
 	Reason      string `json:"reason"`
+	// indexes of the existing stages which must be closed or deleted before closing this stage
+	DependsOn []uint `json:"dependsOn"`
 }
 
 // Trade creation fields
@@ -44,6 +90,10 @@ type NewTradeInput struct {
 	BuyerID      string  `json:"buyerID"`
 	Description  *string `json:"description"`
 	TradeOfferID *string `json:"tradeOfferID"`
+	// organization of the trade creator
+	OrgID *string `json:"orgID"`
+	// additional parties: brokers, inspection agencies, banks and freight forwarders
+	Participants []TradeParticipantInput `json:"participants"`
 }
 
 // New user input data
@@ -55,8 +105,10 @@ type NewUserInput struct {
 	Avatar    *string `json:"avatar"`
 	PublicKey string  `json:"publicKey"`
 	Biography *string `json:"biography"`
-	OrgID     string  `json:"orgID"`
-	OrgRole   string  `json:"orgRole"`
+	// the signup sends a join request to the organization
+	OrgID string `json:"orgID"`
+	// position in the organization
+	OrgRole string `json:"orgRole"`
 }
 
 // Organization input data
@@ -67,6 +119,19 @@ type OrgInput struct {
 	Email     string `json:"email"`
 }
 
+// Organization member with the role
+type OrgMember struct {
+	User User    `json:"user"`
+	Role OrgRole `json:"role"`
+}
+
+// Relay page info
+type PageInfo struct {
+	HasNextPage bool `json:"hasNextPage"`
+	// cursor of the last edge; use it as `after` to fetch the next page
+	EndCursor *string `json:"endCursor"`
+}
+
 // StellarNet; stellar network infomation, we provide it to frontend
 type StellarNet struct {
 	Name       string `json:"name"`
@@ -74,6 +139,12 @@ type StellarNet struct {
 	Passphrase string `json:"passphrase"`
 }
 
+// TOTPEnrollment is a new TOTP secret; uri is encoded in the QR code for authenticator apps
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
 // TradeActorWallet; data about an actor in a trade
 type TradeActorWallet struct {
 	PubKey   string `json:"pubKey"`
@@ -81,6 +152,65 @@ type TradeActorWallet struct {
 	WalletID string `json:"walletID"`
 }
 
+type TradeConnection struct {
+	Edges    []TradeEdge `json:"edges"`
+	PageInfo PageInfo    `json:"pageInfo"`
+	// number of all trades matching the filter
+	TotalCount int `json:"totalCount"`
+}
+
+type TradeEdge struct {
+	Cursor string `json:"cursor"`
+	Node   Trade  `json:"node"`
+}
+
+// Trade list filter. All the fields are optional
+type TradeFilter struct {
+	Status *TradeStatus `json:"status"`
+	// trades where the given user is a buyer or a seller
+	Counterparty *string    `json:"counterparty"`
+	TemplateID   *string    `json:"templateID"`
+	CreatedFrom  *time.Time `json:"createdFrom"`
+	CreatedTo    *time.Time `json:"createdTo"`
+}
+
+// counter-offer input data
+type TradeOfferBidInput struct {
+	OfferID string `json:"offerID"`
+	// required when the offer creator counters a bid
+	BidderID *string     `json:"bidderID"`
+	Price    *float64    `json:"price"`
+	Vol      *int        `json:"vol"`
+	Shipment []time.Time `json:"shipment"`
+	Incoterm *Incoterm   `json:"incoterm"`
+	Message  string      `json:"message"`
+}
+
+type TradeOfferConnection struct {
+	Edges    []TradeOfferEdge `json:"edges"`
+	PageInfo PageInfo         `json:"pageInfo"`
+	// number of all offers matching the filter
+	TotalCount int `json:"totalCount"`
+}
+
+type TradeOfferEdge struct {
+	Cursor string     `json:"cursor"`
+	Node   TradeOffer `json:"node"`
+}
+
+// Trade offer list filter. All the fields are optional
+type TradeOfferFilter struct {
+	Commodity *string   `json:"commodity"`
+	Currency  *Currency `json:"currency"`
+	Incoterm  *Incoterm `json:"incoterm"`
+	IsSell    *bool     `json:"isSell"`
+	PriceMin  *float64  `json:"priceMin"`
+	PriceMax  *float64  `json:"priceMax"`
+	// offers with the shipment window overlapping the [shipmentFrom, shipmentTo] window
+	ShipmentFrom *time.Time `json:"shipmentFrom"`
+	ShipmentTo   *time.Time `json:"shipmentTo"`
+}
+
 // trade offer input data
 type TradeOfferInput struct {
 	Price       float64        `json:"price"`
@@ -102,6 +232,20 @@ type TradeOfferInput struct {
 	DocID       *string        `json:"docID"`
 }
 
+// offer matching another offer
+type TradeOfferMatch struct {
+	Offer TradeOffer `json:"offer"`
+	// match quality between 0 and 1; higher is better
+	Score float64 `json:"score"`
+}
+
+// Additional trade party
+type TradeParticipantInput struct {
+	UserID string `json:"userID"`
+	// one of: r, i, k, f
+	Role TradeActor `json:"role"`
+}
+
 // Context of a document
 type TradeStageDocPath struct {
 	Tid          string `json:"tid"`
@@ -110,12 +254,29 @@ type TradeStageDocPath struct {
 	StageDocHash string `json:"stageDocHash"`
 }
 
+// Version of a trade stage document
+type TradeStageDocVersion struct {
+	StageDocIdx uint          `json:"stageDocIdx"`
+	Version     int           `json:"version"`
+	StageDoc    TradeStageDoc `json:"stageDoc"`
+	// document metadata changes compared to the previous version
+	Changes []DocMetaChange `json:"changes"`
+}
+
 // Context of a trade stage
 type TradeStagePath struct {
 	Tid      string `json:"tid"`
 	StageIdx uint   `json:"stageIdx"`
 }
 
+// Trade template creation fields
+type TradeTemplateInput struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// the first stage must be the trade contract
+	Stages []TradeStageTemplate `json:"stages"`
+}
+
 // User data for logging in
 type UserLoginInput struct {
 	Email    string `json:"email"`
@@ -128,7 +289,7 @@ type UserOrgMap struct {
 	Role string       `json:"role"`
 }
 
-// User orgMap input
+// User orgMap input; role is the position in the organization
 type UserOrgMapInput struct {
 	ID   string `json:"id"`
 	Role string `json:"role"`
@@ -136,12 +297,60 @@ type UserOrgMapInput struct {
 
 // User Profile update data
 type UserProfileInput struct {
-	FirstName string            `json:"firstName"`
-	LastName  string            `json:"lastName"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	// sends join requests to the organizations the user isn't member of
 	OrgMap    []UserOrgMapInput `json:"orgMap"`
 	Biography string            `json:"biography"`
 }
 
+// OperationsAllowedToAnAPIKey
+type APIKeyScope string
+
+const (
+	// read trades and download their documents
+	APIKeyScopeReadTrades APIKeyScope = "readTrades"
+	// upload trade stage documents
+	APIKeyScopeUploadDocs APIKeyScope = "uploadDocs"
+	// create and manage trade offers and bids
+	APIKeyScopeManageOffers APIKeyScope = "manageOffers"
+)
+
+var AllAPIKeyScope = []APIKeyScope{
+	APIKeyScopeReadTrades,
+	APIKeyScopeUploadDocs,
+	APIKeyScopeManageOffers,
+}
+
+func (e APIKeyScope) IsValid() bool {
+	switch e {
+	case APIKeyScopeReadTrades, APIKeyScopeUploadDocs, APIKeyScopeManageOffers:
+		return true
+	}
+	return false
+}
+
+func (e APIKeyScope) String() string {
+	return string(e)
+}
+
+func (e *APIKeyScope) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = APIKeyScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid APIKeyScope", str)
+	}
+	return nil
+}
+
+func (e APIKeyScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 // ApprovalStatusOfATradeOrAStage
 type Approval string
 
@@ -341,6 +550,96 @@ func (e Incoterm) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+// KindOfTheOrganizationKybDocument
+type KYBDocKind string
+
+const (
+	KYBDocKindRegistrationCertificate KYBDocKind = "registrationCertificate"
+	KYBDocKindBeneficialOwners        KYBDocKind = "beneficialOwners"
+)
+
+var AllKYBDocKind = []KYBDocKind{
+	KYBDocKindRegistrationCertificate,
+	KYBDocKindBeneficialOwners,
+}
+
+func (e KYBDocKind) IsValid() bool {
+	switch e {
+	case KYBDocKindRegistrationCertificate, KYBDocKindBeneficialOwners:
+		return true
+	}
+	return false
+}
+
+func (e KYBDocKind) String() string {
+	return string(e)
+}
+
+func (e *KYBDocKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = KYBDocKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid KYBDocKind", str)
+	}
+	return nil
+}
+
+func (e KYBDocKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// OrganizationKyb(knowYourBusiness)VerificationStatus
+type KYBStatus string
+
+const (
+	// no documents were submitted
+	KYBStatusUnverified KYBStatus = "unverified"
+	// documents are waiting for the review
+	KYBStatusPending  KYBStatus = "pending"
+	KYBStatusVerified KYBStatus = "verified"
+	KYBStatusRejected KYBStatus = "rejected"
+)
+
+var AllKYBStatus = []KYBStatus{
+	KYBStatusUnverified,
+	KYBStatusPending,
+	KYBStatusVerified,
+	KYBStatusRejected,
+}
+
+func (e KYBStatus) IsValid() bool {
+	switch e {
+	case KYBStatusUnverified, KYBStatusPending, KYBStatusVerified, KYBStatusRejected:
+		return true
+	}
+	return false
+}
+
+func (e KYBStatus) String() string {
+	return string(e)
+}
+
+func (e *KYBStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = KYBStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid KYBStatus", str)
+	}
+	return nil
+}
+
+func (e KYBStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 type NotifType string
 
 const (
@@ -426,6 +725,312 @@ func (e OfferPriceType) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+// OrganizationMembershipChangeRecordedInTheAuditLog
+type OrgAuditAction string
+
+const (
+	OrgAuditActionCreated            OrgAuditAction = "created"
+	OrgAuditActionInvited            OrgAuditAction = "invited"
+	OrgAuditActionInvitationAccepted OrgAuditAction = "invitationAccepted"
+	OrgAuditActionInvitationRejected OrgAuditAction = "invitationRejected"
+	OrgAuditActionJoinRequested      OrgAuditAction = "joinRequested"
+	OrgAuditActionJoinApproved       OrgAuditAction = "joinApproved"
+	OrgAuditActionJoinRejected       OrgAuditAction = "joinRejected"
+	OrgAuditActionRoleChanged        OrgAuditAction = "roleChanged"
+	OrgAuditActionMemberRemoved      OrgAuditAction = "memberRemoved"
+)
+
+var AllOrgAuditAction = []OrgAuditAction{
+	OrgAuditActionCreated,
+	OrgAuditActionInvited,
+	OrgAuditActionInvitationAccepted,
+	OrgAuditActionInvitationRejected,
+	OrgAuditActionJoinRequested,
+	OrgAuditActionJoinApproved,
+	OrgAuditActionJoinRejected,
+	OrgAuditActionRoleChanged,
+	OrgAuditActionMemberRemoved,
+}
+
+func (e OrgAuditAction) IsValid() bool {
+	switch e {
+	case OrgAuditActionCreated, OrgAuditActionInvited, OrgAuditActionInvitationAccepted, OrgAuditActionInvitationRejected, OrgAuditActionJoinRequested, OrgAuditActionJoinApproved, OrgAuditActionJoinRejected, OrgAuditActionRoleChanged, OrgAuditActionMemberRemoved:
+		return true
+	}
+	return false
+}
+
+func (e OrgAuditAction) String() string {
+	return string(e)
+}
+
+func (e *OrgAuditAction) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrgAuditAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrgAuditAction", str)
+	}
+	return nil
+}
+
+func (e OrgAuditAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OrgMemberReqKind string
+
+const (
+	// admin invites an email
+	OrgMemberReqKindInvitation OrgMemberReqKind = "invitation"
+	// user asks to join
+	OrgMemberReqKindJoinRequest OrgMemberReqKind = "joinRequest"
+)
+
+var AllOrgMemberReqKind = []OrgMemberReqKind{
+	OrgMemberReqKindInvitation,
+	OrgMemberReqKindJoinRequest,
+}
+
+func (e OrgMemberReqKind) IsValid() bool {
+	switch e {
+	case OrgMemberReqKindInvitation, OrgMemberReqKindJoinRequest:
+		return true
+	}
+	return false
+}
+
+func (e OrgMemberReqKind) String() string {
+	return string(e)
+}
+
+func (e *OrgMemberReqKind) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrgMemberReqKind(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrgMemberReqKind", str)
+	}
+	return nil
+}
+
+func (e OrgMemberReqKind) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// RoleOfAUserInAnOrganization
+type OrgRole string
+
+const (
+	// manages the members, the settings and the API keys
+	OrgRoleAdmin OrgRole = "admin"
+	// acts for the organization
+	OrgRoleMember OrgRole = "member"
+	// has a read only access to the organization trades and trade offers
+	OrgRoleViewer OrgRole = "viewer"
+)
+
+var AllOrgRole = []OrgRole{
+	OrgRoleAdmin,
+	OrgRoleMember,
+	OrgRoleViewer,
+}
+
+func (e OrgRole) IsValid() bool {
+	switch e {
+	case OrgRoleAdmin, OrgRoleMember, OrgRoleViewer:
+		return true
+	}
+	return false
+}
+
+func (e OrgRole) String() string {
+	return string(e)
+}
+
+func (e *OrgRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrgRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OrgRole", str)
+	}
+	return nil
+}
+
+func (e OrgRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// PermissionGrantedByTheUserRoles
+type Permission string
+
+const (
+	// list users and organizations
+	PermissionUserRead Permission = "userRead"
+	// list all users with their approval details
+	PermissionUserReadAll Permission = "userReadAll"
+	// approve or reject users
+	PermissionUserApprove Permission = "userApprove"
+	PermissionOrgCreate   Permission = "orgCreate"
+	// manage the organization settings
+	PermissionOrgManage Permission = "orgManage"
+	// manage the organization API keys; organization members only
+	PermissionAPIKeyManage Permission = "apiKeyManage"
+	// read own trades and trade templates
+	PermissionTradeRead Permission = "tradeRead"
+	// read all trades
+	PermissionTradeReadAll Permission = "tradeReadAll"
+	// create and act on own trades
+	PermissionTradeWrite Permission = "tradeWrite"
+	// act on all trades as a moderator
+	PermissionTradeModerate Permission = "tradeModerate"
+	PermissionOfferRead     Permission = "offerRead"
+	PermissionOfferWrite    Permission = "offerWrite"
+)
+
+var AllPermission = []Permission{
+	PermissionUserRead,
+	PermissionUserReadAll,
+	PermissionUserApprove,
+	PermissionOrgCreate,
+	PermissionOrgManage,
+	PermissionAPIKeyManage,
+	PermissionTradeRead,
+	PermissionTradeReadAll,
+	PermissionTradeWrite,
+	PermissionTradeModerate,
+	PermissionOfferRead,
+	PermissionOfferWrite,
+}
+
+func (e Permission) IsValid() bool {
+	switch e {
+	case PermissionUserRead, PermissionUserReadAll, PermissionUserApprove, PermissionOrgCreate, PermissionOrgManage, PermissionAPIKeyManage, PermissionTradeRead, PermissionTradeReadAll, PermissionTradeWrite, PermissionTradeModerate, PermissionOfferRead, PermissionOfferWrite:
+		return true
+	}
+	return false
+}
+
+func (e Permission) String() string {
+	return string(e)
+}
+
+func (e *Permission) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Permission(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Permission", str)
+	}
+	return nil
+}
+
+func (e Permission) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// ModeratorDecisionOnASanctionsScreeningHit
+type ScreeningHitStatus string
+
+const (
+	// waiting for the review; blocks trades
+	ScreeningHitStatusPending ScreeningHitStatus = "pending"
+	// the user or the organization is on the list; blocks trades
+	ScreeningHitStatusConfirmed ScreeningHitStatus = "confirmed"
+	// false positive
+	ScreeningHitStatusCleared ScreeningHitStatus = "cleared"
+)
+
+var AllScreeningHitStatus = []ScreeningHitStatus{
+	ScreeningHitStatusPending,
+	ScreeningHitStatusConfirmed,
+	ScreeningHitStatusCleared,
+}
+
+func (e ScreeningHitStatus) IsValid() bool {
+	switch e {
+	case ScreeningHitStatusPending, ScreeningHitStatusConfirmed, ScreeningHitStatusCleared:
+		return true
+	}
+	return false
+}
+
+func (e ScreeningHitStatus) String() string {
+	return string(e)
+}
+
+func (e *ScreeningHitStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ScreeningHitStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ScreeningHitStatus", str)
+	}
+	return nil
+}
+
+func (e ScreeningHitStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// KindOfTheScreenedSubject
+type ScreeningSubject string
+
+const (
+	ScreeningSubjectUser         ScreeningSubject = "user"
+	ScreeningSubjectOrganization ScreeningSubject = "organization"
+)
+
+var AllScreeningSubject = []ScreeningSubject{
+	ScreeningSubjectUser,
+	ScreeningSubjectOrganization,
+}
+
+func (e ScreeningSubject) IsValid() bool {
+	switch e {
+	case ScreeningSubjectUser, ScreeningSubjectOrganization:
+		return true
+	}
+	return false
+}
+
+func (e ScreeningSubject) String() string {
+	return string(e)
+}
+
+func (e *ScreeningSubject) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ScreeningSubject(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ScreeningSubject", str)
+	}
+	return nil
+}
+
+func (e ScreeningSubject) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 // SimpleApprovalIsABasicStatusForApprovals
 type SimpleApproval string
 
@@ -480,6 +1085,14 @@ const (
 	TradeActorS TradeActor = "s"
 	// Moderator
 	TradeActorM TradeActor = "m"
+	// Broker
+	TradeActorR TradeActor = "r"
+	// Inspection agency
+	TradeActorI TradeActor = "i"
+	// Bank
+	TradeActorK TradeActor = "k"
+	// Freight forwarder
+	TradeActorF TradeActor = "f"
 )
 
 var AllTradeActor = []TradeActor{
@@ -487,11 +1100,15 @@ var AllTradeActor = []TradeActor{
 	TradeActorB,
 	TradeActorS,
 	TradeActorM,
+	TradeActorR,
+	TradeActorI,
+	TradeActorK,
+	TradeActorF,
 }
 
 func (e TradeActor) IsValid() bool {
 	switch e {
-	case TradeActorN, TradeActorB, TradeActorS, TradeActorM:
+	case TradeActorN, TradeActorB, TradeActorS, TradeActorM, TradeActorR, TradeActorI, TradeActorK, TradeActorF:
 		return true
 	}
 	return false
@@ -518,6 +1135,136 @@ func (e TradeActor) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
+// SortOrderOfTradeOffers
+type TradeOfferOrder string
+
+const (
+	TradeOfferOrderCreatedAsc  TradeOfferOrder = "created_asc"
+	TradeOfferOrderCreatedDesc TradeOfferOrder = "created_desc"
+	TradeOfferOrderPriceAsc    TradeOfferOrder = "price_asc"
+	TradeOfferOrderPriceDesc   TradeOfferOrder = "price_desc"
+)
+
+var AllTradeOfferOrder = []TradeOfferOrder{
+	TradeOfferOrderCreatedAsc,
+	TradeOfferOrderCreatedDesc,
+	TradeOfferOrderPriceAsc,
+	TradeOfferOrderPriceDesc,
+}
+
+func (e TradeOfferOrder) IsValid() bool {
+	switch e {
+	case TradeOfferOrderCreatedAsc, TradeOfferOrderCreatedDesc, TradeOfferOrderPriceAsc, TradeOfferOrderPriceDesc:
+		return true
+	}
+	return false
+}
+
+func (e TradeOfferOrder) String() string {
+	return string(e)
+}
+
+func (e *TradeOfferOrder) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TradeOfferOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TradeOfferOrder", str)
+	}
+	return nil
+}
+
+func (e TradeOfferOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// SortOrderOfTrades
+type TradeOrder string
+
+const (
+	TradeOrderCreatedAsc  TradeOrder = "created_asc"
+	TradeOrderCreatedDesc TradeOrder = "created_desc"
+)
+
+var AllTradeOrder = []TradeOrder{
+	TradeOrderCreatedAsc,
+	TradeOrderCreatedDesc,
+}
+
+func (e TradeOrder) IsValid() bool {
+	switch e {
+	case TradeOrderCreatedAsc, TradeOrderCreatedDesc:
+		return true
+	}
+	return false
+}
+
+func (e TradeOrder) String() string {
+	return string(e)
+}
+
+func (e *TradeOrder) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TradeOrder(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TradeOrder", str)
+	}
+	return nil
+}
+
+func (e TradeOrder) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// TradeStatusDerivedFromTheTradeCloseRequests
+type TradeStatus string
+
+const (
+	TradeStatusOpen   TradeStatus = "open"
+	TradeStatusClosed TradeStatus = "closed"
+)
+
+var AllTradeStatus = []TradeStatus{
+	TradeStatusOpen,
+	TradeStatusClosed,
+}
+
+func (e TradeStatus) IsValid() bool {
+	switch e {
+	case TradeStatusOpen, TradeStatusClosed:
+		return true
+	}
+	return false
+}
+
+func (e TradeStatus) String() string {
+	return string(e)
+}
+
+func (e *TradeStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TradeStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TradeStatus", str)
+	}
+	return nil
+}
+
+func (e TradeStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
 // TxTradeEntityEnumsForTransactionBuild
 type TxTradeEntity string
 
@@ -572,16 +1319,28 @@ const (
 	UserRoleTrader UserRole = "trader"
 	// Moderator has supervisor privileges for trade
 	UserRoleModerator UserRole = "moderator"
+	// Organization admin manages the organization settings and API keys
+	UserRoleOrgAdmin UserRole = "orgAdmin"
+	// Viewer has a read only access to own trades and trade offers
+	UserRoleViewer UserRole = "viewer"
+	// Auditor has a read only access to all trades and users
+	UserRoleAuditor UserRole = "auditor"
+	// Compliance officer reviews all trades and approves users
+	UserRoleComplianceOfficer UserRole = "complianceOfficer"
 )
 
 var AllUserRole = []UserRole{
 	UserRoleTrader,
 	UserRoleModerator,
+	UserRoleOrgAdmin,
+	UserRoleViewer,
+	UserRoleAuditor,
+	UserRoleComplianceOfficer,
 }
 
 func (e UserRole) IsValid() bool {
 	switch e {
-	case UserRoleTrader, UserRoleModerator:
+	case UserRoleTrader, UserRoleModerator, UserRoleOrgAdmin, UserRoleViewer, UserRoleAuditor, UserRoleComplianceOfficer:
 		return true
 	}
 	return false
@@ -0,0 +1,69 @@
+package model
+
+// KYBStatus returns the organization verification status. The last review decides
+// the status unless new documents were uploaded after it; a verified organization
+// stays verified while the new documents wait for the review.
+func (o *Organization) KYBStatus() KYBStatus {
+	if n := len(o.Approvals); n > 0 {
+		last := o.Approvals[n-1]
+		if last.Status == SimpleApprovalApproved {
+			return KYBStatusVerified
+		}
+		if !o.hasKYBDocsAfter(last) {
+			return KYBStatusRejected
+		}
+		return KYBStatusPending
+	}
+	if len(o.KYBDocs) > 0 {
+		return KYBStatusPending
+	}
+	return KYBStatusUnverified
+}
+
+func (o *Organization) hasKYBDocsAfter(a AccessApproval) bool {
+	for _, d := range o.KYBDocs {
+		if d.CreatedAt.After(a.CreatedAt) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsVerified checks if the organization passed the KYB review
+func (o *Organization) IsVerified() bool {
+	return o.KYBStatus() == KYBStatusVerified
+}
+
+// MissingKYBDocs returns kinds of the required documents which were not uploaded
+func (o *Organization) MissingKYBDocs() []KYBDocKind {
+	var missing []KYBDocKind
+	for _, k := range AllKYBDocKind {
+		found := false
+		for _, d := range o.KYBDocs {
+			if d.Kind == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, k)
+		}
+	}
+	return missing
+}
+
+// HasKYBDoc checks if the document is one of the organization KYB documents
+func (o *Organization) HasKYBDoc(docID string) bool {
+	for _, d := range o.KYBDocs {
+		if d.DocID == docID {
+			return true
+		}
+	}
+	return false
+}
+
+// CanReadKYBDocs checks if the user can read the organization KYB documents:
+// the organization admins and the reviewers can.
+func (u *User) CanReadKYBDocs(orgID string) bool {
+	return u.CanManageOrg(orgID) || u.Can(PermissionUserApprove)
+}
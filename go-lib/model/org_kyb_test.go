@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestOrgKYBStatus(c *C) {
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	o := Organization{}
+	c.Check(o.KYBStatus(), Equals, KYBStatusUnverified)
+	c.Check(o.MissingKYBDocs(), DeepEquals, AllKYBDocKind)
+
+	o.KYBDocs = []KYBDoc{{DocID: "d1", Kind: KYBDocKindRegistrationCertificate, CreatedAt: t0}}
+	c.Check(o.KYBStatus(), Equals, KYBStatusPending)
+	c.Check(o.MissingKYBDocs(), DeepEquals, []KYBDocKind{KYBDocKindBeneficialOwners})
+	c.Check(o.HasKYBDoc("d1"), IsTrue)
+	c.Check(o.HasKYBDoc("d2"), IsFalse)
+
+	o.Approvals = []AccessApproval{{Status: SimpleApprovalRejected, CreatedAt: t0.Add(time.Hour)}}
+	c.Check(o.KYBStatus(), Equals, KYBStatusRejected)
+	c.Check(o.IsVerified(), IsFalse)
+
+	// new documents after the rejection are reviewed again
+	o.KYBDocs = append(o.KYBDocs, KYBDoc{DocID: "d2", Kind: KYBDocKindBeneficialOwners, CreatedAt: t0.Add(2 * time.Hour)})
+	c.Check(o.KYBStatus(), Equals, KYBStatusPending)
+	c.Check(o.MissingKYBDocs(), HasLen, 0)
+
+	o.Approvals = append(o.Approvals, AccessApproval{Status: SimpleApprovalApproved, CreatedAt: t0.Add(3 * time.Hour)})
+	c.Check(o.KYBStatus(), Equals, KYBStatusVerified)
+	c.Check(o.IsVerified(), IsTrue)
+
+	// verified organization stays verified when it uploads new documents
+	o.KYBDocs = append(o.KYBDocs, KYBDoc{DocID: "d3", Kind: KYBDocKindBeneficialOwners, CreatedAt: t0.Add(4 * time.Hour)})
+	c.Check(o.IsVerified(), IsTrue)
+}
+
+func (s *S) TestCanReadKYBDocs(c *C) {
+	admin := User{Roles: []UserRole{UserRoleTrader}, Organizations: map[string]string{"o1": "admin", "o2": "member"}}
+	c.Check(admin.CanReadKYBDocs("o1"), IsTrue)
+	c.Check(admin.CanReadKYBDocs("o2"), IsFalse)
+	officer := User{Roles: []UserRole{UserRoleComplianceOfficer}}
+	c.Check(officer.CanReadKYBDocs("o2"), IsTrue)
+	viewer := User{Roles: []UserRole{UserRoleViewer}}
+	c.Check(viewer.CanReadKYBDocs("o1"), IsFalse)
+}
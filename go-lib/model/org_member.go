@@ -0,0 +1,34 @@
+package model
+
+// SetID implements dal.HasID interface
+func (r *OrgMemberReq) SetID(id string) {
+	r.ID = id
+}
+
+// IsPending checks if the invitation or the join request waits for a response
+func (r *OrgMemberReq) IsPending() bool {
+	return r.Status == ApprovalPending
+}
+
+// IsInvitationFor checks if the invitation was sent to a verified email of the user
+func (r *OrgMemberReq) IsInvitationFor(u *User) bool {
+	return r.Kind == OrgMemberReqKindInvitation && r.Email != nil && u.IsEmailVerified(*r.Email)
+}
+
+// SetID implements dal.HasID interface
+func (e *OrgAuditEntry) SetID(id string) {
+	e.ID = id
+}
+
+// OrgRole returns the user role in the organization. Memberships created before the
+// roles were introduced store a free text position; they are members.
+func (u *User) OrgRole(orgID string) (OrgRole, bool) {
+	role, ok := u.Organizations[orgID]
+	if !ok {
+		return "", false
+	}
+	if r := OrgRole(role); r.IsValid() {
+		return r, true
+	}
+	return OrgRoleMember, true
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestUserOrgRole(c *C) {
+	u := User{Organizations: map[string]string{"a": "admin", "v": "viewer", "old": "Chief Trader"}}
+	role, ok := u.OrgRole("a")
+	c.Check(ok, IsTrue)
+	c.Check(role, Equals, OrgRoleAdmin)
+	role, _ = u.OrgRole("v")
+	c.Check(role, Equals, OrgRoleViewer)
+	role, ok = u.OrgRole("old")
+	c.Check(ok, IsTrue)
+	c.Check(role, Equals, OrgRoleMember)
+	_, ok = u.OrgRole("x")
+	c.Check(ok, IsFalse)
+}
+
+func (s *S) TestOrgMemberReq(c *C) {
+	email := "bob@example.com"
+	r := OrgMemberReq{Kind: OrgMemberReqKindInvitation, Email: &email, Status: ApprovalPending}
+	c.Check(r.IsPending(), IsTrue)
+	bob := User{Emails: []string{email}}
+	c.Check(r.IsInvitationFor(&bob), IsFalse, Comment("the email must be verified"))
+	bob.VerifiedEmails = []string{email}
+	c.Check(r.IsInvitationFor(&bob), IsTrue)
+	c.Check(r.IsInvitationFor(&User{VerifiedEmails: []string{"ann@example.com"}}), IsFalse)
+
+	r.Kind = OrgMemberReqKindJoinRequest
+	c.Check(r.IsInvitationFor(&bob), IsFalse)
+	r.Status = ApprovalApproved
+	c.Check(r.IsPending(), IsFalse)
+}
@@ -9,7 +9,7 @@ import (
 // ParseTradeActor converts string to TradeActor value
 func ParseTradeActor(s string, errb errstack.Builder) TradeActor {
 	switch TradeActor(s) {
-	case TradeActorB, TradeActorS, TradeActorM:
+	case TradeActorB, TradeActorS, TradeActorM, TradeActorR, TradeActorI, TradeActorK, TradeActorF:
 		return TradeActor(s)
 	}
 	errb.Put("owner", "wrong tradeActor value")
@@ -0,0 +1,118 @@
+package model
+
+import "github.com/robert-zaremba/errstack"
+
+// rolePermissions is the authorization policy: permissions granted by the user roles.
+// GraphQL fields declare the required permission with the @hasPermission directive.
+var rolePermissions = map[UserRole][]Permission{
+	UserRoleTrader: {
+		PermissionUserRead, PermissionOrgCreate, PermissionAPIKeyManage,
+		PermissionTradeRead, PermissionTradeWrite,
+		PermissionOfferRead, PermissionOfferWrite,
+	},
+	UserRoleModerator: {
+		PermissionUserRead, PermissionUserReadAll, PermissionUserApprove,
+		PermissionOrgCreate, PermissionOrgManage, PermissionAPIKeyManage,
+		PermissionTradeRead, PermissionTradeReadAll, PermissionTradeWrite, PermissionTradeModerate,
+		PermissionOfferRead, PermissionOfferWrite,
+	},
+	UserRoleOrgAdmin: {
+		PermissionUserRead, PermissionOrgCreate, PermissionOrgManage, PermissionAPIKeyManage,
+		PermissionTradeRead, PermissionOfferRead,
+	},
+	UserRoleViewer: {
+		PermissionUserRead, PermissionTradeRead, PermissionOfferRead,
+	},
+	UserRoleAuditor: {
+		PermissionUserRead, PermissionUserReadAll,
+		PermissionTradeRead, PermissionTradeReadAll, PermissionOfferRead,
+	},
+	UserRoleComplianceOfficer: {
+		PermissionUserRead, PermissionUserReadAll, PermissionUserApprove,
+		PermissionTradeRead, PermissionTradeReadAll, PermissionOfferRead,
+	},
+}
+
+// orgRolePermissions lists permissions granted by the user role in an organization.
+// They apply only to the organization resources.
+var orgRolePermissions = map[OrgRole][]Permission{
+	OrgRoleAdmin: {
+		PermissionOrgManage, PermissionAPIKeyManage,
+		PermissionTradeRead, PermissionTradeWrite, PermissionOfferRead, PermissionOfferWrite,
+	},
+	OrgRoleMember: {
+		PermissionAPIKeyManage,
+		PermissionTradeRead, PermissionTradeWrite, PermissionOfferRead, PermissionOfferWrite,
+	},
+	OrgRoleViewer: {
+		PermissionTradeRead, PermissionOfferRead,
+	},
+}
+
+// HasRole checks if the user has the role
+func (u *User) HasRole(role UserRole) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can checks if one of the user roles grants the permission
+func (u *User) Can(p Permission) bool {
+	if u == nil {
+		return false
+	}
+	for _, r := range u.Roles {
+		for _, rp := range rolePermissions[r] {
+			if rp == p {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CanInOrg checks if the user role in the organization grants the permission
+func (u *User) CanInOrg(orgID string, p Permission) bool {
+	if u == nil {
+		return false
+	}
+	role, ok := u.OrgRole(orgID)
+	if !ok {
+		return false
+	}
+	for _, rp := range orgRolePermissions[role] {
+		if rp == p {
+			return true
+		}
+	}
+	return false
+}
+
+// CanManageOrg checks if the user can manage the organization members and settings.
+// Organization admins manage only their organizations, moderators all of them.
+func (u *User) CanManageOrg(orgID string) bool {
+	return u.IsModerator() || u.CanInOrg(orgID, PermissionOrgManage)
+}
+
+// IsParticipant checks if the user is a trade party
+func (t *Trade) IsParticipant(u *User) bool {
+	if u == nil {
+		return false
+	}
+	_, ok := t.findParty(u.ID)
+	return ok
+}
+
+// CanBeReadBy checks whether a trade can be read by the given user
+func (t *Trade) CanBeReadBy(u *User) errstack.E {
+	if t.IsParticipant(u) || u.Can(PermissionTradeReadAll) {
+		return nil
+	}
+	if t.OrgID == nil || !u.CanInOrg(*t.OrgID, PermissionTradeRead) {
+		return ErrUnauthorized
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"io/ioutil"
+	"strings"
+
+	. "github.com/robert-zaremba/checkers"
+	"github.com/vektah/gqlparser"
+	"github.com/vektah/gqlparser/ast"
+	. "gopkg.in/check.v1"
+)
+
+func loadSchema(c *C) *ast.Schema {
+	input, err := ioutil.ReadFile("../../api/websrv_schema.graphql")
+	c.Assert(err, IsNil)
+	schema, gerr := gqlparser.LoadSchema(&ast.Source{Name: "websrv_schema.graphql", Input: string(input)})
+	c.Assert(gerr, IsNil)
+	return schema
+}
+
+func fieldPermission(f *ast.FieldDefinition) Permission {
+	d := f.Directives.ForName("hasPermission")
+	if d == nil {
+		return ""
+	}
+	return Permission(d.Arguments.ForName("permission").Value.Raw)
+}
+
+func (s *S) TestQueryPermissions(c *C) {
+	for _, f := range loadSchema(c).Query.Fields {
+		if f.Name == "user" || f.Name == "notifications" || f.Name == "notificationsTrade" || f.Name == "stellarNet" {
+			c.Check(fieldPermission(f), Equals, Permission(""), Comment(f.Name))
+			continue
+		}
+		if strings.HasPrefix(f.Name, "__") {
+			continue
+		}
+		c.Check(fieldPermission(f).IsValid(), IsTrue, Comment(f.Name))
+	}
+}
+
+func (s *S) TestCan(c *C) {
+	var u *User
+	c.Check(u.Can(PermissionTradeRead), IsFalse)
+	u = &User{Roles: []UserRole{UserRoleViewer, UserRoleAuditor}}
+	c.Check(u.Can(PermissionTradeReadAll), IsTrue)
+	c.Check(u.Can(PermissionTradeWrite), IsFalse)
+	for _, p := range AllPermission {
+		c.Check((&User{}).Can(p), IsFalse, Comment(p))
+	}
+
+	u = &User{Roles: []UserRole{UserRoleTrader}, Organizations: map[string]string{"o1": "admin", "o2": "Chief Trader"}}
+	c.Check(u.CanManageOrg("o1"), IsTrue)
+	c.Check(u.CanManageOrg("o2"), IsFalse)
+	c.Check(u.CanManageOrg("o3"), IsFalse)
+	u = &User{Roles: []UserRole{UserRoleModerator}}
+	c.Check(u.CanManageOrg("o2"), IsTrue)
+}
+
+func (s *S) TestCanInOrg(c *C) {
+	u := &User{Organizations: map[string]string{"a": "admin", "m": "Chief Trader", "v": "viewer"}}
+	var tcs = []struct {
+		orgID string
+		perm  Permission
+		can   bool
+	}{
+		{"a", PermissionOrgManage, true},
+		{"a", PermissionTradeWrite, true},
+		{"m", PermissionOrgManage, false},
+		{"m", PermissionAPIKeyManage, true},
+		{"m", PermissionOfferWrite, true},
+		{"v", PermissionTradeRead, true},
+		{"v", PermissionOfferWrite, false},
+		{"v", PermissionAPIKeyManage, false},
+		{"x", PermissionTradeRead, false},
+	}
+	for _, tc := range tcs {
+		c.Check(u.CanInOrg(tc.orgID, tc.perm), Equals, tc.can, Comment(tc.orgID, " ", tc.perm))
+	}
+	var nobody *User
+	c.Check(nobody.CanInOrg("a", PermissionTradeRead), IsFalse)
+}
+
+func (s *S) TestTradeCanBeReadBy(c *C) {
+	t := Trade{Buyer: TradeParticipant{UserID: "b"}, Seller: TradeParticipant{UserID: "s"}}
+	c.Check(t.CanBeReadBy(&User{ID: "b"}), IsNil)
+	c.Check(t.CanBeReadBy(&User{ID: "s"}), IsNil)
+	c.Check(t.CanBeReadBy(&User{ID: "x", Roles: []UserRole{UserRoleTrader}}), Equals, ErrUnauthorized)
+	c.Check(t.CanBeReadBy(&User{ID: "x", Roles: []UserRole{UserRoleAuditor}}), IsNil)
+	c.Check(t.CanBeModifiedBy(&User{ID: "x", Roles: []UserRole{UserRoleAuditor}}), Equals, ErrUnauthorized)
+	c.Check(t.CanBeModifiedBy(&User{ID: "x", Roles: []UserRole{UserRoleModerator}}), IsNil)
+	c.Check(t.CanBeReadBy(nil), Equals, ErrUnauthorized)
+
+	org := "o1"
+	t.OrgID = &org
+	c.Check(t.CanBeReadBy(&User{ID: "x", Organizations: map[string]string{"o1": "viewer"}}), IsNil)
+	c.Check(t.CanBeReadBy(&User{ID: "x", Organizations: map[string]string{"o2": "admin"}}), Equals, ErrUnauthorized)
+	c.Check(t.CanBeModifiedBy(&User{ID: "x", Organizations: map[string]string{"o1": "admin"}}), Equals, ErrUnauthorized)
+}
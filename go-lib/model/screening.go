@@ -0,0 +1,16 @@
+package model
+
+// SetID implements dal.HasID interface
+func (e *SanctionEntry) SetID(id string) {
+	e.ID = id
+}
+
+// SetID implements dal.HasID interface
+func (h *ScreeningHit) SetID(id string) {
+	h.ID = id
+}
+
+// BlocksTrades checks if the hit prevents the subject from trading
+func (h *ScreeningHit) BlocksTrades() bool {
+	return h.Status != ScreeningHitStatusCleared
+}
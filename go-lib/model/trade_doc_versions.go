@@ -0,0 +1,65 @@
+package model
+
+import "github.com/robert-zaremba/errstack"
+
+// VersionNum returns the version of the stage doc. Docs uploaded before the
+// versioning are first versions.
+func (d TradeStageDoc) VersionNum() int {
+	if d.Version < 1 {
+		return 1
+	}
+	return d.Version
+}
+
+// CanBeSuperseded checks if a new version of the stage doc can be uploaded.
+// Only the latest version of a rejected doc can be superseded.
+func (s TradeStage) CanBeSuperseded(idx uint) errstack.E {
+	d, errs := s.GetDoc(idx)
+	if errs != nil {
+		return errs
+	}
+	if d.SupersededBy != nil {
+		return errstack.NewReqF("Stage doc %d already has a newer version %d", idx, *d.SupersededBy)
+	}
+	if d.Status != ApprovalRejected {
+		return errstack.NewReq("Only a rejected document can be replaced by a new version")
+	}
+	return nil
+}
+
+// AddDoc appends the doc to the stage. When supersedes is not nil, the doc becomes
+// the next version of the stage doc with that index.
+func (s *TradeStage) AddDoc(d TradeStageDoc, supersedes *uint) errstack.E {
+	d.Version = 1
+	if supersedes != nil {
+		if errs := s.CanBeSuperseded(*supersedes); errs != nil {
+			return errs
+		}
+		idx := uint(len(s.Docs))
+		prev := &s.Docs[*supersedes]
+		prev.SupersededBy = &idx
+		d.Supersedes = supersedes
+		d.Version = prev.VersionNum() + 1
+	}
+	s.Docs = append(s.Docs, d)
+	return nil
+}
+
+// DocVersions returns indexes of all versions of the stage doc, oldest first
+func (s TradeStage) DocVersions(idx uint) []uint {
+	if int(idx) >= len(s.Docs) {
+		return nil
+	}
+	// the links always point to valid indexes, the limit only guards against bad data
+	limit := len(s.Docs)
+	cur := idx
+	for i := 0; i < limit && s.Docs[cur].Supersedes != nil; i++ {
+		cur = *s.Docs[cur].Supersedes
+	}
+	vs := []uint{cur}
+	for i := 0; i < limit && s.Docs[cur].SupersededBy != nil; i++ {
+		cur = *s.Docs[cur].SupersededBy
+		vs = append(vs, cur)
+	}
+	return vs
+}
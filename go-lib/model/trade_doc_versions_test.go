@@ -0,0 +1,48 @@
+package model
+
+import (
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type DocVersionsSuite struct{}
+
+var _ = Suite(&DocVersionsSuite{})
+
+func (s *DocVersionsSuite) TestAddDoc(c *C) {
+	st := NewTradeStage("s", "", 0, TradeActorN, nil)
+	c.Assert(st.AddDoc(TradeStageDoc{DocID: "d0", Status: ApprovalPending}, nil), IsNil)
+	c.Assert(st.AddDoc(TradeStageDoc{DocID: "d1", Status: ApprovalSubmitted}, nil), IsNil)
+	c.Check(st.Docs[0].VersionNum(), Equals, 1)
+
+	var idx0, idx2 uint = 0, 2
+	c.Check(st.AddDoc(TradeStageDoc{DocID: "d2"}, &idx0), ErrorContains, "Only a rejected document")
+	st.Docs[0].Status = ApprovalRejected
+	c.Assert(st.AddDoc(TradeStageDoc{DocID: "d2", Status: ApprovalPending}, &idx0), IsNil)
+	c.Check(*st.Docs[0].SupersededBy, Equals, uint(2))
+	c.Check(*st.Docs[2].Supersedes, Equals, uint(0))
+	c.Check(st.Docs[2].Version, Equals, 2)
+	// only the latest version can be superseded
+	c.Check(st.CanBeSuperseded(0), ErrorContains, "already has a newer version 2")
+	c.Check(st.CanBeSuperseded(5), NotNil)
+
+	st.Docs[2].Status = ApprovalRejected
+	c.Assert(st.AddDoc(TradeStageDoc{DocID: "d3"}, &idx2), IsNil)
+	c.Check(st.Docs[3].Version, Equals, 3)
+	for _, idx := range []uint{0, 2, 3} {
+		c.Check(st.DocVersions(idx), DeepEquals, []uint{0, 2, 3}, Comment(idx))
+	}
+	c.Check(st.DocVersions(1), DeepEquals, []uint{1})
+	c.Check(st.DocVersions(4), IsNil)
+}
+
+func (s *DocVersionsSuite) TestDocMetaChanges(c *C) {
+	prev := Doc{Name: "bl.pdf", Type: "pdf", Hash: "h1", CreatedBy: "u1"}
+	next := prev
+	c.Check(DocMetaChanges(&prev, &next), HasLen, 0)
+	next.Hash, next.Note = "h2", "fixed the port"
+	c.Check(DocMetaChanges(&prev, &next), DeepEquals, []DocMetaChange{
+		{Field: "note", Old: "", New: "fixed the port"},
+		{Field: "hash", Old: "h1", New: "h2"},
+	})
+}
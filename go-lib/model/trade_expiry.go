@@ -0,0 +1,112 @@
+package model
+
+import "time"
+
+// ExpiryAlertLeads are the times before the deadline when an expiry alert is sent,
+// in descending order.
+var ExpiryAlertLeads = []time.Duration{24 * time.Hour, time.Hour}
+
+// ExpiryEvent is an expiry alert or an expiration of a trade stage or a stage document
+type ExpiryEvent struct {
+	StageIdx uint
+	// DocIdx is nil for stage events
+	DocIdx *uint
+	// Lead is the time left to the deadline. Zero when the item has expired.
+	Lead time.Duration
+}
+
+// Expired returns true if the item has expired
+func (e ExpiryEvent) Expired() bool {
+	return e.Lead == 0
+}
+
+// UpdateExpiry moves pending stage documents and open stages past their deadline
+// into the expired state. It returns the events which should be notified:
+// expirations and alerts ahead of the deadlines.
+func (t *Trade) UpdateExpiry(now time.Time) []ExpiryEvent {
+	var events []ExpiryEvent
+	if t.CheckTradeClosed() {
+		return events
+	}
+	for i := range t.Stages {
+		s := &t.Stages[i]
+		if s.IsDeletedOrClosed() {
+			continue
+		}
+		for j := range s.Docs {
+			d := &s.Docs[j]
+			if d.Status != ApprovalPending || d.ExpiresAt.IsZero() {
+				continue
+			}
+			lead, ok := expiryLead(d.ExpiresAt, now, &d.AlertedBefore)
+			if !ok {
+				continue
+			}
+			if lead == 0 {
+				d.Status = ApprovalExpired
+			}
+			docIdx := uint(j)
+			events = append(events, ExpiryEvent{uint(i), &docIdx, lead})
+		}
+		if s.ExpiresAt == nil || s.isExpired() {
+			continue
+		}
+		lead, ok := expiryLead(*s.ExpiresAt, now, &s.AlertedBefore)
+		if !ok {
+			continue
+		}
+		if lead == 0 {
+			s.expire(now)
+		}
+		events = append(events, ExpiryEvent{uint(i), nil, lead})
+	}
+	return events
+}
+
+// expiryLead returns the lead of the alert which should be sent now, or zero lead
+// if the deadline has passed. `alerted` keeps the smallest lead already alerted,
+// so every alert is sent only once.
+func expiryLead(deadline, now time.Time, alerted *time.Duration) (time.Duration, bool) {
+	left := deadline.Sub(now)
+	if left <= 0 {
+		return 0, true
+	}
+	for i := len(ExpiryAlertLeads) - 1; i >= 0; i-- {
+		lead := ExpiryAlertLeads[i]
+		if left > lead {
+			continue
+		}
+		if *alerted != 0 && *alerted <= lead {
+			return 0, false
+		}
+		*alerted = lead
+		return lead, true
+	}
+	return 0, false
+}
+
+func (s *TradeStage) isExpired() bool {
+	l := len(s.CloseReqs)
+	return l > 0 && s.CloseReqs[l-1].Status == ApprovalExpired
+}
+
+// expire expires the pending close request or appends an expired one
+func (s *TradeStage) expire(now time.Time) {
+	l := len(s.CloseReqs)
+	if l > 0 && s.CloseReqs[l-1].Status == ApprovalPending {
+		s.CloseReqs[l-1].Status = ApprovalExpired
+		return
+	}
+	s.CloseReqs = append(s.CloseReqs, ApproveReq{
+		Status:    ApprovalExpired,
+		ReqActor:  s.Owner,
+		ReqAt:     now,
+		ReqReason: "The stage deadline has passed",
+	})
+}
+
+// SetExpiresAt sets the stage deadline and resets the expiry alerts
+func (s *TradeStage) SetExpiresAt(t *time.Time) {
+	s.ExpiresAt = t
+	s.AlertedBefore = 0
+}
@@ -0,0 +1,118 @@
+package model
+
+import (
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type ExpirySuite struct{}
+
+var _ = Suite(&ExpirySuite{})
+
+func mkExpiryTrade(docDeadline, stageDeadline time.Time) Trade {
+	return Trade{
+		Stages: []TradeStage{{
+			Owner:     TradeActorB,
+			ExpiresAt: &stageDeadline,
+			Docs: []TradeStageDoc{
+				{Status: ApprovalPending, ExpiresAt: docDeadline},
+				{Status: ApprovalApproved, ExpiresAt: docDeadline},
+			},
+		}},
+	}
+}
+
+func (s *ExpirySuite) TestAlertsAreSentOnce(c *C) {
+	now := time.Now().UTC()
+	t := mkExpiryTrade(now.Add(23*time.Hour), now.Add(48*time.Hour))
+	events := t.UpdateExpiry(now)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].StageIdx, Equals, uint(0))
+	c.Assert(events[0].DocIdx, NotNil)
+	c.Check(*events[0].DocIdx, Equals, uint(0))
+	c.Check(events[0].Lead, Equals, 24*time.Hour)
+	c.Check(events[0].Expired(), IsFalse)
+
+	c.Check(t.UpdateExpiry(now.Add(time.Minute)), HasLen, 0)
+
+	events = t.UpdateExpiry(now.Add(22*time.Hour + 30*time.Minute))
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Lead, Equals, time.Hour)
+	c.Check(t.UpdateExpiry(now.Add(22*time.Hour+40*time.Minute)), HasLen, 0)
+	c.Check(t.Stages[0].Docs[0].Status, Equals, ApprovalPending)
+}
+
+func (s *ExpirySuite) TestOnlySmallestLeadIsAlerted(c *C) {
+	now := time.Now().UTC()
+	t := mkExpiryTrade(now.Add(30*time.Minute), now.Add(48*time.Hour))
+	events := t.UpdateExpiry(now)
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Lead, Equals, time.Hour)
+	c.Check(t.UpdateExpiry(now), HasLen, 0)
+}
+
+func (s *ExpirySuite) TestExpire(c *C) {
+	now := time.Now().UTC()
+	t := mkExpiryTrade(now.Add(-time.Minute), now.Add(-time.Minute))
+	events := t.UpdateExpiry(now)
+	c.Assert(events, HasLen, 2)
+	c.Check(events[0].Expired(), IsTrue)
+	c.Check(events[0].DocIdx, NotNil)
+	c.Check(events[1].Expired(), IsTrue)
+	c.Check(events[1].DocIdx, IsNil)
+
+	stage := t.Stages[0]
+	c.Check(stage.Docs[0].Status, Equals, ApprovalExpired)
+	c.Check(stage.Docs[1].Status, Equals, ApprovalApproved)
+	c.Assert(stage.CloseReqs, HasLen, 1)
+	c.Check(stage.CloseReqs[0].Status, Equals, ApprovalExpired)
+	c.Check(stage.CloseReqs[0].ReqActor, Equals, TradeActorB)
+
+	c.Check(t.UpdateExpiry(now), HasLen, 0)
+}
+
+func (s *ExpirySuite) TestExpirePendingCloseReq(c *C) {
+	now := time.Now().UTC()
+	t := mkExpiryTrade(now.Add(48*time.Hour), now.Add(-time.Minute))
+	t.Stages[0].CloseReqs = []ApproveReq{{Status: ApprovalPending}}
+	c.Check(t.UpdateExpiry(now), HasLen, 1)
+	c.Assert(t.Stages[0].CloseReqs, HasLen, 1)
+	c.Check(t.Stages[0].CloseReqs[0].Status, Equals, ApprovalExpired)
+}
+
+func (s *ExpirySuite) TestClosedIsNotExpired(c *C) {
+	now := time.Now().UTC()
+	t := mkExpiryTrade(now.Add(-time.Minute), now.Add(-time.Minute))
+	t.Stages[0].CloseReqs = []ApproveReq{{Status: ApprovalApproved}}
+	c.Check(t.UpdateExpiry(now), HasLen, 0)
+	c.Check(t.Stages[0].Docs[0].Status, Equals, ApprovalPending)
+
+	t = mkExpiryTrade(now.Add(-time.Minute), now.Add(-time.Minute))
+	t.CloseReqs = []ApproveReq{{Status: ApprovalApproved}}
+	c.Check(t.UpdateExpiry(now), HasLen, 0)
+}
+
+func (s *ExpirySuite) TestSetExpiresAtResetsAlerts(c *C) {
+	now := time.Now().UTC()
+	t := mkExpiryTrade(now.Add(48*time.Hour), now.Add(time.Hour))
+	c.Check(t.UpdateExpiry(now), HasLen, 1)
+	deadline := now.Add(2 * time.Hour)
+	t.Stages[0].SetExpiresAt(&deadline)
+	c.Check(t.Stages[0].AlertedBefore, Equals, time.Duration(0))
+	events := t.UpdateExpiry(now.Add(90 * time.Minute))
+	c.Assert(events, HasLen, 1)
+	c.Check(events[0].Lead, Equals, time.Hour)
+}
+
+func (s *ExpirySuite) TestCloneKeepsPrevState(c *C) {
+	now := time.Now().UTC()
+	t := mkExpiryTrade(now.Add(-time.Minute), now.Add(-time.Minute))
+	prev, errs := t.Clone()
+	c.Assert(errs, IsNil)
+	c.Assert(t.UpdateExpiry(now), HasLen, 2)
+	c.Check(prev.Stages[0].Docs[0].Status, Equals, ApprovalPending)
+	c.Check(prev.Stages[0].CloseReqs, HasLen, 0)
+	c.Check(t.Stages[0].Docs[0].Status, Equals, ApprovalExpired)
+}
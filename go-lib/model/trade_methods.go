@@ -1,6 +1,7 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 
 	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
@@ -45,6 +46,19 @@ func (input NewTradeInput) Validate(templateID string, u *User) errstack.Builder
 	return vb.ToErrstackBuilder()
 }
 
+// Clone returns a deep copy of the trade
+func (t Trade) Clone() (*Trade, errstack.E) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, errstack.WrapAsDomain(err, "Can't copy the trade")
+	}
+	var tc Trade
+	if err = json.Unmarshal(b, &tc); err != nil {
+		return nil, errstack.WrapAsDomain(err, "Can't copy the trade")
+	}
+	return &tc, nil
+}
+
 // SetID implements dal.HasID interface
 func (t *Trade) SetID(id string) {
 	t.ID = id
@@ -77,36 +91,22 @@ func (t Trade) GetStageDoc(stageIdx uint, docIdx uint) (*TradeStage, *TradeStage
 	return s, d, err
 }
 
-// Requester checks the requester info for auth and returns as tradeActor
+// Requester checks the requester info for auth and returns his role in the trade
 func (t Trade) Requester(u *User) (TradeActor, errstack.E) {
 	if u == nil || u.ID == "" {
 		return TradeActorB, ErrUnauthenticated
 	}
-	if t.Buyer.UserID == u.ID {
-		return TradeActorB, nil
-	} else if t.Seller.UserID == u.ID {
-		return TradeActorS, nil
-	} else if u.IsModerator() {
+	if p, ok := t.findParty(u.ID); ok {
+		return p.Role, nil
+	} else if u.Can(PermissionTradeModerate) {
 		return TradeActorM, nil
 	}
 	return TradeActorB, ErrUnauthorized
 }
 
-// NotificationReceiver checks the requester info for notification action and returns receiver as string(user id) array
-func (t Trade) NotificationReceiver(u *User) ([]string, errstack.E) {
-	if t.Buyer.UserID == u.ID {
-		return []string{t.Seller.UserID}, nil
-	} else if t.Seller.UserID == u.ID {
-		return []string{t.Buyer.UserID}, nil
-	} else if u.IsModerator() {
-		return []string{t.Buyer.UserID, t.Seller.UserID}, nil
-	}
-	return nil, ErrUnauthorized
-}
-
 // CanBeModifiedBy checks whether a trade can be modified by the given user
 func (t *Trade) CanBeModifiedBy(user *User) errstack.E {
-	if user.ID != t.Buyer.UserID && user.ID != t.Seller.UserID && !user.IsModerator() {
+	if !t.IsParticipant(user) && !user.Can(PermissionTradeModerate) {
 		return ErrUnauthorized
 	}
 	return nil
@@ -199,11 +199,16 @@ func (s TradeStage) GetDoc(idx uint) (*TradeStageDoc, errstack.E) {
 	return &s.Docs[idx], nil
 }
 
-// AssertOwnedBy checks whether a user is the owner of the stage or not, returns permission error if not owner
+// AssertOwnedBy checks whether a user is the owner of the stage or not, returns permission error if not owner.
+// Stages without an owner belong to the buyer and the seller, stages with a participant
+// role owner to all parties with that role.
 func (s TradeStage) AssertOwnedBy(t *Trade, uid string) errstack.E {
-	if (s.Owner == TradeActorN && t.Buyer.UserID != uid && t.Seller.UserID != uid) ||
-		(s.Owner == TradeActorB && t.Buyer.UserID != uid) ||
-		(s.Owner == TradeActorS && t.Seller.UserID != uid) {
+	p, isParty := t.findParty(uid)
+	switch {
+	case s.Owner == TradeActorM:
+	case s.Owner == TradeActorN && (p.Role == TradeActorB || p.Role == TradeActorS):
+	case isParty && p.Role == s.Owner:
+	default:
 		return errstack.NewReq("trade.stage edit.not-authorized")
 	}
 	return nil
@@ -252,13 +257,10 @@ func (tt *TradeTemplate) SetID(id string) {
 
 // FindParticipant looks up for the user in the trade
 func (t *Trade) FindParticipant(user *User) (*TradeParticipant, errstack.E) {
-	if t.Buyer.UserID == user.ID {
-		return &t.Buyer, nil
-	}
-	if t.Seller.UserID == user.ID {
-		return &t.Seller, nil
+	if p, ok := t.findParty(user.ID); ok {
+		return &p, nil
 	}
-	if user.IsModerator() {
+	if user.Can(PermissionTradeModerate) {
 		// HACK! HD wallets won't work
 		// TODO: Remove it during HD wallet refactoring
 		sw, ok := user.StaticWallets[user.DefaultWalletID]
@@ -0,0 +1,147 @@
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// A moderator claims a trade or a stage to let the other moderators know who is looking
+// at it. Claimed items can be taken over only explicitly.
+
+// ModeratorID returns the ID of the moderator who claimed the trade
+func (t Trade) ModeratorID() string {
+	if t.Moderator == nil {
+		return ""
+	}
+	return t.Moderator.UserID
+}
+
+// ClaimModeration assigns the trade to the moderator and moves its moderation in
+// progress. Returns the ID of the previous moderator.
+func (t *Trade) ClaimModeration(uid string, now time.Time, takeOver bool) (string, errstack.E) {
+	if t.CheckTradeClosed() {
+		return "", errstack.NewReq("You can't moderate closed trade")
+	}
+	prev := t.ModeratorID()
+	if prev != "" && prev != uid && !takeOver {
+		return prev, ErrModerationClaimed
+	}
+	t.Moderator = &StageModerator{UserID: uid, CreatedAt: &now}
+	t.Moderating = DoneStatusDoing
+	return prev, nil
+}
+
+// ReleaseModeration removes the moderator claim. The moderation stays done if it
+// was finished.
+func (t *Trade) ReleaseModeration(uid string) errstack.E {
+	if t.ModeratorID() != uid {
+		return ErrModerationNotClaimed
+	}
+	t.Moderator = nil
+	if t.Moderating == DoneStatusDoing {
+		t.Moderating = DoneStatusNil
+	}
+	return nil
+}
+
+// FinishModeration marks the moderation done and releases the claim. The trade can
+// be claimed again when it needs more attention.
+func (t *Trade) FinishModeration(uid string) errstack.E {
+	if errs := t.ReleaseModeration(uid); errs != nil {
+		return errs
+	}
+	t.Moderating = DoneStatusDone
+	return nil
+}
+
+// ClaimStageModeration assigns the stage to the moderator. Returns the ID of the
+// previous moderator.
+func (t *Trade) ClaimStageModeration(idx uint, uid string, now time.Time, takeOver bool) (string, errstack.E) {
+	if t.CheckTradeClosed() {
+		return "", errstack.NewReq("You can't moderate closed trade")
+	}
+	s, errs := t.GetStage(idx)
+	if errs != nil {
+		return "", errs
+	}
+	if s.IsDeletedOrClosed() {
+		return "", errstack.NewReq("You can't moderate closed or deleted stage")
+	}
+	prev := s.Moderator.UserID
+	if prev != "" && prev != uid && !takeOver {
+		return prev, ErrModerationClaimed
+	}
+	s.Moderator = StageModerator{UserID: uid, CreatedAt: &now}
+	return prev, nil
+}
+
+// ReleaseStageModeration removes the moderator claim of the stage
+func (t *Trade) ReleaseStageModeration(idx uint, uid string) errstack.E {
+	s, errs := t.GetStage(idx)
+	if errs != nil {
+		return errs
+	}
+	if s.Moderator.UserID != uid {
+		return ErrModerationNotClaimed
+	}
+	s.Moderator = StageModerator{}
+	return nil
+}
+
+// PendingApprovals returns the number of requests and stage documents of the trade
+// which wait for an approval, and the time of the oldest one. docCreatedAt maps IDs of
+// the pending stage documents to their upload times. The oldest time is nil when
+// nothing is pending.
+func (t Trade) PendingApprovals(docCreatedAt map[string]time.Time) (int, *time.Time) {
+	var n int
+	var oldest *time.Time
+	add := func(at time.Time) {
+		n++
+		if !at.IsZero() && (oldest == nil || at.Before(*oldest)) {
+			oldest = &at
+		}
+	}
+	addReqs := func(reqs []ApproveReq) {
+		for _, r := range reqs {
+			if r.Status == ApprovalPending {
+				add(r.ReqAt)
+			}
+		}
+	}
+	if t.CheckTradeClosed() {
+		return 0, nil
+	}
+	for _, r := range t.StageAddReqs {
+		if r.Status == ApprovalPending {
+			add(r.ReqAt)
+		}
+	}
+	addReqs(t.CloseReqs)
+	for _, s := range t.Stages {
+		addReqs(s.DelReqs)
+		addReqs(s.CloseReqs)
+		if s.IsDeletedOrClosed() {
+			continue
+		}
+		for _, d := range s.Docs {
+			if d.Status == ApprovalPending {
+				add(docCreatedAt[d.DocID])
+			}
+		}
+	}
+	return n, oldest
+}
+
+// SortModerationQueue orders the queue by the oldest pending approval. Items with
+// an unknown time go last.
+func SortModerationQueue(q []ModerationQueueItem) {
+	sort.SliceStable(q, func(i, j int) bool {
+		a, b := q[i].OldestPendingAt, q[j].OldestPendingAt
+		if a == nil || b == nil {
+			return b == nil && a != nil
+		}
+		return a.Before(*b)
+	})
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type ModerationSuite struct{}
+
+var _ = Suite(&ModerationSuite{})
+
+func (s *ModerationSuite) TestClaimTrade(c *C) {
+	now := time.Now()
+	t := Trade{}
+	prev, errs := t.ClaimModeration("m1", now, false)
+	c.Assert(errs, IsNil)
+	c.Check(prev, Equals, "")
+	c.Check(t.ModeratorID(), Equals, "m1")
+	c.Check(t.Moderating, Equals, DoneStatusDoing)
+
+	_, errs = t.ClaimModeration("m2", now, false)
+	c.Check(errs, Equals, ErrModerationClaimed)
+	c.Check(t.ReleaseModeration("m2"), Equals, ErrModerationNotClaimed)
+	prev, errs = t.ClaimModeration("m2", now, true)
+	c.Assert(errs, IsNil)
+	c.Check(prev, Equals, "m1")
+	c.Check(t.ModeratorID(), Equals, "m2")
+
+	c.Assert(t.ReleaseModeration("m2"), IsNil)
+	c.Check(t.Moderator, IsNil)
+	c.Check(t.Moderating, Equals, DoneStatusNil)
+
+	_, errs = t.ClaimModeration("m1", now, false)
+	c.Assert(errs, IsNil)
+	c.Assert(t.FinishModeration("m1"), IsNil)
+	c.Check(t.Moderator, IsNil)
+	c.Check(t.Moderating, Equals, DoneStatusDone)
+
+	t.CloseReqs = approvedReq
+	_, errs = t.ClaimModeration("m1", now, false)
+	c.Check(errs, ErrorContains, "closed trade")
+}
+
+func (s *ModerationSuite) TestClaimStage(c *C) {
+	now := time.Now()
+	t := mkDepsTrade()
+	prev, errs := t.ClaimStageModeration(1, "m1", now, false)
+	c.Assert(errs, IsNil)
+	c.Check(prev, Equals, "")
+	c.Check(t.Stages[1].Moderator.UserID, Equals, "m1")
+	c.Check(t.Moderator, IsNil)
+
+	_, errs = t.ClaimStageModeration(1, "m2", now, false)
+	c.Check(errs, Equals, ErrModerationClaimed)
+	c.Check(t.ReleaseStageModeration(1, "m2"), Equals, ErrModerationNotClaimed)
+	c.Assert(t.ReleaseStageModeration(1, "m1"), IsNil)
+	c.Check(t.Stages[1].Moderator, DeepEquals, StageModerator{})
+
+	t.Stages[2].CloseReqs = approvedReq
+	_, errs = t.ClaimStageModeration(2, "m1", now, false)
+	c.Check(errs, ErrorContains, "closed or deleted stage")
+	_, errs = t.ClaimStageModeration(9, "m1", now, false)
+	c.Check(errs, NotNil)
+}
+
+func (s *ModerationSuite) TestPendingApprovals(c *C) {
+	t0 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	t := mkDepsTrade()
+	n, oldest := t.PendingApprovals(nil)
+	c.Check(n, Equals, 0)
+	c.Check(oldest, IsNil)
+
+	t.StageAddReqs = []TradeStageAddReq{{ApproveReq: ApproveReq{Status: ApprovalPending, ReqAt: t0.Add(3 * time.Hour)}}}
+	t.Stages[1].DelReqs = []ApproveReq{{Status: ApprovalRejected, ReqAt: t0}}
+	t.Stages[1].CloseReqs = []ApproveReq{{Status: ApprovalPending, ReqAt: t0.Add(2 * time.Hour)}}
+	t.Stages[3].Docs = []TradeStageDoc{{DocID: "d1", Status: ApprovalPending}, {DocID: "d2", Status: ApprovalApproved}}
+	// documents of closed stages can't be approved anymore
+	t.Stages[4].CloseReqs = approvedReq
+	t.Stages[4].Docs = []TradeStageDoc{{DocID: "d3", Status: ApprovalPending}}
+	n, oldest = t.PendingApprovals(map[string]time.Time{"d1": t0.Add(time.Hour), "d3": t0})
+	c.Check(n, Equals, 3)
+	c.Check(*oldest, Equals, t0.Add(time.Hour))
+
+	t.CloseReqs = approvedReq
+	n, _ = t.PendingApprovals(nil)
+	c.Check(n, Equals, 0)
+}
+
+func (s *ModerationSuite) TestSortModerationQueue(c *C) {
+	t0 := time.Now()
+	t1 := t0.Add(time.Hour)
+	q := []ModerationQueueItem{
+		{Trade: Trade{ID: "a"}},
+		{Trade: Trade{ID: "b"}, OldestPendingAt: &t1},
+		{Trade: Trade{ID: "c"}, OldestPendingAt: &t0},
+	}
+	SortModerationQueue(q)
+	var ids []string
+	for _, it := range q {
+		ids = append(ids, it.Trade.ID)
+	}
+	c.Check(ids, DeepEquals, []string{"c", "b", "a"})
+}
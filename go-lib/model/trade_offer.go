@@ -1,10 +1,17 @@
 package model
 
 import (
+	"fmt"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
 	"bitbucket.org/cerealia/apps/go-lib/validation"
 	"github.com/robert-zaremba/errstack"
 )
 
+// dateFormat is used to format dates in the texts generated for users
+const dateFormat = "2006-01-02"
+
 // ValidateInput validates the new tradeOfferInput data
 func (ti TradeOfferInput) ValidateInput() errstack.E {
 	errb := errstack.NewBuilder()
@@ -30,3 +37,52 @@ func (ti TradeOfferInput) ValidateInput() errstack.E {
 func (o *TradeOffer) SetID(id string) {
 	o.ID = id
 }
+
+// IsAnonymousTo checks if the offer creator identity is hidden from the user
+func (o *TradeOffer) IsAnonymousTo(u *User) bool {
+	return o.IsAnonymous && u.ID != o.CreatedBy
+}
+
+// FullID2 returns the offer ID in the `collection:key` format used by notification entity IDs
+func (o TradeOffer) FullID2() string {
+	return string(dbconst.ColTradeOffers) + ":" + o.ID
+}
+
+// CanBeAcceptedBy checks if the user can accept the offer and become its counterparty
+func (o *TradeOffer) CanBeAcceptedBy(u *User, now time.Time) errstack.E {
+	if o.CreatedBy == u.ID {
+		return errstack.NewReq("You can't accept your own trade offer")
+	}
+	if !o.IsActive(now) {
+		return ErrTradeOfferClosed
+	}
+	return nil
+}
+
+// NewTradeInput creates the input of a trade between the offer creator and the user
+// accepting the offer. The trade name and description are prefilled from the offer.
+// The trade belongs to the offer organization.
+func (o *TradeOffer) NewTradeInput(u *User, templateID string) NewTradeInput {
+	buyer, seller := u.ID, o.CreatedBy
+	if !o.IsSell {
+		buyer, seller = seller, buyer
+	}
+	desc := fmt.Sprintf("%d of %s %s from %s at %.2f %s, %s %s. Shipment %s - %s.",
+		o.Vol, o.Quality, o.Commodity, o.Origin, o.Price, o.Currency, o.Incoterm, o.MarketLoc,
+		o.Shipment[0].Format(dateFormat), o.Shipment[1].Format(dateFormat))
+	if o.Note != "" {
+		desc += "\n" + o.Note
+	}
+	in := NewTradeInput{
+		TemplateID:   templateID,
+		Name:         fmt.Sprintf("%s %s %s", o.Commodity, o.Incoterm, o.MarketLoc),
+		BuyerID:      buyer,
+		SellerID:     seller,
+		Description:  &desc,
+		TradeOfferID: &o.ID,
+	}
+	if o.OrgID != "" {
+		in.OrgID = &o.OrgID
+	}
+	return in
+}
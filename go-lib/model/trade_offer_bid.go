@@ -0,0 +1,133 @@
+package model
+
+import (
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	"github.com/robert-zaremba/errstack"
+)
+
+// ReasonBidCountered is the reject reason of a pending bid replaced by a counter-offer
+const ReasonBidCountered = "Countered by a new bid"
+
+// SetID implements dal.HasID interface
+func (b *TradeOfferBid) SetID(id string) {
+	b.ID = id
+}
+
+// IsVisibleTo checks if the user takes part in the bid negotiation thread
+func (b *TradeOfferBid) IsVisibleTo(u *User, o *TradeOffer) bool {
+	return u.ID == b.Bidder || u.ID == o.CreatedBy
+}
+
+// Recipient returns the ID of the thread participant the bid is addressed to
+func (b *TradeOfferBid) Recipient(o *TradeOffer) string {
+	if b.CreatedBy == b.Bidder {
+		return o.CreatedBy
+	}
+	return b.Bidder
+}
+
+// IsAnonymousTo checks if the bid creator identity is hidden from the user.
+// Bids of the creator of an anonymous offer are hidden from the bidders.
+func (b *TradeOfferBid) IsAnonymousTo(u *User, o *TradeOffer) bool {
+	return b.CreatedBy == o.CreatedBy && o.IsAnonymousTo(u)
+}
+
+// CanBeRespondedBy checks if the user can accept or reject the bid.
+// Only the other side of the thread can respond to a pending bid.
+func (b *TradeOfferBid) CanBeRespondedBy(u *User, o *TradeOffer) errstack.E {
+	if !b.IsVisibleTo(u, o) || u.ID == b.CreatedBy {
+		return ErrUnauthorized
+	}
+	if b.Status != ApprovalPending {
+		return ErrBidNotPending
+	}
+	return nil
+}
+
+// Respond sets the bid status
+func (b *TradeOfferBid) Respond(isAccept bool, reason string, now time.Time) {
+	b.Status = ApprovalRejected
+	if isAccept {
+		b.Status = ApprovalApproved
+	}
+	b.RejectReason = reason
+	b.RespondedAt = &now
+}
+
+// NewBid creates a new bid in a negotiation thread of the offer.
+// `bidder` is required when the offer creator counters a bid, otherwise the user is the bidder.
+// Terms which are not set in the input are copied from the `last` bid of the thread
+// or from the offer if the thread is empty.
+func (o *TradeOffer) NewBid(u *User, input TradeOfferBidInput, last *TradeOfferBid, now time.Time) (TradeOfferBid, errstack.E) {
+	b := TradeOfferBid{
+		OfferID:   o.ID,
+		Bidder:    u.ID,
+		CreatedBy: u.ID,
+		CreatedAt: now,
+		Price:     o.Price,
+		Vol:       o.Vol,
+		Shipment:  o.Shipment,
+		Incoterm:  o.Incoterm,
+		Message:   input.Message,
+		Status:    ApprovalPending,
+	}
+	if !o.IsActive(now) || o.AcceptedBid != nil {
+		return b, ErrTradeOfferClosed
+	}
+	if u.ID == o.CreatedBy {
+		if input.BidderID == nil || *input.BidderID == u.ID || last == nil {
+			return b, errstack.NewReq("Offer creator can only counter an existing bid")
+		}
+		b.Bidder = *input.BidderID
+	}
+	if last != nil {
+		b.Price, b.Vol, b.Shipment, b.Incoterm = last.Price, last.Vol, last.Shipment, last.Incoterm
+	}
+	errb := errstack.NewBuilder()
+	if input.Price != nil {
+		b.Price = *input.Price
+		if b.Price <= 0 {
+			errb.Put("price", "must be positive")
+		}
+	}
+	if input.Vol != nil {
+		b.Vol = *input.Vol
+		if b.Vol <= 0 {
+			errb.Put("vol", "must be positive")
+		}
+	}
+	if input.Shipment != nil {
+		b.Shipment = input.Shipment
+		if len(b.Shipment) != 2 || !b.Shipment[1].After(b.Shipment[0]) {
+			errb.Put("shipment", "date is not correct")
+		}
+	}
+	if input.Incoterm != nil {
+		b.Incoterm = *input.Incoterm
+	}
+	if len(b.Message) > 2000 {
+		errb.Put("message", "can't be longer than 2000 characters")
+	}
+	return b, errb.ToReqErr()
+}
+
+// WithBid returns a copy of the offer with the terms of the bid
+func (o TradeOffer) WithBid(b *TradeOfferBid) TradeOffer {
+	o.Price, o.Vol, o.Shipment, o.Incoterm = b.Price, b.Vol, b.Shipment, b.Incoterm
+	return o
+}
+
+// TradeOfferBidEdge represents graph edge between a bid and a trade offer
+type TradeOfferBidEdge struct {
+	OfferID string
+}
+
+// ToEdgeDO implements dal.Edge interface
+func (e TradeOfferBidEdge) ToEdgeDO(absoluteBidID string) interface{} {
+	return TradeOfferBidEdgeDO{
+		FullBidID:        absoluteBidID,
+		FullTradeOfferID: dbconst.ColTradeOffers.FullID(e.OfferID),
+	}
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (s *OfferMatchSuite) TestNewBid(c *C) {
+	o := s.mkOffer("1", true, 200, 10, 20)
+	creator, bidder := &User{ID: o.CreatedBy}, &User{ID: "u2"}
+	price := 190.0
+	b, errs := o.NewBid(bidder, TradeOfferBidInput{OfferID: o.ID, Price: &price, Message: "hi"}, nil, s.now)
+	c.Assert(errs, IsNil)
+	c.Check(b.Bidder, Equals, bidder.ID)
+	c.Check(b.CreatedBy, Equals, bidder.ID)
+	c.Check(b.Price, Equals, 190.0)
+	c.Check(b.Vol, Equals, o.Vol)
+	c.Check(b.Incoterm, Equals, o.Incoterm)
+	c.Check(b.Status, Equals, ApprovalPending)
+	c.Check(b.Recipient(&o), Equals, creator.ID)
+
+	// creator must counter an existing thread
+	_, errs = o.NewBid(creator, TradeOfferBidInput{OfferID: o.ID, BidderID: &bidder.ID}, nil, s.now)
+	c.Check(errs, ErrorContains, "can only counter an existing bid")
+	vol := 50
+	counter, errs := o.NewBid(creator, TradeOfferBidInput{OfferID: o.ID, BidderID: &bidder.ID, Vol: &vol}, &b, s.now)
+	c.Assert(errs, IsNil)
+	c.Check(counter.Bidder, Equals, bidder.ID)
+	c.Check(counter.CreatedBy, Equals, creator.ID)
+	c.Check(counter.Price, Equals, 190.0, Comment("terms are copied from the last bid"))
+	c.Check(counter.Vol, Equals, 50)
+	c.Check(counter.Recipient(&o), Equals, bidder.ID)
+
+	negative := -1.0
+	_, errs = o.NewBid(bidder, TradeOfferBidInput{OfferID: o.ID, Price: &negative}, nil, s.now)
+	c.Check(errs, ErrorContains, "price")
+	_, errs = o.NewBid(bidder, TradeOfferBidInput{OfferID: o.ID, Shipment: []time.Time{s.now}}, nil, s.now)
+	c.Check(errs, ErrorContains, "shipment")
+
+	accepted := "b1"
+	o.AcceptedBid = &accepted
+	_, errs = o.NewBid(bidder, TradeOfferBidInput{OfferID: o.ID}, nil, s.now)
+	c.Check(errs, Equals, ErrTradeOfferClosed)
+}
+
+func (s *OfferMatchSuite) TestBidResponse(c *C) {
+	o := s.mkOffer("1", true, 200, 10, 20)
+	o.IsAnonymous = true
+	creator, bidder, other := &User{ID: o.CreatedBy}, &User{ID: "u2"}, &User{ID: "u3"}
+	b := TradeOfferBid{OfferID: o.ID, Bidder: bidder.ID, CreatedBy: bidder.ID, Status: ApprovalPending, Price: 190, Vol: 10}
+	c.Check(b.CanBeRespondedBy(bidder, &o), Equals, ErrUnauthorized)
+	c.Check(b.CanBeRespondedBy(other, &o), Equals, ErrUnauthorized)
+	c.Check(b.CanBeRespondedBy(creator, &o), IsNil)
+	c.Check(b.IsVisibleTo(other, &o), IsFalse)
+	c.Check(b.IsAnonymousTo(bidder, &o), IsFalse)
+	c.Check(o.IsAnonymousTo(bidder), IsTrue)
+	c.Check(o.IsAnonymousTo(creator), IsFalse)
+
+	b.Respond(true, "", s.now)
+	c.Check(b.Status, Equals, ApprovalApproved)
+	c.Check(b.CanBeRespondedBy(creator, &o), Equals, ErrBidNotPending)
+	agreed := o.WithBid(&b)
+	c.Check(agreed.Price, Equals, 190.0)
+	c.Check(agreed.Vol, Equals, 10)
+	c.Check(o.Price, Equals, 200.0)
+
+	counter := TradeOfferBid{Bidder: bidder.ID, CreatedBy: creator.ID}
+	c.Check(counter.IsAnonymousTo(bidder, &o), IsTrue)
+	c.Check(counter.IsAnonymousTo(creator, &o), IsFalse)
+}
@@ -0,0 +1,110 @@
+package model
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Weights of the match score components. They sum up to 1.
+const (
+	matchWeightPrice    = 0.4
+	matchWeightVol      = 0.3
+	matchWeightShipment = 0.2
+	matchWeightQuality  = 0.05
+	matchWeightOrigin   = 0.05
+)
+
+// IsActive checks if the offer is neither closed nor expired
+func (o *TradeOffer) IsActive(now time.Time) bool {
+	return o.ClosedAt == nil && (o.ExpiresAt == nil || o.ExpiresAt.After(now))
+}
+
+// Matches checks if the `other` offer is a compatible opposite side offer:
+// same commodity, incoterm and currency, overlapping shipment window and, when both
+// offers are firm, a crossing price (the buy price is not lower than the sell price).
+func (o *TradeOffer) Matches(other *TradeOffer, now time.Time) bool {
+	if o.IsSell == other.IsSell || o.CreatedBy == other.CreatedBy ||
+		!o.IsActive(now) || !other.IsActive(now) ||
+		o.Commodity != other.Commodity || o.Incoterm != other.Incoterm || o.Currency != other.Currency {
+		return false
+	}
+	if shipmentOverlap(o.Shipment, other.Shipment) <= 0 {
+		return false
+	}
+	if o.PriceType == OfferPriceTypeFirm && other.PriceType == OfferPriceTypeFirm {
+		buy, sell := o, other
+		if o.IsSell {
+			buy, sell = other, o
+		}
+		return buy.Price >= sell.Price
+	}
+	return true
+}
+
+// MatchScore ranks the `other` offer as a counterparty of the offer.
+// The score is between 0 and 1; higher is better. It favors close prices and volumes,
+// long shipment window overlaps and the same quality and origin.
+func (o *TradeOffer) MatchScore(other *TradeOffer) float64 {
+	score := matchWeightPrice*closeness(o.Price, other.Price) +
+		matchWeightVol*closeness(float64(o.Vol), float64(other.Vol))
+	if overlap := shipmentOverlap(o.Shipment, other.Shipment); overlap > 0 {
+		shorter := math.Min(float64(shipmentLen(o.Shipment)), float64(shipmentLen(other.Shipment)))
+		if shorter > 0 {
+			score += matchWeightShipment * float64(overlap) / shorter
+		}
+	}
+	if o.Quality == other.Quality {
+		score += matchWeightQuality
+	}
+	if o.Origin == other.Origin {
+		score += matchWeightOrigin
+	}
+	return score
+}
+
+// RankTradeOfferMatches filters the candidates matching the offer and sorts them
+// by the match score, best first.
+func RankTradeOfferMatches(o *TradeOffer, candidates []TradeOffer, now time.Time) []TradeOfferMatch {
+	matches := []TradeOfferMatch{}
+	for i := range candidates {
+		if c := &candidates[i]; o.Matches(c, now) {
+			matches = append(matches, TradeOfferMatch{Offer: *c, Score: o.MatchScore(c)})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
+
+// closeness returns the ratio of the smaller to the bigger value
+func closeness(a, b float64) float64 {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+	return math.Min(a, b) / math.Max(a, b)
+}
+
+// shipmentOverlap returns the duration of the common part of two [from, to] shipment windows
+func shipmentOverlap(a, b []time.Time) time.Duration {
+	if len(a) != 2 || len(b) != 2 {
+		return 0
+	}
+	from, to := a[0], a[1]
+	if b[0].After(from) {
+		from = b[0]
+	}
+	if b[1].Before(to) {
+		to = b[1]
+	}
+	if to.Before(from) {
+		return 0
+	}
+	// windows sharing a single instant still overlap
+	return to.Sub(from) + 1
+}
+
+func shipmentLen(s []time.Time) time.Duration {
+	return s[1].Sub(s[0]) + 1
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type OfferMatchSuite struct {
+	now time.Time
+}
+
+var _ = Suite(&OfferMatchSuite{
+	now: time.Date(2018, 10, 1, 0, 0, 0, 0, time.UTC)})
+
+func (s *OfferMatchSuite) mkOffer(id string, isSell bool, price float64, shipFrom, shipTo int) TradeOffer {
+	day := 24 * time.Hour
+	return TradeOffer{
+		ID:        id,
+		IsSell:    isSell,
+		Price:     price,
+		PriceType: OfferPriceTypeFirm,
+		Currency:  CurrencyEur,
+		CreatedBy: "u" + id,
+		Commodity: "wheat",
+		Quality:   "A",
+		Origin:    "PL",
+		Incoterm:  IncotermFob,
+		Vol:       100,
+		Shipment:  []time.Time{s.now.Add(time.Duration(shipFrom) * day), s.now.Add(time.Duration(shipTo) * day)},
+	}
+}
+
+func (s *OfferMatchSuite) TestMatches(c *C) {
+	buy := s.mkOffer("1", false, 200, 10, 20)
+	sell := s.mkOffer("2", true, 190, 20, 30)
+	c.Check(buy.Matches(&sell, s.now), IsTrue)
+	c.Check(sell.Matches(&buy, s.now), IsTrue)
+
+	// same side
+	sell2 := s.mkOffer("3", false, 190, 10, 20)
+	c.Check(buy.Matches(&sell2, s.now), IsFalse)
+	// no price crossing for firm offers
+	sell.Price = 210
+	c.Check(buy.Matches(&sell, s.now), IsFalse)
+	sell.PriceType = OfferPriceTypeQuote
+	c.Check(buy.Matches(&sell, s.now), IsTrue)
+	// shipment windows don't overlap
+	sell = s.mkOffer("2", true, 190, 21, 30)
+	c.Check(buy.Matches(&sell, s.now), IsFalse)
+	// different terms
+	sell = s.mkOffer("2", true, 190, 10, 20)
+	sell.Incoterm = IncotermCif
+	c.Check(buy.Matches(&sell, s.now), IsFalse)
+	// own or inactive offer
+	sell = s.mkOffer("2", true, 190, 10, 20)
+	sell.CreatedBy = buy.CreatedBy
+	c.Check(buy.Matches(&sell, s.now), IsFalse)
+	sell = s.mkOffer("2", true, 190, 10, 20)
+	sell.ExpiresAt = &s.now
+	c.Check(buy.Matches(&sell, s.now), IsFalse)
+}
+
+func (s *OfferMatchSuite) TestRank(c *C) {
+	buy := s.mkOffer("1", false, 200, 10, 20)
+	far := s.mkOffer("2", true, 100, 10, 20)
+	near := s.mkOffer("3", true, 199, 10, 20)
+	partial := s.mkOffer("4", true, 199, 18, 40)
+	expensive := s.mkOffer("5", true, 250, 10, 20)
+
+	matches := RankTradeOfferMatches(&buy, []TradeOffer{far, partial, expensive, near}, s.now)
+	c.Assert(matches, HasLen, 3)
+	c.Check(matches[0].Offer.ID, Equals, "3")
+	c.Check(matches[1].Offer.ID, Equals, "4")
+	c.Check(matches[2].Offer.ID, Equals, "2")
+	c.Check(matches[0].Score <= 1, IsTrue)
+	c.Check(matches[2].Score > 0, IsTrue)
+
+	c.Check(RankTradeOfferMatches(&buy, nil, s.now), HasLen, 0)
+}
@@ -0,0 +1,38 @@
+package model
+
+import (
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (s *OfferMatchSuite) TestCanBeAcceptedBy(c *C) {
+	o := s.mkOffer("1", true, 200, 10, 20)
+	c.Check(o.CanBeAcceptedBy(&User{ID: "u2"}, s.now), IsNil)
+	c.Check(o.CanBeAcceptedBy(&User{ID: o.CreatedBy}, s.now), ErrorContains, "your own trade offer")
+	o.ClosedAt = &s.now
+	c.Check(o.CanBeAcceptedBy(&User{ID: "u2"}, s.now), Equals, ErrTradeOfferClosed)
+}
+
+func (s *OfferMatchSuite) TestNewTradeInput(c *C) {
+	u := &User{ID: "u2"}
+	o := s.mkOffer("1", true, 200, 10, 20)
+	o.MarketLoc = "Gdansk"
+	in := o.NewTradeInput(u, "tpl")
+	c.Check(in.SellerID, Equals, o.CreatedBy)
+	c.Check(in.BuyerID, Equals, u.ID)
+	c.Check(in.TemplateID, Equals, "tpl")
+	c.Check(in.Name, Equals, "wheat FOB Gdansk")
+	c.Assert(in.Description, NotNil)
+	c.Check(*in.Description, Equals, "100 of A wheat from PL at 200.00 EUR, FOB Gdansk. Shipment 2018-10-11 - 2018-10-21.")
+	c.Check(*in.TradeOfferID, Equals, "1")
+	c.Check(in.OrgID, IsNil)
+	c.Check(in.Validate(in.TemplateID, u).NotNil(), IsFalse)
+
+	o.IsSell = false
+	o.OrgID = "org1"
+	in = o.NewTradeInput(u, "tpl")
+	c.Check(in.BuyerID, Equals, o.CreatedBy)
+	c.Check(in.SellerID, Equals, u.ID)
+	c.Assert(in.OrgID, NotNil)
+	c.Check(*in.OrgID, Equals, "org1")
+}
@@ -0,0 +1,164 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// MaxTradeParticipants is the maximum number of additional trade parties. Every party
+// is a signer of the trade account and Stellar accounts can have at most 20 signers.
+const MaxTradeParticipants = 10
+
+// TradeAction is an operation of a trade party on the trade stages and documents
+type TradeAction int
+
+// List of trade actions
+const (
+	// TradeActionStageAdd requests a new stage
+	TradeActionStageAdd TradeAction = iota
+	// TradeActionStageDel requests the deletion of an owned stage
+	TradeActionStageDel
+	// TradeActionStageClose requests closing of an owned stage
+	TradeActionStageClose
+	// TradeActionStageApprove approves or rejects the stage requests of other parties
+	// and sets the stage expire time
+	TradeActionStageApprove
+	// TradeActionDocAdd uploads documents to owned stages
+	TradeActionDocAdd
+	// TradeActionDocApprove approves or rejects the stage documents
+	TradeActionDocApprove
+	// TradeActionClose requests, approves or rejects the trade close
+	TradeActionClose
+)
+
+// participantRoles are the roles of the additional trade parties
+var participantRoles = []TradeActor{TradeActorR, TradeActorI, TradeActorK, TradeActorF}
+
+// tradeActorActions is the trade authorization policy: actions allowed to the trade
+// parties. The buyer, the seller and the moderators can do everything.
+var tradeActorActions = map[TradeActor][]TradeAction{
+	TradeActorR: {TradeActionStageAdd, TradeActionStageDel, TradeActionStageClose, TradeActionDocAdd},
+	TradeActorI: {TradeActionStageClose, TradeActionDocAdd},
+	TradeActorK: {TradeActionStageClose, TradeActionDocAdd, TradeActionDocApprove},
+	TradeActorF: {TradeActionStageClose, TradeActionDocAdd},
+}
+
+// IsParticipantRole checks if the actor is a role of the additional trade parties
+func (e TradeActor) IsParticipantRole() bool {
+	for _, r := range participantRoles {
+		if r == e {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStageOwner checks if the actor can own a template stage. Moderators don't own
+// stages.
+func (e TradeActor) IsStageOwner() bool {
+	switch e {
+	case TradeActorN, TradeActorB, TradeActorS:
+		return true
+	}
+	return e.IsParticipantRole()
+}
+
+// Can checks if the trade actor is allowed to do the action
+func (e TradeActor) Can(a TradeAction) bool {
+	switch e {
+	case TradeActorB, TradeActorS, TradeActorM:
+		return true
+	}
+	for _, ra := range tradeActorActions[e] {
+		if ra == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Parties returns all trade parties: the buyer, the seller and the additional participants
+func (t Trade) Parties() []TradeParticipant {
+	b, s := t.Buyer, t.Seller
+	b.Role, s.Role = TradeActorB, TradeActorS
+	return append([]TradeParticipant{b, s}, t.Participants...)
+}
+
+// PartyIDs returns user IDs of all trade parties
+func (t Trade) PartyIDs() []string {
+	ps := t.Parties()
+	ids := make([]string, len(ps))
+	for i := range ps {
+		ids[i] = ps[i].UserID
+	}
+	return ids
+}
+
+// HasRole checks if the role is taken by a trade party
+func (t Trade) HasRole(role TradeActor) bool {
+	for _, p := range t.Parties() {
+		if p.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// findParty returns the trade party of the user
+func (t Trade) findParty(uid string) (TradeParticipant, bool) {
+	if uid != "" {
+		for _, p := range t.Parties() {
+			if p.UserID == uid {
+				return p, true
+			}
+		}
+	}
+	return TradeParticipant{}, false
+}
+
+// AssertCan checks if the user is allowed to do the action in the trade
+func (t Trade) AssertCan(u *User, a TradeAction) errstack.E {
+	actor, errs := t.Requester(u)
+	if errs != nil {
+		return errs
+	}
+	if !actor.Can(a) {
+		return ErrUnauthorized
+	}
+	return nil
+}
+
+// NotificationReceivers returns user IDs of the trade parties notified about an action
+// of the user: all parties except the user
+func (t Trade) NotificationReceivers(u *User) ([]string, errstack.E) {
+	if !t.IsParticipant(u) && !u.Can(PermissionTradeModerate) {
+		return nil, ErrUnauthorized
+	}
+	var ids []string
+	for _, id := range t.PartyIDs() {
+		if id != u.ID {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// ValidateParticipants checks the additional trade parties of the new trade
+func (input NewTradeInput) ValidateParticipants() errstack.E {
+	if len(input.Participants) > MaxTradeParticipants {
+		return errstack.NewReqF("A trade can have at most %d additional participants", MaxTradeParticipants)
+	}
+	errb := errstack.NewBuilder()
+	seen := map[string]bool{input.BuyerID: true, input.SellerID: true}
+	for i, p := range input.Participants {
+		key := fmt.Sprintf("participants.%d", i)
+		if !p.Role.IsParticipantRole() {
+			errb.Put(key, "role must be a broker, an inspection agency, a bank or a freight forwarder")
+		} else if seen[p.UserID] {
+			errb.Put(key, "the user is already a trade party")
+		}
+		seen[p.UserID] = true
+	}
+	return errb.ToReqErr()
+}
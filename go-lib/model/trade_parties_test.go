@@ -0,0 +1,147 @@
+package model
+
+import (
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type TradePartiesSuite struct {
+	trade Trade
+}
+
+var _ = Suite(&TradePartiesSuite{
+	Trade{
+		ID:     "t1",
+		Buyer:  TradeParticipant{UserID: "buyer-id", PubKey: "buyer-key"},
+		Seller: TradeParticipant{UserID: "seller-id", PubKey: "seller-key"},
+		Participants: []TradeParticipant{
+			{UserID: "broker-id", Role: TradeActorR, PubKey: "broker-key"},
+			{UserID: "bank-id", Role: TradeActorK, PubKey: "bank-key"},
+		},
+	}})
+
+var moderator = &User{ID: "moderator-id", Roles: []UserRole{UserRoleModerator}}
+
+func (s *TradePartiesSuite) TestParties(c *C) {
+	ps := s.trade.Parties()
+	c.Assert(ps, HasLen, 4)
+	c.Check(ps[0].Role, Equals, TradeActorB)
+	c.Check(ps[1].Role, Equals, TradeActorS)
+	c.Check(s.trade.PartyIDs(), DeepEquals, []string{"buyer-id", "seller-id", "broker-id", "bank-id"})
+	c.Check(s.trade.HasRole(TradeActorK), IsTrue)
+	c.Check(s.trade.HasRole(TradeActorF), IsFalse)
+	// the stored buyer is not modified
+	c.Check(s.trade.Buyer.Role, Equals, TradeActor(""))
+
+	c.Check(s.trade.IsParticipant(&User{ID: "bank-id"}), IsTrue)
+	c.Check(s.trade.IsParticipant(&User{ID: "stranger-id"}), IsFalse)
+	c.Check(s.trade.IsParticipant(&User{}), IsFalse)
+
+	p, errs := s.trade.FindParticipant(&User{ID: "broker-id"})
+	c.Assert(errs, IsNil)
+	c.Check(p.PubKey, Equals, "broker-key")
+}
+
+func (s *TradePartiesSuite) TestRequester(c *C) {
+	var tcs = []struct {
+		u     *User
+		actor TradeActor
+		err   error
+	}{
+		{&User{ID: "buyer-id"}, TradeActorB, nil},
+		{&User{ID: "seller-id"}, TradeActorS, nil},
+		{&User{ID: "broker-id"}, TradeActorR, nil},
+		{&User{ID: "bank-id"}, TradeActorK, nil},
+		{moderator, TradeActorM, nil},
+		{&User{ID: "stranger-id"}, TradeActorB, ErrUnauthorized},
+	}
+	for _, tc := range tcs {
+		actor, errs := s.trade.Requester(tc.u)
+		c.Check(actor, Equals, tc.actor, Comment(tc.u.ID))
+		if tc.err == nil {
+			c.Check(errs, IsNil, Comment(tc.u.ID))
+		} else {
+			c.Check(errs, Equals, tc.err, Comment(tc.u.ID))
+		}
+	}
+}
+
+func (s *TradePartiesSuite) TestAssertCan(c *C) {
+	var tcs = []struct {
+		uid     string
+		allowed []TradeAction
+	}{
+		{"buyer-id", []TradeAction{TradeActionStageAdd, TradeActionStageApprove, TradeActionDocApprove, TradeActionClose}},
+		{"broker-id", []TradeAction{TradeActionStageAdd, TradeActionStageDel, TradeActionDocAdd}},
+		{"bank-id", []TradeAction{TradeActionDocAdd, TradeActionDocApprove, TradeActionStageClose}},
+	}
+	for _, tc := range tcs {
+		for _, a := range tc.allowed {
+			c.Check(s.trade.AssertCan(&User{ID: tc.uid}, a), IsNil, Comment(tc.uid, a))
+		}
+	}
+	c.Check(s.trade.AssertCan(&User{ID: "broker-id"}, TradeActionDocApprove), Equals, ErrUnauthorized)
+	c.Check(s.trade.AssertCan(&User{ID: "broker-id"}, TradeActionClose), Equals, ErrUnauthorized)
+	c.Check(s.trade.AssertCan(&User{ID: "bank-id"}, TradeActionStageAdd), Equals, ErrUnauthorized)
+	c.Check(s.trade.AssertCan(&User{ID: "bank-id"}, TradeActionStageApprove), Equals, ErrUnauthorized)
+	c.Check(s.trade.AssertCan(moderator, TradeActionClose), IsNil)
+	c.Check(s.trade.AssertCan(&User{ID: "stranger-id"}, TradeActionDocAdd), Equals, ErrUnauthorized)
+	c.Check(TradeActorI.Can(TradeActionDocAdd), IsTrue)
+	c.Check(TradeActorF.Can(TradeActionDocApprove), IsFalse)
+}
+
+func (s *TradePartiesSuite) TestAssertOwnedBy(c *C) {
+	var tcs = []struct {
+		owner TradeActor
+		uid   string
+		owns  bool
+	}{
+		{TradeActorN, "buyer-id", true},
+		{TradeActorN, "seller-id", true},
+		{TradeActorN, "bank-id", false},
+		{TradeActorB, "buyer-id", true},
+		{TradeActorB, "seller-id", false},
+		{TradeActorK, "bank-id", true},
+		{TradeActorK, "broker-id", false},
+		{TradeActorK, "buyer-id", false},
+		{TradeActorI, "bank-id", false},
+		{TradeActorM, "stranger-id", true},
+	}
+	for _, tc := range tcs {
+		errs := TradeStage{Owner: tc.owner}.AssertOwnedBy(&s.trade, tc.uid)
+		c.Check(errs == nil, Equals, tc.owns, Comment(tc.owner, " ", tc.uid))
+	}
+}
+
+func (s *TradePartiesSuite) TestNotificationReceivers(c *C) {
+	ids, errs := s.trade.NotificationReceivers(&User{ID: "bank-id"})
+	c.Assert(errs, IsNil)
+	c.Check(ids, DeepEquals, []string{"buyer-id", "seller-id", "broker-id"})
+	ids, errs = s.trade.NotificationReceivers(moderator)
+	c.Assert(errs, IsNil)
+	c.Check(ids, DeepEquals, []string{"buyer-id", "seller-id", "broker-id", "bank-id"})
+	_, errs = s.trade.NotificationReceivers(&User{ID: "stranger-id"})
+	c.Check(errs, Equals, ErrUnauthorized)
+}
+
+func (s *TradePartiesSuite) TestValidateParticipants(c *C) {
+	input := NewTradeInput{BuyerID: "b", SellerID: "s", Participants: []TradeParticipantInput{
+		{UserID: "r", Role: TradeActorR},
+		{UserID: "k", Role: TradeActorK},
+	}}
+	c.Check(input.ValidateParticipants(), IsNil)
+
+	input.Participants = append(input.Participants,
+		TradeParticipantInput{UserID: "x", Role: TradeActorB},
+		TradeParticipantInput{UserID: "s", Role: TradeActorF},
+		TradeParticipantInput{UserID: "r", Role: TradeActorI})
+	errs := input.ValidateParticipants()
+	c.Assert(errs, NotNil)
+	c.Check(errs.Error(), Contains, "participants.2")
+	c.Check(errs.Error(), Contains, "participants.3")
+	c.Check(errs.Error(), Contains, "participants.4")
+	c.Check(errs.Error(), Not(Contains), "participants.1")
+
+	input.Participants = make([]TradeParticipantInput, MaxTradeParticipants+1)
+	c.Check(input.ValidateParticipants(), ErrorContains, "at most 10 additional participants")
+}
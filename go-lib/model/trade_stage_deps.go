@@ -0,0 +1,93 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// Stage dependencies always point to stages with a lower index: template stages can
+// depend only on the preceding stages and a new stage only on the existing ones. So
+// the dependency graph has no cycles and the stage index order is its topological order.
+
+// validateDependsOn checks that the dependencies of the stage with the index are
+// unique and point to preceding stages. Returns an empty string when they are valid.
+func validateDependsOn(idx int, deps []uint) string {
+	seen := make(map[uint]bool, len(deps))
+	for _, d := range deps {
+		if int(d) >= idx {
+			return fmt.Sprintf("stage %d is not a preceding stage", d)
+		}
+		if seen[d] {
+			return fmt.Sprintf("stage %d is listed twice", d)
+		}
+		seen[d] = true
+	}
+	return ""
+}
+
+// ValidateDependsOn checks the prerequisites of a new stage
+func (t Trade) ValidateDependsOn(deps []uint) errstack.E {
+	if msg := validateDependsOn(len(t.Stages), deps); msg != "" {
+		return errstack.NewReq("Invalid prerequisite stages: " + msg)
+	}
+	return nil
+}
+
+// OpenPrerequisites returns indexes of the prerequisites of the stage which are
+// neither closed nor deleted
+func (t Trade) OpenPrerequisites(idx uint) []uint {
+	if int(idx) >= len(t.Stages) {
+		return nil
+	}
+	var open []uint
+	for _, d := range t.Stages[idx].DependsOn {
+		if int(d) < len(t.Stages) && !t.Stages[d].IsDeletedOrClosed() {
+			open = append(open, d)
+		}
+	}
+	return open
+}
+
+// AssertPrerequisitesDone checks that all prerequisites of the stage are closed or deleted
+func (t Trade) AssertPrerequisitesDone(idx uint) errstack.E {
+	if open := t.OpenPrerequisites(idx); len(open) > 0 {
+		return errstack.NewReqF("Prerequisite stages %v must be closed or deleted first", open)
+	}
+	return nil
+}
+
+// CriticalPath returns indexes of the longest chain of dependent stages which are
+// neither closed nor deleted, in the order they must be closed. Closing the trade
+// requires at least as many steps as the path length.
+func (t Trade) CriticalPath() []uint {
+	n := len(t.Stages)
+	length := make([]int, n)
+	prev := make([]int, n)
+	end := -1
+	for i := range t.Stages {
+		prev[i] = -1
+		if t.Stages[i].IsDeletedOrClosed() {
+			continue
+		}
+		length[i] = 1
+		for _, d := range t.Stages[i].DependsOn {
+			// ignore malformed dependencies, they would break the index order
+			if int(d) < i && length[d]+1 > length[i] {
+				length[i] = length[d] + 1
+				prev[i] = int(d)
+			}
+		}
+		if end < 0 || length[i] > length[end] {
+			end = i
+		}
+	}
+	path := []uint{}
+	for i := end; i >= 0; i = prev[i] {
+		path = append(path, uint(i))
+	}
+	for l, r := 0, len(path)-1; l < r; l, r = l+1, r-1 {
+		path[l], path[r] = path[r], path[l]
+	}
+	return path
+}
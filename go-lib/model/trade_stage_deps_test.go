@@ -0,0 +1,62 @@
+package model
+
+import (
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type StageDepsSuite struct{}
+
+var _ = Suite(&StageDepsSuite{})
+
+var approvedReq = []ApproveReq{{Status: ApprovalApproved}}
+
+// mkDepsTrade creates a trade with the contract (0), loading (1) after the contract,
+// inspection (2) after the loading, insurance (3) after the contract and payment (4)
+// after the inspection and the insurance.
+func mkDepsTrade() Trade {
+	deps := [][]uint{nil, {0}, {1}, {0}, {2, 3}}
+	t := Trade{}
+	for i, d := range deps {
+		t.Stages = append(t.Stages, NewTradeStage("s", "", i, TradeActorN, d))
+	}
+	return t
+}
+
+func (s *StageDepsSuite) TestValidateDependsOn(c *C) {
+	t := mkDepsTrade()
+	c.Check(t.ValidateDependsOn(nil), IsNil)
+	c.Check(t.ValidateDependsOn([]uint{0, 4}), IsNil)
+	c.Check(t.ValidateDependsOn([]uint{5}), ErrorContains, "not a preceding stage")
+	c.Check(t.ValidateDependsOn([]uint{1, 1}), ErrorContains, "listed twice")
+}
+
+func (s *StageDepsSuite) TestPrerequisites(c *C) {
+	t := mkDepsTrade()
+	c.Check(t.AssertPrerequisitesDone(0), IsNil)
+	c.Check(t.OpenPrerequisites(4), DeepEquals, []uint{2, 3})
+	c.Check(t.AssertPrerequisitesDone(4), ErrorContains, "[2 3]")
+
+	t.Stages[2].CloseReqs = approvedReq
+	t.Stages[3].DelReqs = approvedReq
+	c.Check(t.OpenPrerequisites(4), HasLen, 0)
+	c.Check(t.AssertPrerequisitesDone(4), IsNil)
+	// pending requests don't satisfy the dependency
+	t.Stages[1].CloseReqs = []ApproveReq{{Status: ApprovalPending}}
+	c.Check(t.OpenPrerequisites(2), DeepEquals, []uint{1})
+}
+
+func (s *StageDepsSuite) TestCriticalPath(c *C) {
+	t := mkDepsTrade()
+	c.Check(t.CriticalPath(), DeepEquals, []uint{0, 1, 2, 4})
+
+	t.Stages[0].CloseReqs = approvedReq
+	t.Stages[1].CloseReqs = approvedReq
+	c.Check(t.CriticalPath(), DeepEquals, []uint{2, 4})
+
+	for i := range t.Stages {
+		t.Stages[i].CloseReqs = approvedReq
+	}
+	c.Check(t.CriticalPath(), DeepEquals, []uint{})
+	c.Check(Trade{}.CriticalPath(), DeepEquals, []uint{})
+}
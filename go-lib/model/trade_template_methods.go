@@ -1,25 +1,86 @@
 package model
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// TradeContractStageName is the name of the first stage of every trade template
+const TradeContractStageName = "trade contract"
+
 // BuildStages converts TradeStageTemplate stages to trade stages
 func (tt TradeTemplate) BuildStages() []TradeStage {
 	var stages []TradeStage
 	ss := tt.Stages
 	for i := range tt.Stages {
 		stages = append(stages, NewTradeStage(
-			ss[i].Name, ss[i].Description, -1, ss[i].Owner))
+			ss[i].Name, ss[i].Description, -1, ss[i].Owner, ss[i].DependsOn))
 	}
 	return stages
 }
 
 // NewTradeStage constructs TradeStage
-func NewTradeStage(name, description string, addReqIdx int, owner TradeActor) TradeStage {
+func NewTradeStage(name, description string, addReqIdx int, owner TradeActor, dependsOn []uint) TradeStage {
 	return TradeStage{
 		Name:        name,
 		Description: description,
 		AddReqIdx:   addReqIdx,
 		Owner:       owner,
+		DependsOn:   dependsOn,
 		Docs:        []TradeStageDoc{},
 		DelReqs:     []ApproveReq{},
 		CloseReqs:   []ApproveReq{},
 	}
 }
+
+// Family returns the ID of the first version of the template
+func (tt TradeTemplate) Family() string {
+	if tt.FamilyID == "" {
+		return tt.ID
+	}
+	return tt.FamilyID
+}
+
+// VersionNum returns the template version. Templates created before the versioning
+// don't have the version and are the first versions.
+func (tt TradeTemplate) VersionNum() int {
+	if tt.Version < 1 {
+		return 1
+	}
+	return tt.Version
+}
+
+// IsActive checks if the template is the latest version and is not archived.
+// Only active templates can be used for new trades.
+func (tt TradeTemplate) IsActive() bool {
+	return tt.SupersededBy == nil && tt.ArchivedAt == nil
+}
+
+// Validate checks the template name and stages. The first stage must be the trade
+// contract, every stage must be owned by a trade party and can depend only on the
+// preceding stages.
+func (tt TradeTemplate) Validate() errstack.E {
+	errb := errstack.NewBuilder()
+	if strings.TrimSpace(tt.Name) == "" {
+		errb.Put("name", "can't be empty")
+	}
+	if len(tt.Stages) == 0 {
+		errb.Put("stages", "template must have at least one stage")
+	} else if tt.Stages[0].Name != TradeContractStageName {
+		errb.Put("stages", "the first stage must be the "+TradeContractStageName)
+	}
+	for i, st := range tt.Stages {
+		if strings.TrimSpace(st.Name) == "" {
+			errb.Put(fmt.Sprintf("stages.%d.name", i), "can't be empty")
+		}
+		if !st.Owner.IsStageOwner() {
+			errb.Put(fmt.Sprintf("stages.%d.owner", i), "must be one of: n, b, s, r, i, k, f")
+		}
+		if msg := validateDependsOn(i, st.DependsOn); msg != "" {
+			errb.Put(fmt.Sprintf("stages.%d.dependsOn", i), msg)
+		}
+	}
+	return errb.ToReqErr()
+}
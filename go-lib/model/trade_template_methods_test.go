@@ -0,0 +1,54 @@
+package model
+
+import (
+	"time"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+type TradeTemplateSuite struct{}
+
+var _ = Suite(&TradeTemplateSuite{})
+
+func (s *TradeTemplateSuite) TestValidate(c *C) {
+	contract := TradeStageTemplate{Name: TradeContractStageName, Owner: TradeActorN}
+	var tcs = []struct {
+		name   string
+		stages []TradeStageTemplate
+		valid  bool
+	}{
+		{"t", []TradeStageTemplate{contract}, true},
+		{"t", []TradeStageTemplate{contract, {Name: "inspection", Owner: TradeActorI}}, true},
+		{" ", []TradeStageTemplate{contract}, false},
+		{"t", nil, false},
+		{"t", []TradeStageTemplate{{Name: "stage 1", Owner: TradeActorS}}, false},
+		{"t", []TradeStageTemplate{contract, {Name: "", Owner: TradeActorS}}, false},
+		{"t", []TradeStageTemplate{contract, {Name: "stage 1", Owner: TradeActorM}}, false},
+		{"t", []TradeStageTemplate{contract, {Name: "stage 1", Owner: "seller"}}, false},
+		{"t", []TradeStageTemplate{contract, {Name: "stage 1", Owner: TradeActorS, DependsOn: []uint{0}}}, true},
+		{"t", []TradeStageTemplate{contract, {Name: "stage 1", Owner: TradeActorS, DependsOn: []uint{1}}}, false},
+	}
+	for i, tc := range tcs {
+		errs := TradeTemplate{Name: tc.name, Stages: tc.stages}.Validate()
+		c.Check(errs == nil, Equals, tc.valid, Comment(i, errs))
+	}
+}
+
+func (s *TradeTemplateSuite) TestVersions(c *C) {
+	now := time.Now()
+	next := "t2"
+	legacy := TradeTemplate{ID: "t1"}
+	c.Check(legacy.Family(), Equals, "t1")
+	c.Check(legacy.VersionNum(), Equals, 1)
+	c.Check(legacy.IsActive(), IsTrue)
+
+	v2 := TradeTemplate{ID: "t2", FamilyID: "t1", Version: 2}
+	c.Check(v2.Family(), Equals, "t1")
+	c.Check(v2.VersionNum(), Equals, 2)
+
+	legacy.SupersededBy = &next
+	c.Check(legacy.IsActive(), IsFalse)
+	v2.ArchivedAt = &now
+	c.Check(v2.IsActive(), IsFalse)
+}
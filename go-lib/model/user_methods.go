@@ -6,6 +6,7 @@ import (
 
 	"bitbucket.org/cerealia/apps/go-lib/validation"
 	"github.com/robert-zaremba/errstack"
+	bat "github.com/robert-zaremba/go-bat"
 )
 
 const shortLengthMessage = "Password length must be at least 8 characters"
@@ -16,6 +17,53 @@ func (u *User) SetID(id string) {
 	u.ID = id
 }
 
+// IsAccepted checks if the user account was accepted by the Cerealia team
+func (u *User) IsAccepted() bool {
+	return len(u.Approvals) > 0 && u.Approvals[len(u.Approvals)-1].Status != SimpleApprovalRejected
+}
+
+// HasEmail checks if the email is one of the user emails
+func (u *User) HasEmail(email string) bool {
+	return bat.StrSliceIdx(u.Emails, email) >= 0
+}
+
+// IsEmailVerified checks if the user confirmed the ownership of the email
+func (u *User) IsEmailVerified(email string) bool {
+	return u.HasEmail(email) && bat.StrSliceIdx(u.VerifiedEmails, email) >= 0
+}
+
+// IsPrimaryEmailVerified checks if the first (primary) user email is verified
+func (u *User) IsPrimaryEmailVerified() bool {
+	return len(u.Emails) > 0 && u.IsEmailVerified(u.Emails[0])
+}
+
+// UnverifiedEmails returns the user emails which are not verified yet
+func (u *User) UnverifiedEmails() []string {
+	var emails []string
+	for _, e := range u.Emails {
+		if !u.IsEmailVerified(e) {
+			emails = append(emails, e)
+		}
+	}
+	return emails
+}
+
+// IsOrgMember checks if the user belongs to the organization
+func (u *User) IsOrgMember(orgID string) bool {
+	_, ok := u.Organizations[orgID]
+	return ok
+}
+
+// HasStaticWalletKey checks if the public key belongs to one of the user static wallets
+func (u *User) HasStaticWalletKey(pubKey string) bool {
+	for _, w := range u.StaticWallets {
+		if w.PubKey == pubKey {
+			return true
+		}
+	}
+	return false
+}
+
 // FindWallet returns a wallet from the user if it exists
 func (u *User) FindWallet(walletID string) (KeyWallet, errstack.E) {
 	hd, ok := u.HDCerealiaWallets[walletID]
@@ -34,14 +82,116 @@ func (u *User) DefaultWallet() (KeyWallet, errstack.E) {
 	return u.FindWallet(u.DefaultWalletID)
 }
 
-// IsModerator returns true if user has moderator role.
-func (u *User) IsModerator() bool {
-	for _, role := range u.Roles {
-		if role == UserRoleModerator {
-			return true
+// DeriveNewKey derives a new key from the user's default wallet.
+// Wallets are stored by value, so the mutated wallet is put back to the user object.
+// The used HD wallet key has to be saved afterwards, see dal.UseHDWalletKey.
+func (u *User) DeriveNewKey() (pubKey string, keyDerivationPath string, error errstack.E) {
+	wallet, err := u.DefaultWallet()
+	if err != nil {
+		return "", "", err
+	}
+	pubKey, keyDerivationPath, err = wallet.DeriveNewKey()
+	if err != nil {
+		return "", "", err
+	}
+	if hd, ok := wallet.(*HDCerealiaWallet); ok {
+		u.HDCerealiaWallets[u.DefaultWalletID] = *hd
+	}
+	return pubKey, keyDerivationPath, nil
+}
+
+// AddHDWallet validates and adds a new HD wallet to the user
+func (u *User) AddHDWallet(walletID string, w HDCerealiaWallet) errstack.E {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	if _, err := u.FindWallet(walletID); err == nil {
+		return errstack.NewReqF("Wallet with ID '%s' already exists", walletID)
+	}
+	if u.HDCerealiaWallets == nil {
+		u.HDCerealiaWallets = map[string]HDCerealiaWallet{}
+	}
+	u.HDCerealiaWallets[walletID] = w
+	return nil
+}
+
+// AddHDWalletKeys appends public keys to the user HD wallet
+func (u *User) AddHDWalletKeys(walletID string, pubKeys []string) errstack.E {
+	w, ok := u.HDCerealiaWallets[walletID]
+	if !ok {
+		return errstack.NewReqF("User does not have a HD wallet with ID '%s'", walletID)
+	}
+	if errs := w.AddPubKeys(pubKeys); errs != nil {
+		return errs
+	}
+	u.HDCerealiaWallets[walletID] = w
+	return nil
+}
+
+// SetDefaultWallet sets the wallet used for new trades
+func (u *User) SetDefaultWallet(walletID string) errstack.E {
+	if _, err := u.FindWallet(walletID); err != nil {
+		return errstack.WrapAsReq(err, "Can't set the default wallet")
+	}
+	u.DefaultWalletID = walletID
+	return nil
+}
+
+// WantsEmail checks the user preferences whether a notification should be emailed.
+// Users are opted in by default. A preference for the action takes precedence over
+// a preference for the whole notification type.
+func (u *User) WantsEmail(t NotifType, action Approval) bool {
+	wants := true
+	for _, p := range u.NotifPrefs {
+		if p.Type != t {
+			continue
+		}
+		if p.Action == nil {
+			wants = p.Email
+		} else if *p.Action == action {
+			return p.Email
 		}
 	}
-	return false
+	return wants
+}
+
+// SetNotifPrefs validates and sets the notification preferences.
+// A preference replaces the existing one with the same type and action.
+func (u *User) SetNotifPrefs(prefs []NotifPref) errstack.E {
+	for _, p := range prefs {
+		if !p.Type.IsValid() {
+			return errstack.NewReqF("Wrong notification type: '%s'", p.Type)
+		}
+		if p.Action != nil && !p.Action.IsValid() {
+			return errstack.NewReqF("Wrong notification action: '%s'", *p.Action)
+		}
+	}
+	for _, p := range prefs {
+		u.setNotifPref(p)
+	}
+	return nil
+}
+
+func (u *User) setNotifPref(p NotifPref) {
+	for i, old := range u.NotifPrefs {
+		if old.Type == p.Type && sameApproval(old.Action, p.Action) {
+			u.NotifPrefs[i] = p
+			return
+		}
+	}
+	u.NotifPrefs = append(u.NotifPrefs, p)
+}
+
+func sameApproval(a, b *Approval) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// IsModerator returns true if user has moderator role.
+func (u *User) IsModerator() bool {
+	return u.HasRole(UserRoleModerator)
 }
 
 // CleanAndValidate validates the new userinput data and changes the nil value
@@ -63,7 +213,7 @@ func (nu *NewUserInput) CleanAndValidate() errstack.E {
 	if errb != nil {
 		return errb.ToReqErr()
 	}
-	return checkPassword(nu.Password)
+	return CheckPassword(nu.Password)
 }
 
 func assignEmptyStringAndTrim(s **string) {
@@ -79,7 +229,8 @@ func (o *Organization) SetID(id string) {
 	o.ID = id
 }
 
-func checkPassword(password string) errstack.E {
+// CheckPassword validates the password strength
+func CheckPassword(password string) errstack.E {
 	if len(password) < 8 {
 		return errstack.NewReq(shortLengthMessage)
 	}
@@ -2,6 +2,7 @@ package model
 
 import (
 	"reflect"
+	"time"
 
 	. "github.com/robert-zaremba/checkers"
 	rzc "github.com/robert-zaremba/checkers"
@@ -13,25 +14,25 @@ type S struct{}
 var _ = Suite(&S{})
 
 func (s *S) TestCheckPassword(c *C) {
-	c.Check(checkPassword("abcDEF@123"), IsNil, Comment("Failed to check password"))
-	c.Check(checkPassword("15!@ERdvhe"), IsNil, Comment("Failed to check password"))
-	c.Check(checkPassword("tue$dl@dfiDd"), IsNil, Comment("Failed to check password"))
+	c.Check(CheckPassword("abcDEF@123"), IsNil, Comment("Failed to check password"))
+	c.Check(CheckPassword("15!@ERdvhe"), IsNil, Comment("Failed to check password"))
+	c.Check(CheckPassword("tue$dl@dfiDd"), IsNil, Comment("Failed to check password"))
 
 	// negative test
 	// negative test for short length password
-	errs := checkPassword("As@3gs")
+	errs := CheckPassword("As@3gs")
 	c.Assert(errs, ErrorContains, shortLengthMessage, Comment("Expected error to check for short length"))
 
 	// negative test for wrong password without lowercase letter
-	errs = checkPassword("AB#1235VET")
+	errs = CheckPassword("AB#1235VET")
 	c.Assert(errs, ErrorContains, characterPwdMessage)
 
 	// negative test for wrong password without uppercase letter
-	errs = checkPassword("abc@#154ft")
+	errs = CheckPassword("abc@#154ft")
 	c.Assert(errs, ErrorContains, characterPwdMessage)
 
 	// negative test for wrong password without special character
-	errs = checkPassword("abctSD321")
+	errs = CheckPassword("abctSD321")
 	c.Assert(errs, ErrorContains, characterPwdMessage)
 
 }
@@ -174,3 +175,103 @@ var tradeWithParticipantsHD = Trade{
 		PubKey:   "pubkey-abcde",
 	},
 }
+
+func (s *S) TestUserDeriveNewKey(c *C) {
+	u := User{
+		ID:              "hd-user",
+		DefaultWalletID: "hd",
+		StaticWallets:   map[string]StaticWallet{},
+		HDCerealiaWallets: map[string]HDCerealiaWallet{
+			"hd": HDCerealiaWallet{PubKeys: sep5PubKeys, DerivationIndex: 1},
+		},
+	}
+	k, path, err := u.DeriveNewKey()
+	c.Assert(err, IsNil)
+	c.Check(k, Equals, sep5PubKeys[1])
+	c.Check(path, Equals, "m/44'/148'/1'")
+	// the incremented index must be stored in the user object
+	c.Check(u.HDCerealiaWallets["hd"].DerivationIndex, Equals, 2)
+
+	_, _, err = emptyUser.DeriveNewKey()
+	c.Check(err, ErrorContains, "does not have a wallet with ID")
+}
+
+func (s *S) TestAddHDWalletAndSetDefault(c *C) {
+	u := User{
+		ID:              "user",
+		DefaultWalletID: "static",
+		StaticWallets:   map[string]StaticWallet{"static": StaticWallet{PubKey: "key"}},
+	}
+	err := u.AddHDWallet("hd", HDCerealiaWallet{PubKeys: []string{"bad key"}})
+	c.Check(err, ErrorContains, "pubKeys.0")
+	err = u.AddHDWallet("static", HDCerealiaWallet{PubKeys: sep5PubKeys[:1]})
+	c.Check(err, ErrorContains, "Wallet with ID 'static' already exists")
+	err = u.AddHDWallet("hd", HDCerealiaWallet{PubKeys: sep5PubKeys[:1]})
+	c.Assert(err, IsNil)
+	c.Check(u.HDCerealiaWallets, HasLen, 1)
+	c.Check(u.DefaultWalletID, Equals, "static")
+
+	c.Check(u.SetDefaultWallet("unknown"), ErrorContains, "Can't set the default wallet")
+	c.Check(u.DefaultWalletID, Equals, "static")
+	c.Assert(u.SetDefaultWallet("hd"), IsNil)
+	c.Check(u.DefaultWalletID, Equals, "hd")
+
+	c.Check(u.AddHDWalletKeys("static", sep5PubKeys[1:]), ErrorContains, "HD wallet")
+	c.Assert(u.AddHDWalletKeys("hd", sep5PubKeys[1:]), IsNil)
+	c.Check(u.HDCerealiaWallets["hd"].PubKeys, DeepEquals, sep5PubKeys)
+}
+
+func (s *S) TestNotifPrefs(c *C) {
+	u := User{}
+	c.Check(u.WantsEmail(NotifTypeAction, ApprovalPending), IsTrue)
+
+	pending := ApprovalPending
+	err := u.SetNotifPrefs([]NotifPref{
+		{Type: NotifTypeAction, Email: false},
+		{Type: NotifTypeAction, Action: &pending, Email: true},
+	})
+	c.Assert(err, IsNil)
+	c.Check(u.WantsEmail(NotifTypeAction, ApprovalPending), IsTrue)
+	c.Check(u.WantsEmail(NotifTypeAction, ApprovalApproved), IsFalse)
+	c.Check(u.WantsEmail(NotifTypeAlert, ApprovalApproved), IsTrue)
+
+	// existing preferences are replaced
+	err = u.SetNotifPrefs([]NotifPref{{Type: NotifTypeAction, Action: &pending, Email: false}})
+	c.Assert(err, IsNil)
+	c.Check(u.NotifPrefs, HasLen, 2)
+	c.Check(u.WantsEmail(NotifTypeAction, ApprovalPending), IsFalse)
+
+	wrong := Approval("wrong")
+	c.Check(u.SetNotifPrefs([]NotifPref{{Type: "wrong"}}), ErrorContains, "Wrong notification type")
+	c.Check(u.SetNotifPrefs([]NotifPref{{Type: NotifTypeAlert, Action: &wrong}}), ErrorContains, "Wrong notification action")
+	c.Check(u.NotifPrefs, HasLen, 2)
+}
+
+func (s *S) TestUserSessionIsActive(c *C) {
+	now := time.Now()
+	us := UserSession{ExpiresAt: now.Add(time.Hour)}
+	c.Check(us.IsActive(now), IsTrue)
+	c.Check(us.IsActive(now.Add(2*time.Hour)), IsFalse)
+	us.RevokedAt = &now
+	c.Check(us.IsActive(now), IsFalse)
+}
+
+func (s *S) TestEmailVerification(c *C) {
+	u := User{Emails: []string{"a@a.a", "b@b.b"}, VerifiedEmails: []string{"b@b.b", "old@c.c"}}
+	c.Check(u.IsEmailVerified("b@b.b"), IsTrue)
+	c.Check(u.IsEmailVerified("old@c.c"), IsFalse, Comment("removed emails are not verified"))
+	c.Check(u.IsPrimaryEmailVerified(), IsFalse)
+	c.Check(u.UnverifiedEmails(), DeepEquals, []string{"a@a.a"})
+	u.VerifiedEmails = append(u.VerifiedEmails, "a@a.a")
+	c.Check(u.IsPrimaryEmailVerified(), IsTrue)
+	c.Check(u.UnverifiedEmails(), IsNil)
+}
+
+func (s *S) TestHasStaticWalletKey(c *C) {
+	u := User{StaticWallets: map[string]StaticWallet{
+		"w1": StaticWallet{PubKey: "GD3EPS4EBOK6ZELDEN466I6EU4LW7TK6UL6INZRC6OKLZKYXXESS64VE"},
+	}}
+	c.Check(u.HasStaticWalletKey("GD3EPS4EBOK6ZELDEN466I6EU4LW7TK6UL6INZRC6OKLZKYXXESS64VE"), IsTrue)
+	c.Check(u.HasStaticWalletKey("GCZVKTLPQY54OGK5R3EEAU22Q7COES2XP5544H2C3CRNN7GGRL74IBUM"), IsFalse)
+	c.Check((&User{}).HasStaticWalletKey(""), IsFalse)
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// MFAFreshTime is how long a verified TOTP code authorizes the sensitive operations
+const MFAFreshTime = 5 * time.Minute
+
+// SetID implements dal.HasID interface
+func (s *UserSession) SetID(id string) {
+	s.ID = id
+}
+
+// IsActive checks if the session can be used to authenticate the user
+func (s *UserSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// IsMFAFresh checks if a TOTP code was verified in the session recently
+func (s *UserSession) IsMFAFresh(now time.Time) bool {
+	return s.MFAVerifiedAt != nil && now.Sub(*s.MFAVerifiedAt) < MFAFreshTime
+}
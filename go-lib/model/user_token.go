@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// MaxMFAAttempts is the number of TOTP codes which can be tried with one login token
+const MaxMFAAttempts = 5
+
+// TTL returns the validity time of the tokens with the purpose
+func (p UserTokenPurpose) TTL() time.Duration {
+	switch p {
+	case UserTokenPasswordReset:
+		return time.Hour
+	case UserTokenKeyLogin, UserTokenMFALogin:
+		return 5 * time.Minute
+	}
+	return 48 * time.Hour
+}
+
+// SetID implements dal.HasID interface
+func (t *UserToken) SetID(id string) {
+	t.ID = id
+}
@@ -0,0 +1,107 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/auth/totp"
+	"github.com/robert-zaremba/errstack"
+)
+
+const recoveryCodesCount = 10
+
+// HasTOTP checks if the user enabled two-factor authentication
+func (u *User) HasTOTP() bool {
+	return u.TOTP != nil && u.TOTP.Enabled
+}
+
+// EnrollTOTP starts the TOTP enrollment with a new secret. The secret has to be
+// confirmed with EnableTOTP.
+func (u *User) EnrollTOTP() (string, errstack.E) {
+	if u.HasTOTP() {
+		return "", errstack.NewReq("Two-factor authentication is already enabled")
+	}
+	secret, errs := totp.NewSecret()
+	if errs != nil {
+		return "", errs
+	}
+	u.TOTP = &UserTOTP{Secret: secret}
+	return secret, nil
+}
+
+// EnableTOTP confirms the enrollment with the first code and returns new recovery codes.
+// Only hashes of the recovery codes are stored.
+func (u *User) EnableTOTP(code string, now time.Time) ([]string, errstack.E) {
+	if u.TOTP == nil || u.TOTP.Enabled {
+		return nil, errstack.NewReq("Two-factor authentication enrollment is not started")
+	}
+	counter, ok := totp.Verify(u.TOTP.Secret, code, now, u.TOTP.LastCounter)
+	if !ok {
+		return nil, ErrInvalidTOTP
+	}
+	codes := make([]string, recoveryCodesCount)
+	hashes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errstack.WrapAsInf(err, "Can't generate recovery codes")
+		}
+		c := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = c[:8] + "-" + c[8:16]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	u.TOTP.Enabled = true
+	u.TOTP.EnabledAt = &now
+	u.TOTP.LastCounter = counter
+	u.TOTP.RecoveryCodes = hashes
+	return codes, nil
+}
+
+// DisableTOTP removes the two-factor authentication after verifying the code
+func (u *User) DisableTOTP(code string, now time.Time) errstack.E {
+	if _, errs := u.VerifySecondFactor(code, now); errs != nil {
+		return errs
+	}
+	u.TOTP = nil
+	return nil
+}
+
+// SecondFactorUse is the code accepted by VerifySecondFactor
+type SecondFactorUse struct {
+	// Counter is the time step of the TOTP code, 0 when a recovery code was used
+	Counter int64
+	// RecoveryCode is the hash of the used recovery code
+	RecoveryCode string
+}
+
+// VerifySecondFactor checks the TOTP code or a recovery code. Accepted codes can't be
+// used again. The returned use has to be saved with dal.UseUserTOTPCode, which fails
+// when the code was accepted concurrently.
+func (u *User) VerifySecondFactor(code string, now time.Time) (SecondFactorUse, errstack.E) {
+	if !u.HasTOTP() {
+		return SecondFactorUse{}, errstack.NewReq("Two-factor authentication is not enabled")
+	}
+	if counter, ok := totp.Verify(u.TOTP.Secret, code, now, u.TOTP.LastCounter); ok {
+		u.TOTP.LastCounter = counter
+		return SecondFactorUse{Counter: counter}, nil
+	}
+	h := hashRecoveryCode(code)
+	for i, rc := range u.TOTP.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(rc), []byte(h)) == 1 {
+			u.TOTP.RecoveryCodes = append(u.TOTP.RecoveryCodes[:i], u.TOTP.RecoveryCodes[i+1:]...)
+			return SecondFactorUse{RecoveryCode: rc}, nil
+		}
+	}
+	return SecondFactorUse{}, ErrInvalidTOTP
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/auth/totp"
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (s *S) TestTOTP(c *C) {
+	now := time.Unix(1500000000, 0)
+	u := User{}
+	c.Check(u.HasTOTP(), IsFalse)
+	_, errs := u.VerifySecondFactor("123456", now)
+	c.Check(errs, ErrorContains, "not enabled")
+	_, errs = u.EnableTOTP("123456", now)
+	c.Check(errs, ErrorContains, "not started")
+
+	secret, errs := u.EnrollTOTP()
+	c.Assert(errs, IsNil)
+	c.Check(u.HasTOTP(), IsFalse, Comment("enrollment must be confirmed"))
+	code := func(t time.Time) string {
+		code, errs := totp.Code(secret, totp.Counter(t))
+		c.Assert(errs, IsNil)
+		return code
+	}
+	_, errs = u.EnableTOTP("000000", now)
+	c.Check(errs, Equals, ErrInvalidTOTP)
+	recovery, errs := u.EnableTOTP(code(now), now)
+	c.Assert(errs, IsNil)
+	c.Check(recovery, HasLen, recoveryCodesCount)
+	c.Check(u.HasTOTP(), IsTrue)
+	c.Check(u.TOTP.RecoveryCodes, Not(DeepEquals), recovery, Comment("only hashes are stored"))
+	_, errs = u.EnrollTOTP()
+	c.Check(errs, ErrorContains, "already enabled")
+
+	_, errs = u.VerifySecondFactor(code(now), now)
+	c.Check(errs, Equals, ErrInvalidTOTP, Comment("code reuse"))
+	later := now.Add(totp.Period)
+	use, errs := u.VerifySecondFactor(code(later), later)
+	c.Assert(errs, IsNil)
+	c.Check(use, Equals, SecondFactorUse{Counter: totp.Counter(later)})
+
+	rc := u.TOTP.RecoveryCodes[0]
+	use, errs = u.VerifySecondFactor(recovery[0], later)
+	c.Assert(errs, IsNil)
+	c.Check(use, Equals, SecondFactorUse{RecoveryCode: rc})
+	c.Check(u.TOTP.RecoveryCodes, HasLen, recoveryCodesCount-1)
+	_, errs = u.VerifySecondFactor(recovery[0], later)
+	c.Check(errs, Equals, ErrInvalidTOTP, Comment("recovery codes are single use"))
+
+	c.Check(u.DisableTOTP("000000", later), Equals, ErrInvalidTOTP)
+	c.Check(u.DisableTOTP(recovery[1], later), IsNil)
+	c.Check(u.HasTOTP(), IsFalse)
+	c.Check(u.TOTP, IsNil)
+}
+
+func (s *S) TestUserSessionIsMFAFresh(c *C) {
+	now := time.Now()
+	us := UserSession{}
+	c.Check(us.IsMFAFresh(now), IsFalse)
+	us.MFAVerifiedAt = &now
+	c.Check(us.IsMFAFresh(now.Add(time.Minute)), IsTrue)
+	c.Check(us.IsMFAFresh(now.Add(MFAFreshTime)), IsFalse)
+}
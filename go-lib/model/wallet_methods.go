@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/robert-zaremba/errstack"
+	"github.com/stellar/go/strkey"
 )
 
 const hdCerealiaWalletFormat = "m/44'/148'/%v'"
@@ -22,10 +23,46 @@ func (w *StaticWallet) DeriveNewKey() (pubKey string, keyDerivationPath string,
 }
 
 // DeriveNewKey implements interface KeyWallet
-// TODO: add pubkey derivation lib
+// It returns the registered `m/44'/148'/<DerivationIndex>'` key and increments the index.
 func (w *HDCerealiaWallet) DeriveNewKey() (pubKey string, keyDerivationPath string, error errstack.E) {
-	format := fmt.Sprintf(hdCerealiaWalletFormat, w.DerivationIndex)
+	if w.DerivationIndex < 0 || w.DerivationIndex >= len(w.PubKeys) {
+		return "", "", errstack.NewReq("All keys of the HD wallet are used, please register more keys")
+	}
+	pubKey = w.PubKeys[w.DerivationIndex]
+	path := fmt.Sprintf(hdCerealiaWalletFormat, w.DerivationIndex)
 	w.DerivationIndex++
-	// TODO: Derive the key here
-	return "GD3EPS4EBOK6ZELDEN466I6EU4LW7TK6UL6INZRC6OKLZKYXXESS64VE", format, nil
+	return pubKey, path, nil
+}
+
+// AddPubKeys appends keys of the following derivation indexes
+func (w *HDCerealiaWallet) AddPubKeys(pubKeys []string) errstack.E {
+	next := *w
+	next.PubKeys = append(append([]string{}, w.PubKeys...), pubKeys...)
+	if errs := next.Validate(); errs != nil {
+		return errs
+	}
+	*w = next
+	return nil
+}
+
+// Validate checks if the HD wallet can provide keys
+func (w *HDCerealiaWallet) Validate() errstack.E {
+	errb := errstack.NewBuilder()
+	if len(w.PubKeys) == 0 {
+		errb.Put("pubKeys", "at least one key is required")
+	}
+	seen := make(map[string]bool, len(w.PubKeys))
+	for i, k := range w.PubKeys {
+		key := fmt.Sprintf("pubKeys.%d", i)
+		if _, err := strkey.Decode(strkey.VersionByteAccountID, k); err != nil {
+			errb.Put(key, "must be a Stellar public key")
+		} else if seen[k] {
+			errb.Put(key, "the key is listed twice")
+		}
+		seen[k] = true
+	}
+	if w.DerivationIndex < 0 || w.DerivationIndex > len(w.PubKeys) {
+		errb.Put("derivationIndex", "must point to a registered key")
+	}
+	return errb.ToReqErr()
 }
@@ -1,7 +1,7 @@
 package model
 
 import (
-	//. "github.com/robert-zaremba/checkers"
+	. "github.com/robert-zaremba/checkers"
 	. "gopkg.in/check.v1"
 )
 
@@ -24,21 +24,52 @@ func (s *WalletSuite) TestDeriveStatic(c *C) {
 	c.Assert(path, Equals, "")
 }
 
+// keys of the m/44'/148'/<i>' paths of the SEP-0005 test vector 1 seed
+var sep5PubKeys = []string{
+	"GDRXE2BQUC3AZNPVFSCEZ76NJ3WWL25FYFK6RGZGIEKWE4SOOHSUJUJ6",
+	"GBAW5XGWORWVFE2XTJYDTLDHXTY2Q2MO73HYCGB3XMFMQ562Q2W2GJQX",
+	"GAY5PRAHJ2HIYBYCLZXTHID6SPVELOOYH2LBPH3LD4RUMXUW3DOYTLXW",
+}
+
 func (s *WalletSuite) TestDeriveNewKeyHD(c *C) {
 	w := HDCerealiaWallet{
-		// TODO: add parameters
-		DerivationIndex: 42858,
+		PubKeys:         sep5PubKeys[:2],
+		DerivationIndex: 0,
 	}
 	k, path, err := w.DeriveNewKey()
 	c.Assert(err, IsNil)
-	c.Check(k, Equals, "GD3EPS4EBOK6ZELDEN466I6EU4LW7TK6UL6INZRC6OKLZKYXXESS64VE")
-	c.Check(w.DerivationIndex, Equals, 42859)
-	c.Check(path, Equals, "m/44'/148'/42858'")
+	c.Check(k, Equals, sep5PubKeys[0])
+	c.Check(w.DerivationIndex, Equals, 1)
+	c.Check(path, Equals, "m/44'/148'/0'")
 	// Repeated attempt should produce a new key
-	//	k2, path2, err := w.DeriveNewKey()
-	//	c.Assert(err, IsNil)
-	//	c.Check(k2, Not(Equals), k) //TODO: make it work with proper derivation
-	//	c.Check(w.DerivationIndex, Equals, 42860)
-	//	c.Check(path2, Equals, "m/44'/148'/42859'")
-	//	c.Check(path2, Not(Equals), path)
+	k2, path2, err := w.DeriveNewKey()
+	c.Assert(err, IsNil)
+	c.Check(k2, Equals, sep5PubKeys[1])
+	c.Check(w.DerivationIndex, Equals, 2)
+	c.Check(path2, Equals, "m/44'/148'/1'")
+	// all the keys are used
+	_, _, err = w.DeriveNewKey()
+	c.Check(err, ErrorContains, "register more keys")
+	c.Check(w.DerivationIndex, Equals, 2)
+}
+
+func (s *WalletSuite) TestAddPubKeys(c *C) {
+	w := HDCerealiaWallet{PubKeys: sep5PubKeys[:1]}
+	c.Check(w.AddPubKeys(sep5PubKeys[:1]), ErrorContains, "listed twice")
+	c.Check(w.PubKeys, HasLen, 1)
+	c.Assert(w.AddPubKeys(sep5PubKeys[1:]), IsNil)
+	c.Check(w.PubKeys, DeepEquals, sep5PubKeys)
+}
+
+func (s *WalletSuite) TestValidateHD(c *C) {
+	w := HDCerealiaWallet{PubKeys: sep5PubKeys}
+	c.Check(w.Validate(), IsNil)
+	w.DerivationIndex = -1
+	c.Check(w.Validate(), ErrorContains, "derivationIndex")
+	w.DerivationIndex = 4
+	c.Check(w.Validate(), ErrorContains, "derivationIndex")
+	c.Check((&HDCerealiaWallet{}).Validate(), ErrorContains, "pubKeys")
+	// secret keys must never be registered
+	w = HDCerealiaWallet{PubKeys: []string{"SBGWSG6BTNCKCOB3DIFBGCVMUPQFYPA2G4O34RMTB343OYPXU5DJDVMN"}}
+	c.Check(w.Validate(), ErrorContains, "pubKeys.0")
 }
@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"text/template"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"github.com/robert-zaremba/errstack"
+)
+
+var verificationTemplate = template.Must(template.New("verification").Parse(
+	`Hello {{.User.FirstName}} {{.User.LastName}},
+
+please confirm that {{.Email}} is your email address by opening the link:
+{{.Link}}
+
+The link is valid for {{.TTL}}. If you didn't register in Cerealia, please ignore this email.
+`))
+
+var passwordResetTemplate = template.Must(template.New("passwordReset").Parse(
+	`Hello {{.User.FirstName}} {{.User.LastName}},
+
+we received a request to reset your Cerealia password. You can set a new password here:
+{{.Link}}
+
+The link is valid for {{.TTL}}. If you didn't ask for the password reset, please ignore this email;
+your password won't change.
+`))
+
+var orgInvitationTemplate = template.Must(template.New("orgInvitation").Parse(
+	`Hello,
+
+{{.Inviter.FirstName}} {{.Inviter.LastName}} invites you to join {{.Org.Name}} in Cerealia as {{.Role}}.
+Sign in or register with this email address and accept the invitation here:
+{{.Link}}
+`))
+
+var accountLockedTemplate = template.Must(template.New("accountLocked").Parse(
+	`Hello {{.User.FirstName}} {{.User.LastName}},
+
+your Cerealia account was locked after too many failed login attempts. You can login again
+after {{.Until}}.
+
+If it wasn't you, somebody may be guessing your password. Please change it after the lockout
+or contact the Cerealia team to unlock the account.
+`))
+
+type accountEmailData struct {
+	User  *model.User
+	Email string
+	Link  string
+	TTL   string
+}
+
+// SendEmailVerification emails the link to verify the user email address
+func (d *Dispatcher) SendEmailVerification(u *model.User, email, token string) errstack.E {
+	return d.sendAccountEmail(verificationTemplate, "Cerealia: verify your email", u, email,
+		d.appURL+"/view/verify-email?token="+url.QueryEscape(token), model.UserTokenEmailVerification)
+}
+
+// SendPasswordReset emails the password reset link
+func (d *Dispatcher) SendPasswordReset(u *model.User, email, token string) errstack.E {
+	return d.sendAccountEmail(passwordResetTemplate, "Cerealia: password reset", u, email,
+		d.appURL+"/view/reset-password?token="+url.QueryEscape(token), model.UserTokenPasswordReset)
+}
+
+func (d *Dispatcher) sendAccountEmail(t *template.Template, subject string, u *model.User, email, link string, purpose model.UserTokenPurpose) errstack.E {
+	var b bytes.Buffer
+	ttl := fmt.Sprintf("%d hours", int(purpose.TTL().Hours()))
+	if purpose.TTL() == time.Hour {
+		ttl = "1 hour"
+	}
+	err := t.Execute(&b, accountEmailData{u, email, link, ttl})
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't render the email")
+	}
+	return d.sender.Send(email, subject, b.String())
+}
+
+type orgInvitationData struct {
+	Inviter *model.User
+	Org     *model.Organization
+	Role    model.OrgRole
+	Link    string
+}
+
+// SendOrgInvitation emails the organization invitation
+func (d *Dispatcher) SendOrgInvitation(inviter *model.User, org *model.Organization, email string, role model.OrgRole) errstack.E {
+	var b bytes.Buffer
+	err := orgInvitationTemplate.Execute(&b, orgInvitationData{inviter, org, role, d.appURL + "/view/settings/invitations"})
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't render the email")
+	}
+	return d.sender.Send(email, "Cerealia: invitation to "+org.Name, b.String())
+}
+
+type accountLockedData struct {
+	User  *model.User
+	Until string
+}
+
+// SendAccountLocked notifies the user about the temporary account lockout
+func (d *Dispatcher) SendAccountLocked(u *model.User, email string, until time.Time) errstack.E {
+	var b bytes.Buffer
+	err := accountLockedTemplate.Execute(&b, accountLockedData{u, until.UTC().Format("2006-01-02 15:04 MST")})
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't render the email")
+	}
+	return d.sender.Send(email, "Cerealia: account locked", b.String())
+}
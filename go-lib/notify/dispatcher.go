@@ -0,0 +1,119 @@
+// Package notify delivers notifications to the users: stores them, pushes them to the
+// live subscribers and emails them to the receivers who opted in.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"text/template"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/pubsub"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+	"github.com/robert-zaremba/log15"
+)
+
+var logger = log15.Root()
+
+// dispatchTimeout limits the time of emailing a notification to all its receivers
+const dispatchTimeout = time.Minute
+
+var emailTemplate = template.Must(template.New("notification").Parse(
+	`Hello {{.User.FirstName}} {{.User.LastName}},
+
+{{.Notification.Msg}}
+
+Open it in Cerealia: {{.Link}}
+
+You can choose which notifications are emailed to you in the preferences: {{.PrefsLink}}
+`))
+
+var subjects = map[model.NotifType]string{
+	model.NotifTypeAction: "Cerealia: new trade activity",
+	model.NotifTypeAlert:  "Cerealia: trade alert",
+}
+
+type emailData struct {
+	User         *model.User
+	Notification *model.Notification
+	Link         string
+	PrefsLink    string
+}
+
+// Dispatcher emails notifications
+type Dispatcher struct {
+	sender Sender
+	appURL string
+}
+
+// NewDispatcher creates a Dispatcher. appURL is used to create links to the app.
+func NewDispatcher(s Sender, appURL string) *Dispatcher {
+	return &Dispatcher{s, appURL}
+}
+
+// Default is the dispatcher used by Deliver. It's replaced during the server setup.
+var Default = NewDispatcher(LogSender{}, "http://localhost:8000")
+
+// Deliver stores the notification, publishes it to the live subscribers
+// and emails it (in background) to the receivers.
+func Deliver(ctx context.Context, db driver.Database, n *model.Notification) errstack.E {
+	if errs := dal.InsertNotification(ctx, db, n); errs != nil {
+		return errs
+	}
+	pubsub.Default.PublishNotification(n)
+	Default.DispatchAsync(db, n)
+	return nil
+}
+
+// Link returns a deep link to the notification entity
+func (d *Dispatcher) Link(n *model.Notification) string {
+	return d.appURL + "/view/trades?entity=" + url.QueryEscape(n.EntityID)
+}
+
+// Dispatch emails the notification to all the receivers.
+// Errors are logged and the last one is returned.
+func (d *Dispatcher) Dispatch(ctx context.Context, db driver.Database, n *model.Notification) errstack.E {
+	var last errstack.E
+	for _, uid := range n.Receiver {
+		u, errs := dal.GetUser(ctx, db, uid)
+		if errs == nil {
+			errs = d.Send(u, n)
+		}
+		if errs != nil {
+			logger.Error("Can't email the notification", "user", uid, "notification", n.ID, errs)
+			last = errs
+		}
+	}
+	return last
+}
+
+// DispatchAsync runs Dispatch in background, so the email delivery doesn't block the request.
+func (d *Dispatcher) DispatchAsync(db driver.Database, n *model.Notification) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), dispatchTimeout)
+		defer cancel()
+		_ = d.Dispatch(ctx, db, n) // errors are logged
+	}()
+}
+
+// Send emails the notification to the user if the user opted in for it
+func (d *Dispatcher) Send(u *model.User, n *model.Notification) errstack.E {
+	if len(u.Emails) == 0 || !u.WantsEmail(n.Type, n.Action) {
+		return nil
+	}
+	var b bytes.Buffer
+	err := emailTemplate.Execute(&b, emailData{
+		User:         u,
+		Notification: n,
+		Link:         d.Link(n),
+		PrefsLink:    d.appURL + "/view/settings/preferences",
+	})
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't render the notification email")
+	}
+	return d.sender.Send(u.Emails[0], subjects[n.Type], b.String())
+}
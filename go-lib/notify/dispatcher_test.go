@@ -0,0 +1,127 @@
+package notify
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	. "github.com/robert-zaremba/checkers"
+	"github.com/robert-zaremba/errstack"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type DispatcherSuite struct{}
+
+var _ = Suite(&DispatcherSuite{})
+
+type email struct {
+	to, subject, body string
+}
+
+type memSender struct {
+	emails []email
+}
+
+func (s *memSender) Send(to, subject, body string) errstack.E {
+	s.emails = append(s.emails, email{to, subject, body})
+	return nil
+}
+
+var notif = model.Notification{
+	ID:       "n1",
+	Receiver: []string{"u1"},
+	Type:     model.NotifTypeAction,
+	Action:   model.ApprovalPending,
+	EntityID: "trades:1/stages:0/docs:1",
+	Msg:      "New stage doc has been created by John Smith",
+}
+
+func (s *DispatcherSuite) TestSend(c *C) {
+	ms := &memSender{}
+	d := NewDispatcher(ms, "https://app.cerealia.io")
+	u := model.User{FirstName: "Ann", LastName: "Lee", Emails: []string{"ann@example.com", "ann2@example.com"}}
+	c.Assert(d.Send(&u, &notif), IsNil)
+	c.Assert(ms.emails, HasLen, 1)
+	e := ms.emails[0]
+	c.Check(e.to, Equals, "ann@example.com")
+	c.Check(e.subject, Equals, subjects[model.NotifTypeAction])
+	c.Check(e.body, Contains, "Hello Ann Lee")
+	c.Check(e.body, Contains, notif.Msg)
+	c.Check(e.body, Contains, "https://app.cerealia.io/view/trades?entity=trades%3A1%2Fstages%3A0%2Fdocs%3A1")
+
+	// opted out
+	pending := model.ApprovalPending
+	u.NotifPrefs = []model.NotifPref{{Type: model.NotifTypeAction, Action: &pending, Email: false}}
+	c.Assert(d.Send(&u, &notif), IsNil)
+	c.Check(ms.emails, HasLen, 1)
+
+	// no email address
+	c.Assert(d.Send(&model.User{}, &notif), IsNil)
+	c.Check(ms.emails, HasLen, 1)
+}
+
+func (s *DispatcherSuite) TestFileSender(c *C) {
+	dir, err := ioutil.TempDir("", "notify")
+	c.Assert(err, IsNil)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "emails.txt")
+	fs := NewFileSender(path)
+	c.Assert(fs.Send("a@example.com", "subject 1", "body 1"), IsNil)
+	c.Assert(fs.Send("b@example.com", "subject 2", "body 2"), IsNil)
+	content, err := ioutil.ReadFile(path)
+	c.Assert(err, IsNil)
+	c.Check(string(content), Contains, "To: a@example.com\r\nSubject: subject 1\r\n")
+	c.Check(string(content), Contains, "To: b@example.com\r\nSubject: subject 2\r\n")
+	c.Check(string(content), Contains, "body 2")
+}
+
+func (s *DispatcherSuite) TestAccountEmails(c *C) {
+	ms := &memSender{}
+	d := NewDispatcher(ms, "https://app.cerealia.io")
+	u := model.User{FirstName: "Ann", LastName: "Lee", Emails: []string{"ann@example.com"}}
+	c.Assert(d.SendEmailVerification(&u, "ann2@example.com", "t1.secret"), IsNil)
+	c.Assert(d.SendPasswordReset(&u, "ann@example.com", "t2.secret"), IsNil)
+	c.Assert(ms.emails, HasLen, 2)
+
+	e := ms.emails[0]
+	c.Check(e.to, Equals, "ann2@example.com")
+	c.Check(e.body, Contains, "https://app.cerealia.io/view/verify-email?token=t1.secret")
+	c.Check(e.body, Contains, "valid for 48 hours")
+	e = ms.emails[1]
+	c.Check(e.to, Equals, "ann@example.com")
+	c.Check(e.body, Contains, "https://app.cerealia.io/view/reset-password?token=t2.secret")
+	c.Check(e.body, Contains, "valid for 1 hour.")
+}
+
+func (s *DispatcherSuite) TestOrgInvitation(c *C) {
+	ms := &memSender{}
+	d := NewDispatcher(ms, "https://app.cerealia.io")
+	u := model.User{FirstName: "Ann", LastName: "Lee"}
+	org := model.Organization{Name: "Grain Co"}
+	c.Assert(d.SendOrgInvitation(&u, &org, "bob@example.com", model.OrgRoleViewer), IsNil)
+	c.Assert(ms.emails, HasLen, 1)
+	e := ms.emails[0]
+	c.Check(e.to, Equals, "bob@example.com")
+	c.Check(e.subject, Equals, "Cerealia: invitation to Grain Co")
+	c.Check(e.body, Contains, "Ann Lee invites you to join Grain Co in Cerealia as viewer.")
+	c.Check(e.body, Contains, "https://app.cerealia.io/view/settings/invitations")
+}
+
+func (s *DispatcherSuite) TestAccountLocked(c *C) {
+	ms := &memSender{}
+	d := NewDispatcher(ms, "https://app.cerealia.io")
+	u := model.User{FirstName: "Ann", LastName: "Lee"}
+	until := time.Date(2019, 3, 1, 10, 30, 0, 0, time.UTC)
+	c.Assert(d.SendAccountLocked(&u, "ann@example.com", until), IsNil)
+	c.Assert(ms.emails, HasLen, 1)
+	e := ms.emails[0]
+	c.Check(e.to, Equals, "ann@example.com")
+	c.Check(e.subject, Equals, "Cerealia: account locked")
+	c.Check(e.body, Contains, "Hello Ann Lee")
+	c.Check(e.body, Contains, "after 2019-03-01 10:30 UTC.")
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"net/smtp"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/robert-zaremba/errstack"
+)
+
+// Sender delivers an email
+type Sender interface {
+	Send(to, subject, body string) errstack.E
+}
+
+func mkMessage(from, to, subject, body string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n\r\n")
+	b.WriteString(body)
+	return b.Bytes()
+}
+
+// SMTPSender sends emails through an SMTP server
+type SMTPSender struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+// NewSMTPSender creates SMTPSender for the server URL: //username:password@host:port
+func NewSMTPSender(u url.URL, from string) SMTPSender {
+	var a smtp.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		a = smtp.PlainAuth("", u.User.Username(), password, u.Hostname())
+	}
+	return SMTPSender{Addr: u.Host, From: from, Auth: a}
+}
+
+// Send implements Sender interface
+func (s SMTPSender) Send(to, subject, body string) errstack.E {
+	err := smtp.SendMail(s.Addr, s.Auth, s.From, []string{to}, mkMessage(s.From, to, subject, body))
+	return errstack.WrapAsInf(err, "Can't send email")
+}
+
+// FileSender appends emails to a file. It's used in development and tests.
+type FileSender struct {
+	Path string
+	mu   *sync.Mutex
+}
+
+// NewFileSender creates a FileSender
+func NewFileSender(path string) FileSender {
+	return FileSender{path, &sync.Mutex{}}
+}
+
+// Send implements Sender interface
+func (s FileSender) Send(to, subject, body string) errstack.E {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't open emails file")
+	}
+	defer errstack.CallAndLog(logger, f.Close)
+	_, err = f.Write(append(mkMessage("cerealia", to, subject, body), "\r\n\r\n"...))
+	return errstack.WrapAsInf(err, "Can't write email to the file")
+}
+
+// LogSender only logs the emails
+type LogSender struct{}
+
+// Send implements Sender interface
+func (LogSender) Send(to, subject, body string) errstack.E {
+	logger.Info("Email", "to", to, "subject", subject)
+	return nil
+}
@@ -0,0 +1,76 @@
+// Package pubsub provides an in-process publish / subscribe hub used to push
+// live updates (GraphQL subscriptions) to the connected clients.
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/robert-zaremba/log15"
+)
+
+var logger = log15.Root()
+
+// subscriberBuffer is a size of the subscriber channel buffer.
+// Messages to subscribers with a full buffer are dropped, so a slow client
+// never blocks the publisher.
+const subscriberBuffer = 16
+
+type subscribers map[chan interface{}]struct{}
+
+// Hub dispatches messages published to a topic to all the topic subscribers
+type Hub struct {
+	mu     sync.RWMutex
+	topics map[string]subscribers
+}
+
+// NewHub creates a new Hub
+func NewHub() *Hub {
+	return &Hub{topics: map[string]subscribers{}}
+}
+
+// Subscribe returns a channel which receives all messages published to the topic.
+// The subscription is removed and the channel is closed when ctx is done.
+func (h *Hub) Subscribe(ctx context.Context, topic string) <-chan interface{} {
+	ch := make(chan interface{}, subscriberBuffer)
+	h.mu.Lock()
+	subs, ok := h.topics[topic]
+	if !ok {
+		subs = subscribers{}
+		h.topics[topic] = subs
+	}
+	subs[ch] = struct{}{}
+	h.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		h.mu.Lock()
+		delete(subs, ch)
+		if len(subs) == 0 {
+			delete(h.topics, topic)
+		}
+		close(ch)
+		h.mu.Unlock()
+	}()
+	return ch
+}
+
+// Publish sends the message to all topic subscribers. It never blocks.
+func (h *Hub) Publish(topic string, msg interface{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.topics[topic] {
+		select {
+		case ch <- msg:
+		default:
+			logger.Warn("Subscriber buffer is full, dropping message", "topic", topic)
+		}
+	}
+}
+
+// NumSubscribers returns the number of the topic subscribers
+func (h *Hub) NumSubscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
@@ -0,0 +1,92 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type HubSuite struct{}
+
+var _ = Suite(&HubSuite{})
+
+func (s *HubSuite) TestPublishSubscribe(c *C) {
+	h := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch1 := h.Subscribe(ctx, "t1")
+	ch2 := h.Subscribe(ctx, "t1")
+	other := h.Subscribe(ctx, "t2")
+	c.Check(h.NumSubscribers("t1"), Equals, 2)
+
+	h.Publish("t1", 1)
+	c.Check(<-ch1, Equals, 1)
+	c.Check(<-ch2, Equals, 1)
+	c.Check(len(other), Equals, 0)
+
+	cancel()
+	_, ok := <-ch1
+	c.Check(ok, Equals, false, Comment("channel should be closed after ctx is done"))
+	_, ok = <-ch2
+	c.Check(ok, Equals, false)
+	c.Check(h.NumSubscribers("t1"), Equals, 0)
+	h.Publish("t1", 2) // must not panic on closed subscribers
+}
+
+func (s *HubSuite) TestPublishNeverBlocks(c *C) {
+	h := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := h.Subscribe(ctx, "t")
+	for i := 0; i < subscriberBuffer*2; i++ {
+		h.Publish("t", i)
+	}
+	c.Check(len(ch), Equals, subscriberBuffer)
+}
+
+func (s *HubSuite) TestNotifications(c *C) {
+	h := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	chA := h.SubscribeNotifications(ctx, "a")
+	chB := h.SubscribeNotifications(ctx, "b")
+	n := &model.Notification{ID: "n1", Receiver: []string{"a"}}
+	h.PublishNotification(n)
+	select {
+	case got := <-chA:
+		c.Check(got, Equals, n)
+	case <-time.After(time.Second):
+		c.Fatal("notification not received")
+	}
+	select {
+	case <-chB:
+		c.Error("notification received by a wrong user")
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func (s *HubSuite) TestTrade(c *C) {
+	h := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := h.SubscribeTrade(ctx, "t1")
+	t := &model.Trade{ID: "t1", Name: "before", Stages: []model.TradeStage{{Name: "before"}}}
+	h.PublishTrade(t)
+	t.Name = "after"
+	t.Stages[0].Name = "after"
+	select {
+	case got := <-ch:
+		c.Check(got.ID, Equals, "t1")
+		c.Check(got.Name, Equals, "before")
+		c.Check(got.Stages[0].Name, Equals, "before")
+	case <-time.After(time.Second):
+		c.Fatal("trade update not received")
+	}
+	cancel()
+	_, ok := <-ch
+	c.Check(ok, Equals, false)
+}
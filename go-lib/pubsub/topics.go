@@ -0,0 +1,76 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+)
+
+// Default is the hub shared by the web server handlers and resolvers
+var Default = NewHub()
+
+func notificationTopic(userID string) string {
+	return "notifications/" + userID
+}
+
+func tradeTopic(tradeID string) string {
+	return "trades/" + tradeID
+}
+
+// PublishNotification publishes the notification to all its receivers
+func (h *Hub) PublishNotification(n *model.Notification) {
+	for _, uid := range n.Receiver {
+		h.Publish(notificationTopic(uid), n)
+	}
+}
+
+// SubscribeNotifications subscribes to notifications received by the user
+func (h *Hub) SubscribeNotifications(ctx context.Context, userID string) <-chan *model.Notification {
+	out := make(chan *model.Notification)
+	in := h.Subscribe(ctx, notificationTopic(userID))
+	go func() {
+		defer close(out)
+		for msg := range in {
+			select {
+			case out <- msg.(*model.Notification):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// PublishTrade publishes the updated trade.
+// The trade is deep copied, so the caller can continue modifying it and the
+// subscribers never share the stages or the requests with it.
+func (h *Hub) PublishTrade(t *model.Trade) {
+	b, err := json.Marshal(t)
+	var tc model.Trade
+	if err == nil {
+		err = json.Unmarshal(b, &tc)
+	}
+	if err != nil {
+		logger.Error("Can't copy the trade update", "trade", t.ID, err)
+		return
+	}
+	h.Publish(tradeTopic(t.ID), &tc)
+}
+
+// SubscribeTrade subscribes to the trade updates
+func (h *Hub) SubscribeTrade(ctx context.Context, tradeID string) <-chan *model.Trade {
+	out := make(chan *model.Trade)
+	in := h.Subscribe(ctx, tradeTopic(tradeID))
+	go func() {
+		defer close(out)
+		for msg := range in {
+			select {
+			case out <- msg.(*model.Trade):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -5,4 +5,7 @@ import "github.com/robert-zaremba/errstack"
 var (
 	errSelfApprove = errstack.NewReq("You can't approve your own request")
 	errChangeStage = errstack.NewReq("You can’t change deleted or closed stage")
+
+	errOrgMember     = errstack.NewReq("The user is already a member of the organization")
+	errOrgReqPending = errstack.NewReq("There is already a pending invitation or join request")
 )
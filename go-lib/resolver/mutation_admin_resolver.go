@@ -2,12 +2,14 @@ package resolver
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
 
 	"github.com/robert-zaremba/errstack"
 
+	"bitbucket.org/cerealia/apps/go-lib/auth/throttle"
 	"bitbucket.org/cerealia/apps/go-lib/middleware"
 	"bitbucket.org/cerealia/apps/go-lib/model"
 )
@@ -18,7 +20,7 @@ func (r mutationResolver) AdminApproveUser(ctx context.Context, id string, statu
 	errb := errstack.NewBuilder()
 	au, errs := middleware.GetAuthUser(ctx)
 	errb.Put("Authentication", errs)
-	if !au.IsModerator() {
+	if errs == nil && !au.Can(model.PermissionUserApprove) {
 		errb.Put("Admin", "Admin role required")
 	}
 	if status == model.SimpleApprovalRejected && *reason == "" {
@@ -26,9 +28,15 @@ func (r mutationResolver) AdminApproveUser(ctx context.Context, id string, statu
 	}
 	u, errs := dal.GetUser(ctx, r.db, id)
 	errb.Put("GetUser", errs)
+	if errs == nil && status == model.SimpleApprovalApproved && !u.IsPrimaryEmailVerified() {
+		errb.Put("Email", "User email is not verified")
+	}
 	if errs = errb.ToReqErr(); errs != nil {
 		return nil, errs
 	}
+	if errs = requireTOTP(ctx, r.db, au); errs != nil {
+		return nil, errs
+	}
 
 	approval := model.AccessApproval{
 		Status:    status,
@@ -39,3 +47,96 @@ func (r mutationResolver) AdminApproveUser(ctx context.Context, id string, statu
 	u.Approvals = append(u.Approvals, approval)
 	return &approval, dal.ReplaceUser(ctx, r.db, u)
 }
+
+// AdminApproveOrg reviews the organization KYB documents.
+// If status == rejected then reason is required, otherwise it's ignored.
+func (r mutationResolver) AdminApproveOrg(ctx context.Context, id string, status model.SimpleApproval, reason *string) (*model.AccessApproval, error) {
+	errb := errstack.NewBuilder()
+	au, errs := middleware.GetAuthUser(ctx)
+	errb.Put("Authentication", errs)
+	if errs == nil && !au.Can(model.PermissionUserApprove) {
+		errb.Put("Admin", "Admin role required")
+	}
+	if status == model.SimpleApprovalRejected && (reason == nil || *reason == "") {
+		errb.Put("ReasonError", "Reason for reject is required")
+	}
+	o, errs := dal.GetOrganization(ctx, r.db, id)
+	errb.Put("GetOrganization", errs)
+	if errs == nil && status == model.SimpleApprovalApproved {
+		if missing := o.MissingKYBDocs(); len(missing) > 0 {
+			errb.Put("KYBDocs", fmt.Sprint("Missing KYB documents: ", missing))
+		}
+	}
+	if errs = errb.ToReqErr(); errs != nil {
+		return nil, errs
+	}
+	if errs = requireTOTP(ctx, r.db, au); errs != nil {
+		return nil, errs
+	}
+
+	approval := model.AccessApproval{
+		Status:    status,
+		Reason:    reason,
+		Approver:  au.ID,
+		CreatedAt: time.Now().UTC(),
+	}
+	return &approval, dal.AddOrgApproval(ctx, r.db, id, approval)
+}
+
+// AdminUserUnlock resets the failed login counters of all user emails
+func (r mutationResolver) AdminUserUnlock(ctx context.Context, id string) (*int, error) {
+	au, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !au.Can(model.PermissionUserApprove) {
+		return nil, model.ErrUnauthorized
+	}
+	u, errs := dal.GetUser(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	logger.Info("Unlocking user account", "user", u.ID, "moderator", au.ID)
+	return nil, throttle.Default.Unlock(ctx, append([]string{mfaThrottleAccount(u.ID)}, u.Emails...)...)
+}
+
+// AdminOrgTOTPRequire sets if the organization members must use two-factor authentication
+func (r mutationResolver) AdminOrgTOTPRequire(ctx context.Context, id string, required bool) (*int, error) {
+	au, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !au.CanManageOrg(id) {
+		return nil, model.ErrUnauthorized
+	}
+	if _, errs = dal.GetOrganization(ctx, r.db, id); errs != nil {
+		return nil, errs
+	}
+	return nil, dal.SetOrgRequireTOTP(ctx, r.db, id, required)
+}
+
+// AdminScreeningHitReview confirms the sanctions screening hit or clears it as a false
+// positive. Confirmed hits keep blocking trades of the subject.
+func (r mutationResolver) AdminScreeningHitReview(ctx context.Context, id string, status model.ScreeningHitStatus, reason string) (*model.ScreeningHit, error) {
+	errb := errstack.NewBuilder()
+	au, errs := middleware.GetAuthUser(ctx)
+	errb.Put("Authentication", errs)
+	if errs == nil && !au.Can(model.PermissionUserApprove) {
+		errb.Put("Admin", "Admin role required")
+	}
+	if status != model.ScreeningHitStatusConfirmed && status != model.ScreeningHitStatusCleared {
+		errb.Put("Status", "The hit can be only confirmed or cleared")
+	}
+	if reason == "" {
+		errb.Put("ReasonError", "Reason is required")
+	}
+	h, errs := dal.GetScreeningHit(ctx, r.db, id)
+	errb.Put("GetScreeningHit", errs)
+	if errs = errb.ToReqErr(); errs != nil {
+		return nil, errs
+	}
+	if errs = requireTOTP(ctx, r.db, au); errs != nil {
+		return nil, errs
+	}
+	return h, dal.ReviewScreeningHit(ctx, r.db, h, status, au.ID, reason)
+}
@@ -0,0 +1,200 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
+	"github.com/robert-zaremba/errstack"
+)
+
+// OrgInvite invites the email to the organization and emails the invitation
+func (r mutationResolver) OrgInvite(ctx context.Context, orgID string, email string, role model.OrgRole) (*model.OrgMemberReq, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.CanManageOrg(orgID) {
+		return nil, model.ErrUnauthorized
+	}
+	if !role.IsValid() {
+		return nil, errstack.NewReq("Invalid organization role")
+	}
+	org, errs := dal.GetOrganization(ctx, r.db, orgID)
+	if errs != nil {
+		return nil, errs
+	}
+	email = strings.TrimSpace(email)
+	invitee, errs := dal.GetUserByEmail(ctx, r.db, email)
+	if errs == nil && invitee.IsOrgMember(orgID) {
+		return nil, errOrgMember
+	} else if errs != nil && !dal.IsNotFound(errs) {
+		return nil, errs
+	}
+	pending, errs := dal.HasPendingOrgMemberReq(ctx, r.db, orgID, &email, nil)
+	if errs != nil {
+		return nil, errs
+	}
+	if pending {
+		return nil, errOrgReqPending
+	}
+	req := model.OrgMemberReq{
+		OrgID:     orgID,
+		Kind:      model.OrgMemberReqKindInvitation,
+		Email:     &email,
+		Role:      role,
+		Status:    model.ApprovalPending,
+		CreatedBy: u.ID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if errs = dal.InsertOrgMemberReq(ctx, r.db, &req); errs != nil {
+		return nil, errs
+	}
+	if errs = orgAudit(ctx, r.db, u, model.OrgAuditEntry{
+		OrgID: orgID, Action: model.OrgAuditActionInvited, Email: &email, Role: &role}); errs != nil {
+		return nil, errs
+	}
+	if errs = notify.Default.SendOrgInvitation(u, org, email, role); errs != nil {
+		logger.Error("Can't send the organization invitation", "invitation", req.ID, errs)
+	}
+	return &req, nil
+}
+
+// OrgInvitationAccept adds the user to the organization with the invitation role
+func (r mutationResolver) OrgInvitationAccept(ctx context.Context, id string) (*int, error) {
+	return nil, r.respondOrgInvitation(ctx, id, model.ApprovalApproved)
+}
+
+// OrgInvitationReject rejects the invitation
+func (r mutationResolver) OrgInvitationReject(ctx context.Context, id string) (*int, error) {
+	return nil, r.respondOrgInvitation(ctx, id, model.ApprovalRejected)
+}
+
+func (r mutationResolver) respondOrgInvitation(ctx context.Context, id string, status model.Approval) errstack.E {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return errs
+	}
+	req, errs := getPendingOrgMemberReq(ctx, r.db, id, model.OrgMemberReqKindInvitation)
+	if errs != nil {
+		return errs
+	}
+	if !req.IsInvitationFor(u) {
+		return model.ErrUnauthorized
+	}
+	if errs = dal.RespondOrgMemberReq(ctx, r.db, req, status, u.ID, &u.ID); errs != nil {
+		return errs
+	}
+	action := model.OrgAuditActionInvitationRejected
+	if status == model.ApprovalApproved {
+		action = model.OrgAuditActionInvitationAccepted
+		if errs = dal.SetOrgMember(ctx, r.db, u.ID, req.OrgID, req.Role); errs != nil {
+			return errs
+		}
+	}
+	return orgAudit(ctx, r.db, u, model.OrgAuditEntry{
+		OrgID: req.OrgID, Action: action, UserID: &u.ID, Email: req.Email, Role: &req.Role})
+}
+
+// OrgJoinRequest asks the organization admins to accept the user as a member
+func (r mutationResolver) OrgJoinRequest(ctx context.Context, orgID string, message string) (*model.OrgMemberReq, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	return requestOrgJoin(ctx, r.db, u, orgID, "", message)
+}
+
+// OrgJoinRequestApprove adds the requesting user to the organization with the role
+func (r mutationResolver) OrgJoinRequestApprove(ctx context.Context, id string, role model.OrgRole) (*int, error) {
+	if !role.IsValid() {
+		return nil, errstack.NewReq("Invalid organization role")
+	}
+	return nil, r.respondOrgJoinRequest(ctx, id, model.ApprovalApproved, role)
+}
+
+// OrgJoinRequestReject rejects the join request
+func (r mutationResolver) OrgJoinRequestReject(ctx context.Context, id string) (*int, error) {
+	return nil, r.respondOrgJoinRequest(ctx, id, model.ApprovalRejected, model.OrgRoleMember)
+}
+
+func (r mutationResolver) respondOrgJoinRequest(ctx context.Context, id string, status model.Approval, role model.OrgRole) errstack.E {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return errs
+	}
+	req, errs := getPendingOrgMemberReq(ctx, r.db, id, model.OrgMemberReqKindJoinRequest)
+	if errs != nil {
+		return errs
+	}
+	if !u.CanManageOrg(req.OrgID) {
+		return model.ErrUnauthorized
+	}
+	req.Role = role
+	if errs = dal.RespondOrgMemberReq(ctx, r.db, req, status, u.ID, nil); errs != nil {
+		return errs
+	}
+	action := model.OrgAuditActionJoinRejected
+	if status == model.ApprovalApproved {
+		action = model.OrgAuditActionJoinApproved
+		if errs = dal.SetOrgMember(ctx, r.db, *req.UserID, req.OrgID, role); errs != nil {
+			return errs
+		}
+	}
+	return orgAudit(ctx, r.db, u, model.OrgAuditEntry{
+		OrgID: req.OrgID, Action: action, UserID: req.UserID, Role: &role})
+}
+
+// OrgMemberRoleSet changes the member role in the organization
+func (r mutationResolver) OrgMemberRoleSet(ctx context.Context, orgID string, userID string, role model.OrgRole) (*int, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.CanManageOrg(orgID) {
+		return nil, model.ErrUnauthorized
+	}
+	if !role.IsValid() {
+		return nil, errstack.NewReq("Invalid organization role")
+	}
+	member, errs := dal.GetUser(ctx, r.db, userID)
+	if errs != nil {
+		return nil, errs
+	}
+	if !member.IsOrgMember(orgID) {
+		return nil, errstack.NewReq("The user is not a member of the organization")
+	}
+	if errs = dal.SetOrgMember(ctx, r.db, userID, orgID, role); errs != nil {
+		return nil, errs
+	}
+	return nil, orgAudit(ctx, r.db, u, model.OrgAuditEntry{
+		OrgID: orgID, Action: model.OrgAuditActionRoleChanged, UserID: &userID, Role: &role})
+}
+
+// OrgMemberRemove removes the member from the organization. Members can leave the
+// organization themselves.
+func (r mutationResolver) OrgMemberRemove(ctx context.Context, orgID string, userID string) (*int, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if u.ID != userID && !u.CanManageOrg(orgID) {
+		return nil, model.ErrUnauthorized
+	}
+	member, errs := dal.GetUser(ctx, r.db, userID)
+	if errs != nil {
+		return nil, errs
+	}
+	if !member.IsOrgMember(orgID) {
+		return nil, errstack.NewReq("The user is not a member of the organization")
+	}
+	if errs = dal.RemoveOrgMember(ctx, r.db, userID, orgID); errs != nil {
+		return nil, errs
+	}
+	return nil, orgAudit(ctx, r.db, u, model.OrgAuditEntry{
+		OrgID: orgID, Action: model.OrgAuditActionMemberRemoved, UserID: &userID})
+}
@@ -6,12 +6,19 @@ import (
 	"time"
 
 	"bitbucket.org/cerealia/apps/go-lib/auth"
+	"bitbucket.org/cerealia/apps/go-lib/auth/throttle"
+	"bitbucket.org/cerealia/apps/go-lib/auth/totp"
 	"bitbucket.org/cerealia/apps/go-lib/middleware"
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
 	"bitbucket.org/cerealia/apps/go-lib/model/txlog"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
+	"bitbucket.org/cerealia/apps/go-lib/screening"
 	"bitbucket.org/cerealia/apps/go-lib/stellar"
 	"bitbucket.org/cerealia/apps/go-lib/stellar/txvalidation"
+	"bitbucket.org/cerealia/apps/go-lib/stellar/webauth"
+	"github.com/google/uuid"
 	"github.com/robert-zaremba/errstack"
 	"github.com/stellar/go/keypair"
 )
@@ -23,24 +30,224 @@ func (r mutationResolver) UserSignup(ctx context.Context, input *model.NewUserIn
 	if errs != nil {
 		return nil, errs
 	}
-	_, errs = dal.InsertUser(ctx, r.db, input)
-	return nil, errs
+	u, errs := dal.InsertUser(ctx, r.db, input)
+	if errs != nil {
+		return nil, errs
+	}
+	if _, errs = screening.Screen(ctx, r.db, screening.UserSubject(u)); errs != nil {
+		logger.Error("Can't screen the new user", "user", u.ID, errs)
+	}
+	if input.OrgID != "" {
+		if _, errs = requestOrgJoin(ctx, r.db, u, input.OrgID, input.OrgRole, ""); errs != nil {
+			logger.Error("Can't create the organization join request", "user", u.ID, errs)
+		}
+	}
+	if errs = sendUserToken(ctx, r.db, u, model.UserTokenEmailVerification, u.Emails[0]); errs != nil {
+		logger.Error("Can't send the email verification", "user", u.ID, errs)
+	}
+	return nil, nil
 }
 
+// UserLogin checks the user password. Attempts are throttled per account and per
+// client IP; the user is notified when the account gets locked.
 func (r mutationResolver) UserLogin(ctx context.Context, input model.UserLoginInput) (*model.AuthUser, error) {
+	ip := middleware.GetClientIP(ctx)
+	attempt, errs := throttle.Default.Reserve(ctx, input.Email, ip, time.Now().UTC())
+	if errs != nil {
+		return nil, errs
+	}
 	user, errs := dal.UserLogin(ctx, r.db, input)
 	if errs != nil {
+		loginFailed(ctx, attempt, user, input.Email, ip, errs)
 		return nil, errs
 	}
-	// Generate JWT token
-	token, errs := auth.CreateJWT(user.ID)
+	loginSucceeded(ctx, attempt, user)
+	return startLogin(ctx, r.db, user)
+}
+
+// loginFailed keeps the throttled attempt when the credentials are wrong and notifies
+// the user when the account got locked. Unknown and not accepted accounts are counted
+// per IP only. Attempts failed for other reasons are released.
+func loginFailed(ctx context.Context, attempt *throttle.Attempt, u *model.User, email, ip string, errs errstack.E) {
+	if errs == model.ErrUserNotAccepted || dal.IsNotFound(errs) {
+		if errs = attempt.ReleaseAccount(ctx); errs != nil {
+			logger.Error("Can't release the login attempt", "ip", ip, errs)
+		}
+		return
+	}
+	if errs != model.ErrInvalidCredentials && errs != model.ErrInvalidTOTP {
+		releaseLoginAttempt(ctx, attempt, ip)
+		return
+	}
+	until := attempt.LockedUntil()
+	if until == nil || u == nil || u.ID == "" || email == "" {
+		return
+	}
+	logger.Warn("User account locked after failed logins", "user", u.ID, "ip", ip)
+	if errs = notify.Default.SendAccountLocked(u, email, *until); errs != nil {
+		logger.Error("Can't send the account lockout email", "user", u.ID, errs)
+	}
+}
+
+func releaseLoginAttempt(ctx context.Context, attempt *throttle.Attempt, ip string) {
+	if errs := attempt.Release(ctx); errs != nil {
+		logger.Error("Can't release the login attempt", "ip", ip, errs)
+	}
+}
+
+// mfaThrottleAccount is the throttled account of the user second factor codes
+func mfaThrottleAccount(userID string) string {
+	return "mfa:" + userID
+}
+
+// loginSucceeded releases the throttled attempt and resets the failed login counter
+func loginSucceeded(ctx context.Context, attempt *throttle.Attempt, u *model.User) {
+	if errs := attempt.Succeeded(ctx); errs != nil {
+		logger.Error("Can't reset the failed login counter", "user", u.ID, errs)
+	}
+}
+
+// UserKeyLoginChallenge creates a SEP-10 challenge transaction to login with the key
+// of a user static wallet
+func (r mutationResolver) UserKeyLoginChallenge(ctx context.Context, pubKey string) (string, error) {
+	u, errs := dal.GetUserByPubKey(ctx, r.db, pubKey)
+	if dal.IsNotFound(errs) {
+		u, errs = nil, nil
+	}
+	if errs != nil {
+		return "", errs
+	}
+	now := time.Now().UTC()
+	nonce, errs := newKeyLoginToken(ctx, r.db, u, now)
+	if errs != nil {
+		return "", errs
+	}
+	return webauth.Default.Challenge(pubKey, nonce, now, model.UserTokenKeyLogin.TTL())
+}
+
+// UserLoginWithKey verifies the challenge signed by the user and starts a new session.
+// The account of an invalid challenge is unknown, so the attempts are throttled per
+// client IP only.
+func (r mutationResolver) UserLoginWithKey(ctx context.Context, signedTx string) (*model.AuthUser, error) {
+	ip := middleware.GetClientIP(ctx)
+	attempt, errs := throttle.Default.Reserve(ctx, "", ip, time.Now().UTC())
 	if errs != nil {
 		return nil, errs
 	}
-	return &model.AuthUser{
-		ID:    user.ID,
-		Token: token,
-	}, nil
+	u, errs := verifyKeyLogin(ctx, r.db, signedTx)
+	if errs == model.ErrUserNotAccepted {
+		releaseLoginAttempt(ctx, attempt, ip)
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	loginSucceeded(ctx, attempt, u)
+	return startLogin(ctx, r.db, u)
+}
+
+// UserTOTPLogin finishes the login of a user with two-factor authentication
+func (r mutationResolver) UserTOTPLogin(ctx context.Context, mfaToken string, code string) (*model.AuthUser, error) {
+	uid, tokenID, errs := auth.AuthorizeMFA(mfaToken)
+	if errs != nil {
+		return nil, errs
+	}
+	// the attempt is counted before the code is checked, the token is burned after
+	// model.MaxMFAAttempts attempts
+	t, errs := dal.ReserveUserTokenAttempt(ctx, r.db, tokenID, model.UserTokenMFALogin, model.MaxMFAAttempts)
+	if errs != nil {
+		return nil, errs
+	}
+	if t.UserID != uid {
+		return nil, model.ErrInvalidToken
+	}
+	u, errs := dal.GetUser(ctx, r.db, uid)
+	if errs != nil {
+		return nil, errs
+	}
+	// the code attempts are throttled per user too, so new MFA tokens don't give
+	// new attempts
+	ip := middleware.GetClientIP(ctx)
+	attempt, errs := throttle.Default.Reserve(ctx, mfaThrottleAccount(u.ID), ip, time.Now().UTC())
+	if errs != nil {
+		return nil, errs
+	}
+	use, errs := u.VerifySecondFactor(code, time.Now())
+	if errs == nil {
+		errs = dal.UseUserTOTPCode(ctx, r.db, u.ID, use)
+	}
+	if errs != nil {
+		var email string
+		if len(u.Emails) > 0 {
+			email = u.Emails[0]
+		}
+		loginFailed(ctx, attempt, u, email, ip, errs)
+		return nil, errs
+	}
+	loginSucceeded(ctx, attempt, u)
+	if errs = dal.MarkUserTokenUsed(ctx, r.db, tokenID); errs != nil {
+		return nil, errs
+	}
+	return newUserSession(ctx, r.db, u, true)
+}
+
+// UserTokenRefresh rotates the refresh token. A refresh token can be used only once:
+// when an already rotated token is used, the session is revoked because the token
+// was probably stolen.
+func (r mutationResolver) UserTokenRefresh(ctx context.Context, refreshToken string) (*model.AuthUser, error) {
+	sid, hash, errs := auth.ParseRefreshToken(refreshToken)
+	if errs != nil {
+		return nil, errs
+	}
+	s, errs := dal.GetUserSession(ctx, r.db, sid)
+	if errs != nil {
+		return nil, errs
+	}
+	if !s.IsActive(time.Now()) {
+		return nil, model.ErrInvalidSession
+	}
+	if s.TokenHash != hash {
+		logger.Warn("Refresh token reused, revoking the session", "session", sid, "user", s.UserID)
+		if errs = dal.RevokeUserSession(ctx, r.db, sid); errs != nil {
+			logger.Error("Can't revoke the session", "session", sid, errs)
+		}
+		return nil, model.ErrInvalidSession
+	}
+	u, errs := dal.GetUser(ctx, r.db, s.UserID)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.IsAccepted() {
+		return nil, model.ErrInvalidSession
+	}
+	newToken, newHash, errs := auth.NewRefreshToken(sid)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = dal.RotateUserSession(ctx, r.db, sid, hash, newHash, time.Now().Add(auth.RefreshExpireTime)); errs != nil {
+		return nil, errs
+	}
+	token, errs := auth.CreateJWT(u.ID, sid)
+	if errs != nil {
+		return nil, errs
+	}
+	return &model.AuthUser{ID: u.ID, Token: &token, RefreshToken: &newToken}, nil
+}
+
+// UserLogout revokes the current session
+func (r mutationResolver) UserLogout(ctx context.Context) (*int, error) {
+	if _, errs := middleware.GetAuthUser(ctx); errs != nil {
+		return nil, errs
+	}
+	return nil, dal.RevokeUserSession(ctx, r.db, middleware.GetAuthSessionID(ctx))
+}
+
+// UserLogoutAll revokes all sessions of the user
+func (r mutationResolver) UserLogoutAll(ctx context.Context) (*int, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, dal.RevokeUserSessions(ctx, r.db, u.ID)
 }
 
 func (r mutationResolver) UserPasswordChange(ctx context.Context, input model.ChangePasswordInput) (*int, error) {
@@ -51,33 +258,318 @@ func (r mutationResolver) UserPasswordChange(ctx context.Context, input model.Ch
 	return nil, dal.ChangePassword(ctx, r.db, u, input)
 }
 
+// UserEmailVerify marks the email of the token as verified
+func (r mutationResolver) UserEmailVerify(ctx context.Context, token string) (*int, error) {
+	t, errs := useUserToken(ctx, r.db, token, model.UserTokenEmailVerification)
+	if errs != nil {
+		return nil, errs
+	}
+	u, errs := dal.GetUser(ctx, r.db, t.UserID)
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, dal.VerifyUserEmail(ctx, r.db, u, t.Email)
+}
+
+// UserEmailVerificationResend sends a new verification email. It doesn't reveal if
+// the email is registered.
+func (r mutationResolver) UserEmailVerificationResend(ctx context.Context, email string) (*int, error) {
+	u, errs := dal.GetUserByEmail(ctx, r.db, email)
+	if dal.IsNotFound(errs) {
+		return nil, nil
+	} else if errs != nil {
+		return nil, errs
+	}
+	if u.IsEmailVerified(email) {
+		return nil, nil
+	}
+	return nil, sendUserToken(ctx, r.db, u, model.UserTokenEmailVerification, email)
+}
+
+// UserPasswordResetRequest emails a password reset link. It doesn't reveal if the email
+// is registered.
+func (r mutationResolver) UserPasswordResetRequest(ctx context.Context, email string) (*int, error) {
+	u, errs := dal.GetUserByEmail(ctx, r.db, email)
+	if dal.IsNotFound(errs) {
+		return nil, nil
+	} else if errs != nil {
+		return nil, errs
+	}
+	return nil, sendUserToken(ctx, r.db, u, model.UserTokenPasswordReset, email)
+}
+
+// UserPasswordResetConfirm sets a new password. The reset link was delivered to the
+// email, so the email is marked as verified as well.
+func (r mutationResolver) UserPasswordResetConfirm(ctx context.Context, token string, newPassword string) (*int, error) {
+	if errs := model.CheckPassword(newPassword); errs != nil {
+		return nil, errs
+	}
+	t, errs := useUserToken(ctx, r.db, token, model.UserTokenPasswordReset)
+	if errs != nil {
+		return nil, errs
+	}
+	u, errs := dal.GetUser(ctx, r.db, t.UserID)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = dal.SetPassword(ctx, r.db, u, newPassword); errs != nil {
+		return nil, errs
+	}
+	return nil, dal.VerifyUserEmail(ctx, r.db, u, t.Email)
+}
+
 func (r mutationResolver) UserEmailChange(ctx context.Context, input []string) (*int, error) {
 	u, errs := middleware.GetAuthUser(ctx)
 	if errs != nil {
 		return nil, errs
 	}
-	return nil, dal.ChangeEmail(ctx, r.db, u, input)
+	if errs = dal.ChangeEmail(ctx, r.db, u, input); errs != nil {
+		return nil, errs
+	}
+	for _, email := range u.UnverifiedEmails() {
+		if errs = sendUserToken(ctx, r.db, u, model.UserTokenEmailVerification, email); errs != nil {
+			logger.Error("Can't send the email verification", "user", u.ID, errs)
+		}
+	}
+	return nil, nil
+}
+
+// UserTOTPEnroll starts the TOTP enrollment
+func (r mutationResolver) UserTOTPEnroll(ctx context.Context) (*model.TOTPEnrollment, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	secret, errs := u.EnrollTOTP()
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = dal.UpdateUserTOTP(ctx, r.db, u); errs != nil {
+		return nil, errs
+	}
+	account := u.ID
+	if len(u.Emails) > 0 {
+		account = u.Emails[0]
+	}
+	return &model.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.ProvisioningURI(secret, "Cerealia", account),
+	}, nil
+}
+
+// UserTOTPEnable enables two-factor authentication and returns the recovery codes
+func (r mutationResolver) UserTOTPEnable(ctx context.Context, code string) ([]string, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	now := time.Now()
+	codes, errs := u.EnableTOTP(code, now)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = dal.UpdateUserTOTP(ctx, r.db, u); errs != nil {
+		return nil, errs
+	}
+	return codes, dal.SetUserSessionMFAVerified(ctx, r.db, middleware.GetAuthSessionID(ctx), now)
+}
+
+// UserTOTPDisable disables two-factor authentication unless the user organization requires it
+func (r mutationResolver) UserTOTPDisable(ctx context.Context, code string) (*int, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	required, errs := dal.IsTOTPRequired(ctx, r.db, u)
+	if errs != nil {
+		return nil, errs
+	}
+	if required {
+		return nil, errstack.NewReq("Your organization requires two-factor authentication")
+	}
+	if errs = u.DisableTOTP(code, time.Now()); errs != nil {
+		return nil, errs
+	}
+	return nil, dal.UpdateUserTOTP(ctx, r.db, u)
+}
+
+// UserTOTPVerify verifies the code for the sensitive operations of the current session
+func (r mutationResolver) UserTOTPVerify(ctx context.Context, code string) (*int, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	now := time.Now()
+	use, errs := u.VerifySecondFactor(code, now)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = dal.UseUserTOTPCode(ctx, r.db, u.ID, use); errs != nil {
+		return nil, errs
+	}
+	return nil, dal.SetUserSessionMFAVerified(ctx, r.db, middleware.GetAuthSessionID(ctx), now)
 }
 
+// UserProfileUpdate updates the user profile. Organizations of orgMap which the user
+// isn't member of get a join request.
 func (r mutationResolver) UserProfileUpdate(ctx context.Context, input model.UserProfileInput) (*model.User, error) {
 	u, errs := middleware.GetAuthUser(ctx)
 	if errs != nil {
 		return nil, errs
 	}
-	return dal.UpdateUserProfile(ctx, r.db, u, input)
+	for _, o := range input.OrgMap {
+		if u.IsOrgMember(o.ID) {
+			continue
+		}
+		if _, errs = requestOrgJoin(ctx, r.db, u, o.ID, o.Role, ""); errs != nil && errs != errOrgReqPending {
+			return nil, errs
+		}
+	}
+	u, errs = dal.UpdateUserProfile(ctx, r.db, u, input)
+	if errs != nil {
+		return nil, errs
+	}
+	// the name could change
+	if _, errs = screening.Screen(ctx, r.db, screening.UserSubject(u)); errs != nil {
+		logger.Error("Can't screen the user", "user", u.ID, errs)
+	}
+	return u, nil
+}
+
+// UserHDWalletRegister adds a new HD wallet to the user. Keys of the new trades will be
+// derived from it when it's the default wallet.
+func (r mutationResolver) UserHDWalletRegister(ctx context.Context, input model.HDWalletInput) (*string, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	w := model.HDCerealiaWallet{
+		Wallet: model.Wallet{
+			Name: input.Name,
+			Note: input.Note,
+		},
+		PubKeys: input.PubKeys,
+	}
+	walletID, errs := dal.AddHDWallet(ctx, r.db, u, w, input.SetDefault)
+	if errs != nil {
+		return nil, errs
+	}
+	return &walletID, nil
+}
+
+// UserHDWalletKeysAdd registers more public keys of the HD wallet
+func (r mutationResolver) UserHDWalletKeysAdd(ctx context.Context, id string, pubKeys []string) (*int, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, dal.AddHDWalletKeys(ctx, r.db, u, id, pubKeys)
+}
+
+// UserDefaultWalletSet sets the wallet used to create keys for new trades
+func (r mutationResolver) UserDefaultWalletSet(ctx context.Context, id string) (*int, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, dal.SetDefaultWallet(ctx, r.db, u, id)
+}
+
+// UserNotificationPrefs sets the user email notification preferences
+func (r mutationResolver) UserNotificationPrefs(ctx context.Context, input []model.NotifPref) ([]model.NotifPref, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = dal.UpdateNotifPrefs(ctx, r.db, u, input); errs != nil {
+		return nil, errs
+	}
+	return u.NotifPrefs, nil
 }
 
+// OrganizationCreate creates an organization with the user as its admin
 func (r mutationResolver) OrganizationCreate(ctx context.Context, input model.OrgInput) (*model.Organization, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
 	newOrg := model.Organization{
 		Name:      input.Name,
 		Address:   input.Address,
 		Email:     input.Email,
 		Telephone: input.Telephone,
 	}
-	if errs := newOrg.ValidateNewOrg(); errs != nil {
+	if errs = newOrg.ValidateNewOrg(); errs != nil {
+		return nil, errs
+	}
+	if _, errs = dal.CreateOrganization(ctx, r.db, &newOrg); errs != nil {
+		return nil, errs
+	}
+	if errs = dal.SetOrgMember(ctx, r.db, u.ID, newOrg.ID, model.OrgRoleAdmin); errs != nil {
+		return nil, errs
+	}
+	if _, errs = screening.Screen(ctx, r.db, screening.OrgSubject(&newOrg)); errs != nil {
+		logger.Error("Can't screen the new organization", "org", newOrg.ID, errs)
+	}
+	admin := model.OrgRoleAdmin
+	return &newOrg, orgAudit(ctx, r.db, u, model.OrgAuditEntry{
+		OrgID: newOrg.ID, Action: model.OrgAuditActionCreated, UserID: &u.ID, Role: &admin})
+}
+
+// APIKeyCreate creates an organization API key. The key is returned only here.
+func (r mutationResolver) APIKeyCreate(ctx context.Context, input model.APIKeyInput) (*model.APIKeyCreated, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	now := time.Now().UTC()
+	if errs = input.Validate(now); errs != nil {
+		return nil, errs
+	}
+	if !u.CanInOrg(input.OrgID, model.PermissionAPIKeyManage) {
+		return nil, model.ErrUnauthorized
+	}
+	if errs = requireFreshTOTP(ctx, r.db, u); errs != nil {
+		return nil, errs
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, errstack.WrapAsInf(err, "Can't generate API key ID")
+	}
+	key, hash, errs := auth.NewAPIKey(id.String())
+	if errs != nil {
+		return nil, errs
+	}
+	k := model.APIKey{
+		ID:        id.String(),
+		OrgID:     input.OrgID,
+		Name:      input.Name,
+		Scopes:    input.Scopes,
+		KeyHash:   hash,
+		CreatedBy: u.ID,
+		CreatedAt: now,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if errs = dal.InsertAPIKey(ctx, r.db, &k); errs != nil {
+		return nil, errs
+	}
+	return &model.APIKeyCreated{Key: key, APIKey: k}, nil
+}
+
+// APIKeyRevoke revokes an API key of the user organization
+func (r mutationResolver) APIKeyRevoke(ctx context.Context, id string) (*int, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	k, errs := dal.GetAPIKey(ctx, r.db, id)
+	if errs != nil {
 		return nil, errs
 	}
-	return dal.CreateOrganization(ctx, r.db, &newOrg)
+	if !u.CanInOrg(k.OrgID, model.PermissionAPIKeyManage) {
+		return nil, model.ErrUnauthorized
+	}
+	return nil, dal.RevokeAPIKey(ctx, r.db, id)
 }
 
 // TradeCreate creates a new trade.
@@ -86,27 +578,57 @@ func (r mutationResolver) TradeCreate(ctx context.Context, input model.NewTradeI
 	if errs != nil {
 		return nil, errs
 	}
+	if input.OrgID != nil && !u.CanInOrg(*input.OrgID, model.PermissionTradeWrite) {
+		return nil, model.ErrUnauthorized
+	}
+	return r.createTrade(ctx, u, input, nil)
+}
+
+// createTrade creates the trade and its stellar account. termsDocID, when not nil, is
+// added to the first stage before the trade is stored.
+func (r mutationResolver) createTrade(ctx context.Context, u *model.User, input model.NewTradeInput, termsDocID *string) (*model.Trade, errstack.E) {
 	errb := input.Validate(input.TemplateID, u)
 	if errb.NotNil() {
 		return nil, errb.ToReqErr()
 	}
-	pks, errs := getTradeParticipants(ctx, r.db, input.BuyerID, input.SellerID)
+	if errs := input.ValidateParticipants(); errs != nil {
+		return nil, errs
+	}
+	parties := []string{input.BuyerID, input.SellerID}
+	for _, p := range input.Participants {
+		parties = append(parties, p.UserID)
+	}
+	if errs := assertPartiesVerified(ctx, r.db, parties); errs != nil {
+		return nil, errs
+	}
+	if input.OrgID != nil {
+		if errs := assertOrgVerified(ctx, r.db, *input.OrgID); errs != nil {
+			return nil, errs
+		}
+		parties = append(parties, *input.OrgID)
+	}
+	if errs := screening.AssertNotFlagged(ctx, r.db, parties...); errs != nil {
+		return nil, errs
+	}
+	pks, users, errs := getTradeParties(ctx, r.db, input)
 	if errs != nil {
 		return nil, errstack.WrapAsDomain(errs, "Private key not found")
 	}
-	keypair, erre := keypair.Random()
-	if erre != nil {
-		return nil, erre
+	keypair, err := keypair.Random()
+	if err != nil {
+		return nil, errstack.WrapAsInf(err, "Can't create trade keypair")
 	}
 	t := model.Trade{
 		Name:         input.Name,
 		TemplateID:   input.TemplateID,
-		Buyer:        pks.Buyer,
-		Seller:       pks.Seller,
+		Buyer:        pks[0],
+		Seller:       pks[1],
+		Participants: pks[2:],
 		Description:  input.Description,
 		CreatedBy:    u.ID,
 		SCAddr:       model.SCAddr(keypair.Address()),
 		TradeOfferID: input.TradeOfferID,
+		OrgID:        input.OrgID,
 		StageAddReqs: []model.TradeStageAddReq{},
 		CloseReqs:    []model.ApproveReq{},
 		Moderating:   model.DoneStatusNil,
@@ -116,12 +638,33 @@ func (r mutationResolver) TradeCreate(ctx context.Context, input model.NewTradeI
 	if errs != nil {
 		return &t, errs
 	}
+	if !tt.IsActive() {
+		return &t, model.ErrTemplateNotActive
+	}
 	t.Stages = tt.BuildStages()
+	if termsDocID != nil {
+		if errs = addOfferTerms(&t, *termsDocID); errs != nil {
+			return &t, errs
+		}
+	}
+	// the derived keys are used only when the trade is valid, so failed attempts
+	// don't skip the wallet keys
+	if errs = useTradeKeys(ctx, r.db, users); errs != nil {
+		return nil, errs
+	}
 	meta, errs := dal.InsertTrade(ctx, r.db, &t)
 	if errs != nil {
 		return &t, errs
 	}
 	t.ID = meta.Key
+	if termsDocID != nil {
+		if errs = insertOfferTermsEdge(ctx, r.db, &t, *termsDocID); errs != nil {
+			if err := dal.DeleteByID(ctx, r.db, dbconst.ColTrades, t.ID); err != nil {
+				logger.Error("Can't remove trade", "trade", t.ID, err)
+			}
+			return nil, errs
+		}
+	}
 	sourceAccs, err := r.txSourceDriver.Create(ctx, *keypair, t.ID, u.ID, model.TxSourceAccTypeTrade)
 	if err != nil {
 		return nil, errstack.WrapAsInf(err)
@@ -156,7 +699,11 @@ func (r mutationResolver) TradeStageAddReq(ctx context.Context, input model.NewS
 	owner := model.ParseTradeActor(input.Owner, errb)
 	reqActor, errs := t.Requester(u)
 	errb.Put("requester", errs)
-	errb.Put("permissions", t.CanBeModifiedBy(u))
+	errb.Put("permissions", t.AssertCan(u, model.TradeActionStageAdd))
+	if owner.IsParticipantRole() && !t.HasRole(owner) {
+		errb.Put("owner", "the trade has no participant with the owner role")
+	}
+	errb.Put("dependsOn", t.ValidateDependsOn(input.DependsOn))
 	if errb.NotNil() {
 		return nil, errb.ToReqErr()
 	}
@@ -165,6 +712,7 @@ func (r mutationResolver) TradeStageAddReq(ctx context.Context, input model.NewS
 		Name:        input.Name,
 		Description: input.Description,
 		Owner:       owner,
+		DependsOn:   input.DependsOn,
 		ApproveReq: model.ApproveReq{
 			Status:    model.ApprovalPending,
 			ReqActor:  reqActor,
@@ -215,7 +763,7 @@ func (r mutationResolver) TradeStageDelReq(ctx context.Context, id model.TradeSt
 	if len(reason) < 10 {
 		errb.Put("lengthError", errstack.NewReq("Reason must be at least 10 characters long"))
 	}
-	reqActor, u, t, errs := getTradeRequester(ctx, r.db, id.Tid)
+	reqActor, u, t, errs := getTradeRequester(ctx, r.db, id.Tid, model.TradeActionStageDel)
 	errb.Put("getRequester", errs)
 	if errb.NotNil() {
 		return nil, errb.ToReqErr()
@@ -281,7 +829,7 @@ func (r mutationResolver) TradeStageCloseReq(ctx context.Context, id model.Trade
 	if len(reason) < 10 {
 		errb.Put("lengthError", errstack.NewReq("Reason must be at least 10 characters long"))
 	}
-	reqActor, u, t, errs := getTradeRequester(ctx, r.db, id.Tid)
+	reqActor, u, t, errs := getTradeRequester(ctx, r.db, id.Tid, model.TradeActionStageClose)
 	errb.Put("getReqActor", errs)
 	if errb.NotNil() {
 		return nil, errb.ToReqErr()
@@ -297,6 +845,7 @@ func (r mutationResolver) TradeStageCloseReq(ctx context.Context, id model.Trade
 	if s.IsDeletedOrClosed() {
 		errb.Put("checkStageStatus", errChangeStage)
 	}
+	errb.Put("prerequisites", t.AssertPrerequisitesDone(id.StageIdx))
 	errb.Put("permissions", s.AssertOwnedBy(t, u.ID))
 	if errb.NotNil() {
 		return nil, errb.ToReqErr()
@@ -331,6 +880,9 @@ func (r mutationResolver) TradeStageCloseReq(ctx context.Context, id model.Trade
 
 func (r mutationResolver) TradeStageCloseReqApprove(ctx context.Context, id model.TradeStagePath,
 	signedTx string) (*model.ApproveReq, error) {
+	if errs := r.requireTOTP(ctx); errs != nil {
+		return nil, errs
+	}
 	return tradeStageCloseApproval(ctx, r, id, signedTx, "", true)
 }
 
@@ -345,7 +897,7 @@ func (r mutationResolver) TradeCloseReq(ctx context.Context, id string, reason s
 	if len(reason) < 10 {
 		errb.Put("lengthError", errstack.NewReq("Reason must be at least 10 characters long"))
 	}
-	reqActor, u, t, errs := getTradeRequester(ctx, r.db, id)
+	reqActor, u, t, errs := getTradeRequester(ctx, r.db, id, model.TradeActionClose)
 	errb.Put("getReqActor", errs)
 	if errb.NotNil() {
 		return nil, errb.ToReqErr()
@@ -384,16 +936,28 @@ func (r mutationResolver) TradeCloseReq(ctx context.Context, id string, reason s
 }
 
 func (r mutationResolver) TradeCloseReqApprove(ctx context.Context, id string, signedTx string) (*model.ApproveReq, error) {
+	if errs := r.requireTOTP(ctx); errs != nil {
+		return nil, errs
+	}
 	return tradeCloseApproval(ctx, r, id, signedTx, "", true)
 }
 
+// requireTOTP checks the two-factor authentication of the authenticated user
+func (r mutationResolver) requireTOTP(ctx context.Context) errstack.E {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return errs
+	}
+	return requireTOTP(ctx, r.db, u)
+}
+
 func (r mutationResolver) TradeCloseReqReject(ctx context.Context, id string, reason string, signedTx string) (*int, error) {
 	_, errs := tradeCloseApproval(ctx, r, id, signedTx, reason, false)
 	return nil, errs
 }
 
 func (r mutationResolver) TradeStageSetExpireTime(ctx context.Context, id model.TradeStagePath, expiresAt string) (*int, error) {
-	reqActor, u, t, errs := getTradeRequester(ctx, r.db, id.Tid)
+	reqActor, u, t, errs := getTradeRequester(ctx, r.db, id.Tid, model.TradeActionStageApprove)
 	if errs != nil {
 		return nil, errs
 	}
@@ -413,8 +977,8 @@ func (r mutationResolver) TradeStageSetExpireTime(ctx context.Context, id model.
 	if errb.NotNil() {
 		return nil, errb.ToReqErr()
 	}
-	s.ExpiresAt = expTime
-	if _, errs = tradeStageDelReqNotif(ctx, r.db, t, u, id); errs != nil {
+	s.SetExpiresAt(expTime)
+	if _, errs = tradeStageSetExpireNotif(ctx, r.db, t, u, id); errs != nil {
 		return nil, errs
 	}
 	_, errs = updateTrade(ctx, r.db, t)
@@ -430,6 +994,14 @@ func (r mutationResolver) TradeOfferCreate(ctx context.Context, input model.Trad
 	if errs != nil {
 		return nil, errs
 	}
+	if !u.CanInOrg(input.OrgID, model.PermissionOfferWrite) {
+		return nil, model.ErrUnauthorized
+	}
+	if input.PriceType == model.OfferPriceTypeFirm {
+		if errs = assertOrgVerified(ctx, r.db, input.OrgID); errs != nil {
+			return nil, errs
+		}
+	}
 	tof := model.TradeOffer{
 		Price:       input.Price,
 		PriceType:   input.PriceType,
@@ -456,7 +1028,17 @@ func (r mutationResolver) TradeOfferCreate(ctx context.Context, input model.Trad
 			return nil, errs
 		}
 	}
-	return dal.InsertTradeOffer(ctx, r.db, &tof)
+	if _, errs = dal.InsertTradeOffer(ctx, r.db, &tof); errs != nil {
+		return nil, errs
+	}
+	matches, errs := findTradeOfferMatches(ctx, r.db, &tof)
+	if errs == nil {
+		errs = tradeOfferMatchNotifs(ctx, r.db, &tof, matches)
+	}
+	if errs != nil {
+		logger.Error("Can't notify about trade offer matches", "offer", tof.ID, errs)
+	}
+	return &tof, nil
 }
 
 func (r mutationResolver) TradeOfferClose(ctx context.Context, id string) (*int, error) {
@@ -468,12 +1050,104 @@ func (r mutationResolver) TradeOfferClose(ctx context.Context, id string) (*int,
 	if errs != nil {
 		return nil, errs
 	}
-	if tof.CreatedBy != u.ID {
+	if tof.CreatedBy != u.ID && !u.CanInOrg(tof.OrgID, model.PermissionOrgManage) {
 		return nil, model.ErrUnauthorized
 	}
 	return nil, dal.CloseTradeOffer(ctx, r.db, id)
 }
 
+// TradeOfferAccept creates a trade from the offer with the caller as the counterparty
+// and closes the offer. The offer terms document is attached to the first trade stage.
+func (r mutationResolver) TradeOfferAccept(ctx context.Context, id string, templateID string) (*model.Trade, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	tof, errs := dal.GetTradeOffer(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = tof.CanBeAcceptedBy(u, time.Now().UTC()); errs != nil {
+		return nil, errs
+	}
+	// the counterparty must trade for a verified organization too
+	if errs = assertVerifiedOrgMember(ctx, r.db, u); errs != nil {
+		return nil, errs
+	}
+	if tof.AcceptedBid != nil {
+		b, errs := dal.GetTradeOfferBid(ctx, r.db, *tof.AcceptedBid)
+		if errs != nil {
+			return nil, errs
+		}
+		if b.Bidder != u.ID {
+			return nil, errstack.NewReq("The offer terms were agreed with another bidder")
+		}
+		agreed := tof.WithBid(b)
+		tof = &agreed
+	}
+	// claim the offer first, so it can't be accepted twice. The offer terms are stored
+	// with the trade, so the offer is reopened when the trade isn't created.
+	if errs = dal.CloseTradeOffer(ctx, r.db, tof.ID); errs != nil {
+		return nil, errs
+	}
+	t, errs := r.createTrade(ctx, u, tof.NewTradeInput(u, templateID), tof.DocID)
+	if errs != nil {
+		if err := dal.ReopenTradeOffer(ctx, r.db, tof.ID); err != nil {
+			logger.Error("Can't reopen trade offer", "offer", tof.ID, err)
+		}
+		return nil, errs
+	}
+	return t, nil
+}
+
+// TradeOfferBid creates a counter-offer in a negotiation thread of the offer
+func (r mutationResolver) TradeOfferBid(ctx context.Context, input model.TradeOfferBidInput) (*model.TradeOfferBid, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	tof, errs := dal.GetTradeOffer(ctx, r.db, input.OfferID)
+	if errs != nil {
+		return nil, errs
+	}
+	bidder := u.ID
+	if u.ID == tof.CreatedBy && input.BidderID != nil {
+		bidder = *input.BidderID
+	}
+	thread, errs := dal.GetTradeOfferBids(ctx, r.db, tof.ID, bidder)
+	if errs != nil {
+		return nil, errs
+	}
+	var last *model.TradeOfferBid
+	if len(thread) > 0 {
+		last = &thread[len(thread)-1]
+	}
+	b, errs := tof.NewBid(u, input, last, time.Now().UTC())
+	if errs != nil {
+		return nil, errs
+	}
+	if last != nil && last.Status == model.ApprovalPending {
+		last.Respond(false, model.ReasonBidCountered, b.CreatedAt)
+		if errs = dal.RespondTradeOfferBid(ctx, r.db, last); errs != nil {
+			return nil, errs
+		}
+	}
+	if errs = dal.InsertTradeOfferBid(ctx, r.db, &b); errs != nil {
+		return nil, errs
+	}
+	return &b, tradeOfferBidNotif(ctx, r.db, tof, &b)
+}
+
+// TradeOfferBidAccept accepts the bid and freezes its terms in the offer
+func (r mutationResolver) TradeOfferBidAccept(ctx context.Context, id string) (*model.TradeOfferBid, error) {
+	return respondTradeOfferBid(ctx, r.db, id, "", true)
+}
+
+// TradeOfferBidReject rejects the bid
+func (r mutationResolver) TradeOfferBidReject(ctx context.Context, id string, reason string) (*model.TradeOfferBid, error) {
+	return respondTradeOfferBid(ctx, r.db, id, reason, false)
+}
+
 func (r mutationResolver) NotificationDismiss(ctx context.Context, id string) (*int, error) {
 	u, err := middleware.GetAuthUser(ctx)
 	if err != nil {
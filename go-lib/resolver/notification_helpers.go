@@ -8,6 +8,7 @@ import (
 
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
 	"bitbucket.org/cerealia/apps/go-lib/utils"
 	driver "github.com/arangodb/go-driver"
 	"github.com/robert-zaremba/errstack"
@@ -19,7 +20,7 @@ func tadeCreateNotif(ctx context.Context, db driver.Database, t *model.Trade, u
 	n.EntityID = t.FullID2() + "/"
 	n.Msg = fmt.Sprintf("New trade '%s' has been created by %s %s", t.Name, u.FirstName, u.LastName)
 	n.Action = model.ApprovalApproved
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeStageAddReqNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User, withApproval bool) (*model.Notification, errstack.E) {
@@ -34,7 +35,7 @@ func tradeStageAddReqNotif(ctx context.Context, db driver.Database, t *model.Tra
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "stageAddReqs:"+idx)
 	n.Msg = msg
 	n.Action = action
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeStageAddApprovalNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
@@ -49,7 +50,7 @@ func tradeStageAddApprovalNotif(ctx context.Context, db driver.Database, t *mode
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "stageAddReqs:"+utils.UintToString(id.StageIdx))
 	n.Msg = msg
 	n.Action = action
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeStageDelReqNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
@@ -59,7 +60,7 @@ func tradeStageDelReqNotif(ctx context.Context, db driver.Database, t *model.Tra
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(id.StageIdx), "delReqs:"+idx)
 	n.Msg = fmt.Sprintf("New stage delete request has been created by %s %s", u.FirstName, u.LastName)
 	n.Action = model.ApprovalPending
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeStageDeleteApprovalNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
@@ -75,7 +76,7 @@ func tradeStageDeleteApprovalNotif(ctx context.Context, db driver.Database, t *m
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(id.StageIdx), "delReqs:"+idx)
 	n.Msg = msg
 	n.Action = action
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeStageDocApprovalNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
@@ -90,7 +91,7 @@ func tradeStageDocApprovalNotif(ctx context.Context, db driver.Database, t *mode
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(id.StageIdx), "docs:"+utils.UintToString(id.StageDocIdx))
 	n.Msg = msg
 	n.Action = action
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeStageCloseReqNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
@@ -100,7 +101,7 @@ func tradeStageCloseReqNotif(ctx context.Context, db driver.Database, t *model.T
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(id.StageIdx), "closeReqs:"+idx)
 	n.Msg = fmt.Sprintf("New stage close request has been created by %s %s", u.FirstName, u.LastName)
 	n.Action = model.ApprovalPending
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeStageCloseApprovalNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
@@ -116,7 +117,7 @@ func tradeStageCloseApprovalNotif(ctx context.Context, db driver.Database, t *mo
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(id.StageIdx), "closeReqs:"+idx)
 	n.Msg = msg
 	n.Action = action
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeCloseReqNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User) (*model.Notification, errstack.E) {
@@ -125,7 +126,7 @@ func tradeCloseReqNotif(ctx context.Context, db driver.Database, t *model.Trade,
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "closeReqs:"+idx)
 	n.Msg = fmt.Sprintf("New trade close request has been created by %s %s", u.FirstName, u.LastName)
 	n.Action = model.ApprovalPending
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
 func tradeCloseApprovalNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
@@ -141,20 +142,41 @@ func tradeCloseApprovalNotif(ctx context.Context, db driver.Database, t *model.T
 	n.EntityID = bat.StrJoin("/", t.FullID2(), "closeReqs:"+idx)
 	n.Msg = msg
 	n.Action = action
-	return n, dal.InsertNotification(ctx, db, n)
+	return n, notify.Deliver(ctx, db, n)
 }
 
-// func tradeStageSetExpireNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
-// 	id model.TradeStagePath) (*model.Notification, errstack.E) {
-// 	n := mkBasicNotification(ctx, db, t, u)
-// 	n.EntityID = bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(id.StageIdx), "expireTime:0")
-// 	n.Msg = fmt.Sprintf("New stage expire time has been set by %s %s", u.FirstName, u.LastName)
-// 	n.Action = model.ApprovalApproved
-// 	return n, dal.InsertNotification(ctx, db, n)
-// }
+func tradeStageSetExpireNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
+	id model.TradeStagePath) (*model.Notification, errstack.E) {
+	n := mkBasicNotification(ctx, db, t, u)
+	n.EntityID = bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(id.StageIdx), "expireTime:0")
+	n.Msg = fmt.Sprintf("New stage expire time has been set by %s %s", u.FirstName, u.LastName)
+	n.Action = model.ApprovalApproved
+	return n, notify.Deliver(ctx, db, n)
+}
+
+// tradeModerationNotif notifies the other moderators about a moderator assignment
+// change. The trade parties aren't notified, they don't see who moderates the trade.
+func tradeModerationNotif(ctx context.Context, db driver.Database, t *model.Trade, u *model.User,
+	entityID, msg string, action model.Approval) (*model.Notification, errstack.E) {
+	ids, errs := dal.GetUserIDsWithRole(ctx, db, model.UserRoleModerator)
+	if errs != nil {
+		return nil, errs
+	}
+	n := mkBasicNotification(ctx, db, t, u)
+	n.Receiver = []string{}
+	for _, id := range ids {
+		if id != u.ID {
+			n.Receiver = append(n.Receiver, id)
+		}
+	}
+	n.EntityID = entityID
+	n.Msg = msg
+	n.Action = action
+	return n, notify.Deliver(ctx, db, n)
+}
 
 func mkBasicNotification(ctx context.Context, db driver.Database, t *model.Trade, u *model.User) *model.Notification {
-	receiver, _ := t.NotificationReceiver(u)
+	receiver, _ := t.NotificationReceivers(u)
 	n := model.Notification{
 		CreatedAt:   time.Now().UTC(),
 		Receiver:    receiver,
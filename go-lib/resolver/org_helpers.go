@@ -0,0 +1,110 @@
+package resolver
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// orgAudit records the organization membership change made by the actor
+func orgAudit(ctx context.Context, db driver.Database, actor *model.User, e model.OrgAuditEntry) errstack.E {
+	e.ActorID = actor.ID
+	e.CreatedAt = time.Now().UTC()
+	return dal.InsertOrgAuditEntry(ctx, db, &e)
+}
+
+// requestOrgJoin creates a request of the user to join the organization.
+// position is the position of the user in the organization given at the signup or in
+// the profile.
+func requestOrgJoin(ctx context.Context, db driver.Database, u *model.User, orgID, position, message string) (*model.OrgMemberReq, errstack.E) {
+	if _, errs := dal.GetOrganization(ctx, db, orgID); errs != nil {
+		return nil, errs
+	}
+	if u.IsOrgMember(orgID) {
+		return nil, errOrgMember
+	}
+	pending, errs := dal.HasPendingOrgMemberReq(ctx, db, orgID, nil, &u.ID)
+	if errs != nil {
+		return nil, errs
+	}
+	if pending {
+		return nil, errOrgReqPending
+	}
+	r := model.OrgMemberReq{
+		OrgID:     orgID,
+		Kind:      model.OrgMemberReqKindJoinRequest,
+		UserID:    &u.ID,
+		Role:      model.OrgRoleMember,
+		Position:  position,
+		Message:   message,
+		Status:    model.ApprovalPending,
+		CreatedBy: u.ID,
+		CreatedAt: time.Now().UTC(),
+	}
+	if errs = dal.InsertOrgMemberReq(ctx, db, &r); errs != nil {
+		return nil, errs
+	}
+	return &r, orgAudit(ctx, db, u, model.OrgAuditEntry{
+		OrgID: orgID, Action: model.OrgAuditActionJoinRequested, UserID: &u.ID})
+}
+
+// getPendingOrgMemberReq gets the pending invitation or join request of the kind
+func getPendingOrgMemberReq(ctx context.Context, db driver.Database, id string, kind model.OrgMemberReqKind) (*model.OrgMemberReq, errstack.E) {
+	r, errs := dal.GetOrgMemberReq(ctx, db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	if r.Kind != kind {
+		return nil, errstack.NewReqF("Organization request %s is not %s", id, kind)
+	}
+	if !r.IsPending() {
+		return nil, model.ErrOrgMemberReqResolved
+	}
+	return r, nil
+}
+
+// assertOrgVerified checks that the organization passed the KYB review
+func assertOrgVerified(ctx context.Context, db driver.Database, orgID string) errstack.E {
+	o, errs := dal.GetOrganization(ctx, db, orgID)
+	if errs != nil {
+		return errs
+	}
+	if !o.IsVerified() {
+		return model.ErrOrgNotVerified
+	}
+	return nil
+}
+
+// assertVerifiedOrgMember checks that the user can trade in a verified organization
+func assertVerifiedOrgMember(ctx context.Context, db driver.Database, u *model.User) errstack.E {
+	for orgID := range u.Organizations {
+		if !u.CanInOrg(orgID, model.PermissionTradeWrite) {
+			continue
+		}
+		errs := assertOrgVerified(ctx, db, orgID)
+		if errs == nil {
+			return nil
+		} else if errs != model.ErrOrgNotVerified && !dal.IsNotFound(errs) {
+			return errs
+		}
+	}
+	return model.ErrOrgNotVerified
+}
+
+// assertPartiesVerified checks that every trade party can trade in a verified organization
+func assertPartiesVerified(ctx context.Context, db driver.Database, userIDs []string) errstack.E {
+	for _, id := range userIDs {
+		u, errs := dal.GetUser(ctx, db, id)
+		if errs != nil {
+			return errs
+		}
+		if errs = assertVerifiedOrgMember(ctx, db, u); errs != nil {
+			return errs
+		}
+	}
+	return nil
+}
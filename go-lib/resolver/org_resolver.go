@@ -0,0 +1,28 @@
+package resolver
+
+import (
+	"context"
+
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+)
+
+type orgMemberReqResolver struct{ *resolver }
+
+func (r orgMemberReqResolver) Org(ctx context.Context, obj *model.OrgMemberReq) (*model.Organization, error) {
+	o, errs := dal.GetOrganization(ctx, r.db, obj.OrgID)
+	return o, model.ResetIfErrNoID(errs)
+}
+
+type organizationResolver struct{ *resolver }
+
+// KybDocs returns the KYB documents to the organization admins and the reviewers.
+// Other users get an empty list.
+func (r organizationResolver) KybDocs(ctx context.Context, obj *model.Organization) ([]model.KYBDoc, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil || !u.CanReadKYBDocs(obj.ID) {
+		return []model.KYBDoc{}, nil
+	}
+	return obj.KYBDocs, nil
+}
@@ -35,12 +35,24 @@ func (r queryResolver) AdminUsers(ctx context.Context) ([]model.AdminUser, error
 	if err != nil {
 		return nil, err
 	}
-	if !u.IsModerator() {
-		return nil, errstack.NewReq("Admin role required")
+	if !u.Can(model.PermissionUserReadAll) {
+		return nil, model.ErrUnauthorized
 	}
 	return dal.GetAdminUsers(ctx, r.db)
 }
 
+// AdminScreeningHits returns sanctions screening hits for the compliance review
+func (r queryResolver) AdminScreeningHits(ctx context.Context, status *model.ScreeningHitStatus) ([]model.ScreeningHit, error) {
+	u, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !u.Can(model.PermissionUserApprove) {
+		return nil, model.ErrUnauthorized
+	}
+	return dal.GetScreeningHits(ctx, r.db, status)
+}
+
 func (r queryResolver) TradeTemplates(ctx context.Context) ([]model.TradeTemplate, error) {
 	return dal.GetTradeTemplates(ctx, r.db)
 }
@@ -51,10 +63,52 @@ func (r queryResolver) Trade(ctx context.Context, id string) (*model.Trade, erro
 		return nil, err
 	}
 	t, errs := dal.GetTrade(ctx, r.db, id)
-	if errs != nil && t.Buyer.UserID != u.ID && t.Seller.UserID != u.ID {
-		return nil, model.ErrUnauthorized
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = t.CanBeReadBy(u); errs != nil {
+		return nil, errs
+	}
+	if errs = middleware.AssertAPIKeyTrade(ctx, t); errs != nil {
+		return nil, errs
+	}
+	return t, nil
+}
+
+// TradeStageDocHistory returns all versions of the stage doc with their metadata changes
+func (r queryResolver) TradeStageDocHistory(ctx context.Context, tid string, stageIdx uint, stageDocIdx uint) ([]model.TradeStageDocVersion, error) {
+	t, err := r.Trade(ctx, tid)
+	if err != nil {
+		return nil, err
+	}
+	s, errs := t.GetStage(stageIdx)
+	if errs != nil {
+		return nil, errs
+	}
+	if _, errs = s.GetDoc(stageDocIdx); errs != nil {
+		return nil, errs
+	}
+	vs := []model.TradeStageDocVersion{}
+	var prev *model.Doc
+	for _, idx := range s.DocVersions(stageDocIdx) {
+		sd := s.Docs[idx]
+		d, errs := dal.GetDoc(ctx, r.db, sd.DocID)
+		if errs != nil {
+			return nil, errs
+		}
+		changes := []model.DocMetaChange{}
+		if prev != nil {
+			changes = model.DocMetaChanges(prev, d)
+		}
+		vs = append(vs, model.TradeStageDocVersion{
+			StageDocIdx: idx,
+			Version:     sd.VersionNum(),
+			StageDoc:    sd,
+			Changes:     changes,
+		})
+		prev = d
 	}
-	return t, errs
+	return vs, nil
 }
 
 func (r queryResolver) Trades(ctx context.Context) ([]model.Trade, error) {
@@ -62,7 +116,15 @@ func (r queryResolver) Trades(ctx context.Context) ([]model.Trade, error) {
 	if err != nil {
 		return nil, err
 	}
-	return dal.GetTrades(ctx, r.db, u.ID)
+	return dal.GetTrades(ctx, r.db, u.ID, middleware.APIKeyOrgID(ctx))
+}
+
+func (r queryResolver) TradesConnection(ctx context.Context, first *int, after *string, filter *model.TradeFilter, orderBy *model.TradeOrder) (*model.TradeConnection, error) {
+	u, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return dal.GetTradesPage(ctx, r.db, u.ID, middleware.APIKeyOrgID(ctx), filter, orderBy, first, after)
 }
 
 func (r queryResolver) TradeOffer(ctx context.Context, id string) (*model.TradeOffer, error) {
@@ -79,6 +141,41 @@ func (r queryResolver) TradeOffers(ctx context.Context) ([]model.TradeOffer, err
 	return dal.GetTradeOffers(ctx, r.db)
 }
 
+func (r queryResolver) TradeOffersConnection(ctx context.Context, first *int, after *string, filter *model.TradeOfferFilter, orderBy *model.TradeOfferOrder) (*model.TradeOfferConnection, error) {
+	if _, err := middleware.GetAuthUser(ctx); err != nil {
+		return nil, err
+	}
+	return dal.GetTradeOffersPage(ctx, r.db, filter, orderBy, first, after)
+}
+
+func (r queryResolver) TradeOfferMatches(ctx context.Context, id string) ([]model.TradeOfferMatch, error) {
+	if _, err := middleware.GetAuthUser(ctx); err != nil {
+		return nil, err
+	}
+	tof, errs := dal.GetTradeOffer(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	return findTradeOfferMatches(ctx, r.db, tof)
+}
+
+func (r queryResolver) TradeOfferBids(ctx context.Context, offerID string) ([]model.TradeOfferBid, error) {
+	u, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tof, errs := dal.GetTradeOffer(ctx, r.db, offerID)
+	if errs != nil {
+		return nil, errs
+	}
+	bidder := u.ID
+	// the offer organization members follow all negotiation threads
+	if u.ID == tof.CreatedBy || u.CanInOrg(tof.OrgID, model.PermissionOfferRead) {
+		bidder = ""
+	}
+	return dal.GetTradeOfferBids(ctx, r.db, offerID, bidder)
+}
+
 func (r queryResolver) Notifications(ctx context.Context, from uint) ([]model.Notification, error) {
 	u, err := middleware.GetAuthUser(ctx)
 	if err != nil {
@@ -108,12 +205,23 @@ func (r queryResolver) AdminTrades(ctx context.Context) ([]model.Trade, error) {
 	if err != nil {
 		return nil, err
 	}
-	if !u.IsModerator() {
+	if !u.Can(model.PermissionTradeReadAll) {
 		return nil, model.ErrUnauthorized
 	}
 	return dal.GetAllTrades(ctx, r.db)
 }
 
+func (r queryResolver) AdminTradesConnection(ctx context.Context, first *int, after *string, filter *model.TradeFilter, orderBy *model.TradeOrder) (*model.TradeConnection, error) {
+	u, err := middleware.GetAuthUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !u.Can(model.PermissionTradeReadAll) {
+		return nil, model.ErrUnauthorized
+	}
+	return dal.GetTradesPage(ctx, r.db, "", "", filter, orderBy, first, after)
+}
+
 // PubKey retrieves current user's public key for a trade
 func (r queryResolver) PubKey(ctx context.Context, tradeID string) (*string, error) {
 	u, err := middleware.GetAuthUser(ctx)
@@ -139,3 +247,69 @@ func (r queryResolver) Organizations(ctx context.Context) ([]model.Organization,
 	}
 	return dal.GetAllOrganizations(ctx, r.db)
 }
+
+// APIKeys returns the API keys of the organization
+func (r queryResolver) APIKeys(ctx context.Context, orgID string) ([]model.APIKey, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.CanInOrg(orgID, model.PermissionAPIKeyManage) {
+		return nil, model.ErrUnauthorized
+	}
+	return dal.GetOrgAPIKeys(ctx, r.db, orgID)
+}
+
+// OrgMembers returns the organization members with their roles
+func (r queryResolver) OrgMembers(ctx context.Context, orgID string) ([]model.OrgMember, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.IsOrgMember(orgID) && !u.Can(model.PermissionUserReadAll) {
+		return nil, model.ErrUnauthorized
+	}
+	us, errs := dal.GetOrgMembers(ctx, r.db, orgID)
+	if errs != nil {
+		return nil, errs
+	}
+	members := make([]model.OrgMember, len(us))
+	for i := range us {
+		members[i].User = us[i]
+		members[i].Role, _ = us[i].OrgRole(orgID)
+	}
+	return members, nil
+}
+
+// OrgMemberReqs returns pending invitations and join requests of the organization
+func (r queryResolver) OrgMemberReqs(ctx context.Context, orgID string) ([]model.OrgMemberReq, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.CanManageOrg(orgID) {
+		return nil, model.ErrUnauthorized
+	}
+	return dal.GetOrgPendingMemberReqs(ctx, r.db, orgID)
+}
+
+// OrgInvitations returns pending invitations sent to the verified emails of the user
+func (r queryResolver) OrgInvitations(ctx context.Context) ([]model.OrgMemberReq, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	return dal.GetPendingOrgInvitations(ctx, r.db, u.VerifiedEmails)
+}
+
+// OrgAuditLog returns the organization membership changes
+func (r queryResolver) OrgAuditLog(ctx context.Context, orgID string) ([]model.OrgAuditEntry, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.CanManageOrg(orgID) && !u.Can(model.PermissionUserReadAll) {
+		return nil, model.ErrUnauthorized
+	}
+	return dal.GetOrgAuditLog(ctx, r.db, orgID)
+}
@@ -29,14 +29,20 @@ type resolver struct {
 	docRes              gql.DocResolver
 	mutationRes         gql.MutationResolver
 	queryRes            gql.QueryResolver
+	subscriptionRes     gql.SubscriptionResolver
 	tradeRes            gql.TradeResolver
+	tradeParticipantRes gql.TradeParticipantResolver
 	tradeStageAddReqRes gql.TradeStageAddReqResolver
 	tradeStageDocRes    gql.TradeStageDocResolver
+	tradeTemplateRes    gql.TradeTemplateResolver
 	userRes             gql.UserResolver
 	accessApprovalRes   gql.AccessApprovalResolver
 	tradeOfferRes       gql.TradeOfferResolver
+	tradeOfferBidRes    gql.TradeOfferBidResolver
 	moderatorRes        gql.StageModeratorResolver
 	notificationRes     gql.NotificationResolver
+	orgMemberReqRes     gql.OrgMemberReqResolver
+	organizationRes     gql.OrganizationResolver
 }
 
 // NewResolver initialize a new instance of resolver
@@ -49,14 +55,20 @@ func NewResolver(db driver.Database, stellarDriver *stellar.Driver, txSourceDriv
 	r.docRes = docResolver{r}
 	r.mutationRes = mutationResolver{r}
 	r.queryRes = queryResolver{r}
+	r.subscriptionRes = subscriptionResolver{r}
 	r.tradeRes = tradeResolver{r}
+	r.tradeParticipantRes = tradeParticipantResolver{r}
 	r.tradeStageAddReqRes = tradeStageAddReqResolver{r}
 	r.tradeStageDocRes = tradeStageDocResolver{r}
+	r.tradeTemplateRes = tradeTemplateResolver{r}
 	r.userRes = userResolver{r}
 	r.accessApprovalRes = accessApprovalResolver{r}
 	r.tradeOfferRes = tradeOfferRes{r}
+	r.tradeOfferBidRes = tradeOfferBidRes{r}
 	r.moderatorRes = stageModeratorResolver{r}
 	r.notificationRes = notificationResolver{r}
+	r.orgMemberReqRes = orgMemberReqResolver{r}
+	r.organizationRes = organizationResolver{r}
 	return r
 }
 
@@ -75,6 +87,11 @@ func (r *resolver) Query() gql.QueryResolver {
 	return r.queryRes
 }
 
+// Subscription gets a subscription resolver
+func (r *resolver) Subscription() gql.SubscriptionResolver {
+	return r.subscriptionRes
+}
+
 func (r *resolver) ApproveReq() gql.ApproveReqResolver {
 	return approveReqResolver{r}
 }
@@ -88,6 +105,11 @@ func (r *resolver) Trade() gql.TradeResolver {
 	return r.tradeRes
 }
 
+// TradeParticipant returns a trade participant resolver
+func (r *resolver) TradeParticipant() gql.TradeParticipantResolver {
+	return r.tradeParticipantRes
+}
+
 // TradeStageAddReq implements the resolver interface
 func (r *resolver) TradeStageAddReq() gql.TradeStageAddReqResolver {
 	return r.tradeStageAddReqRes
@@ -98,6 +120,11 @@ func (r *resolver) TradeStageDoc() gql.TradeStageDocResolver {
 	return r.tradeStageDocRes
 }
 
+// TradeTemplate returns a trade template resolver
+func (r *resolver) TradeTemplate() gql.TradeTemplateResolver {
+	return r.tradeTemplateRes
+}
+
 // User returns user resolver
 func (r *resolver) User() gql.UserResolver {
 	return r.userRes
@@ -113,6 +140,11 @@ func (r *resolver) TradeOffer() gql.TradeOfferResolver {
 	return r.tradeOfferRes
 }
 
+// TradeOfferBid implements the resolver interface
+func (r *resolver) TradeOfferBid() gql.TradeOfferBidResolver {
+	return r.tradeOfferBidRes
+}
+
 // StageModerator implements the trade stage moderator interface
 func (r *resolver) StageModerator() gql.StageModeratorResolver {
 	return r.moderatorRes
@@ -122,3 +154,13 @@ func (r *resolver) StageModerator() gql.StageModeratorResolver {
 func (r *resolver) Notification() gql.NotificationResolver {
 	return r.notificationRes
 }
+
+// Organization gets an organization resolver
+func (r *resolver) Organization() gql.OrganizationResolver {
+	return r.organizationRes
+}
+
+// OrgMemberReq gets an organization invitation and join request resolver
+func (r *resolver) OrgMemberReq() gql.OrgMemberReqResolver {
+	return r.orgMemberReqRes
+}
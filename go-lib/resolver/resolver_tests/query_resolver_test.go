@@ -2,6 +2,7 @@ package resolvertests
 
 import (
 	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/resolver/testutil"
 	. "gopkg.in/check.v1"
 )
 
@@ -23,3 +24,15 @@ func (s *TradeIntegrationSuite) TestGetPubKey(c *C) {
 		WalletID: "default-user2-wallet",
 	})
 }
+
+func (s *TradeIntegrationSuite) TestGetTradeAccess(c *C) {
+	t, err := testutil.GetTrade(s.seller.Ctx, s.noopResolver, s.trade.ID)
+	c.Assert(err, IsNil)
+	c.Check(t.ID, Equals, s.trade.ID)
+	_, err = testutil.GetTrade(s.moderator.Ctx, s.noopResolver, s.trade.ID)
+	c.Check(err, IsNil)
+	_, err = testutil.GetTrade(s.third.Ctx, s.noopResolver, s.trade.ID)
+	c.Check(err, Equals, model.ErrUnauthorized)
+	_, err = testutil.GetTrade(s.buyer.Ctx, s.noopResolver, "missing-trade")
+	c.Check(err, NotNil)
+}
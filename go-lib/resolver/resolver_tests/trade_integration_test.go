@@ -121,6 +121,68 @@ func (s *TradeIntegrationSuite) TestMakeNewTradeNilDesc(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (s *TradeIntegrationSuite) TestMakeNewTradeWithParticipants(c *C) {
+	input := testutil.MakeTradeInput("test-trade", s.buyer.ID, s.seller.ID, &sampleDesc)
+	input.Participants = []model.TradeParticipantInput{{UserID: s.third.ID, Role: model.TradeActorK}}
+	trade, err := s.noopResolver.Mutation().TradeCreate(s.buyer.Ctx, input)
+	c.Assert(err, IsNil)
+	c.Assert(trade.Participants, HasLen, 1)
+	c.Check(trade.Participants[0].UserID, Equals, s.third.ID)
+	c.Check(trade.Participants[0].Role, Equals, model.TradeActorK)
+	c.Check(trade.Participants[0].PubKey, Not(Equals), "")
+
+	// the bank is notified and can read the trade, but can't close it
+	notifications, err := s.noopResolver.Query().NotificationsTrade(s.third.Ctx, trade.ID)
+	c.Assert(err, IsNil)
+	c.Assert(notifications, Not(HasLen), 0)
+	c.Check(notifications[0].Receiver, Contains, s.third.ID)
+	_, err = testutil.GetTrade(s.third.Ctx, s.noopResolver, trade.ID)
+	c.Check(err, IsNil)
+	_, err = s.noopResolver.Mutation().TradeCloseReq(s.third.Ctx, trade.ID, validReason, "")
+	c.Check(err, Equals, model.ErrUnauthorized)
+
+	input.Participants = []model.TradeParticipantInput{{UserID: s.seller.ID, Role: model.TradeActorR}}
+	_, err = s.noopResolver.Mutation().TradeCreate(s.buyer.Ctx, input)
+	c.Check(err, ErrorContains, "the user is already a trade party")
+}
+
+func (s *TradeIntegrationSuite) TestMakeNewTradeUnverifiedParty(c *C) {
+	const thirdOrgID = "430823"
+	reject := model.AccessApproval{Status: model.SimpleApprovalRejected, Approver: s.moderator.ID, CreatedAt: time.Now()}
+	c.Assert(dal.AddOrgApproval(testctx, s.db, thirdOrgID, reject), IsNil)
+	defer func() {
+		approve := model.AccessApproval{Status: model.SimpleApprovalApproved, Approver: s.moderator.ID, CreatedAt: time.Now()}
+		c.Check(dal.AddOrgApproval(testctx, s.db, thirdOrgID, approve), IsNil)
+	}()
+
+	trade, err := s.noopResolver.Mutation().TradeCreate(s.buyer.Ctx, testutil.MakeTradeInput("test-trade", s.buyer.ID, s.third.ID, &sampleDesc))
+	c.Check(err, Equals, model.ErrOrgNotVerified)
+	c.Check(trade, IsNil)
+
+	input := testutil.MakeTradeInput("test-trade", s.buyer.ID, s.seller.ID, &sampleDesc)
+	input.Participants = []model.TradeParticipantInput{{UserID: s.third.ID, Role: model.TradeActorK}}
+	trade, err = s.noopResolver.Mutation().TradeCreate(s.buyer.Ctx, input)
+	c.Check(err, Equals, model.ErrOrgNotVerified)
+	c.Check(trade, IsNil)
+}
+
+func (s *TradeIntegrationSuite) TestMakeNewTradeKeepsKeysOnFailure(c *C) {
+	derivationIndex := func(uid string) int {
+		u, err := dal.GetUser(testctx, s.db, uid)
+		c.Assert(err, IsNil)
+		return u.HDCerealiaWallets[u.DefaultWalletID].DerivationIndex
+	}
+	buyerIdx, sellerIdx := derivationIndex(s.buyer.ID), derivationIndex(s.seller.ID)
+	input := testutil.MakeTradeInput("test-trade", s.buyer.ID, s.seller.ID, &sampleDesc)
+	input.TemplateID = "unknown-template"
+	trade, err := s.noopResolver.Mutation().TradeCreate(s.buyer.Ctx, input)
+	c.Assert(err, NotNil)
+	c.Check(trade, NotNil)
+	// the party keys are used only when the trade is created
+	c.Check(derivationIndex(s.buyer.ID), Equals, buyerIdx)
+	c.Check(derivationIndex(s.seller.ID), Equals, sellerIdx)
+}
+
 func (s *TradeIntegrationSuite) TestMakeNewTradeWrongName(c *C) {
 	trade, err := s.noopResolver.Mutation().TradeCreate(s.buyer.Ctx, model.NewTradeInput{
 		TemplateID:  "1471516",
@@ -141,6 +203,58 @@ func (s *TradeIntegrationSuite) TestMakeNewTradeSellerDoesNotExist(c *C) {
 	c.Check(err, ErrorContains, "DB: object not found [document not found]")
 }
 
+func (s *TradeIntegrationSuite) TestTradeTemplateVersions(c *C) {
+	mutation := s.noopResolver.Mutation()
+	input := model.TradeTemplateInput{
+		Name: "template " + time.Now().Format(time.RFC3339Nano),
+		Stages: []model.TradeStageTemplate{
+			{Name: model.TradeContractStageName, Owner: model.TradeActorN},
+			{Name: "inspection", Owner: model.TradeActorI},
+		},
+	}
+	_, err := mutation.TradeTemplateCreate(s.buyer.Ctx, input)
+	c.Check(err, Equals, model.ErrUnauthorized)
+	v1, err := mutation.TradeTemplateCreate(s.moderator.Ctx, input)
+	c.Assert(err, IsNil)
+	c.Check(v1.Version, Equals, 1)
+	_, err = mutation.TradeTemplateCreate(s.moderator.Ctx, input)
+	c.Check(err, Equals, model.ErrTemplateNameTaken)
+
+	tradeInput := testutil.MakeTradeInput("test-trade", s.buyer.ID, s.seller.ID, &sampleDesc)
+	tradeInput.TemplateID = v1.ID
+	t1, err := mutation.TradeCreate(s.buyer.Ctx, tradeInput)
+	c.Assert(err, IsNil)
+
+	input.Stages = append(input.Stages, model.TradeStageTemplate{Name: "payment", Owner: model.TradeActorK})
+	v2, err := mutation.TradeTemplateUpdate(s.moderator.Ctx, v1.ID, input)
+	c.Assert(err, IsNil)
+	c.Check(v2.Version, Equals, 2)
+	c.Check(v2.Family(), Equals, v1.ID)
+	_, err = mutation.TradeTemplateUpdate(s.moderator.Ctx, v1.ID, input)
+	c.Check(err, Equals, model.ErrTemplateNotActive)
+	versions, err := s.noopResolver.Query().TradeTemplateVersions(s.buyer.Ctx, v1.ID)
+	c.Assert(err, IsNil)
+	c.Assert(versions, HasLen, 2)
+	c.Check(versions[0].ID, Equals, v2.ID)
+	c.Check(*versions[1].SupersededBy, Equals, v2.ID)
+
+	// the trade keeps its version, new trades can't use superseded versions
+	t1, err = testutil.GetTrade(s.buyer.Ctx, s.noopResolver, t1.ID)
+	c.Assert(err, IsNil)
+	c.Check(t1.TemplateID, Equals, v1.ID)
+	c.Check(t1.Stages, HasLen, 2)
+	_, err = mutation.TradeCreate(s.buyer.Ctx, tradeInput)
+	c.Check(err, Equals, model.ErrTemplateNotActive)
+
+	_, err = mutation.TradeTemplateArchive(s.moderator.Ctx, v1.ID)
+	c.Assert(err, IsNil)
+	tradeInput.TemplateID = v2.ID
+	_, err = mutation.TradeCreate(s.buyer.Ctx, tradeInput)
+	c.Check(err, Equals, model.ErrTemplateNotActive)
+	_, err = mutation.TradeTemplateArchive(s.moderator.Ctx, v2.ID)
+	c.Check(err, Equals, model.ErrTemplateNotActive)
+}
+
 func (s *TradeIntegrationSuite) TestNewStageWithConfirmation(c *C) {
 	c.Check(len(s.trade.Stages), Equals, 13)
 
@@ -493,8 +607,87 @@ func (s *TradeIntegrationSuite) TestCloseStageWithConfirmation(c *C) {
 		testutil.SampleUser2Seed,
 	)
 	c.Assert(err, IsNil)
+	_, err = mr.TradeStageCloseReqApprove(s.seller.Ctx, tradeStagePath, rawTxSigned)
+	c.Check(err, Equals, model.ErrTOTPNotEnabled)
+	disableTOTP, err := testutil.EnableTOTP(s.noopResolver, s.seller)
+	c.Assert(err, IsNil)
+	defer func() { c.Check(disableTOTP(), IsNil) }()
 	approveReq, err = mr.TradeStageCloseReqApprove(s.seller.Ctx, tradeStagePath, rawTxSigned)
 	c.Assert(err, IsNil)
 	c.Check(approveReq.Status, Equals, model.ApprovalApproved)
 	c.Check(approveReq.ApprovedBy, Equals, s.seller.ID)
 }
+
+func (s *TradeIntegrationSuite) TestTradeModeration(c *C) {
+	mutation := s.noopResolver.Mutation()
+	tradeInput := testutil.MakeTradeInput("test-trade", s.buyer.ID, s.seller.ID, &sampleDesc)
+	t, err := mutation.TradeCreate(s.buyer.Ctx, tradeInput)
+	c.Assert(err, IsNil)
+
+	_, err = mutation.TradeModerationClaim(s.buyer.Ctx, t.ID, nil)
+	c.Check(err, Equals, model.ErrUnauthorized)
+	t, err = mutation.TradeModerationClaim(s.moderator.Ctx, t.ID, nil)
+	c.Assert(err, IsNil)
+	c.Check(t.ModeratorID(), Equals, s.moderator.ID)
+	c.Check(t.Moderating, Equals, model.DoneStatusDoing)
+	_, err = mutation.TradeModerationDone(s.moderator.Ctx, t.ID)
+	c.Assert(err, IsNil)
+	t, err = testutil.GetTrade(s.buyer.Ctx, s.noopResolver, t.ID)
+	c.Assert(err, IsNil)
+	c.Check(t.Moderator, IsNil)
+	c.Check(t.Moderating, Equals, model.DoneStatusDone)
+	_, err = mutation.TradeModerationRelease(s.moderator.Ctx, t.ID)
+	c.Check(err, Equals, model.ErrModerationNotClaimed)
+
+	stage := model.TradeStagePath{Tid: t.ID, StageIdx: 0}
+	st, err := mutation.TradeStageModerationClaim(s.moderator.Ctx, stage, nil)
+	c.Assert(err, IsNil)
+	c.Check(st.Moderator.UserID, Equals, s.moderator.ID)
+	_, err = mutation.TradeStageModerationRelease(s.moderator.Ctx, stage)
+	c.Assert(err, IsNil)
+	t, err = testutil.GetTrade(s.buyer.Ctx, s.noopResolver, t.ID)
+	c.Assert(err, IsNil)
+	c.Check(t.Stages[0].Moderator.UserID, Equals, "")
+
+	// whole trade writes keep the moderator claims
+	stale, err := testutil.GetTrade(s.buyer.Ctx, s.noopResolver, t.ID)
+	c.Assert(err, IsNil)
+	_, err = mutation.TradeModerationClaim(s.moderator.Ctx, t.ID, nil)
+	c.Assert(err, IsNil)
+	stale.CloseReqs = append(stale.CloseReqs, model.ApproveReq{
+		Status:   model.ApprovalPending,
+		ReqActor: model.TradeActorB,
+		ReqBy:    s.buyer.ID,
+		ReqAt:    time.Now().UTC(),
+	})
+	_, err = dal.UpdateTrade(s.buyer.Ctx, s.db, stale)
+	c.Assert(err, IsNil)
+	c.Check(stale.ModeratorID(), Equals, s.moderator.ID)
+	t, err = testutil.GetTrade(s.buyer.Ctx, s.noopResolver, t.ID)
+	c.Assert(err, IsNil)
+	c.Check(t.ModeratorID(), Equals, s.moderator.ID)
+	c.Check(t.Moderating, Equals, model.DoneStatusDoing)
+	c.Check(len(t.CloseReqs), Equals, 1)
+
+	queue, err := s.noopResolver.Query().AdminModerationQueue(s.moderator.Ctx)
+	c.Assert(err, IsNil)
+	var found bool
+	for i, it := range queue {
+		c.Check(it.PendingCount > 0, IsTrue)
+		if it.Trade.ID == t.ID {
+			found = true
+			c.Check(it.PendingCount, Equals, 1)
+			c.Check(it.OldestPendingAt, NotNil)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := queue[i-1].OldestPendingAt
+		if prev == nil {
+			c.Check(it.OldestPendingAt, IsNil, Commentf("queue item %d", i))
+		} else if it.OldestPendingAt != nil {
+			c.Check(it.OldestPendingAt.Before(*prev), IsFalse, Commentf("queue item %d", i))
+		}
+	}
+	c.Check(found, IsTrue)
+}
@@ -1,10 +1,23 @@
 package resolvertests
 
 import (
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/auth"
+	"bitbucket.org/cerealia/apps/go-lib/auth/totp"
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
 	"bitbucket.org/cerealia/apps/go-lib/resolver/testutil"
+	"bitbucket.org/cerealia/apps/go-lib/screening"
+	"bitbucket.org/cerealia/apps/go-lib/stellar/webauth"
 	. "github.com/robert-zaremba/checkers"
+	"github.com/stellar/go/keypair"
 	. "gopkg.in/check.v1"
 )
 
@@ -27,6 +40,20 @@ func (s *TradeIntegrationSuite) TestUserSignup(c *C) {
 
 	_, err := mr.UserSignup(testctx, &testutil.SampleNewUser)
 	c.Check(err, IsNil, Comment("Failed to insert user"))
+	u, err := dal.GetUserByEmail(testctx, s.db, testutil.SampleNewUser.Email)
+	c.Assert(err, IsNil)
+	reqs, err := dal.GetOrgPendingMemberReqs(testctx, s.db, testutil.SampleNewUser.OrgID)
+	c.Assert(err, IsNil)
+	var joinReq *model.OrgMemberReq
+	for i := range reqs {
+		if reqs[i].UserID != nil && *reqs[i].UserID == u.ID {
+			joinReq = &reqs[i]
+		}
+	}
+	c.Assert(joinReq, NotNil)
+	c.Check(joinReq.Role, Equals, model.OrgRoleMember)
+	c.Check(joinReq.Position, Equals, testutil.SampleNewUser.OrgRole)
+	c.Check(joinReq.Message, Equals, "")
 
 	// test for new user with email which already exists.
 	nu1 := testutil.SampleNewUser
@@ -58,26 +85,342 @@ func (s *TradeIntegrationSuite) TestUserSignupNagative(c *C) {
 
 func (s *TradeIntegrationSuite) TestCreateNewOrganization(c *C) {
 	mr := s.noopResolver.Mutation()
-	// test for correct new user
+	_, err := mr.OrganizationCreate(testctx, testutil.SampleNewOrganization)
+	c.Check(err, NotNil, Comment("organization can be created only by a logged in user"))
 
-	org, err := mr.OrganizationCreate(testctx, testutil.SampleNewOrganization)
+	org, err := mr.OrganizationCreate(s.third.Ctx, testutil.SampleNewOrganization)
 	c.Assert(err, IsNil, Comment("Failed to insert organization"))
 	c.Check(org.ID, Not(IsEmpty))
+	third, err := dal.GetUser(testctx, s.db, s.third.ID)
+	c.Assert(err, IsNil)
+	role, _ := third.OrgRole(org.ID)
+	c.Check(role, Equals, model.OrgRoleAdmin, Comment("the creator is the organization admin"))
 
 	// negative test for new organization with name which already exists.
 	newOrg1 := testutil.SampleNewOrganization
 	newOrg1.Address = "testAddress1"
 	newOrg1.Email = "test.org1@gmail.com"
-	_, err = mr.OrganizationCreate(testctx, newOrg1)
+	_, err = mr.OrganizationCreate(s.third.Ctx, newOrg1)
 	c.Check(err, NotNil)
 
 	// negative test for new organization with address which already exists.
 	newOrg2 := testutil.SampleNewOrganization
 	newOrg2.Name = "testCompany2"
 	newOrg1.Email = "test.org2@gmail.com"
-	_, err = mr.OrganizationCreate(testctx, newOrg2)
+	_, err = mr.OrganizationCreate(s.third.Ctx, newOrg2)
 	c.Check(err, NotNil)
 	// delete new organization from db
+	c.Check(dal.RemoveOrgMember(testctx, s.db, s.third.ID, org.ID), IsNil)
 	err = dal.DeleteOrg(testctx, s.db, org.ID)
 	c.Check(err, IsNil, Comment("Failed to delete new org from db"))
 }
+
+func (s *TradeIntegrationSuite) TestOrgMembership(c *C) {
+	mr := s.noopResolver.Mutation()
+	qr := s.noopResolver.Query()
+	newOrg := testutil.SampleNewOrganization
+	newOrg.Name = "testMembershipOrg"
+	newOrg.Address = "testMembershipAddress"
+	org, err := mr.OrganizationCreate(s.moderator.Ctx, newOrg)
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Check(dal.RemoveOrgMember(testctx, s.db, s.moderator.ID, org.ID), IsNil)
+		c.Check(dal.DeleteOrg(testctx, s.db, org.ID), IsNil)
+	}()
+
+	// join request
+	req, err := mr.OrgJoinRequest(s.third.Ctx, org.ID, "trader")
+	c.Assert(err, IsNil)
+	c.Check(req.Status, Equals, model.ApprovalPending)
+	_, err = mr.OrgJoinRequest(s.third.Ctx, org.ID, "trader")
+	c.Check(err, NotNil, Comment("the request is already pending"))
+	_, err = mr.OrgJoinRequestApprove(s.third.Ctx, req.ID, model.OrgRoleAdmin)
+	c.Check(err, Equals, model.ErrUnauthorized)
+	_, err = mr.OrgJoinRequestApprove(s.moderator.Ctx, req.ID, model.OrgRoleViewer)
+	c.Assert(err, IsNil)
+	_, err = mr.OrgJoinRequestReject(s.moderator.Ctx, req.ID)
+	c.Check(err, Equals, model.ErrOrgMemberReqResolved)
+
+	members, err := qr.OrgMembers(s.moderator.Ctx, org.ID)
+	c.Assert(err, IsNil)
+	c.Check(members, HasLen, 2)
+
+	// invitation can be answered only by the invitee
+	inv, err := mr.OrgInvite(s.moderator.Ctx, org.ID, "org.invitee@example.com", model.OrgRoleMember)
+	c.Assert(err, IsNil)
+	_, err = mr.OrgInvitationAccept(s.third.Ctx, inv.ID)
+	c.Check(err, Equals, model.ErrUnauthorized)
+	reqs, err := qr.OrgMemberReqs(s.moderator.Ctx, org.ID)
+	c.Assert(err, IsNil)
+	c.Check(reqs, HasLen, 1)
+
+	// the last admin can't leave
+	_, err = mr.OrgMemberRemove(s.moderator.Ctx, org.ID, s.moderator.ID)
+	c.Check(err, Equals, model.ErrOrgLastAdmin)
+	_, err = mr.OrgMemberRemove(s.third.Ctx, org.ID, s.third.ID)
+	c.Check(err, IsNil)
+
+	log, err := qr.OrgAuditLog(s.moderator.Ctx, org.ID)
+	c.Assert(err, IsNil)
+	c.Assert(log, HasLen, 5)
+	c.Check(log[0].Action, Equals, model.OrgAuditActionMemberRemoved)
+	c.Check(log[4].Action, Equals, model.OrgAuditActionCreated)
+}
+
+func (s *TradeIntegrationSuite) TestSessions(c *C) {
+	mr := s.noopResolver.Mutation()
+	authUser, err := mr.UserLogin(testctx, testutil.SampleUser1)
+	c.Assert(err, IsNil)
+	c.Assert(authUser.RefreshToken, NotNil)
+
+	refreshed, err := mr.UserTokenRefresh(testctx, *authUser.RefreshToken)
+	c.Assert(err, IsNil)
+	c.Check(refreshed.ID, Equals, authUser.ID)
+	c.Check(*refreshed.RefreshToken, Not(Equals), *authUser.RefreshToken)
+
+	// reusing the rotated token revokes the session
+	_, err = mr.UserTokenRefresh(testctx, *authUser.RefreshToken)
+	c.Check(err, Equals, model.ErrInvalidSession)
+	_, err = mr.UserTokenRefresh(testctx, *refreshed.RefreshToken)
+	c.Check(err, Equals, model.ErrInvalidSession)
+
+	creds, err := testutil.Login(s.noopResolver, testutil.SampleUser1)
+	c.Assert(err, IsNil)
+	_, err = mr.UserLogout(creds.Ctx)
+	c.Assert(err, IsNil)
+	_, err = testutil.Login(s.noopResolver, testutil.SampleUser1)
+	c.Assert(err, IsNil)
+	_, err = mr.UserLogoutAll(creds.Ctx)
+	c.Check(err, IsNil)
+}
+
+func (s *TradeIntegrationSuite) TestEmailVerificationAndPasswordReset(c *C) {
+	emailsFile := filepath.Join(c.MkDir(), "emails.txt")
+	defaultDispatcher := notify.Default
+	notify.Default = notify.NewDispatcher(notify.NewFileSender(emailsFile), "http://localhost:8000")
+	defer func() { notify.Default = defaultDispatcher }()
+	lastToken := func() string {
+		content, err := ioutil.ReadFile(emailsFile)
+		c.Assert(err, IsNil)
+		m := regexp.MustCompile(`token=([^\s]+)`).FindAllStringSubmatch(string(content), -1)
+		c.Assert(m, Not(HasLen), 0)
+		token, err := url.QueryUnescape(m[len(m)-1][1])
+		c.Assert(err, IsNil)
+		return token
+	}
+
+	mr := s.noopResolver.Mutation()
+	nu := testutil.SampleNewUser
+	_, err := mr.UserSignup(testctx, &nu)
+	c.Assert(err, IsNil)
+	defer func() { c.Check(dal.DeleteUser(testctx, s.db, nu.Email), IsNil) }()
+	disableTOTP, err := testutil.EnableTOTP(s.noopResolver, s.moderator)
+	c.Assert(err, IsNil)
+	defer func() { c.Check(disableTOTP(), IsNil) }()
+	u, err := dal.GetUserByEmail(testctx, s.db, nu.Email)
+	c.Assert(err, IsNil)
+	c.Check(u.IsPrimaryEmailVerified(), IsFalse)
+	_, err = mr.AdminApproveUser(s.moderator.Ctx, u.ID, model.SimpleApprovalApproved, nil)
+	c.Check(err, ErrorContains, "not verified")
+
+	token := lastToken()
+	_, err = mr.UserEmailVerify(testctx, token)
+	c.Assert(err, IsNil)
+	_, err = mr.UserEmailVerify(testctx, token)
+	c.Check(err, Equals, model.ErrInvalidToken, Comment("tokens are single use"))
+	u, err = dal.GetUserByEmail(testctx, s.db, nu.Email)
+	c.Assert(err, IsNil)
+	c.Check(u.IsPrimaryEmailVerified(), IsTrue)
+	_, err = mr.AdminApproveUser(s.moderator.Ctx, u.ID, model.SimpleApprovalApproved, nil)
+	c.Assert(err, IsNil)
+
+	_, err = mr.UserPasswordResetRequest(testctx, "unknown@example.com")
+	c.Check(err, IsNil)
+	_, err = mr.UserPasswordResetRequest(testctx, nu.Email)
+	c.Assert(err, IsNil)
+	token = lastToken()
+	_, err = mr.UserPasswordResetConfirm(testctx, token, "weak")
+	c.Check(err, ErrorContains, "Password length")
+	_, err = mr.UserPasswordResetConfirm(testctx, token, "NewPassword@1")
+	c.Assert(err, IsNil)
+	_, err = mr.UserPasswordResetConfirm(testctx, token, "NewPassword@2")
+	c.Check(err, Equals, model.ErrInvalidToken)
+	_, err = mr.UserLogin(testctx, model.UserLoginInput{Email: nu.Email, Password: "NewPassword@1"})
+	c.Check(err, IsNil)
+}
+
+func (s *TradeIntegrationSuite) TestTOTPLogin(c *C) {
+	mr := s.noopResolver.Mutation()
+	creds, errs := testutil.Login(s.noopResolver, testutil.SampleUser3)
+	c.Assert(errs, IsNil)
+	enrollment, err := mr.UserTOTPEnroll(creds.Ctx)
+	c.Assert(err, IsNil)
+	c.Check(enrollment.URI, Contains, "secret="+enrollment.Secret)
+	counter := totp.Counter(time.Now())
+	code, errs := totp.Code(enrollment.Secret, counter)
+	c.Assert(errs, IsNil)
+	recovery, err := mr.UserTOTPEnable(creds.Ctx, code)
+	c.Assert(err, IsNil)
+
+	authUser, err := mr.UserLogin(testctx, testutil.SampleUser3)
+	c.Assert(err, IsNil)
+	c.Check(authUser.Token, IsNil)
+	c.Assert(authUser.MfaToken, NotNil)
+	_, err = mr.UserTOTPLogin(testctx, *authUser.MfaToken, code)
+	c.Check(err, Equals, model.ErrInvalidTOTP, Comment("codes can't be reused"))
+	code, errs = totp.Code(enrollment.Secret, counter+1)
+	c.Assert(errs, IsNil)
+	authUser, err = mr.UserTOTPLogin(testctx, *authUser.MfaToken, code)
+	c.Assert(err, IsNil)
+	c.Check(authUser.Token, NotNil)
+
+	// the login token is burned after too many wrong codes
+	authUser, err = mr.UserLogin(testctx, testutil.SampleUser3)
+	c.Assert(err, IsNil)
+	for i := 0; i < model.MaxMFAAttempts; i++ {
+		_, err = mr.UserTOTPLogin(testctx, *authUser.MfaToken, "000000")
+		c.Check(err, Equals, model.ErrInvalidTOTP)
+	}
+	_, err = mr.UserTOTPLogin(testctx, *authUser.MfaToken, recovery[1])
+	c.Check(err, Equals, model.ErrInvalidToken)
+
+	_, err = mr.UserTOTPDisable(creds.Ctx, recovery[0])
+	c.Check(err, IsNil)
+}
+
+func (s *TradeIntegrationSuite) TestKeyLogin(c *C) {
+	mr := s.noopResolver.Mutation()
+	pubKey := keypair.MustParse(testutil.SampleUser1Seed).Address()
+	tx, err := mr.UserKeyLoginChallenge(testctx, pubKey)
+	c.Assert(err, IsNil)
+	_, err = mr.UserLoginWithKey(testctx, tx)
+	c.Check(err, Equals, webauth.ErrInvalidChallenge, Comment("not signed by the user"))
+	signed, err := testutil.SignTx(*s.noopDriver, tx, testutil.SampleUser1Seed)
+	c.Assert(err, IsNil)
+	authUser, err := mr.UserLoginWithKey(testctx, signed)
+	c.Assert(err, IsNil)
+	c.Check(authUser.Token, NotNil)
+	_, err = mr.UserLoginWithKey(testctx, signed)
+	c.Check(err, Equals, webauth.ErrInvalidChallenge, Comment("challenges are single use"))
+
+	// unknown keys get a challenge, but it can't be used to login
+	kp, err := keypair.Random()
+	c.Assert(err, IsNil)
+	tx, err = mr.UserKeyLoginChallenge(testctx, kp.Address())
+	c.Assert(err, IsNil)
+	signed, err = testutil.SignTx(*s.noopDriver, tx, kp.Seed())
+	c.Assert(err, IsNil)
+	_, err = mr.UserLoginWithKey(testctx, signed)
+	c.Check(err, Equals, webauth.ErrInvalidChallenge)
+}
+
+func (s *TradeIntegrationSuite) TestAPIKeys(c *C) {
+	mr, qr := s.noopResolver.Mutation(), s.noopResolver.Query()
+	creds, errs := testutil.Login(s.noopResolver, testutil.SampleUser1)
+	c.Assert(errs, IsNil)
+	const orgID = "430644"
+	input := model.APIKeyInput{OrgID: orgID, Name: "ERP", Scopes: []model.APIKeyScope{model.APIKeyScopeReadTrades}}
+	created, err := mr.APIKeyCreate(creds.Ctx, input)
+	c.Assert(err, IsNil)
+	c.Check(auth.IsAPIKey(created.Key), IsTrue)
+	c.Check(created.APIKey.KeyHash, Not(Equals), "")
+	c.Check(created.APIKey.CreatedBy, Equals, creds.User.ID)
+
+	keys, err := qr.APIKeys(creds.Ctx, orgID)
+	c.Assert(err, IsNil)
+	c.Assert(keys, HasLen, 1)
+	c.Check(keys[0].ID, Equals, created.APIKey.ID)
+
+	input.OrgID = "other-org"
+	_, err = mr.APIKeyCreate(creds.Ctx, input)
+	c.Check(err, Equals, model.ErrUnauthorized)
+	_, err = qr.APIKeys(creds.Ctx, input.OrgID)
+	c.Check(err, Equals, model.ErrUnauthorized)
+
+	_, err = mr.APIKeyRevoke(creds.Ctx, created.APIKey.ID)
+	c.Check(err, IsNil)
+	_, err = mr.APIKeyRevoke(creds.Ctx, created.APIKey.ID)
+	c.Check(err, Equals, model.ErrInvalidAPIKey)
+}
+
+func (s *TradeIntegrationSuite) TestAdminApproveOrg(c *C) {
+	mr := s.noopResolver.Mutation()
+	newOrg := testutil.SampleNewOrganization
+	newOrg.Name = "testKYBOrg"
+	newOrg.Address = "testKYBAddress"
+	org, err := mr.OrganizationCreate(s.third.Ctx, newOrg)
+	c.Assert(err, IsNil)
+	defer func() {
+		c.Check(dal.RemoveOrgMember(testctx, s.db, s.third.ID, org.ID), IsNil)
+		c.Check(dal.DeleteOrg(testctx, s.db, org.ID), IsNil)
+	}()
+	c.Check(org.KYBStatus(), Equals, model.KYBStatusUnverified)
+
+	_, err = mr.AdminApproveOrg(s.third.Ctx, org.ID, model.SimpleApprovalRejected, nil)
+	c.Check(err, ErrorContains, "Admin role required")
+	_, err = mr.AdminApproveOrg(s.moderator.Ctx, org.ID, model.SimpleApprovalApproved, nil)
+	c.Check(err, ErrorContains, "Missing KYB documents")
+
+	fi := model.FileInfo{FileName: "registration.pdf", Hash: "abc", URL: "registration.pdf"}
+	_, err = dal.InsertOrgKYBDoc(testctx, s.db, org.ID, model.KYBDocKindRegistrationCertificate, fi, s.third.ID)
+	c.Assert(err, IsNil)
+	reason := "beneficial owners declaration is missing"
+	_, err = mr.AdminApproveOrg(s.moderator.Ctx, org.ID, model.SimpleApprovalRejected, &reason)
+	c.Check(err, Equals, model.ErrTOTPNotEnabled)
+	disableTOTP, err := testutil.EnableTOTP(s.noopResolver, s.moderator)
+	c.Assert(err, IsNil)
+	defer func() { c.Check(disableTOTP(), IsNil) }()
+	_, err = mr.AdminApproveOrg(s.moderator.Ctx, org.ID, model.SimpleApprovalRejected, &reason)
+	c.Assert(err, IsNil)
+	o, err := dal.GetOrganization(testctx, s.db, org.ID)
+	c.Assert(err, IsNil)
+	c.Check(o.KYBStatus(), Equals, model.KYBStatusRejected)
+
+	fi.FileName = "owners.pdf"
+	_, err = dal.InsertOrgKYBDoc(testctx, s.db, org.ID, model.KYBDocKindBeneficialOwners, fi, s.third.ID)
+	c.Assert(err, IsNil)
+	_, err = mr.AdminApproveOrg(s.moderator.Ctx, org.ID, model.SimpleApprovalApproved, nil)
+	c.Assert(err, IsNil)
+	o, err = dal.GetOrganization(testctx, s.db, org.ID)
+	c.Assert(err, IsNil)
+	c.Check(o.IsVerified(), IsTrue)
+}
+
+func (s *TradeIntegrationSuite) TestScreeningHitReview(c *C) {
+	mr := s.noopResolver.Mutation()
+	h := model.ScreeningHit{
+		SubjectKind: model.ScreeningSubjectUser,
+		SubjectID:   s.third.ID,
+		SubjectName: s.third.FirstName + " " + s.third.LastName,
+		List:        "test-list",
+		EntryRef:    "1",
+		EntryName:   s.third.FirstName + " " + s.third.LastName,
+		Score:       1,
+		Status:      model.ScreeningHitStatusPending,
+		CreatedAt:   time.Now().UTC(),
+	}
+	inserted, errs := dal.InsertScreeningHit(testctx, s.db, &h)
+	c.Assert(errs, IsNil)
+	c.Assert(inserted, IsTrue)
+	defer func() {
+		c.Check(dal.DeleteByID(testctx, s.db, dbconst.ColScreeningHits, h.ID), IsNil)
+	}()
+	inserted, errs = dal.InsertScreeningHit(testctx, s.db, &h)
+	c.Assert(errs, IsNil)
+	c.Check(inserted, IsFalse, Comment("the subject is matched with the entry only once"))
+
+	c.Check(screening.AssertNotFlagged(testctx, s.db, s.buyer.ID, s.third.ID), Equals, model.ErrScreeningFlagged)
+	c.Check(screening.AssertNotFlagged(testctx, s.db, s.buyer.ID, s.seller.ID), IsNil)
+
+	_, err := mr.AdminScreeningHitReview(s.third.Ctx, h.ID, model.ScreeningHitStatusCleared, "different person")
+	c.Check(err, ErrorContains, "Admin role required")
+	disableTOTP, err := testutil.EnableTOTP(s.noopResolver, s.moderator)
+	c.Assert(err, IsNil)
+	defer func() { c.Check(disableTOTP(), IsNil) }()
+	_, err = mr.AdminScreeningHitReview(s.moderator.Ctx, h.ID, model.ScreeningHitStatusPending, "reason")
+	c.Check(err, NotNil)
+	reviewed, err := mr.AdminScreeningHitReview(s.moderator.Ctx, h.ID, model.ScreeningHitStatusCleared, "different person")
+	c.Assert(err, IsNil)
+	c.Check(reviewed.Status, Equals, model.ScreeningHitStatusCleared)
+	c.Check(screening.AssertNotFlagged(testctx, s.db, s.third.ID), IsNil)
+}
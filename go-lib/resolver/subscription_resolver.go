@@ -0,0 +1,35 @@
+package resolver
+
+import (
+	"context"
+
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/pubsub"
+)
+
+type subscriptionResolver struct{ *resolver }
+
+func (r subscriptionResolver) NotificationAdded(ctx context.Context) (<-chan *model.Notification, error) {
+	u, errs := middleware.GetWebsocketAuthUser(ctx, r.db)
+	if errs != nil {
+		return nil, errs
+	}
+	return pubsub.Default.SubscribeNotifications(ctx, u.ID), nil
+}
+
+func (r subscriptionResolver) TradeUpdated(ctx context.Context, id string) (<-chan *model.Trade, error) {
+	u, errs := middleware.GetWebsocketAuthUser(ctx, r.db)
+	if errs != nil {
+		return nil, errs
+	}
+	t, errs := dal.GetTrade(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = t.CanBeReadBy(u); errs != nil {
+		return nil, errs
+	}
+	return pubsub.Default.SubscribeTrade(ctx, t.ID), nil
+}
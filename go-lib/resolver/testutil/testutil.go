@@ -6,7 +6,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
+	"bitbucket.org/cerealia/apps/go-lib/auth/totp"
 	"bitbucket.org/cerealia/apps/go-lib/gql"
 	"bitbucket.org/cerealia/apps/go-lib/middleware"
 	"bitbucket.org/cerealia/apps/go-lib/model"
@@ -89,10 +91,13 @@ func loginErr(r resolver.Resolver, loginInput model.UserLoginInput) (*Credential
 	if err != nil {
 		return nil, err
 	}
+	if authUser.Token == nil {
+		return nil, errstack.NewReq("User requires two-factor authentication")
+	}
 	authHandler := middleware.WithAuth(r.DB())
 	req := &http.Request{
 		Header: map[string][]string{
-			"Authorization": []string{"Bearer " + authUser.Token},
+			"Authorization": []string{"Bearer " + *authUser.Token},
 		}}
 	req = req.WithContext(ctx)
 	rctx := routing.Context{Request: req}
@@ -116,6 +121,48 @@ func Login(r resolver.Resolver, loginInput model.UserLoginInput) (*Credentials,
 	return creds, errstack.WrapAsInf(err)
 }
 
+// LoginWithAPIKey creates context for a request authenticated with the API key
+func LoginWithAPIKey(r resolver.Resolver, key string) (*Credentials, errstack.E) {
+	req := &http.Request{
+		Header: map[string][]string{
+			"Authorization": []string{"Bearer " + key},
+		}}
+	req = req.WithContext(context.Background())
+	rctx := routing.Context{Request: req}
+	if err := middleware.WithAuth(r.DB())(&rctx); err != nil {
+		return nil, errstack.WrapAsInf(err)
+	}
+	ctx := rctx.Request.Context()
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errstack.WrapAsReq(errs, "Invalid API key")
+	}
+	return &Credentials{u, ctx}, nil
+}
+
+// EnableTOTP enables two-factor authentication of the logged in user and verifies it in
+// the current session, as the approvals guarded by TOTP require. The returned function
+// disables it again, so the user can log in with the password only.
+func EnableTOTP(r resolver.Resolver, u *Credentials) (func() error, error) {
+	mr := r.Mutation()
+	enrollment, err := mr.UserTOTPEnroll(u.Ctx)
+	if err != nil {
+		return nil, err
+	}
+	code, errs := totp.Code(enrollment.Secret, totp.Counter(time.Now()))
+	if errs != nil {
+		return nil, errs
+	}
+	recovery, err := mr.UserTOTPEnable(u.Ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := mr.UserTOTPDisable(u.Ctx, recovery[0])
+		return err
+	}, nil
+}
+
 // LoginTwo logs in with two users at once
 func LoginTwo(r resolver.Resolver, firstUser, secondUser model.UserLoginInput) (*Credentials, *Credentials, error) {
 	u1, err := Login(r, firstUser)
@@ -137,9 +137,9 @@ func tradeStageCloseApproval(ctx context.Context, r mutationResolver, id model.T
 	if _, errs = tradeStageCloseApprovalNotif(ctx, r.db, t, u, id, isApprove); errs != nil {
 		return nil, errs
 	}
-	// close tradeOffer if it exists in the trade
+	// close tradeOffer if it exists in the trade; accepted offers are already closed
 	if id.StageIdx == 0 && t.TradeOfferID != nil && isApprove {
-		if errs = dal.CloseTradeOffer(ctx, r.db, *t.TradeOfferID); errs != nil {
+		if errs = dal.CloseTradeOffer(ctx, r.db, *t.TradeOfferID); errs != nil && errs != model.ErrTradeOfferClosed {
 			return nil, errs
 		}
 	}
@@ -7,12 +7,15 @@ import (
 	"bitbucket.org/cerealia/apps/go-lib/middleware"
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/pubsub"
 	"bitbucket.org/cerealia/apps/go-lib/stellar/txsource"
 	driver "github.com/arangodb/go-driver"
 	"github.com/robert-zaremba/errstack"
 )
 
-func getTradeRequester(ctx context.Context, db driver.Database, tradeID string) (model.TradeActor, *model.User, *model.Trade, errstack.E) {
+// getTradeRequester returns the role of the authenticated user in the trade and checks
+// if the role is allowed to do the action
+func getTradeRequester(ctx context.Context, db driver.Database, tradeID string, a model.TradeAction) (model.TradeActor, *model.User, *model.Trade, errstack.E) {
 	reqActor := model.TradeActorB
 	u, errs := middleware.GetAuthUser(ctx)
 	if errs != nil {
@@ -27,28 +30,44 @@ func getTradeRequester(ctx context.Context, db driver.Database, tradeID string)
 		return reqActor, nil, nil, errs
 	}
 	reqActor, errs = t.Requester(u)
+	if errs == nil && !reqActor.Can(a) {
+		errs = model.ErrUnauthorized
+	}
 	return reqActor, u, t, errs
 }
 
-func getTradeParticipants(ctx context.Context, db driver.Database, bid, sid string) (model.TradeParticipants, errstack.E) {
-	ub, us, errs := dal.Get2Users(ctx, db, bid, sid)
-	if errs != nil {
-		return model.TradeParticipants{}, errs
-	}
-	buyer, errs := newTradeParticipant(ctx, db, ub)
-	if errs != nil {
-		return model.TradeParticipants{}, errs
+// getTradeParties derives the trade keys of the new trade parties: the buyer, the seller
+// and the additional participants, in this order. The keys are not reserved yet, the
+// returned users must be passed to useTradeKeys once the trade is valid.
+func getTradeParties(ctx context.Context, db driver.Database, input model.NewTradeInput) ([]model.TradeParticipant, []*model.User, errstack.E) {
+	roles := append([]model.TradeParticipantInput{
+		{UserID: input.BuyerID, Role: model.TradeActorB},
+		{UserID: input.SellerID, Role: model.TradeActorS},
+	}, input.Participants...)
+	parties := make([]model.TradeParticipant, len(roles))
+	users := make([]*model.User, len(roles))
+	for i, r := range roles {
+		u, errs := dal.GetUser(ctx, db, r.UserID)
+		if errs != nil {
+			return nil, nil, errs
+		}
+		if i >= 2 && !u.IsAccepted() {
+			return nil, nil, errstack.NewReqF("Participant '%s' is not accepted by Cerealia team", u.ID)
+		}
+		p, errs := newTradeParticipant(u)
+		if errs != nil {
+			return nil, nil, errs
+		}
+		p.Role = r.Role
+		parties[i] = *p
+		users[i] = u
 	}
-	seller, errs := newTradeParticipant(ctx, db, us)
-	return model.TradeParticipants{
-		Buyer:  *buyer,
-		Seller: *seller,
-	}, errs
+	return parties, users, nil
 }
 
 func prepareTradeStageAddReqApproval(ctx context.Context, db driver.Database, id model.TradeStagePath,
 	appendStage bool) (*model.Trade, *model.TradeStageAddReq, *model.User, error) {
-	_, u, t, errs := getTradeRequester(ctx, db, id.Tid)
+	_, u, t, errs := getTradeRequester(ctx, db, id.Tid, model.TradeActionStageApprove)
 	if errs != nil {
 		return nil, nil, nil, errs
 	}
@@ -61,14 +80,14 @@ func prepareTradeStageAddReqApproval(ctx context.Context, db driver.Database, id
 	sr.ApprovedAt = &now
 	if appendStage {
 		t.Stages = append(t.Stages,
-			model.NewTradeStage(sr.Name, sr.Description, int(id.StageIdx), sr.Owner))
+			model.NewTradeStage(sr.Name, sr.Description, int(id.StageIdx), sr.Owner, sr.DependsOn))
 	}
 	return t, sr, u, sr.CanBeApproved()
 }
 
 func prepareTradeStageReqApproval(ctx context.Context, db driver.Database, id model.TradeStagePath,
 	isDel bool) (*model.Trade, *model.ApproveReq, *model.User, error) {
-	_, u, t, errs := getTradeRequester(ctx, db, id.Tid)
+	_, u, t, errs := getTradeRequester(ctx, db, id.Tid, model.TradeActionStageApprove)
 	if errs != nil {
 		return nil, nil, nil, errs
 	}
@@ -99,7 +118,7 @@ func prepareTradeStageReqApproval(ctx context.Context, db driver.Database, id mo
 }
 
 func prepareTradeCloseReqApproval(ctx context.Context, db driver.Database, id string) (*model.Trade, *model.ApproveReq, *model.User, error) {
-	_, u, t, errs := getTradeRequester(ctx, db, id)
+	_, u, t, errs := getTradeRequester(ctx, db, id, model.TradeActionClose)
 	if errs != nil {
 		return nil, nil, nil, errs
 	}
@@ -114,7 +133,7 @@ func prepareTradeCloseReqApproval(ctx context.Context, db driver.Database, id st
 
 func prepareTradeStageDocApproval(ctx context.Context, db driver.Database,
 	id model.TradeStageDocPath) (*model.Trade, *model.TradeStageDoc, *model.User, errstack.E) {
-	_, u, t, errs := getTradeRequester(ctx, db, id.Tid)
+	_, u, t, errs := getTradeRequester(ctx, db, id.Tid, model.TradeActionDocApprove)
 	if errs != nil {
 		return nil, nil, nil, errs
 	}
@@ -131,6 +150,9 @@ func prepareTradeStageDocApproval(ctx context.Context, db driver.Database,
 	if s.IsDeletedOrClosed() {
 		return nil, nil, nil, errChangeStage
 	}
+	if errs = t.AssertPrerequisitesDone(id.StageIdx); errs != nil {
+		return nil, nil, nil, errs
+	}
 	doc, errs := dal.GetDoc(ctx, db, d.DocID)
 	if errs != nil {
 		return nil, nil, nil, errs
@@ -149,9 +171,9 @@ func dealCreateStageApproval(t *model.Trade, sr *model.TradeStageAddReq, u *mode
 		return model.ApprovalPending
 	}
 	sr.ApproveReq.Status = model.ApprovalNil
-	s := model.NewTradeStage(sr.Name, sr.Description, len(t.StageAddReqs), sr.Owner)
+	s := model.NewTradeStage(sr.Name, sr.Description, len(t.StageAddReqs), sr.Owner, sr.DependsOn)
 	now := time.Now().UTC()
-	if t.Buyer.UserID != u.ID && t.Seller.UserID != u.ID && u.IsModerator() {
+	if !t.IsParticipant(u) && u.Can(model.PermissionTradeModerate) {
 		s.Moderator = model.StageModerator{
 			UserID:    u.ID,
 			CreatedAt: &now,
@@ -176,6 +198,9 @@ func validateOpTypeAndGetTrade(ctx context.Context, db driver.Database, ld txsou
 	if err = t.CanBeModifiedBy(u); err != nil {
 		return nil, nil, err
 	}
+	if err = middleware.AssertAPIKeyTrade(ctx, t); err != nil {
+		return nil, nil, err
+	}
 	sourceAcc, errAcq := ld.Acquire(ctx, t.SCAddr, t.ID, u.ID)
 	return t, sourceAcc, errstack.WrapAsReq(errAcq, "Couldn't acquire trade's sourceAcc")
 }
@@ -195,5 +220,9 @@ func updateTrade(ctx context.Context, db driver.Database, t *model.Trade) (drive
 	if t.CheckTradeClosed() {
 		return driver.DocumentMeta{}, errstack.NewReq("You can't modify closed trade")
 	}
-	return dal.UpdateTrade(ctx, db, t)
+	meta, errs := dal.UpdateTrade(ctx, db, t)
+	if errs == nil {
+		pubsub.Default.PublishTrade(t)
+	}
+	return meta, errs
 }
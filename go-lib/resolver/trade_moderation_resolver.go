@@ -0,0 +1,162 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/pubsub"
+	"bitbucket.org/cerealia/apps/go-lib/utils"
+	"github.com/robert-zaremba/errstack"
+	bat "github.com/robert-zaremba/go-bat"
+)
+
+// AdminModerationQueue returns open trades with pending approvals
+func (r queryResolver) AdminModerationQueue(ctx context.Context) ([]model.ModerationQueueItem, error) {
+	return dal.GetModerationQueue(ctx, r.db)
+}
+
+// TradeModerationClaim assigns the trade to the current moderator
+func (r mutationResolver) TradeModerationClaim(ctx context.Context, id string, takeOver *bool) (*model.Trade, error) {
+	u, errs := getTemplateManager(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	t, errs := dal.GetTrade(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	prev, errs := t.ClaimModeration(u.ID, time.Now().UTC(), takeOver != nil && *takeOver)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = r.updateTradeModerator(ctx, t, prev); errs != nil {
+		return nil, errs
+	}
+	if prev == u.ID {
+		return t, nil
+	}
+	msg := fmt.Sprintf("Trade '%s' has been claimed for moderation by %s %s", t.Name, u.FirstName, u.LastName)
+	if prev != "" {
+		msg = fmt.Sprintf("Moderation of trade '%s' has been taken over by %s %s", t.Name, u.FirstName, u.LastName)
+	}
+	_, errs = tradeModerationNotif(ctx, r.db, t, u, t.FullID2()+"/", msg, model.ApprovalSubmitted)
+	return t, errs
+}
+
+// TradeModerationRelease removes the claim of the current moderator
+func (r mutationResolver) TradeModerationRelease(ctx context.Context, id string) (*int, error) {
+	u, errs := getTemplateManager(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	t, errs := dal.GetTrade(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = t.ReleaseModeration(u.ID); errs != nil {
+		return nil, errs
+	}
+	if errs = r.updateTradeModerator(ctx, t, u.ID); errs != nil {
+		return nil, errs
+	}
+	msg := fmt.Sprintf("Trade '%s' has been released from moderation by %s %s", t.Name, u.FirstName, u.LastName)
+	_, errs = tradeModerationNotif(ctx, r.db, t, u, t.FullID2()+"/", msg, model.ApprovalSubmitted)
+	return nil, errs
+}
+
+// TradeModerationDone marks the moderation of the trade done
+func (r mutationResolver) TradeModerationDone(ctx context.Context, id string) (*int, error) {
+	u, errs := getTemplateManager(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	t, errs := dal.GetTrade(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = t.FinishModeration(u.ID); errs != nil {
+		return nil, errs
+	}
+	if errs = r.updateTradeModerator(ctx, t, u.ID); errs != nil {
+		return nil, errs
+	}
+	msg := fmt.Sprintf("Moderation of trade '%s' has been marked done by %s %s", t.Name, u.FirstName, u.LastName)
+	_, errs = tradeModerationNotif(ctx, r.db, t, u, t.FullID2()+"/", msg, model.ApprovalApproved)
+	return nil, errs
+}
+
+// TradeStageModerationClaim assigns the trade stage to the current moderator
+func (r mutationResolver) TradeStageModerationClaim(ctx context.Context, id model.TradeStagePath, takeOver *bool) (*model.TradeStage, error) {
+	u, errs := getTemplateManager(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	t, errs := dal.GetTrade(ctx, r.db, id.Tid)
+	if errs != nil {
+		return nil, errs
+	}
+	prev, errs := t.ClaimStageModeration(id.StageIdx, u.ID, time.Now().UTC(), takeOver != nil && *takeOver)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = r.updateStageModerator(ctx, t, id.StageIdx, prev); errs != nil {
+		return nil, errs
+	}
+	s := &t.Stages[id.StageIdx]
+	if prev == u.ID {
+		return s, nil
+	}
+	msg := fmt.Sprintf("Stage '%s' of trade '%s' has been claimed for moderation by %s %s", s.Name, t.Name, u.FirstName, u.LastName)
+	if prev != "" {
+		msg = fmt.Sprintf("Moderation of stage '%s' of trade '%s' has been taken over by %s %s", s.Name, t.Name, u.FirstName, u.LastName)
+	}
+	_, errs = tradeModerationNotif(ctx, r.db, t, u, stageEntityID(t, id), msg, model.ApprovalSubmitted)
+	return s, errs
+}
+
+// TradeStageModerationRelease removes the stage claim of the current moderator
+func (r mutationResolver) TradeStageModerationRelease(ctx context.Context, id model.TradeStagePath) (*int, error) {
+	u, errs := getTemplateManager(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	t, errs := dal.GetTrade(ctx, r.db, id.Tid)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = t.ReleaseStageModeration(id.StageIdx, u.ID); errs != nil {
+		return nil, errs
+	}
+	if errs = r.updateStageModerator(ctx, t, id.StageIdx, u.ID); errs != nil {
+		return nil, errs
+	}
+	msg := fmt.Sprintf("Stage '%s' of trade '%s' has been released from moderation by %s %s",
+		t.Stages[id.StageIdx].Name, t.Name, u.FirstName, u.LastName)
+	_, errs = tradeModerationNotif(ctx, r.db, t, u, stageEntityID(t, id), msg, model.ApprovalSubmitted)
+	return nil, errs
+}
+
+// updateTradeModerator saves the trade moderator unless another moderator changed it
+// since the trade was read
+func (r mutationResolver) updateTradeModerator(ctx context.Context, t *model.Trade, prevUID string) errstack.E {
+	if errs := dal.UpdateTradeModerator(ctx, r.db, t, prevUID); errs != nil {
+		return errs
+	}
+	pubsub.Default.PublishTrade(t)
+	return nil
+}
+
+func (r mutationResolver) updateStageModerator(ctx context.Context, t *model.Trade, idx uint, prevUID string) errstack.E {
+	if errs := dal.UpdateStageModerator(ctx, r.db, t, idx, prevUID); errs != nil {
+		return errs
+	}
+	pubsub.Default.PublishTrade(t)
+	return nil
+}
+
+func stageEntityID(t *model.Trade, id model.TradeStagePath) string {
+	return bat.StrJoin("/", t.FullID2(), "stages:"+utils.UintToString(id.StageIdx))
+}
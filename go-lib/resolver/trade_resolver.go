@@ -24,6 +24,12 @@ func (r tradeResolver) Seller(ctx context.Context, obj *model.Trade) (*model.Use
 	return dal.GetUser(ctx, r.db, obj.Seller.UserID)
 }
 
+type tradeParticipantResolver struct{ *resolver }
+
+func (r tradeParticipantResolver) User(ctx context.Context, obj *model.TradeParticipant) (*model.User, error) {
+	return dal.GetUser(ctx, r.db, obj.UserID)
+}
+
 func (r tradeResolver) CreatedBy(ctx context.Context, obj *model.Trade) (*model.User, error) {
 	return dal.GetUser(ctx, r.db, obj.CreatedBy)
 }
@@ -68,6 +74,10 @@ func (r tradeStageDocResolver) Doc(ctx context.Context, obj *model.TradeStageDoc
 	return d, model.ResetIfErrNoID(errs)
 }
 
+func (r tradeStageDocResolver) Version(ctx context.Context, obj *model.TradeStageDoc) (int, error) {
+	return obj.VersionNum(), nil
+}
+
 type stageModeratorResolver struct{ *resolver }
 
 func (r stageModeratorResolver) User(ctx context.Context, obj *model.StageModerator) (*model.User, error) {
@@ -75,7 +85,7 @@ func (r stageModeratorResolver) User(ctx context.Context, obj *model.StageModera
 	if err != nil {
 		return nil, err
 	}
-	if !u.IsModerator() || obj == nil {
+	if !u.Can(model.PermissionTradeReadAll) || obj == nil {
 		return nil, nil
 	}
 	foundUser, errs := dal.GetUser(ctx, r.db, obj.UserID)
@@ -0,0 +1,122 @@
+package resolver
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"github.com/robert-zaremba/errstack"
+)
+
+type tradeTemplateResolver struct{ *resolver }
+
+func (r tradeTemplateResolver) FamilyID(ctx context.Context, obj *model.TradeTemplate) (string, error) {
+	return obj.Family(), nil
+}
+
+func (r tradeTemplateResolver) Version(ctx context.Context, obj *model.TradeTemplate) (int, error) {
+	return obj.VersionNum(), nil
+}
+
+func (r tradeTemplateResolver) CreatedBy(ctx context.Context, obj *model.TradeTemplate) (*model.User, error) {
+	if obj.CreatedBy == "" {
+		return nil, nil
+	}
+	return dal.GetUser(ctx, r.db, obj.CreatedBy)
+}
+
+// TradeTemplateVersions returns all versions of the template family
+func (r queryResolver) TradeTemplateVersions(ctx context.Context, id string) ([]model.TradeTemplate, error) {
+	tt, errs := dal.GetTradeTempate(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	return dal.GetTradeTemplateVersions(ctx, r.db, tt.Family())
+}
+
+// TradeTemplateCreate creates the first version of a trade template
+func (r mutationResolver) TradeTemplateCreate(ctx context.Context, input model.TradeTemplateInput) (*model.TradeTemplate, error) {
+	u, errs := getTemplateManager(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	tt := newTradeTemplate(u, input)
+	if errs = r.validateTemplate(ctx, tt); errs != nil {
+		return nil, errs
+	}
+	tt.Version = 1
+	return &tt, dal.InsertTradeTemplateVersion(ctx, r.db, nil, &tt)
+}
+
+// TradeTemplateUpdate creates a new version of the active template
+func (r mutationResolver) TradeTemplateUpdate(ctx context.Context, id string, input model.TradeTemplateInput) (*model.TradeTemplate, error) {
+	u, errs := getTemplateManager(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	prev, errs := dal.GetTradeTempate(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	if !prev.IsActive() {
+		return nil, model.ErrTemplateNotActive
+	}
+	tt := newTradeTemplate(u, input)
+	tt.FamilyID = prev.Family()
+	if errs = r.validateTemplate(ctx, tt); errs != nil {
+		return nil, errs
+	}
+	tt.Version = prev.VersionNum() + 1
+	return &tt, dal.InsertTradeTemplateVersion(ctx, r.db, prev, &tt)
+}
+
+// TradeTemplateArchive archives the template family
+func (r mutationResolver) TradeTemplateArchive(ctx context.Context, id string) (*int, error) {
+	if _, errs := getTemplateManager(ctx); errs != nil {
+		return nil, errs
+	}
+	tt, errs := dal.GetTradeTempate(ctx, r.db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	return nil, dal.ArchiveTradeTemplate(ctx, r.db, tt.Family())
+}
+
+func getTemplateManager(ctx context.Context) (*model.User, errstack.E) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.Can(model.PermissionTradeModerate) {
+		return nil, model.ErrUnauthorized
+	}
+	return u, nil
+}
+
+func newTradeTemplate(u *model.User, input model.TradeTemplateInput) model.TradeTemplate {
+	now := time.Now().UTC()
+	return model.TradeTemplate{
+		Name:        strings.TrimSpace(input.Name),
+		Description: input.Description,
+		Stages:      input.Stages,
+		CreatedBy:   u.ID,
+		CreatedAt:   &now,
+	}
+}
+
+func (r mutationResolver) validateTemplate(ctx context.Context, tt model.TradeTemplate) errstack.E {
+	if errs := tt.Validate(); errs != nil {
+		return errs
+	}
+	taken, errs := dal.IsTradeTemplateNameTaken(ctx, r.db, tt.Name, tt.FamilyID)
+	if errs != nil {
+		return errs
+	}
+	if taken {
+		return model.ErrTemplateNameTaken
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package resolver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/model/dbconst"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+)
+
+// findTradeOfferMatches returns the ranked opposite side offers matching the offer
+func findTradeOfferMatches(ctx context.Context, db driver.Database, tof *model.TradeOffer) ([]model.TradeOfferMatch, errstack.E) {
+	candidates, errs := dal.GetTradeOfferMatchCandidates(ctx, db, tof)
+	if errs != nil {
+		return nil, errs
+	}
+	return model.RankTradeOfferMatches(tof, candidates, time.Now().UTC()), nil
+}
+
+// tradeOfferMatchNotifs notifies the creator of the new offer about the matches
+// and the creators of the matched offers about the new offer.
+func tradeOfferMatchNotifs(ctx context.Context, db driver.Database, tof *model.TradeOffer, matches []model.TradeOfferMatch) errstack.E {
+	if len(matches) == 0 {
+		return nil
+	}
+	n := mkTradeOfferNotification(tof, tof.CreatedBy)
+	n.Msg = fmt.Sprintf("Your %s offer of %s matches %d open offers", offerSide(tof), tof.Commodity, len(matches))
+	if errs := notify.Deliver(ctx, db, n); errs != nil {
+		return errs
+	}
+	receivers := []string{}
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if !seen[m.Offer.CreatedBy] {
+			seen[m.Offer.CreatedBy] = true
+			receivers = append(receivers, m.Offer.CreatedBy)
+		}
+	}
+	n = mkTradeOfferNotification(tof, receivers...)
+	n.Msg = fmt.Sprintf("New %s offer of %s matches your offer", offerSide(tof), tof.Commodity)
+	return notify.Deliver(ctx, db, n)
+}
+
+func mkTradeOfferNotification(tof *model.TradeOffer, receiver ...string) *model.Notification {
+	return &model.Notification{
+		CreatedAt:   time.Now().UTC(),
+		Receiver:    receiver,
+		TriggeredBy: tof.CreatedBy,
+		Type:        model.NotifTypeAlert,
+		Dismissed:   []string{},
+		EntityID:    tof.FullID2(),
+		Action:      model.ApprovalSubmitted,
+	}
+}
+
+func offerSide(tof *model.TradeOffer) string {
+	if tof.IsSell {
+		return "sell"
+	}
+	return "buy"
+}
+
+// offerTermsApproveTTL is the time the trade parties have to approve the offer terms
+const offerTermsApproveTTL = 7 * 24 * time.Hour
+
+// addOfferTerms adds the offer terms document to the first stage of a new trade. The
+// terms wait for an approval like any other stage document.
+func addOfferTerms(t *model.Trade, docID string) errstack.E {
+	if len(t.Stages) == 0 {
+		return errstack.NewReq("Trade template has no stages to attach the offer terms to")
+	}
+	t.Stages[0].Docs = append(t.Stages[0].Docs, model.TradeStageDoc{
+		DocID:     docID,
+		Status:    model.ApprovalPending,
+		ExpiresAt: time.Now().UTC().Add(offerTermsApproveTTL),
+	})
+	return nil
+}
+
+// insertOfferTermsEdge links the offer terms document added by addOfferTerms with the
+// stored trade
+func insertOfferTermsEdge(ctx context.Context, db driver.Database, t *model.Trade, docID string) errstack.E {
+	stage := t.Stages[0]
+	edge := model.TradeDocEdgeDO{
+		FullDocID:   dbconst.ColDocs.FullID(docID),
+		FullTradeID: dbconst.ColTrades.FullID(t.ID),
+		StageIdx:    0,
+		StageDocIdx: uint(len(stage.Docs) - 1),
+	}
+	_, errs := dal.InsertAny(ctx, dbconst.ColDocEdges, &edge, db)
+	return errs
+}
+
+// tradeOfferBidNotif notifies the other side of the negotiation thread about a new bid
+func tradeOfferBidNotif(ctx context.Context, db driver.Database, tof *model.TradeOffer, b *model.TradeOfferBid) errstack.E {
+	n := mkTradeOfferBidNotification(tof, b, b.CreatedBy, b.Recipient(tof))
+	n.Action = model.ApprovalPending
+	if b.CreatedBy == b.Bidder {
+		n.Msg = fmt.Sprintf("New bid on your offer of %s", tof.Commodity)
+	} else {
+		n.Msg = fmt.Sprintf("Counter-offer on your bid for %s", tof.Commodity)
+	}
+	return notify.Deliver(ctx, db, n)
+}
+
+// tradeOfferBidResponseNotif notifies the bid creator that the bid was accepted or rejected
+func tradeOfferBidResponseNotif(ctx context.Context, db driver.Database, tof *model.TradeOffer, b *model.TradeOfferBid) errstack.E {
+	n := mkTradeOfferBidNotification(tof, b, b.Recipient(tof), b.CreatedBy)
+	n.Action = b.Status
+	verb := "accepted"
+	if b.Status == model.ApprovalRejected {
+		verb = "rejected"
+	}
+	n.Msg = fmt.Sprintf("Your bid for %s has been %s", tof.Commodity, verb)
+	return notify.Deliver(ctx, db, n)
+}
+
+// mkTradeOfferBidNotification creates a bid notification; the creator of an anonymous offer is not revealed
+func mkTradeOfferBidNotification(tof *model.TradeOffer, b *model.TradeOfferBid, triggeredBy, receiver string) *model.Notification {
+	if tof.IsAnonymous && triggeredBy == tof.CreatedBy {
+		triggeredBy = ""
+	}
+	return &model.Notification{
+		CreatedAt:   time.Now().UTC(),
+		Receiver:    []string{receiver},
+		TriggeredBy: triggeredBy,
+		Type:        model.NotifTypeAction,
+		Dismissed:   []string{},
+		EntityID:    tof.FullID2() + "/bids:" + b.ID,
+	}
+}
+
+func respondTradeOfferBid(ctx context.Context, db driver.Database, id, reason string, isAccept bool) (*model.TradeOfferBid, errstack.E) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	b, errs := dal.GetTradeOfferBid(ctx, db, id)
+	if errs != nil {
+		return nil, errs
+	}
+	tof, errs := dal.GetTradeOffer(ctx, db, b.OfferID)
+	if errs != nil {
+		return nil, errs
+	}
+	if errs = b.CanBeRespondedBy(u, tof); errs != nil {
+		return nil, errs
+	}
+	if isAccept {
+		if errs = dal.SetTradeOfferAcceptedBid(ctx, db, tof.ID, b.ID); errs != nil {
+			return nil, errs
+		}
+	}
+	b.Respond(isAccept, reason, time.Now().UTC())
+	if errs = dal.RespondTradeOfferBid(ctx, db, b); errs != nil {
+		if isAccept {
+			// the bid was countered or rejected in the meantime
+			errstack.Log(logger, dal.ResetTradeOfferAcceptedBid(ctx, db, tof.ID, b.ID))
+		}
+		return nil, errs
+	}
+	return b, tradeOfferBidResponseNotif(ctx, db, tof, b)
+}
@@ -3,6 +3,7 @@ package resolver
 import (
 	"context"
 
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
 )
@@ -10,6 +11,13 @@ import (
 type tradeOfferRes struct{ *resolver }
 
 func (r tradeOfferRes) CreatedBy(ctx context.Context, obj *model.TradeOffer) (*model.User, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	if obj.IsAnonymousTo(u) {
+		return nil, nil
+	}
 	return dal.GetUser(ctx, r.db, obj.CreatedBy)
 }
 
@@ -26,3 +34,43 @@ func (r tradeOfferRes) Terms(ctx context.Context, obj *model.TradeOffer) (*model
 	}
 	return dal.GetDoc(ctx, r.db, *obj.DocID)
 }
+
+func (r tradeOfferRes) AcceptedBid(ctx context.Context, obj *model.TradeOffer) (*model.TradeOfferBid, error) {
+	if obj.AcceptedBid == nil {
+		return nil, nil
+	}
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	b, errs := dal.GetTradeOfferBid(ctx, r.db, *obj.AcceptedBid)
+	if errs != nil || !b.IsVisibleTo(u, obj) {
+		return nil, errs
+	}
+	return b, nil
+}
+
+type tradeOfferBidRes struct{ *resolver }
+
+func (r tradeOfferBidRes) Offer(ctx context.Context, obj *model.TradeOfferBid) (*model.TradeOffer, error) {
+	return dal.GetTradeOffer(ctx, r.db, obj.OfferID)
+}
+
+func (r tradeOfferBidRes) Bidder(ctx context.Context, obj *model.TradeOfferBid) (*model.User, error) {
+	return dal.GetUser(ctx, r.db, obj.Bidder)
+}
+
+func (r tradeOfferBidRes) CreatedBy(ctx context.Context, obj *model.TradeOfferBid) (*model.User, error) {
+	u, errs := middleware.GetAuthUser(ctx)
+	if errs != nil {
+		return nil, errs
+	}
+	tof, errs := dal.GetTradeOffer(ctx, r.db, obj.OfferID)
+	if errs != nil {
+		return nil, errs
+	}
+	if obj.IsAnonymousTo(u, tof) {
+		return nil, nil
+	}
+	return dal.GetUser(ctx, r.db, obj.CreatedBy)
+}
@@ -2,24 +2,186 @@ package resolver
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
 
+	"bitbucket.org/cerealia/apps/go-lib/auth"
+	"bitbucket.org/cerealia/apps/go-lib/middleware"
 	"bitbucket.org/cerealia/apps/go-lib/model"
 	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	"bitbucket.org/cerealia/apps/go-lib/notify"
+	"bitbucket.org/cerealia/apps/go-lib/stellar/webauth"
 	driver "github.com/arangodb/go-driver"
+	"github.com/google/uuid"
 	"github.com/robert-zaremba/errstack"
 )
 
-func newTradeParticipant(ctx context.Context, db driver.Database, u *model.User) (*model.TradeParticipant, errstack.E) {
-	wallet, err := u.DefaultWallet()
+// newUserSession starts a new login session and returns its access and refresh tokens.
+// mfaVerified marks the session as verified with a TOTP code.
+func newUserSession(ctx context.Context, db driver.Database, u *model.User, mfaVerified bool) (*model.AuthUser, errstack.E) {
+	sid, err := uuid.NewRandom()
 	if err != nil {
-		return nil, errstack.WrapAsDomain(err, "Default wallet not found")
+		return nil, errstack.WrapAsInf(err, "Can't generate session ID")
 	}
-	derivedKey, derivationPath, err := wallet.DeriveNewKey()
+	refreshToken, hash, errs := auth.NewRefreshToken(sid.String())
+	if errs != nil {
+		return nil, errs
+	}
+	now := time.Now().UTC()
+	s := model.UserSession{
+		ID:          sid.String(),
+		UserID:      u.ID,
+		TokenHash:   hash,
+		CreatedAt:   now,
+		RefreshedAt: now,
+		ExpiresAt:   now.Add(auth.RefreshExpireTime),
+	}
+	if mfaVerified {
+		s.MFAVerifiedAt = &now
+	}
+	if errs = dal.InsertUserSession(ctx, db, &s); errs != nil {
+		return nil, errs
+	}
+	token, errs := auth.CreateJWT(u.ID, s.ID)
+	if errs != nil {
+		return nil, errs
+	}
+	return &model.AuthUser{ID: u.ID, Token: &token, RefreshToken: &refreshToken}, nil
+}
+
+// startLogin returns a new session of the authenticated user. Users with two-factor
+// authentication get only a token to finish the login with a TOTP code. The token is
+// stored to limit the number of codes tried with it.
+func startLogin(ctx context.Context, db driver.Database, u *model.User) (*model.AuthUser, errstack.E) {
+	if !u.HasTOTP() {
+		return newUserSession(ctx, db, u, false)
+	}
+	id, err := uuid.NewRandom()
 	if err != nil {
-		return nil, errstack.WrapAsDomain(err, "Can't create a new derivation param")
+		return nil, errstack.WrapAsInf(err, "Can't generate token ID")
+	}
+	now := time.Now().UTC()
+	t := model.UserToken{
+		ID:        id.String(),
+		UserID:    u.ID,
+		Purpose:   model.UserTokenMFALogin,
+		CreatedAt: now,
+		ExpiresAt: now.Add(model.UserTokenMFALogin.TTL()),
+	}
+	if errs := dal.InsertUserToken(ctx, db, &t); errs != nil {
+		return nil, errs
+	}
+	mfaToken, errs := auth.CreateMFAToken(u.ID, t.ID)
+	if errs != nil {
+		return nil, errs
+	}
+	return &model.AuthUser{ID: u.ID, MfaToken: &mfaToken}, nil
+}
+
+// newKeyLoginToken creates a single use nonce of the Stellar key login challenge.
+// It's stored only when the user is known, so the challenge doesn't reveal if the
+// key is registered. The ID is short to fit the token into a Stellar data entry.
+func newKeyLoginToken(ctx context.Context, db driver.Database, u *model.User, now time.Time) (string, errstack.E) {
+	rawID := make([]byte, 9)
+	if _, err := rand.Read(rawID); err != nil {
+		return "", errstack.WrapAsInf(err, "Can't generate token ID")
 	}
-	if err = dal.ReplaceUser(ctx, db, u); err != nil {
-		return nil, errstack.WrapAsInf(err, "Can't update user's data")
+	id := base64.RawURLEncoding.EncodeToString(rawID)
+	token, hash, errs := auth.NewOpaqueToken(id)
+	if errs != nil || u == nil {
+		return token, errs
+	}
+	t := model.UserToken{
+		ID:        id,
+		UserID:    u.ID,
+		Purpose:   model.UserTokenKeyLogin,
+		TokenHash: hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(model.UserTokenKeyLogin.TTL()),
+	}
+	return token, dal.InsertUserToken(ctx, db, &t)
+}
+
+// requireTOTP checks that the user enabled two-factor authentication and verified
+// a TOTP code in the current session recently. It guards the approvals with the
+// biggest impact.
+func requireTOTP(ctx context.Context, db driver.Database, u *model.User) errstack.E {
+	if !u.HasTOTP() {
+		return model.ErrTOTPNotEnabled
+	}
+	return requireFreshTOTP(ctx, db, u)
+}
+
+// requireFreshTOTP checks that the user verified a TOTP code in the current session
+// recently. It's required from users with two-factor authentication and from members
+// of organizations which require it.
+func requireFreshTOTP(ctx context.Context, db driver.Database, u *model.User) errstack.E {
+	if !u.HasTOTP() {
+		required, errs := dal.IsTOTPRequired(ctx, db, u)
+		if errs != nil || !required {
+			return errs
+		}
+		return errstack.NewReq("Your organization requires two-factor authentication, please enable it")
+	}
+	s, errs := dal.GetUserSession(ctx, db, middleware.GetAuthSessionID(ctx))
+	if errs != nil {
+		return errs
+	}
+	if !s.IsMFAFresh(time.Now()) {
+		return model.ErrTOTPRequired
+	}
+	return nil
+}
+
+// sendUserToken emails a new token to the user. Previous tokens with the same purpose
+// sent to the email are invalidated.
+func sendUserToken(ctx context.Context, db driver.Database, u *model.User, purpose model.UserTokenPurpose, email string) errstack.E {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return errstack.WrapAsInf(err, "Can't generate token ID")
+	}
+	token, hash, errs := auth.NewOpaqueToken(id.String())
+	if errs != nil {
+		return errs
+	}
+	if errs = dal.InvalidateUserTokens(ctx, db, u.ID, purpose, email); errs != nil {
+		return errs
+	}
+	now := time.Now().UTC()
+	t := model.UserToken{
+		ID:        id.String(),
+		UserID:    u.ID,
+		Purpose:   purpose,
+		Email:     email,
+		TokenHash: hash,
+		CreatedAt: now,
+		ExpiresAt: now.Add(purpose.TTL()),
+	}
+	if errs = dal.InsertUserToken(ctx, db, &t); errs != nil {
+		return errs
+	}
+	if purpose == model.UserTokenPasswordReset {
+		return notify.Default.SendPasswordReset(u, email, token)
+	}
+	return notify.Default.SendEmailVerification(u, email, token)
+}
+
+// useUserToken checks the emailed token and marks it as used
+func useUserToken(ctx context.Context, db driver.Database, token string, purpose model.UserTokenPurpose) (*model.UserToken, errstack.E) {
+	id, hash, errs := auth.ParseOpaqueToken(token)
+	if errs != nil {
+		return nil, model.ErrInvalidToken
+	}
+	return dal.UseUserToken(ctx, db, id, hash, purpose)
+}
+
+// newTradeParticipant derives a new trade key of the user. The key is only derived in
+// memory, it must be reserved with useTradeKeys before the trade is stored.
+func newTradeParticipant(u *model.User) (*model.TradeParticipant, errstack.E) {
+	derivedKey, derivationPath, err := u.DeriveNewKey()
+	if err != nil {
+		return nil, errstack.WrapAsDomain(err, "Can't create a new derivation param")
 	}
 	return &model.TradeParticipant{
 		UserID:            u.ID,
@@ -28,3 +190,42 @@ func newTradeParticipant(ctx context.Context, db driver.Database, u *model.User)
 		PubKey:            derivedKey,
 	}, nil
 }
+
+// useTradeKeys saves the HD wallet keys derived by newTradeParticipant as used
+func useTradeKeys(ctx context.Context, db driver.Database, users []*model.User) errstack.E {
+	for _, u := range users {
+		w, ok := u.HDCerealiaWallets[u.DefaultWalletID]
+		if !ok {
+			continue
+		}
+		if errs := dal.UseHDWalletKey(ctx, db, u.ID, u.DefaultWalletID, w.DerivationIndex-1); errs != nil {
+			return errs
+		}
+	}
+	return nil
+}
+
+// verifyKeyLogin returns the user who signed the challenge with a static wallet key
+func verifyKeyLogin(ctx context.Context, db driver.Database, signedTx string) (*model.User, errstack.E) {
+	account, nonce, errs := webauth.Default.Verify(signedTx, time.Now())
+	if errs != nil {
+		return nil, errs
+	}
+	t, errs := useUserToken(ctx, db, nonce, model.UserTokenKeyLogin)
+	if errs == model.ErrInvalidToken {
+		return nil, webauth.ErrInvalidChallenge
+	} else if errs != nil {
+		return nil, errs
+	}
+	u, errs := dal.GetUser(ctx, db, t.UserID)
+	if errs != nil {
+		return nil, errs
+	}
+	if !u.HasStaticWalletKey(account) {
+		return nil, webauth.ErrInvalidChallenge
+	}
+	if !u.IsAccepted() {
+		return u, model.ErrUserNotAccepted
+	}
+	return u, nil
+}
@@ -15,6 +15,11 @@ func (r userResolver) OrgMap(ctx context.Context, u *model.User) ([]model.UserOr
 	return dal.GetOrgMap(ctx, r.db, u.ID)
 }
 
+// TotpEnabled resolves totpEnabled field
+func (r userResolver) TotpEnabled(ctx context.Context, u *model.User) (bool, error) {
+	return u.HasTOTP(), nil
+}
+
 // PubKey returns user default public key
 func (r userResolver) PubKey(ctx context.Context, u *model.User) (*string, error) {
 	// TODO: this endpoint does not include tradeID. It is deprecated.
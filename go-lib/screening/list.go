@@ -0,0 +1,74 @@
+package screening
+
+import (
+	"encoding/csv"
+	"io"
+	"strings"
+
+	"bitbucket.org/cerealia/apps/go-lib/encoding"
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"github.com/robert-zaremba/errstack"
+)
+
+// ReadListFile reads the sanctions list from the CSV file. See ReadList for the format.
+func ReadListFile(fname, list string) ([]model.SanctionEntry, errstack.E) {
+	r, closeFn, errs := encoding.NewCSVFileReader(fname, 1)
+	if closeFn != nil {
+		defer errstack.CallAndLog(logger, closeFn)
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	return readEntries(r, list)
+}
+
+// ReadList reads the sanctions list from CSV. The first line is a header, the columns
+// are: entry reference, name and optional aliases separated by `;`.
+func ReadList(src io.Reader, list string) ([]model.SanctionEntry, errstack.E) {
+	r, errs := encoding.NewCSVReader(src, 1)
+	if errs != nil {
+		return nil, errs
+	}
+	return readEntries(r, list)
+}
+
+func readEntries(r *csv.Reader, list string) ([]model.SanctionEntry, errstack.E) {
+	// aliases are optional
+	r.FieldsPerRecord = -1
+	var es []model.SanctionEntry
+	for line := 2; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return es, nil
+		}
+		if err != nil {
+			return nil, errstack.WrapAsReqF(err, "Can't read the sanctions list record %d", line)
+		}
+		if len(rec) < 2 || strings.TrimSpace(rec[0]) == "" || strings.TrimSpace(rec[1]) == "" {
+			return nil, errstack.NewReqF("Record %d: reference and name are required", line)
+		}
+		e := model.SanctionEntry{
+			List:    list,
+			Ref:     strings.TrimSpace(rec[0]),
+			Name:    strings.TrimSpace(rec[1]),
+			Aliases: []string{},
+		}
+		if len(rec) > 2 {
+			for _, a := range strings.Split(rec[2], ";") {
+				if a = strings.TrimSpace(a); a != "" {
+					e.Aliases = append(e.Aliases, a)
+				}
+			}
+		}
+		e.NormNames = normNames(e)
+		es = append(es, e)
+	}
+}
+
+func normNames(e model.SanctionEntry) []string {
+	ns := []string{Normalize(e.Name)}
+	for _, a := range e.Aliases {
+		ns = append(ns, Normalize(a))
+	}
+	return ns
+}
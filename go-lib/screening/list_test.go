@@ -0,0 +1,31 @@
+package screening
+
+import (
+	"strings"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (s *ScreeningSuite) TestReadList(c *C) {
+	src := `ref,name,aliases
+# comment
+101,Ivan Petrov,Iwan Petroff; Ivan Petroff
+102,Black Sea Grain LLC
+103,"Doe, John",
+`
+	es, err := ReadList(strings.NewReader(src), "EU")
+	c.Assert(err, IsNil)
+	c.Assert(es, HasLen, 3)
+	c.Check(es[0].List, Equals, "EU")
+	c.Check(es[0].Ref, Equals, "101")
+	c.Check(es[0].Aliases, DeepEquals, []string{"Iwan Petroff", "Ivan Petroff"})
+	c.Check(es[0].NormNames, DeepEquals, []string{"ivan petrov", "iwan petroff", "ivan petroff"})
+	c.Check(es[1].Aliases, HasLen, 0)
+	c.Check(es[1].NormNames, DeepEquals, []string{"black grain sea"})
+	c.Check(es[2].Name, Equals, "Doe, John")
+	c.Check(es[2].Aliases, HasLen, 0)
+
+	_, err = ReadList(strings.NewReader("ref,name\n104,\n"), "EU")
+	c.Check(err, ErrorContains, "name are required")
+}
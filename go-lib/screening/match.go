@@ -0,0 +1,119 @@
+package screening
+
+import (
+	"sort"
+	"strings"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+)
+
+// DefaultThreshold is the minimum similarity of a hit
+const DefaultThreshold = 0.85
+
+// Match is a sanctions list entry matching a name
+type Match struct {
+	Entry model.SanctionEntry
+	Score float64
+}
+
+// Similarity returns the similarity of two normalized names from 0 to 1.
+// Besides the whole names it compares the words, so a name with a missing middle
+// name still matches.
+func Similarity(a, b string) float64 {
+	if a == "" || b == "" {
+		return 0
+	}
+	s := ratio(a, b)
+	if ws := wordsRatio(strings.Fields(a), strings.Fields(b)); ws > s {
+		s = ws
+	}
+	return s
+}
+
+// FindMatches returns the entries matching the name with at least the threshold
+// similarity, best match first
+func FindMatches(name string, entries []model.SanctionEntry, threshold float64) []Match {
+	norm := Normalize(name)
+	var ms []Match
+	for _, e := range entries {
+		best := 0.0
+		for _, n := range e.NormNames {
+			if s := Similarity(norm, n); s > best {
+				best = s
+			}
+		}
+		if best >= threshold {
+			ms = append(ms, Match{Entry: e, Score: best})
+		}
+	}
+	sort.SliceStable(ms, func(i, j int) bool { return ms[i].Score > ms[j].Score })
+	return ms
+}
+
+// wordsRatio matches every word of the shorter name with the most similar word of
+// the longer name. Single word names are compared only as a whole, otherwise common
+// surnames would match too many entries.
+func wordsRatio(a, b []string) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	if len(a) < 2 {
+		return 0
+	}
+	var total, weights float64
+	for _, wa := range a {
+		best := 0.0
+		for _, wb := range b {
+			if s := ratio(wa, wb); s > best {
+				best = s
+			}
+		}
+		w := float64(len(wa))
+		total += best * w
+		weights += w
+	}
+	return total / weights
+}
+
+// ratio is the Levenshtein distance of the strings scaled to the similarity from 0 to 1
+func ratio(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	max := len(ra)
+	if len(rb) > max {
+		max = len(rb)
+	}
+	if max == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(max)
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,55 @@
+package screening
+
+import (
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func (s *ScreeningSuite) TestSimilarity(c *C) {
+	var tcs = []struct {
+		a, b  string
+		match bool
+	}{
+		{"John Doe", "DOE, John", true},
+		{"Jon Doe", "John Doe", true},
+		{"John Doe", "John Michael Doe", true},
+		{"Acme Trading Ltd", "ACME TRADING LIMITED", true},
+		{"Acme Tradng", "Acme Trading", true},
+		{"John Doe", "Jane Roe", false},
+		{"Doe", "Joe", false},
+		{"Doe", "John Doe", false},
+		{"Cerealia", "Ceres Agro", false},
+		{"", "John Doe", false},
+	}
+	for _, tc := range tcs {
+		score := Similarity(Normalize(tc.a), Normalize(tc.b))
+		c.Check(score >= DefaultThreshold, Equals, tc.match, Comment(tc.a, " ~ ", tc.b, ": ", score))
+	}
+	c.Check(Similarity("doe john", "doe john"), Equals, 1.0)
+}
+
+func (s *ScreeningSuite) TestFindMatches(c *C) {
+	entries := []model.SanctionEntry{
+		{Ref: "1", Name: "Ivan Petrov", NormNames: []string{Normalize("Ivan Petrov"), Normalize("Iwan Petroff")}},
+		{Ref: "2", Name: "Black Sea Grain LLC", NormNames: []string{Normalize("Black Sea Grain LLC")}},
+		{Ref: "3", Name: "Ivana Petrova", NormNames: []string{Normalize("Ivana Petrova")}},
+	}
+	ms := FindMatches("Petrov, Ivan", entries, DefaultThreshold)
+	c.Assert(ms, HasLen, 1)
+	c.Check(ms[0].Entry.Ref, Equals, "1")
+	c.Check(ms[0].Score, Equals, 1.0)
+
+	ms = FindMatches("Ivana Petrov", entries, 0.8)
+	c.Assert(ms, HasLen, 2)
+	c.Check(ms[0].Entry.Ref, Equals, "3", Comment("best match first"))
+	c.Check(ms[1].Entry.Ref, Equals, "1")
+	c.Check(ms[0].Score > ms[1].Score, IsTrue)
+
+	ms = FindMatches("Iwan Petroff", entries, DefaultThreshold)
+	c.Assert(ms, Not(HasLen), 0)
+	c.Check(ms[0].Entry.Ref, Equals, "1", Comment("aliases are matched"))
+
+	c.Check(FindMatches("Black Sea Grain Ltd.", entries, DefaultThreshold), HasLen, 1)
+	c.Check(FindMatches("Cerealia", entries, DefaultThreshold), HasLen, 0)
+}
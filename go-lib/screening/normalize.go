@@ -0,0 +1,56 @@
+// Package screening screens users and organizations against sanctions and watch lists.
+// Lists are imported from CSV files. Names are normalized and fuzzy matched; matches
+// are stored as hits for the compliance review.
+package screening
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// legalForms are company suffixes ignored by the matching
+var legalForms = map[string]bool{
+	"ab": true, "ag": true, "as": true, "bv": true, "co": true, "company": true,
+	"corp": true, "corporation": true, "gmbh": true, "inc": true, "incorporated": true,
+	"jsc": true, "limited": true, "llc": true, "llp": true, "lp": true, "ltd": true,
+	"nv": true, "ooo": true, "oy": true, "pjsc": true, "plc": true, "pte": true,
+	"pty": true, "sa": true, "sarl": true, "spa": true, "srl": true, "zao": true,
+}
+
+// dottedAbbrev matches abbreviations written with dots, like "S.A." or "L.L.C."
+var dottedAbbrev = regexp.MustCompile(`\b(?:\pL\.)+\pL\b\.?`)
+
+var latinFolding = strings.NewReplacer(
+	"à", "a", "á", "a", "â", "a", "ã", "a", "ä", "a", "å", "a", "ą", "a", "ă", "a",
+	"æ", "ae", "ç", "c", "ć", "c", "č", "c", "ď", "d", "đ", "d",
+	"è", "e", "é", "e", "ê", "e", "ë", "e", "ę", "e", "ě", "e",
+	"ğ", "g", "ì", "i", "í", "i", "î", "i", "ï", "i", "ı", "i",
+	"ł", "l", "ñ", "n", "ń", "n", "ň", "n",
+	"ò", "o", "ó", "o", "ô", "o", "õ", "o", "ö", "o", "ø", "o", "œ", "oe",
+	"ř", "r", "ś", "s", "š", "s", "ş", "s", "ș", "s", "ß", "ss", "ť", "t", "ț", "t",
+	"ù", "u", "ú", "u", "û", "u", "ü", "u", "ů", "u",
+	"ý", "y", "ÿ", "y", "ź", "z", "ż", "z", "ž", "z",
+)
+
+// Normalize prepares the name for the matching: it lower cases the name, folds
+// Latin diacritics, joins dotted abbreviations, drops punctuation and company legal
+// forms and sorts the words, so the word order doesn't matter.
+func Normalize(name string) string {
+	name = latinFolding.Replace(strings.ToLower(name))
+	name = dottedAbbrev.ReplaceAllStringFunc(name, func(abbrev string) string {
+		return strings.Replace(abbrev, ".", "", -1)
+	})
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	kept := words[:0]
+	for _, w := range words {
+		if !legalForms[w] {
+			kept = append(kept, w)
+		}
+	}
+	sort.Strings(kept)
+	return strings.Join(kept, " ")
+}
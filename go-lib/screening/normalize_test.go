@@ -0,0 +1,36 @@
+package screening
+
+import (
+	"testing"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ScreeningSuite struct{}
+
+var _ = Suite(&ScreeningSuite{})
+
+func (s *ScreeningSuite) TestNormalize(c *C) {
+	var tcs = []struct {
+		name string
+		norm string
+	}{
+		{"John Doe", "doe john"},
+		{"DOE, John", "doe john"},
+		{"  Jöhn   Dœ ", "doe john"},
+		{"Müller-Lüdenscheidt GmbH", "ludenscheidt muller"},
+		{"Acme Trading Co., Ltd.", "acme trading"},
+		{"Société Générale S.A.", "generale societe"},
+		{"Acme L.L.C", "acme"},
+		{"N.V. Philips", "philips"},
+		{"J.R.R. Tolkien", "jrr tolkien"},
+		{"Ltd", ""},
+		{"", ""},
+	}
+	for _, tc := range tcs {
+		c.Check(Normalize(tc.name), Equals, tc.norm, Comment(tc.name))
+	}
+}
@@ -0,0 +1,108 @@
+package screening
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/model"
+	"bitbucket.org/cerealia/apps/go-lib/model/dal"
+	driver "github.com/arangodb/go-driver"
+	"github.com/robert-zaremba/errstack"
+	"github.com/robert-zaremba/log15"
+)
+
+var logger = log15.Root()
+
+// Subject is a screened user or organization
+type Subject struct {
+	Kind model.ScreeningSubject
+	ID   string
+	Name string
+}
+
+// UserSubject returns the screening subject of the user
+func UserSubject(u *model.User) Subject {
+	return Subject{model.ScreeningSubjectUser, u.ID, strings.TrimSpace(u.FirstName + " " + u.LastName)}
+}
+
+// OrgSubject returns the screening subject of the organization
+func OrgSubject(o *model.Organization) Subject {
+	return Subject{model.ScreeningSubjectOrganization, o.ID, o.Name}
+}
+
+// Screen matches the subjects with all imported sanctions lists and stores new hits
+// for the review. Returns the number of new hits.
+func Screen(ctx context.Context, db driver.Database, subjects ...Subject) (int, errstack.E) {
+	entries, errs := dal.GetSanctionEntries(ctx, db)
+	if errs != nil {
+		return 0, errs
+	}
+	return screen(ctx, db, entries, subjects)
+}
+
+// ScreenAll screens all users and organizations. It's used after a list import.
+func ScreenAll(ctx context.Context, db driver.Database) (int, errstack.E) {
+	entries, errs := dal.GetSanctionEntries(ctx, db)
+	if errs != nil {
+		return 0, errs
+	}
+	us, errs := dal.GetAllUsers(ctx, db)
+	if errs != nil {
+		return 0, errs
+	}
+	orgs, errs := dal.GetAllOrganizations(ctx, db)
+	if errs != nil {
+		return 0, errs
+	}
+	subjects := make([]Subject, 0, len(us)+len(orgs))
+	for i := range us {
+		subjects = append(subjects, UserSubject(&us[i]))
+	}
+	for i := range orgs {
+		subjects = append(subjects, OrgSubject(&orgs[i]))
+	}
+	return screen(ctx, db, entries, subjects)
+}
+
+func screen(ctx context.Context, db driver.Database, entries []model.SanctionEntry, subjects []Subject) (int, errstack.E) {
+	n := 0
+	for _, s := range subjects {
+		for _, m := range FindMatches(s.Name, entries, DefaultThreshold) {
+			h := model.ScreeningHit{
+				SubjectKind: s.Kind,
+				SubjectID:   s.ID,
+				SubjectName: s.Name,
+				List:        m.Entry.List,
+				EntryRef:    m.Entry.Ref,
+				EntryName:   m.Entry.Name,
+				Score:       m.Score,
+				Status:      model.ScreeningHitStatusPending,
+				CreatedAt:   time.Now().UTC(),
+			}
+			inserted, errs := dal.InsertScreeningHit(ctx, db, &h)
+			if errs != nil {
+				return n, errs
+			}
+			if inserted {
+				n++
+				logger.Warn("Sanctions screening hit", "subject", s.ID, "list", h.List,
+					"entry", h.EntryRef, "score", h.Score)
+			}
+		}
+	}
+	return n, nil
+}
+
+// AssertNotFlagged checks that none of the users and organizations has a pending or
+// confirmed screening hit
+func AssertNotFlagged(ctx context.Context, db driver.Database, subjectIDs ...string) errstack.E {
+	flagged, errs := dal.HasBlockingScreeningHit(ctx, db, subjectIDs)
+	if errs != nil {
+		return errs
+	}
+	if flagged {
+		return model.ErrScreeningFlagged
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+// Package hdkey implements SLIP-0010 ed25519 hierarchical key derivation
+// used by Stellar wallets (SEP-0005).
+// https://github.com/satoshilabs/slips/blob/master/slip-0010.md
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0005.md
+package hdkey
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/robert-zaremba/errstack"
+	"github.com/stellar/go/keypair"
+)
+
+// FirstHardenedIndex is the index of the first hardened child key.
+// ed25519 supports only hardened derivation.
+const FirstHardenedIndex = uint32(0x80000000)
+
+const seedModifier = "ed25519 seed"
+
+// Key is an extended private key: a private key and its chain code
+type Key struct {
+	Key       [32]byte
+	ChainCode [32]byte
+}
+
+// NewMasterKey creates the master key from a seed (eg. BIP-39 mnemonic seed).
+func NewMasterKey(seed []byte) *Key {
+	return fromHMAC([]byte(seedModifier), seed)
+}
+
+func fromHMAC(key, data []byte) *Key {
+	h := hmac.New(sha512.New, key)
+	h.Write(data) // hash.Hash.Write never returns an error
+	sum := h.Sum(nil)
+	var k Key
+	copy(k.Key[:], sum[:32])
+	copy(k.ChainCode[:], sum[32:])
+	return &k
+}
+
+// Derive derives a hardened child key with index `i'`.
+// `i` must be lower than FirstHardenedIndex, the hardened offset is added automatically.
+func (k *Key) Derive(i uint32) (*Key, errstack.E) {
+	if i >= FirstHardenedIndex {
+		return nil, errstack.NewReqF("Derivation index %d is out of range", i)
+	}
+	data := make([]byte, 1+32+4)
+	copy(data[1:33], k.Key[:])
+	binary.BigEndian.PutUint32(data[33:], i+FirstHardenedIndex)
+	return fromHMAC(k.ChainCode[:], data), nil
+}
+
+// DerivePath derives a key for the given path, eg: "m/44'/148'/0'".
+// All path segments must be hardened.
+func (k *Key) DerivePath(path string) (*Key, errstack.E) {
+	segments := strings.Split(path, "/")
+	if segments[0] != "m" {
+		return nil, errstack.NewReqF("Derivation path '%s' must start with 'm'", path)
+	}
+	var errs errstack.E
+	for _, s := range segments[1:] {
+		if !strings.HasSuffix(s, "'") {
+			return nil, errstack.NewReqF("Derivation path '%s' can contain only hardened segments", path)
+		}
+		i, err := strconv.ParseUint(strings.TrimSuffix(s, "'"), 10, 32)
+		if err != nil {
+			return nil, errstack.WrapAsReqF(err, "Wrong segment '%s' in derivation path '%s'", s, path)
+		}
+		if k, errs = k.Derive(uint32(i)); errs != nil {
+			return nil, errs
+		}
+	}
+	return k, nil
+}
+
+// Keypair returns stellar keypair of the key
+func (k *Key) Keypair() (*keypair.Full, errstack.E) {
+	kp, err := keypair.FromRawSeed(k.Key)
+	return kp, errstack.WrapAsInf(err, "Can't create keypair from derived key")
+}
+
+// String serializes the key into a hex string: key || chain code
+func (k *Key) String() string {
+	return hex.EncodeToString(k.Key[:]) + hex.EncodeToString(k.ChainCode[:])
+}
+
+// Parse parses the hex encoded extended key produced by Key.String
+func Parse(s string) (*Key, errstack.E) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, errstack.WrapAsReq(err, "Extended key must be hex encoded")
+	}
+	if len(b) != 64 {
+		return nil, errstack.NewReq("Extended key must be 64 bytes long")
+	}
+	var k Key
+	copy(k.Key[:], b[:32])
+	copy(k.ChainCode[:], b[32:])
+	return &k, nil
+}
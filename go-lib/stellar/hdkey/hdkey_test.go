@@ -0,0 +1,87 @@
+package hdkey
+
+import (
+	"encoding/hex"
+	"testing"
+
+	. "github.com/robert-zaremba/checkers"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type HDKeySuite struct{}
+
+var _ = Suite(&HDKeySuite{})
+
+// SEP-0005 test vector 1 seed
+const sep5Seed = "e4a5a632e70943ae7f07659df1332160937fad82587216a4c64315a0fb39497ee4a01f76ddab4cba68147977f3a147b6ad584c41808e8238a07f6cc4b582f186"
+
+func mustDecode(c *C, s string) []byte {
+	b, err := hex.DecodeString(s)
+	c.Assert(err, IsNil)
+	return b
+}
+
+// SLIP-0010 ed25519 test vector 1
+func (s *HDKeySuite) TestSLIP10Vector(c *C) {
+	m := NewMasterKey(mustDecode(c, "000102030405060708090a0b0c0d0e0f"))
+	c.Check(hex.EncodeToString(m.ChainCode[:]), Equals, "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb")
+	c.Check(hex.EncodeToString(m.Key[:]), Equals, "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7")
+
+	k, err := m.DerivePath("m/0'")
+	c.Assert(err, IsNil)
+	c.Check(hex.EncodeToString(k.ChainCode[:]), Equals, "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69")
+	c.Check(hex.EncodeToString(k.Key[:]), Equals, "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3")
+
+	k, err = m.DerivePath("m/0'/1'")
+	c.Assert(err, IsNil)
+	c.Check(hex.EncodeToString(k.ChainCode[:]), Equals, "a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14")
+	c.Check(hex.EncodeToString(k.Key[:]), Equals, "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2")
+}
+
+func (s *HDKeySuite) TestSEP5Vector(c *C) {
+	m := NewMasterKey(mustDecode(c, sep5Seed))
+	expected := []string{
+		"GDRXE2BQUC3AZNPVFSCEZ76NJ3WWL25FYFK6RGZGIEKWE4SOOHSUJUJ6",
+		"GBAW5XGWORWVFE2XTJYDTLDHXTY2Q2MO73HYCGB3XMFMQ562Q2W2GJQX",
+	}
+	coin, err := m.DerivePath("m/44'/148'")
+	c.Assert(err, IsNil)
+	for i, addr := range expected {
+		k, err := coin.Derive(uint32(i))
+		c.Assert(err, IsNil)
+		kp, err := k.Keypair()
+		c.Assert(err, IsNil)
+		c.Check(kp.Address(), Equals, addr)
+	}
+	k, err := m.DerivePath("m/44'/148'/0'")
+	c.Assert(err, IsNil)
+	kp, err := k.Keypair()
+	c.Assert(err, IsNil)
+	c.Check(kp.Seed(), Equals, "SBGWSG6BTNCKCOB3DIFBGCVMUPQFYPA2G4O34RMTB343OYPXU5DJDVMN")
+}
+
+func (s *HDKeySuite) TestDerivePathErrors(c *C) {
+	m := NewMasterKey([]byte("seed"))
+	_, err := m.DerivePath("44'/148'")
+	c.Check(err, ErrorContains, "must start with 'm'")
+	_, err = m.DerivePath("m/44'/148")
+	c.Check(err, ErrorContains, "only hardened segments")
+	_, err = m.DerivePath("m/x'")
+	c.Check(err, ErrorContains, "Wrong segment")
+	_, err = m.Derive(FirstHardenedIndex)
+	c.Check(err, ErrorContains, "out of range")
+}
+
+func (s *HDKeySuite) TestStringParse(c *C) {
+	m := NewMasterKey(mustDecode(c, sep5Seed))
+	k, err := Parse(m.String())
+	c.Assert(err, IsNil)
+	c.Check(*k, DeepEquals, *m)
+
+	_, err = Parse("zz")
+	c.Check(err, ErrorContains, "hex encoded")
+	_, err = Parse("abcd")
+	c.Check(err, ErrorContains, "64 bytes long")
+}
@@ -2,6 +2,7 @@ package stellar
 
 import (
 	"fmt"
+	"strconv"
 	"time"
 
 	"bitbucket.org/cerealia/apps/go-lib/model"
@@ -17,10 +18,25 @@ import (
 // https://www.stellar.org/developers/guides/concepts/fees.html
 // (2 + 3 signers + 2 * 3 data-entries) * 0.5 (base fee) = 11 * 0.5 = 5.5
 // Can't remove original account's owner from acc in one tx. Increase by 0.5
-const initialNewAccountFunds = "6.5"
+const initialNewAccountFunds = 6.5
+
+// newAccountFunds returns the initial funds of a trade account with n party signers.
+// initialNewAccountFunds covers 2 parties, every other signer needs 0.5 XLM reserve.
+func newAccountFunds(n int) string {
+	return strconv.FormatFloat(initialNewAccountFunds+float64(n-2)*0.5, 'f', 1, 64)
+}
+
+// tradeAccountWeights computes the trade account weights for n trade parties
+func tradeAccountWeights(n int) (party, validator, medium, high uint32) {
+	party = 1                     // weight of any trade-party key
+	validator = party * uint32(n) // validator == all trade parties
+	medium = validator + party    // validator + any trade party
+	high = validator * 2          // All have to agree
+	return
+}
 
 // CreateTradeAccount creates a tx with CreateTradeAccount operation
-// Also it sets the buyer and seller
+// Also it sets all trade parties as the signers
 //
 // There are two kinds of signers:
 // 1. validation signer
@@ -36,34 +52,31 @@ const initialNewAccountFunds = "6.5"
 // This means that all calls to it are done via our service
 // and therefore are validated using it as a validation mechanism.
 //
-func CreateTradeAccount(d *WrappedDriver, pks model.TradeParticipants, sources *txsource.SourceAccs) errstack.E {
-	var tradeParty uint32 = 1                      // weight of any trade-party key
-	tradeParticipants := tradeParty * 2            // 2 parties: buyer & seller
-	validator := tradeParticipants                 // validator == trade participants
-	signatureRequirement := validator + tradeParty // validator + any trade party
-	t, err := b.Transaction(
+func CreateTradeAccount(d *WrappedDriver, parties []model.TradeParticipant, sources *txsource.SourceAccs) errstack.E {
+	party, validator, medium, high := tradeAccountWeights(len(parties))
+	muts := []b.TransactionMutator{
 		b.SourceAccount{AddressOrSeed: string(sources.PoolAcc.PubKey)},
 		b.AutoSequence{SequenceProvider: d.Client},
 		d.Network.Passphrase,
 		b.CreateAccount(
 			b.Destination{AddressOrSeed: sources.TradeKeyPair.Seed()},
-			b.NativeAmount{Amount: initialNewAccountFunds},
-		),
-		b.SetOptions(
-			b.SourceAccount{AddressOrSeed: sources.TradeKeyPair.Seed()},
-			// Can't add two signers using one SetOptions operation
-			b.AddSigner(pks.Seller.PubKey, tradeParty),
+			b.NativeAmount{Amount: newAccountFunds(len(parties))},
 		),
-		b.SetOptions(
+	}
+	// Can't add two signers using one SetOptions operation
+	for _, p := range parties[:len(parties)-1] {
+		muts = append(muts, b.SetOptions(
 			b.SourceAccount{AddressOrSeed: sources.TradeKeyPair.Seed()},
-			b.AddSigner(pks.Buyer.PubKey, tradeParty),
-			b.MasterWeight(validator),
-			b.SetThresholds(
-				signatureRequirement,
-				signatureRequirement,
-				validator+tradeParticipants), // All have to agree
-		),
-	)
+			b.AddSigner(p.PubKey, party),
+		))
+	}
+	muts = append(muts, b.SetOptions(
+		b.SourceAccount{AddressOrSeed: sources.TradeKeyPair.Seed()},
+		b.AddSigner(parties[len(parties)-1].PubKey, party),
+		b.MasterWeight(validator),
+		b.SetThresholds(medium, medium, high),
+	))
+	t, err := b.Transaction(muts...)
 	if err != nil {
 		return errstack.WrapAsDomain(err, "Can't construct a 'create account' transaction")
 	}
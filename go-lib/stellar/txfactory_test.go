@@ -169,3 +169,15 @@ func (s *TxFactorySuite) TestMkTradeCloseTx(c *C) {
 	c.Assert(err, NotNil, Comment("Expected error doesn't happen"))
 	c.Check(txStr, Equals, "", Comment("Generated tx should be empty"))
 }
+
+func (s *TxFactorySuite) TestTradeAccountWeights(c *C) {
+	party, validator, medium, high := tradeAccountWeights(2)
+	c.Check([]uint32{party, validator, medium, high}, DeepEquals, []uint32{1, 2, 3, 4})
+	party, validator, medium, high = tradeAccountWeights(5)
+	c.Check([]uint32{party, validator, medium, high}, DeepEquals, []uint32{1, 5, 6, 10})
+	// neither the validator alone nor all parties together reach the medium threshold
+	c.Check(validator < medium && 5*party < medium, IsTrue)
+
+	c.Check(newAccountFunds(2), Equals, "6.5")
+	c.Check(newAccountFunds(5), Equals, "8.0")
+}
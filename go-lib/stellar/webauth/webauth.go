@@ -0,0 +1,133 @@
+// Package webauth implements the Stellar web authentication (SEP-10): the server issues
+// a challenge transaction for an account, the account owner signs it and the server
+// verifies the signatures. The challenge is never submitted to the network.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0010.md
+package webauth
+
+import (
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/stellar/txvalidation"
+	"github.com/robert-zaremba/errstack"
+	"github.com/robert-zaremba/log15"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+var logger = log15.Root()
+
+// MaxNonceLen is the maximum length of a challenge nonce (the max size of a data entry value)
+const MaxNonceLen = 64
+
+// ErrInvalidChallenge is returned when the signed challenge is not valid
+var ErrInvalidChallenge = errstack.NewReq("Invalid or expired login challenge")
+
+// Default is the web authentication server used by the resolvers. It uses a random
+// signing key until it's configured.
+var Default *Server
+
+func init() {
+	key, err := keypair.Random()
+	if err != nil {
+		logger.Error("Can't generate web authentication key", err)
+		return
+	}
+	Default = NewServer(build.TestNetwork, key, "localhost")
+}
+
+// Server creates and verifies the challenges. Key signs the challenges, so the clients
+// can check that they come from our server.
+type Server struct {
+	Network    build.Network
+	Key        *keypair.Full
+	HomeDomain string
+}
+
+// NewServer creates a new web authentication server
+func NewServer(network build.Network, key *keypair.Full, homeDomain string) *Server {
+	return &Server{network, key, homeDomain}
+}
+
+// dataName is the name of the challenge manage data operation
+func (s *Server) dataName() string {
+	return s.HomeDomain + " auth"
+}
+
+// Challenge creates a challenge transaction for the account, valid from now for the ttl
+// duration. The nonce must be unique and is returned back by Verify.
+// Returns the base64 encoded transaction envelope signed by the server.
+func (s *Server) Challenge(account, nonce string, now time.Time, ttl time.Duration) (string, errstack.E) {
+	if _, err := strkey.Decode(strkey.VersionByteAccountID, account); err != nil {
+		return "", errstack.WrapAsReq(err, "Invalid Stellar public key")
+	}
+	if len(nonce) == 0 || len(nonce) > MaxNonceLen {
+		return "", errstack.NewDomainF("Challenge nonce must have 1-%d bytes", MaxNonceLen)
+	}
+	tx, err := build.Transaction(
+		build.SourceAccount{AddressOrSeed: s.Key.Address()},
+		build.Sequence{Sequence: 0},
+		build.Timebounds{MinTime: uint64(now.Unix()), MaxTime: uint64(now.Add(ttl).Unix())},
+		s.Network,
+		build.SetData(s.dataName(), []byte(nonce), build.SourceAccount{AddressOrSeed: account}),
+	)
+	if err != nil {
+		return "", errstack.WrapAsDomain(err, "Can't construct the challenge transaction")
+	}
+	txe, err := tx.Sign(s.Key.Seed())
+	if err != nil {
+		return "", errstack.WrapAsDomain(err, "Can't sign the challenge transaction")
+	}
+	txb64, err := txe.Base64()
+	return txb64, errstack.WrapAsDomain(err, "Can't encode the challenge transaction")
+}
+
+// Verify checks the challenge transaction signed by the client:
+// * it was created by this server and is signed by the server key,
+// * now is within the time bounds,
+// * it's signed by the challenged account.
+// It returns the challenged account and the nonce. The caller is responsible to check
+// that the nonce was not used before.
+func (s *Server) Verify(txb64 string, now time.Time) (string, string, errstack.E) {
+	eb, err := txvalidation.ReadEnvelopeBuilder(txb64)
+	if err != nil {
+		return "", "", errstack.WrapAsReq(err, "Can't parse the challenge transaction")
+	}
+	tx := &eb.E.Tx
+	if tx.SourceAccount.Address() != s.Key.Address() || tx.SeqNum != 0 || len(tx.Operations) != 1 {
+		return "", "", ErrInvalidChallenge
+	}
+	tb := tx.TimeBounds
+	if tb == nil || now.Unix() < int64(tb.MinTime) || now.Unix() > int64(tb.MaxTime) {
+		return "", "", ErrInvalidChallenge
+	}
+	op := tx.Operations[0]
+	if op.Body.Type != xdr.OperationTypeManageData || op.SourceAccount == nil ||
+		string(op.Body.ManageDataOp.DataName) != s.dataName() || op.Body.ManageDataOp.DataValue == nil {
+		return "", "", ErrInvalidChallenge
+	}
+	account := op.SourceAccount.Address()
+	clientKey, err := keypair.Parse(account)
+	if err != nil {
+		return "", "", ErrInvalidChallenge
+	}
+	hash, err := (&build.TransactionBuilder{TX: tx, NetworkPassphrase: s.Network.Passphrase}).Hash()
+	if err != nil {
+		return "", "", errstack.WrapAsReq(err, "Can't hash the challenge transaction")
+	}
+	if len(eb.E.Signatures) != 2 ||
+		!isSignedBy(s.Key, hash[:], eb.E.Signatures) || !isSignedBy(clientKey, hash[:], eb.E.Signatures) {
+		return "", "", ErrInvalidChallenge
+	}
+	return account, string(*op.Body.ManageDataOp.DataValue), nil
+}
+
+func isSignedBy(kp keypair.KP, hash []byte, signatures []xdr.DecoratedSignature) bool {
+	for _, s := range signatures {
+		if kp.Verify(hash, s.Signature) == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package webauth
+
+import (
+	"testing"
+	"time"
+
+	"bitbucket.org/cerealia/apps/go-lib/stellar/txvalidation"
+	. "github.com/robert-zaremba/checkers"
+	"github.com/stellar/go/build"
+	"github.com/stellar/go/keypair"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type WebAuthSuite struct {
+	s      *Server
+	client *keypair.Full
+	now    time.Time
+}
+
+var _ = Suite(&WebAuthSuite{})
+
+func (s *WebAuthSuite) SetUpTest(c *C) {
+	serverKey, err := keypair.Random()
+	c.Assert(err, IsNil)
+	s.client, err = keypair.Random()
+	c.Assert(err, IsNil)
+	s.s = NewServer(build.TestNetwork, serverKey, "cerealia.io")
+	s.now = time.Unix(1500000000, 0)
+}
+
+func (s *WebAuthSuite) sign(c *C, txb64 string, key *keypair.Full) string {
+	eb, err := txvalidation.ReadEnvelopeBuilder(txb64)
+	c.Assert(err, IsNil)
+	c.Assert(eb.MutateTX(s.s.Network), IsNil)
+	c.Assert(build.Sign{Seed: key.Seed()}.MutateTransactionEnvelope(eb), IsNil)
+	signed, err := eb.Base64()
+	c.Assert(err, IsNil)
+	return signed
+}
+
+func (s *WebAuthSuite) TestChallenge(c *C) {
+	tx, errs := s.s.Challenge(s.client.Address(), "nonce1", s.now, 5*time.Minute)
+	c.Assert(errs, IsNil)
+	signed := s.sign(c, tx, s.client)
+
+	account, nonce, errs := s.s.Verify(signed, s.now.Add(time.Minute))
+	c.Assert(errs, IsNil)
+	c.Check(account, Equals, s.client.Address())
+	c.Check(nonce, Equals, "nonce1")
+
+	_, _, errs = s.s.Verify(signed, s.now.Add(6*time.Minute))
+	c.Check(errs, Equals, ErrInvalidChallenge, Comment("expired"))
+	_, _, errs = s.s.Verify(tx, s.now)
+	c.Check(errs, Equals, ErrInvalidChallenge, Comment("not signed by the client"))
+
+	other, err := keypair.Random()
+	c.Assert(err, IsNil)
+	_, _, errs = s.s.Verify(s.sign(c, tx, other), s.now)
+	c.Check(errs, Equals, ErrInvalidChallenge, Comment("signed by other key"))
+
+	otherServer := NewServer(build.TestNetwork, other, "cerealia.io")
+	_, _, errs = otherServer.Verify(signed, s.now)
+	c.Check(errs, Equals, ErrInvalidChallenge, Comment("issued by other server"))
+}
+
+func (s *WebAuthSuite) TestChallengeErrors(c *C) {
+	_, errs := s.s.Challenge("GABC", "nonce", s.now, time.Minute)
+	c.Check(errs, ErrorContains, "Invalid Stellar public key")
+	_, errs = s.s.Challenge(s.client.Address(), "", s.now, time.Minute)
+	c.Check(errs, ErrorContains, "nonce")
+	_, _, errs = s.s.Verify("not a tx", s.now)
+	c.Check(errs, ErrorContains, "Can't parse")
+}